@@ -48,6 +48,13 @@ func main() {
 	multi := zerolog.MultiLevelWriter(consoleWriter, logFile)
 	logger := zerologger.New(multi)
 
+	logLevel := zerolog.InfoLevel
+	if logLevelStr, err := utils.GetEnv("LOG_LEVEL"); err == nil {
+		logLevel, err = zerolog.ParseLevel(logLevelStr)
+		exitOnError(err)
+	}
+	zerolog.SetGlobalLevel(logLevel)
+
 	err = server.Run(ctx, logger, utils.GetEnv, firebaseAuthClient, googleGeocodeRepo)
 	exitOnError(err)
 }
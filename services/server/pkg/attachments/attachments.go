@@ -0,0 +1,72 @@
+package attachments
+
+import (
+	"net/http"
+	"path"
+	"spaces-p/pkg/errors"
+	"spaces-p/pkg/models"
+	"spaces-p/pkg/uuid"
+	"strings"
+	"unicode"
+)
+
+const maxFileNameLength = 255
+
+var ErrUnsupportedContentType = errors.New("unsupported content type")
+
+// images are re-encoded to strip their metadata, so only formats the standard library can encode are accepted
+var imageContentTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+}
+
+var fileContentTypes = map[string]bool{
+	"application/pdf":           true,
+	"application/zip":           true,
+	"text/plain; charset=utf-8": true,
+}
+
+// DetectContentType sniffs the content type of an upload instead of trusting the type the client declared. It returns
+// MessageTypeImage or MessageTypeFile depending on the content type.
+func DetectContentType(content []byte) (models.MessageType, string, error) {
+	const op errors.Op = "attachments.DetectContentType"
+
+	contentType := http.DetectContentType(content)
+	switch {
+	case imageContentTypes[contentType]:
+		return models.MessageTypeImage, contentType, nil
+	case fileContentTypes[contentType]:
+		return models.MessageTypeFile, contentType, nil
+	default:
+		return 0, "", errors.E(op, ErrUnsupportedContentType)
+	}
+}
+
+// BlobKey returns the key of the attachment's variant in the blob store
+func BlobKey(attachmentId uuid.Uuid, variant models.AttachmentVariant) string {
+	return path.Join("attachments", attachmentId.String(), string(variant))
+}
+
+// SanitizeFileName strips the directories and control characters of an uploaded file's name
+func SanitizeFileName(fileName string) string {
+	fileName = path.Base(strings.ReplaceAll(fileName, "\\", "/"))
+	fileName = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, fileName)
+
+	var runes = []rune(strings.TrimSpace(fileName))
+	if len(runes) > maxFileNameLength {
+		// keeps the extension
+		runes = runes[len(runes)-maxFileNameLength:]
+	}
+
+	if len(runes) == 0 || string(runes) == "." || string(runes) == "/" {
+		return "attachment"
+	}
+
+	return string(runes)
+}
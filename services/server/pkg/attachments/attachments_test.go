@@ -0,0 +1,75 @@
+package attachments_test
+
+import (
+	"net/url"
+	"spaces-p/pkg/attachments"
+	"spaces-p/pkg/models"
+	"spaces-p/pkg/uuid"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectContentType(t *testing.T) {
+	tests := []struct {
+		name            string
+		content         []byte
+		wantType        models.MessageType
+		wantContentType string
+		wantErr         error
+	}{
+		{name: "png", content: []byte("\x89PNG\x0D\x0A\x1A\x0A"), wantType: models.MessageTypeImage, wantContentType: "image/png"},
+		{name: "pdf", content: []byte("%PDF-1.7"), wantType: models.MessageTypeFile, wantContentType: "application/pdf"},
+		{name: "text", content: []byte("hello"), wantType: models.MessageTypeFile, wantContentType: "text/plain; charset=utf-8"},
+		{name: "html", content: []byte("<html><script>alert(1)</script>"), wantErr: attachments.ErrUnsupportedContentType},
+		{name: "binary", content: []byte{0x00, 0x01, 0x02}, wantErr: attachments.ErrUnsupportedContentType},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			messageType, contentType, err := attachments.DetectContentType(test.content)
+
+			if test.wantErr != nil {
+				assert.ErrorIs(t, err, test.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.wantType, messageType)
+			assert.Equal(t, test.wantContentType, contentType)
+		})
+	}
+}
+
+func TestSanitizeFileName(t *testing.T) {
+	assert.Equal(t, "passwd", attachments.SanitizeFileName("../../etc/passwd"))
+	assert.Equal(t, "photo.jpg", attachments.SanitizeFileName(`C:\Users\me\photo.jpg`))
+	assert.Equal(t, "ab.txt", attachments.SanitizeFileName("a\x00b.txt"))
+	assert.Equal(t, "attachment", attachments.SanitizeFileName(""))
+	assert.Equal(t, strings.Repeat("a", 251)+".pdf", attachments.SanitizeFileName(strings.Repeat("a", 300)+".pdf"))
+}
+
+func TestUrlSigner(t *testing.T) {
+	var attachmentId = uuid.New()
+	signer := attachments.NewUrlSigner([]byte("key"), "/v1", time.Minute)
+
+	signedUrl, expiresAt := signer.SignedUrl(attachmentId, models.ThumbnailAttachmentVariant)
+	parsedUrl, err := url.Parse(signedUrl)
+	require.NoError(t, err)
+	expires, signature := parsedUrl.Query().Get("expires"), parsedUrl.Query().Get("signature")
+
+	assert.Equal(t, "/v1/attachments/"+attachmentId.String()+"/thumbnail", parsedUrl.Path)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), expiresAt, 2*time.Second)
+	assert.NoError(t, signer.Verify(attachmentId, models.ThumbnailAttachmentVariant, expires, signature))
+	assert.ErrorIs(t, signer.Verify(attachmentId, models.OriginalAttachmentVariant, expires, signature), attachments.ErrInvalidSignature)
+	assert.ErrorIs(t, signer.Verify(uuid.New(), models.ThumbnailAttachmentVariant, expires, signature), attachments.ErrInvalidSignature)
+	assert.ErrorIs(t, attachments.NewUrlSigner([]byte("other key"), "/v1", time.Minute).Verify(attachmentId, models.ThumbnailAttachmentVariant, expires, signature), attachments.ErrInvalidSignature)
+
+	expiredSigner := attachments.NewUrlSigner([]byte("key"), "/v1", -time.Minute)
+	expiredUrl, _ := expiredSigner.SignedUrl(attachmentId, models.OriginalAttachmentVariant)
+	parsedUrl, err = url.Parse(expiredUrl)
+	require.NoError(t, err)
+	assert.ErrorIs(t, signer.Verify(attachmentId, models.OriginalAttachmentVariant, parsedUrl.Query().Get("expires"), parsedUrl.Query().Get("signature")), attachments.ErrUrlExpired)
+}
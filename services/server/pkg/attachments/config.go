@@ -0,0 +1,168 @@
+package attachments
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"spaces-p/pkg/common"
+	"spaces-p/pkg/errors"
+	localblobstore "spaces-p/pkg/repositories/local_blob_store"
+	s3blobstore "spaces-p/pkg/repositories/s3_blob_store"
+	"spaces-p/pkg/utils"
+	"time"
+)
+
+type BlobStoreKind string
+
+const (
+	LocalBlobStoreKind BlobStoreKind = "local"
+	// any S3 compatible object storage, e.g. AWS S3 or a local MinIO
+	S3BlobStoreKind BlobStoreKind = "s3"
+)
+
+type Config struct {
+	BlobStore BlobStoreKind
+	LocalDir  string // root directory of the local blob store
+
+	S3Endpoint        string // e.g. https://s3.eu-central-1.amazonaws.com, buckets are addressed path-style
+	S3Region          string
+	S3Bucket          string
+	S3AccessKeyId     string
+	S3SecretAccessKey string
+
+	MaxSize       int64 // bytes
+	ThumbnailSize int   // the longer side of image thumbnails in pixels
+
+	PublicUrl     string // prefix of the download urls, download urls are relative if it is empty
+	UrlSigningKey string // instances behind the same load balancer must share the key, a random key is used if it is empty
+	UrlTtl        time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		BlobStore: LocalBlobStoreKind,
+		LocalDir:  "data/attachments",
+		S3Region:  "us-east-1",
+
+		MaxSize:       10 << 20,
+		ThumbnailSize: 320,
+
+		UrlTtl: 15 * time.Minute,
+	}
+}
+
+// NewConfigFromEnv builds a Config from the ATTACHMENTS_* and S3_* environment variables.
+// Variables that are not set fall back to the values of DefaultConfig.
+func NewConfigFromEnv(getenv func(string) (string, error)) (Config, error) {
+	const op errors.Op = "attachments.NewConfigFromEnv"
+	var cfg = DefaultConfig()
+	var err error
+
+	if blobStore := utils.LookupEnv(getenv, "ATTACHMENTS_BLOB_STORE"); blobStore != "" {
+		cfg.BlobStore = BlobStoreKind(blobStore)
+	}
+	if localDir := utils.LookupEnv(getenv, "ATTACHMENTS_LOCAL_DIR"); localDir != "" {
+		cfg.LocalDir = localDir
+	}
+
+	cfg.S3Endpoint = utils.LookupEnv(getenv, "S3_ENDPOINT")
+	if region := utils.LookupEnv(getenv, "S3_REGION"); region != "" {
+		cfg.S3Region = region
+	}
+	cfg.S3Bucket = utils.LookupEnv(getenv, "S3_BUCKET")
+	cfg.S3AccessKeyId = utils.LookupEnv(getenv, "S3_ACCESS_KEY_ID")
+	cfg.S3SecretAccessKey = utils.LookupEnv(getenv, "S3_SECRET_ACCESS_KEY")
+
+	maxSize, err := utils.ParseIntEnv(getenv, "ATTACHMENTS_MAX_SIZE", int(cfg.MaxSize))
+	if err != nil {
+		return Config{}, errors.E(op, err)
+	}
+	cfg.MaxSize = int64(maxSize)
+	if cfg.ThumbnailSize, err = utils.ParseIntEnv(getenv, "ATTACHMENTS_THUMBNAIL_SIZE", cfg.ThumbnailSize); err != nil {
+		return Config{}, errors.E(op, err)
+	}
+
+	cfg.PublicUrl = utils.LookupEnv(getenv, "ATTACHMENTS_PUBLIC_URL")
+	cfg.UrlSigningKey = utils.LookupEnv(getenv, "ATTACHMENTS_URL_SIGNING_KEY")
+	if cfg.UrlTtl, err = utils.ParseDurationEnv(getenv, "ATTACHMENTS_URL_TTL", cfg.UrlTtl); err != nil {
+		return Config{}, errors.E(op, err)
+	}
+
+	if err := cfg.validate(); err != nil {
+		return Config{}, errors.E(op, err)
+	}
+
+	return cfg, nil
+}
+
+func (cfg Config) validate() error {
+	switch cfg.BlobStore {
+	case LocalBlobStoreKind:
+		if cfg.LocalDir == "" {
+			return fmt.Errorf("the local blob store requires a directory")
+		}
+	case S3BlobStoreKind:
+		if cfg.S3Endpoint == "" || cfg.S3Bucket == "" || cfg.S3AccessKeyId == "" || cfg.S3SecretAccessKey == "" {
+			return fmt.Errorf("the s3 blob store requires an endpoint, a bucket and credentials")
+		}
+	default:
+		return fmt.Errorf("unknown blob store: %s", cfg.BlobStore)
+	}
+
+	if cfg.MaxSize <= 0 {
+		return fmt.Errorf("attachments max size must be positive: %d", cfg.MaxSize)
+	}
+
+	if cfg.ThumbnailSize <= 0 {
+		return fmt.Errorf("attachments thumbnail size must be positive: %d", cfg.ThumbnailSize)
+	}
+
+	if cfg.UrlTtl <= 0 {
+		return fmt.Errorf("attachments url ttl must be positive: %s", cfg.UrlTtl)
+	}
+
+	return nil
+}
+
+// NewBlobStoreFromConfig returns the configured blob store. The bucket of the s3 blob store is created if it doesn't
+// exist yet.
+func NewBlobStoreFromConfig(ctx context.Context, cfg Config) (common.BlobStore, error) {
+	const op errors.Op = "attachments.NewBlobStoreFromConfig"
+
+	switch cfg.BlobStore {
+	case S3BlobStoreKind:
+		blobStore, err := s3blobstore.NewS3BlobStore(cfg.S3Endpoint, cfg.S3Region, cfg.S3Bucket, cfg.S3AccessKeyId, cfg.S3SecretAccessKey)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+
+		if err := blobStore.EnsureBucket(ctx); err != nil {
+			return nil, errors.E(op, err)
+		}
+
+		return blobStore, nil
+	default:
+		blobStore, err := localblobstore.NewLocalBlobStore(cfg.LocalDir)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+
+		return blobStore, nil
+	}
+}
+
+// NewUrlSignerFromConfig returns a signer for download urls below baseUrl, which is prefixed with the configured
+// public url
+func NewUrlSignerFromConfig(cfg Config, baseUrl string) (*UrlSigner, error) {
+	const op errors.Op = "attachments.NewUrlSignerFromConfig"
+
+	var key = []byte(cfg.UrlSigningKey)
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, errors.E(op, err)
+		}
+	}
+
+	return NewUrlSigner(key, cfg.PublicUrl+baseUrl, cfg.UrlTtl), nil
+}
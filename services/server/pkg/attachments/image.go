@@ -0,0 +1,311 @@
+package attachments
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"spaces-p/pkg/errors"
+)
+
+// images are decoded into memory, larger images are rejected
+const maxImagePixels = 40_000_000
+
+var (
+	ErrInvalidImage  = errors.New("invalid image")
+	ErrImageTooLarge = errors.New("image dimensions are too large")
+)
+
+type ProcessedImage struct {
+	Content              []byte // the re-encoded image without its metadata
+	Thumbnail            []byte
+	ThumbnailContentType string
+	Width                int
+	Height               int
+}
+
+// ProcessImage re-encodes the image, which strips all of its metadata including EXIF and GPS data, and generates a
+// thumbnail whose longer side is at most thumbnailSize pixels. The EXIF orientation of JPEGs is applied to the pixels
+// before it is stripped.
+func ProcessImage(content []byte, contentType string, thumbnailSize int) (*ProcessedImage, error) {
+	const op errors.Op = "attachments.ProcessImage"
+
+	imageConfig, _, err := image.DecodeConfig(bytes.NewReader(content))
+	switch {
+	case err != nil:
+		return nil, errors.E(op, fmt.Errorf("%w: %w", ErrInvalidImage, err))
+	case imageConfig.Width*imageConfig.Height > maxImagePixels:
+		return nil, errors.E(op, ErrImageTooLarge)
+	}
+
+	var processedImage *ProcessedImage
+	switch contentType {
+	case "image/jpeg":
+		processedImage, err = processJpeg(content, thumbnailSize)
+	case "image/png":
+		processedImage, err = processPng(content, thumbnailSize)
+	case "image/gif":
+		processedImage, err = processGif(content, thumbnailSize)
+	default:
+		err = fmt.Errorf("%w: %s", ErrUnsupportedContentType, contentType)
+	}
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return processedImage, nil
+}
+
+func processJpeg(content []byte, thumbnailSize int) (*ProcessedImage, error) {
+	decodedImage, err := jpeg.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidImage, err)
+	}
+
+	rgbaImage := orient(toRGBA(decodedImage), jpegOrientation(content))
+
+	var buf, thumbnailBuf bytes.Buffer
+	if err := jpeg.Encode(&buf, rgbaImage, &jpeg.Options{Quality: 90}); err != nil {
+		return nil, err
+	}
+	if err := jpeg.Encode(&thumbnailBuf, thumbnail(rgbaImage, thumbnailSize), &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+
+	return &ProcessedImage{
+		Content:              buf.Bytes(),
+		Thumbnail:            thumbnailBuf.Bytes(),
+		ThumbnailContentType: "image/jpeg",
+		Width:                rgbaImage.Bounds().Dx(),
+		Height:               rgbaImage.Bounds().Dy(),
+	}, nil
+}
+
+func processPng(content []byte, thumbnailSize int) (*ProcessedImage, error) {
+	decodedImage, err := png.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidImage, err)
+	}
+
+	var buf, thumbnailBuf bytes.Buffer
+	if err := png.Encode(&buf, decodedImage); err != nil {
+		return nil, err
+	}
+	if err := png.Encode(&thumbnailBuf, thumbnail(toRGBA(decodedImage), thumbnailSize)); err != nil {
+		return nil, err
+	}
+
+	return &ProcessedImage{
+		Content:              buf.Bytes(),
+		Thumbnail:            thumbnailBuf.Bytes(),
+		ThumbnailContentType: "image/png",
+		Width:                decodedImage.Bounds().Dx(),
+		Height:               decodedImage.Bounds().Dy(),
+	}, nil
+}
+
+// the frames of animated GIFs are kept, the thumbnail only shows the first frame
+func processGif(content []byte, thumbnailSize int) (*ProcessedImage, error) {
+	decodedGif, err := gif.DecodeAll(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidImage, err)
+	}
+
+	var buf, thumbnailBuf bytes.Buffer
+	if err := gif.EncodeAll(&buf, decodedGif); err != nil {
+		return nil, err
+	}
+
+	firstFrame := image.NewRGBA(image.Rect(0, 0, decodedGif.Config.Width, decodedGif.Config.Height))
+	draw.Draw(firstFrame, decodedGif.Image[0].Bounds(), decodedGif.Image[0], decodedGif.Image[0].Bounds().Min, draw.Over)
+	if err := png.Encode(&thumbnailBuf, thumbnail(firstFrame, thumbnailSize)); err != nil {
+		return nil, err
+	}
+
+	return &ProcessedImage{
+		Content:              buf.Bytes(),
+		Thumbnail:            thumbnailBuf.Bytes(),
+		ThumbnailContentType: "image/png",
+		Width:                decodedGif.Config.Width,
+		Height:               decodedGif.Config.Height,
+	}, nil
+}
+
+func toRGBA(src image.Image) *image.RGBA {
+	bounds := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, bounds.Min, draw.Src)
+
+	return dst
+}
+
+// thumbnail downscales the image with a box filter, so that its longer side is at most size pixels
+func thumbnail(src *image.RGBA, size int) *image.RGBA {
+	width, height := src.Bounds().Dx(), src.Bounds().Dy()
+	thumbnailWidth, thumbnailHeight := width, height
+	switch {
+	case width <= size && height <= size:
+		return src
+	case width >= height:
+		thumbnailWidth, thumbnailHeight = size, max(1, height*size/width)
+	default:
+		thumbnailWidth, thumbnailHeight = max(1, width*size/height), size
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, thumbnailWidth, thumbnailHeight))
+	for ty := 0; ty < thumbnailHeight; ty++ {
+		y0 := ty * height / thumbnailHeight
+		y1 := max(y0+1, (ty+1)*height/thumbnailHeight)
+
+		for tx := 0; tx < thumbnailWidth; tx++ {
+			x0 := tx * width / thumbnailWidth
+			x1 := max(x0+1, (tx+1)*width/thumbnailWidth)
+
+			var sum [4]int
+			for y := y0; y < y1; y++ {
+				offset := src.PixOffset(x0, y)
+				for x := x0; x < x1; x++ {
+					for c := 0; c < 4; c++ {
+						sum[c] += int(src.Pix[offset+c])
+					}
+					offset += 4
+				}
+			}
+
+			var count = (y1 - y0) * (x1 - x0)
+			dstOffset := dst.PixOffset(tx, ty)
+			for c := 0; c < 4; c++ {
+				dst.Pix[dstOffset+c] = uint8(sum[c] / count)
+			}
+		}
+	}
+
+	return dst
+}
+
+// orient transforms the image, so that it is displayed upright without its EXIF orientation (1 - 8)
+func orient(src *image.RGBA, orientation int) *image.RGBA {
+	if orientation < 2 || orientation > 8 {
+		return src
+	}
+
+	width, height := src.Bounds().Dx(), src.Bounds().Dy()
+	var dst *image.RGBA
+	if orientation >= 5 {
+		// the orientations from 5 on swap width and height
+		dst = image.NewRGBA(image.Rect(0, 0, height, width))
+	} else {
+		dst = image.NewRGBA(image.Rect(0, 0, width, height))
+	}
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirrored horizontally
+				dx, dy = width-1-x, y
+			case 3: // rotated by 180°
+				dx, dy = width-1-x, height-1-y
+			case 4: // mirrored vertically
+				dx, dy = x, height-1-y
+			case 5: // transposed
+				dx, dy = y, x
+			case 6: // rotated by 90° clockwise
+				dx, dy = height-1-y, x
+			case 7: // transversed
+				dx, dy = height-1-y, width-1-x
+			case 8: // rotated by 90° counterclockwise
+				dx, dy = y, width-1-x
+			}
+
+			copy(dst.Pix[dst.PixOffset(dx, dy):dst.PixOffset(dx, dy)+4], src.Pix[src.PixOffset(x, y):src.PixOffset(x, y)+4])
+		}
+	}
+
+	return dst
+}
+
+// jpegOrientation returns the orientation stored in the EXIF metadata of a JPEG, or 1 if there is none
+func jpegOrientation(content []byte) int {
+	if len(content) < 4 || content[0] != 0xFF || content[1] != 0xD8 {
+		return 1
+	}
+
+	for i := 2; i+4 <= len(content); {
+		if content[i] != 0xFF {
+			return 1
+		}
+
+		var marker = content[i+1]
+		switch marker {
+		case 0xFF:
+			// fill byte
+			i++
+			continue
+		case 0xDA, 0xD9:
+			// the metadata segments precede the start of the scan
+			return 1
+		}
+
+		segmentLength := int(binary.BigEndian.Uint16(content[i+2:]))
+		if segmentLength < 2 || i+2+segmentLength > len(content) {
+			return 1
+		}
+
+		segment := content[i+4 : i+2+segmentLength]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return exifOrientation(segment[6:])
+		}
+
+		i += 2 + segmentLength
+	}
+
+	return 1
+}
+
+// exifOrientation reads the orientation tag of the first image file directory of the EXIF TIFF structure
+func exifOrientation(tiff []byte) int {
+	const orientationTag = 0x0112
+
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	var byteOrder binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		byteOrder = binary.LittleEndian
+	case "MM":
+		byteOrder = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifdOffset := int(byteOrder.Uint32(tiff[4:]))
+	if ifdOffset < 8 || ifdOffset+2 > len(tiff) {
+		return 1
+	}
+
+	entriesCount := int(byteOrder.Uint16(tiff[ifdOffset:]))
+	for i := 0; i < entriesCount; i++ {
+		entryOffset := ifdOffset + 2 + i*12
+		if entryOffset+12 > len(tiff) {
+			return 1
+		}
+
+		if byteOrder.Uint16(tiff[entryOffset:]) == orientationTag {
+			orientation := int(byteOrder.Uint16(tiff[entryOffset+8:]))
+			if orientation < 1 || orientation > 8 {
+				return 1
+			}
+
+			return orientation
+		}
+	}
+
+	return 1
+}
@@ -0,0 +1,103 @@
+package attachments_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"spaces-p/pkg/attachments"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// exifSegment returns an APP1 segment with an orientation and a description tag that stands in for GPS data
+func exifSegment(orientation uint16, description string) []byte {
+	var tiff bytes.Buffer
+	tiff.WriteString("MM")
+	binary.Write(&tiff, binary.BigEndian, uint16(42))
+	binary.Write(&tiff, binary.BigEndian, uint32(8))
+
+	binary.Write(&tiff, binary.BigEndian, uint16(2))
+	binary.Write(&tiff, binary.BigEndian, []uint16{0x0112, 3})
+	binary.Write(&tiff, binary.BigEndian, uint32(1))
+	binary.Write(&tiff, binary.BigEndian, []uint16{orientation, 0})
+	binary.Write(&tiff, binary.BigEndian, []uint16{0x010E, 2})
+	binary.Write(&tiff, binary.BigEndian, uint32(len(description)))
+	binary.Write(&tiff, binary.BigEndian, uint32(8+2+2*12+4))
+	binary.Write(&tiff, binary.BigEndian, uint32(0))
+	tiff.WriteString(description)
+
+	var segment bytes.Buffer
+	segment.Write([]byte{0xFF, 0xE1})
+	binary.Write(&segment, binary.BigEndian, uint16(2+6+tiff.Len()))
+	segment.WriteString("Exif\x00\x00")
+	segment.Write(tiff.Bytes())
+
+	return segment.Bytes()
+}
+
+func TestProcessImage_jpeg(t *testing.T) {
+	// left half red, right half blue
+	src := image.NewRGBA(image.Rect(0, 0, 64, 32))
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 64; x++ {
+			if x < 32 {
+				src.Set(x, y, color.RGBA{255, 0, 0, 255})
+			} else {
+				src.Set(x, y, color.RGBA{0, 0, 255, 255})
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, src, nil))
+	encoded := buf.Bytes()
+
+	// rotated by 90° clockwise
+	content := append(append(append([]byte{}, encoded[:2]...), exifSegment(6, "GPSLatitude 52.52")...), encoded[2:]...)
+
+	processedImage, err := attachments.ProcessImage(content, "image/jpeg", 16)
+	require.NoError(t, err)
+
+	assert.Equal(t, 32, processedImage.Width)
+	assert.Equal(t, 64, processedImage.Height)
+	assert.NotContains(t, string(processedImage.Content), "Exif")
+	assert.NotContains(t, string(processedImage.Content), "GPSLatitude")
+
+	decodedImage, err := jpeg.Decode(bytes.NewReader(processedImage.Content))
+	require.NoError(t, err)
+	top, _, _, _ := decodedImage.At(16, 8).RGBA()
+	bottom, _, _, _ := decodedImage.At(16, 56).RGBA()
+	assert.Greater(t, top, uint32(0xC000), "the left half should be on top")
+	assert.Less(t, bottom, uint32(0x4000), "the right half should be at the bottom")
+
+	thumbnailConfig, err := jpeg.DecodeConfig(bytes.NewReader(processedImage.Thumbnail))
+	require.NoError(t, err)
+	assert.Equal(t, "image/jpeg", processedImage.ThumbnailContentType)
+	assert.Equal(t, 8, thumbnailConfig.Width)
+	assert.Equal(t, 16, thumbnailConfig.Height)
+}
+
+func TestProcessImage_png(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 1000, 500))))
+
+	processedImage, err := attachments.ProcessImage(buf.Bytes(), "image/png", 320)
+	require.NoError(t, err)
+
+	thumbnailConfig, err := png.DecodeConfig(bytes.NewReader(processedImage.Thumbnail))
+	require.NoError(t, err)
+	assert.Equal(t, 1000, processedImage.Width)
+	assert.Equal(t, 320, thumbnailConfig.Width)
+	assert.Equal(t, 160, thumbnailConfig.Height)
+}
+
+func TestProcessImage_invalid(t *testing.T) {
+	_, err := attachments.ProcessImage([]byte("\xFF\xD8\xFFnot a jpeg"), "image/jpeg", 320)
+
+	assert.ErrorIs(t, err, attachments.ErrInvalidImage)
+}
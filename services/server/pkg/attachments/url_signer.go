@@ -0,0 +1,67 @@
+package attachments
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"spaces-p/pkg/errors"
+	"spaces-p/pkg/models"
+	"spaces-p/pkg/uuid"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid download url signature")
+	ErrUrlExpired       = errors.New("download url has expired")
+)
+
+// UrlSigner signs download urls of attachments, so that they can be used without authentication (e.g. as the src of
+// an img element) until they expire
+type UrlSigner struct {
+	key     []byte
+	baseUrl string
+	ttl     time.Duration
+}
+
+func NewUrlSigner(key []byte, baseUrl string, ttl time.Duration) *UrlSigner {
+	return &UrlSigner{key, baseUrl, ttl}
+}
+
+// SignedUrl returns the download url of the attachment's variant and the time it expires at
+func (s *UrlSigner) SignedUrl(attachmentId uuid.Uuid, variant models.AttachmentVariant) (string, time.Time) {
+	var expiresAt = time.Now().Add(s.ttl).Truncate(time.Second)
+	var expires = expiresAt.Unix()
+
+	url := fmt.Sprintf("%s/attachments/%s/%s?expires=%d&signature=%s", s.baseUrl, attachmentId.String(), variant, expires, s.sign(attachmentId, variant, expires))
+
+	return url, expiresAt
+}
+
+// Verify checks the expires and signature query parameters of a download url
+func (s *UrlSigner) Verify(attachmentId uuid.Uuid, variant models.AttachmentVariant, expiresStr, signature string) error {
+	const op errors.Op = "attachments.UrlSigner.Verify"
+
+	expires, err := strconv.ParseInt(expiresStr, 10, 64)
+	if err != nil {
+		return errors.E(op, ErrInvalidSignature)
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(s.sign(attachmentId, variant, expires))) {
+		return errors.E(op, ErrInvalidSignature)
+	}
+
+	if time.Now().Unix() > expires {
+		return errors.E(op, ErrUrlExpired)
+	}
+
+	return nil
+}
+
+func (s *UrlSigner) sign(attachmentId uuid.Uuid, variant models.AttachmentVariant, expires int64) string {
+	mac := hmac.New(sha256.New, s.key)
+	fmt.Fprintf(mac, "%s/%s/%d", attachmentId.String(), variant, expires)
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,14 @@
+package common
+
+import (
+	"context"
+	"io"
+)
+
+// BlobStore stores the content of attachments. Keys are slash separated paths, GetBlob returns ErrNotFound for keys
+// that don't exist.
+type BlobStore interface {
+	PutBlob(ctx context.Context, key string, content io.Reader, size int64, contentType string) error
+	GetBlob(ctx context.Context, key string) (io.ReadCloser, error)
+	DeleteBlob(ctx context.Context, key string) error
+}
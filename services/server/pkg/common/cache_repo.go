@@ -14,33 +14,68 @@ type CacheRepository interface {
 	ThreadCacheRepository
 	MessageCacheRepository
 	AddressCacheRepository
+	RateLimitCacheRepository
+	HeldMessageCacheRepository
+	ReportCacheRepository
+	AttachmentCacheRepository
+	PollCacheRepository
+	ReadMarkerCacheRepository
+	FollowCacheRepository
+	PinCacheRepository
+	RetentionCacheRepository
+	TrendingCacheRepository
 }
 
 type UserCacheRepository interface {
 	GetUserById(ctx context.Context, id models.UserUid) (*models.User, error)
 	SetUser(ctx context.Context, newUser models.NewUser) error
+	GetUserIdsByUsername(ctx context.Context, username string) ([]models.UserUid, error)
+	SetUserBanned(ctx context.Context, userId models.UserUid) error
+	SetUserBlockedUser(ctx context.Context, userId, blockedUserId models.UserUid) error
+	DeleteUserBlockedUser(ctx context.Context, userId, blockedUserId models.UserUid) error
+	HasUserBlockedUser(ctx context.Context, userId, otherUserId models.UserUid) (bool, error)
+	SetUserMutedUser(ctx context.Context, userId, mutedUserId models.UserUid) error
+	DeleteUserMutedUser(ctx context.Context, userId, mutedUserId models.UserUid) error
+	GetUserHiddenUsers(ctx context.Context, userId models.UserUid) ([]models.UserUid, error)
+	GetUsersHidingUser(ctx context.Context, userIds []models.UserUid, otherUserId models.UserUid) (map[models.UserUid]bool, error)
 }
 
 type SpaceCacheRepository interface {
 	GetSpace(ctx context.Context, spaceid uuid.Uuid) (*models.Space, error)
 	GetSpacesByUserId(ctx context.Context, userId models.UserUid, count, offset int64) ([]models.Space, error)
+	GetUserSpaceIds(ctx context.Context, userId models.UserUid) ([]uuid.Uuid, error)
+	GetSpaceSubscriberIds(ctx context.Context, spaceId uuid.Uuid) ([]models.UserUid, error)
 	GetSpacesByLocation(ctx context.Context, location models.Location, radius models.Radius, count int) ([]models.SpaceWithDistance, error)
+	GetSpacesByLocationAndTag(ctx context.Context, location models.Location, radius models.Radius, tag string, count int) ([]models.SpaceWithDistance, error)
+	GetSpacesInBox(ctx context.Context, box models.BoundingBox, count int) ([]models.Space, error)
+	GetTileSpacesCounts(ctx context.Context, geoHashes []string) ([]int64, error)
+	UpdateSpaceTileEntry(ctx context.Context, spaceId uuid.Uuid) error
+	GetTagSuggestions(ctx context.Context, prefix string, count int) ([]models.TagSuggestion, error)
+	GetSpacesByName(ctx context.Context, terms []string) ([]models.Space, error)
 	GetSpaceSubscribers(ctx context.Context, spaceId uuid.Uuid, offset, count int64) ([]models.User, error)
 	GetSpaceActiveSubscribers(ctx context.Context, spaceId uuid.Uuid, offset, count int64) ([]models.User, error)
 	GetSpaceTopLevelThreadsByTime(ctx context.Context, spaceId uuid.Uuid, offset, count int64) ([]models.TopLevelThread, error)
 	GetSpaceTopLevelThreadsByPopularity(ctx context.Context, spaceId uuid.Uuid, offset, count int64) ([]models.TopLevelThread, error)
 	SetSpace(ctx context.Context, newSpace models.NewSpace) (uuid.Uuid, error)
 	DeleteSpace(ctx context.Context, spaceId uuid.Uuid) error
+	ArchiveSpace(ctx context.Context, spaceId uuid.Uuid) error
+	GetDueSpaceTransitions(ctx context.Context, until time.Time, count int64) ([]models.SpaceTransition, error)
+	MoveSpaceTransition(ctx context.Context, transition models.SpaceTransition, next *time.Time) (bool, error)
 	SetSpaceSubscriber(ctx context.Context, spaceId uuid.Uuid, userUid models.UserUid) error
 	DeleteSpaceSubscriber(ctx context.Context, spaceId uuid.Uuid, userUid models.UserUid) error
-	SetSpaceSubscriberSession(ctx context.Context, spaceId uuid.Uuid, userUid models.UserUid, sessionId uuid.Uuid) error
-	DeleteSpaceSubscriberSession(ctx context.Context, spaceId uuid.Uuid, userUid models.UserUid, sessionId uuid.Uuid) error
+	SetSpaceSubscriberSession(ctx context.Context, spaceId uuid.Uuid, userUid models.UserUid, sessionId uuid.Uuid, instanceId string, ttl time.Duration) error
+	RefreshSpaceSubscriberSession(ctx context.Context, sessionId uuid.Uuid, ttl time.Duration) error
+	GetExpiredSpaceSubscriberSessions(ctx context.Context, until time.Time, count int64) ([]models.SpaceSubscriberSession, error)
+	DeleteSpaceSubscriberSession(ctx context.Context, spaceId uuid.Uuid, userUid models.UserUid, sessionId uuid.Uuid) (bool, error)
 	HasSpaceThread(ctx context.Context, spaceId, threadId uuid.Uuid) (bool, error)
 	HasSpaceSubscriber(ctx context.Context, spaceId uuid.Uuid, userUid models.UserUid) (bool, error)
+	SetSpaceBannedUser(ctx context.Context, spaceId uuid.Uuid, userUid models.UserUid) error
+	IsSpaceBannedUser(ctx context.Context, spaceId uuid.Uuid, userUid models.UserUid) (bool, error)
 }
 
 type ThreadCacheRepository interface {
 	GetThread(ctx context.Context, threadId uuid.Uuid) (*models.Thread, error)
+	GetTopLevelThread(ctx context.Context, threadId uuid.Uuid) (*models.TopLevelThread, error)
 	GetThreadMessagesByTime(ctx context.Context, threadId uuid.Uuid, offset, count int64) ([]models.MessageWithChildThreadMessagesCount, error)
 	GetThreadMessagesByPopularity(ctx context.Context, threadId uuid.Uuid, offset, count int64) ([]models.MessageWithChildThreadMessagesCount, error)
 	SetTopLevelThread(ctx context.Context, spaceId uuid.Uuid, newMessage models.NewTopLevelThreadFirstMessage) (*models.TopLevelThread, *models.Message, error)
@@ -48,15 +83,89 @@ type ThreadCacheRepository interface {
 	HasThreadMessage(ctx context.Context, threadId, messageId uuid.Uuid) (bool, error)
 	IncrementTopLevelThreadLikesBy(ctx context.Context, spaceId, threadId uuid.Uuid, increment int64) error
 	IncrementThreadLikesBy(ctx context.Context, threadId uuid.Uuid, increment int64) error
+	DeleteTopLevelThread(ctx context.Context, spaceId, threadId uuid.Uuid) error
 }
 
 type MessageCacheRepository interface {
 	GetMessage(ctx context.Context, messageId uuid.Uuid) (*models.MessageWithChildThreadMessagesCount, error)
 	SetMessage(ctx context.Context, newMessage models.NewMessage) (*models.Message, error)
 	IncrementMessageLikesBy(ctx context.Context, threadId, messageId uuid.Uuid, increment int64) error
+	DeleteMessage(ctx context.Context, threadId, messageId uuid.Uuid) error
+	GetSpaceLocationPins(ctx context.Context, spaceId uuid.Uuid, offset, count int64) ([]models.MessageWithChildThreadMessagesCount, error)
 }
 
 type AddressCacheRepository interface {
 	GetAddress(ctx context.Context, geoHash string) (*models.Address, error)
 	SetAddress(ctx context.Context, newAddress models.Address) error
 }
+
+type RateLimitCacheRepository interface {
+	TakeRateLimitToken(ctx context.Context, policy models.RateLimitPolicy, subject string) (allowed bool, retryAfter time.Duration, err error)
+}
+
+type HeldMessageCacheRepository interface {
+	SetHeldMessage(ctx context.Context, newHeldMessage models.NewHeldMessage) (*models.HeldMessage, error)
+	GetHeldMessage(ctx context.Context, heldMessageId uuid.Uuid) (*models.HeldMessage, error)
+	GetSpaceHeldMessages(ctx context.Context, spaceId uuid.Uuid, offset, count int64) ([]models.HeldMessage, error)
+	DeleteHeldMessage(ctx context.Context, spaceId, heldMessageId uuid.Uuid) error
+}
+
+type ReportCacheRepository interface {
+	AddReport(ctx context.Context, newReport models.NewReport) (added bool, reportsCount int, err error)
+	GetReport(ctx context.Context, target models.ReportTarget) (*models.Report, error)
+	GetSpaceReports(ctx context.Context, spaceId uuid.Uuid, offset, count int64) ([]models.Report, error)
+	GetReports(ctx context.Context, offset, count int64) ([]models.Report, error)
+	DeleteReport(ctx context.Context, target models.ReportTarget, spaceId uuid.Uuid) error
+	SetReportTargetHidden(ctx context.Context, target models.ReportTarget, hidden bool) error
+}
+
+type PollCacheRepository interface {
+	SetPollVote(ctx context.Context, messageId uuid.Uuid, voterId models.UserUid, options []int) error
+	GetPollResults(ctx context.Context, messageId uuid.Uuid, optionsCount int) (*models.PollResults, error)
+	GetExpiredPolls(ctx context.Context, until time.Time, count int64) ([]uuid.Uuid, error)
+	ClosePoll(ctx context.Context, messageId uuid.Uuid) (bool, error)
+}
+
+type RetentionCacheRepository interface {
+	SetSpaceMessageRetention(ctx context.Context, spaceId uuid.Uuid, retentionHours int) error
+	GetMessageRetentionSpaceIds(ctx context.Context) ([]uuid.Uuid, error)
+	GetExpiredTopLevelThreadIds(ctx context.Context, spaceId uuid.Uuid, until time.Time, offset, count int64) ([]uuid.Uuid, error)
+	GetExpiredThreadMessageIds(ctx context.Context, threadId uuid.Uuid, until time.Time, offset, count int64) ([]uuid.Uuid, error)
+	ExpireTopLevelThread(ctx context.Context, spaceId, threadId uuid.Uuid) (bool, error)
+}
+
+type TrendingCacheRepository interface {
+	AddSpaceRecentMessage(ctx context.Context, spaceId, messageId uuid.Uuid, createdAt time.Time, window time.Duration) error
+	GetSpacesActivity(ctx context.Context, spaceIds []uuid.Uuid, messagesSince, subscribersSince time.Time) ([]models.SpaceActivity, error)
+}
+
+type ReadMarkerCacheRepository interface {
+	SetReadMarker(ctx context.Context, userId models.UserUid, readMarker models.ReadMarker) (bool, error)
+	GetSpacesUnreadCounts(ctx context.Context, userId models.UserUid, spaceIds []uuid.Uuid) ([]int64, error)
+	GetThreadsUnreadCounts(ctx context.Context, userId models.UserUid, threadIds []uuid.Uuid) ([]int64, error)
+}
+
+type FollowCacheRepository interface {
+	SetThreadFollower(ctx context.Context, threadId uuid.Uuid, userId models.UserUid) error
+	DeleteThreadFollower(ctx context.Context, threadId uuid.Uuid, userId models.UserUid) error
+	GetThreadFollowers(ctx context.Context, threadId uuid.Uuid) ([]models.UserUid, error)
+	AddInboxMessage(ctx context.Context, userIds []models.UserUid, message models.Message, maxSize int64) error
+	GetUserInbox(ctx context.Context, userId models.UserUid, offset, count int64) ([]models.MessageWithChildThreadMessagesCount, error)
+}
+
+type AttachmentCacheRepository interface {
+	SetAttachment(ctx context.Context, attachmentId uuid.Uuid, newAttachment models.NewAttachment) (*models.Attachment, error)
+	GetAttachment(ctx context.Context, attachmentId uuid.Uuid) (*models.Attachment, error)
+	AttachAttachment(ctx context.Context, attachmentId uuid.Uuid) error
+	DetachAttachment(ctx context.Context, attachmentId uuid.Uuid) error
+	GetUnattachedAttachmentIds(ctx context.Context, uploadedBefore time.Time, count int64) ([]uuid.Uuid, error)
+	DeleteUnattachedAttachment(ctx context.Context, attachmentId uuid.Uuid) error
+	GetDeletedAttachmentIds(ctx context.Context, count int64) ([]uuid.Uuid, error)
+	DeleteDeletedAttachmentId(ctx context.Context, attachmentId uuid.Uuid) error
+}
+
+type PinCacheRepository interface {
+	SetSpacePin(ctx context.Context, spaceId uuid.Uuid, pin models.Pin, maxPins int64) (bool, error)
+	DeleteSpacePin(ctx context.Context, spaceId uuid.Uuid, targetType models.PinTargetType, targetId uuid.Uuid) (bool, error)
+	GetSpacePins(ctx context.Context, spaceId uuid.Uuid) ([]models.Pin, error)
+}
@@ -5,5 +5,10 @@ import "spaces-p/pkg/errors"
 var (
 	ErrNotFound            = errors.New("not found")
 	ErrUserNotSignedUp     = errors.New("user is not fully signed up yet")
+	ErrUserBanned          = errors.New("user is banned")
 	ErrOnlyAllowedInDevEnv = errors.New("only allowed in development environment")
+	ErrPollClosed          = errors.New("poll is closed")
+	ErrPinLimitReached     = errors.New("pin limit reached")
+	ErrSpaceEnded          = errors.New("space has ended")
+	ErrAttachmentAttached  = errors.New("attachment is attached to a message already")
 )
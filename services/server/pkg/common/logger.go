@@ -1,11 +1,48 @@
 package common
 
 import (
+	"context"
 	"time"
 )
 
+// Fields are the structured key-value pairs that are added to log lines
+type Fields map[string]any
+
+// keys of the fields that are added to the loggers carried by request contexts
+const (
+	RequestIdLogField = "request_id"
+	TraceIdLogField   = "trace_id"
+	UserIdLogField    = "user_id"
+	SpaceIdLogField   = "space_id"
+)
+
 type Logger interface {
+	Debug(v ...any)
 	Info(v ...any)
+	Warn(v ...any)
 	Error(v ...any)
 	RequestInfo(method, path, clientIP string, statusCode int, latency time.Duration)
+	// With returns a logger that adds fields to every log line
+	With(fields Fields) Logger
+}
+
+type loggerContextKey struct{}
+
+// ContextWithLogger returns a copy of ctx that carries logger
+func ContextWithLogger(ctx context.Context, logger Logger) context.Context {
+	return context.WithValue(ctx, loggerContextKey{}, logger)
+}
+
+// LoggerFromContext returns the logger carried by ctx, or fallback if there is none
+func LoggerFromContext(ctx context.Context, fallback Logger) Logger {
+	if logger, ok := ctx.Value(loggerContextKey{}).(Logger); ok {
+		return logger
+	}
+
+	return fallback
+}
+
+// ContextWithLogFields returns a copy of ctx whose logger adds fields to every log line
+func ContextWithLogFields(ctx context.Context, fallback Logger, fields Fields) context.Context {
+	return ContextWithLogger(ctx, LoggerFromContext(ctx, fallback).With(fields))
 }
@@ -6,6 +6,7 @@ import (
 	"spaces-p/pkg/errors"
 	"spaces-p/pkg/models"
 	"spaces-p/pkg/services"
+	"spaces-p/pkg/tracing"
 	"spaces-p/pkg/utils"
 
 	"github.com/gin-gonic/gin"
@@ -22,7 +23,8 @@ func NewAddressController(logger common.Logger, addressService *services.Address
 
 func (uc *AddressController) GetAddress(c *gin.Context) {
 	const op errors.Op = "controllers.AddressController.GetAddress"
-	var ctx = c.Request.Context()
+	ctx, span := tracing.Start(c.Request.Context(), op)
+	defer span.End()
 	var query struct {
 		Location string `form:"location" binding:"required"`
 	}
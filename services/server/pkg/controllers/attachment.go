@@ -0,0 +1,149 @@
+package controllers
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"spaces-p/pkg/common"
+	"spaces-p/pkg/errors"
+	"spaces-p/pkg/models"
+	"spaces-p/pkg/services"
+	"spaces-p/pkg/tracing"
+	"spaces-p/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// the multipart encoding adds some bytes to the uploaded file
+const multipartOverhead = 1 << 20
+
+type AttachmentController struct {
+	logger            common.Logger
+	attachmentService *services.AttachmentService
+	maxSize           int64
+}
+
+func NewAttachmentController(logger common.Logger, attachmentService *services.AttachmentService, maxSize int64) *AttachmentController {
+	return &AttachmentController{logger, attachmentService, maxSize}
+}
+
+// UploadAttachment expects a multipart form with the uploaded file in the "file" field
+func (ac *AttachmentController) UploadAttachment(c *gin.Context) {
+	const op errors.Op = "controllers.AttachmentController.UploadAttachment"
+	ctx, span := tracing.Start(c.Request.Context(), op)
+	defer span.End()
+
+	spaceId, err := utils.GetSpaceIdFromPath(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), ac.logger)
+		return
+	}
+
+	user, err := utils.GetUserFromContext(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusInternalServerError), ac.logger)
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, ac.maxSize+multipartOverhead)
+	fileHeader, err := c.FormFile("file")
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		utils.WriteError(c, errors.E(op, err, http.StatusRequestEntityTooLarge), ac.logger)
+		return
+	case err != nil:
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), ac.logger)
+		return
+	case fileHeader.Size > ac.maxSize:
+		err := errors.New("the uploaded file is too large")
+		utils.WriteError(c, errors.E(op, err, http.StatusRequestEntityTooLarge), ac.logger)
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), ac.logger)
+		return
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(io.LimitReader(file, ac.maxSize+1))
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), ac.logger)
+		return
+	}
+
+	attachment, err := ac.attachmentService.CreateAttachment(ctx, spaceId, user.ID, fileHeader.Filename, content)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err), ac.logger)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": attachment})
+}
+
+func (ac *AttachmentController) GetAttachment(c *gin.Context) {
+	const op errors.Op = "controllers.AttachmentController.GetAttachment"
+	ctx, span := tracing.Start(c.Request.Context(), op)
+	defer span.End()
+
+	spaceId, err := utils.GetSpaceIdFromPath(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), ac.logger)
+		return
+	}
+
+	attachmentId, err := utils.GetAttachmentIdFromPath(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), ac.logger)
+		return
+	}
+
+	attachment, err := ac.attachmentService.GetAttachment(ctx, spaceId, attachmentId)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err), ac.logger)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": attachment})
+}
+
+// GetAttachmentContent serves the content of signed download urls, it doesn't require authentication
+func (ac *AttachmentController) GetAttachmentContent(c *gin.Context) {
+	const op errors.Op = "controllers.AttachmentController.GetAttachmentContent"
+	ctx, span := tracing.Start(c.Request.Context(), op)
+	defer span.End()
+
+	attachmentId, err := utils.GetAttachmentIdFromPath(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), ac.logger)
+		return
+	}
+
+	var variant = models.AttachmentVariant(c.Param("variant"))
+	content, attachment, err := ac.attachmentService.GetAttachmentContent(ctx, attachmentId, variant, c.Query("expires"), c.Query("signature"))
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err), ac.logger)
+		return
+	}
+	defer content.Close()
+
+	// the size of thumbnails is not stored
+	var size int64 = -1
+	if variant == models.OriginalAttachmentVariant {
+		size = attachment.Size
+	}
+
+	var headers = map[string]string{
+		// the content of an attachment never changes, download urls differ once they are signed again
+		"Cache-Control":          "private, max-age=86400, immutable",
+		"X-Content-Type-Options": "nosniff",
+	}
+	// only images are displayed inline, other files are downloaded
+	if attachment.Type != models.MessageTypeImage {
+		headers["Content-Disposition"] = mime.FormatMediaType("attachment", map[string]string{"filename": attachment.FileName})
+	}
+
+	c.DataFromReader(http.StatusOK, size, attachment.VariantContentType(variant), content, headers)
+}
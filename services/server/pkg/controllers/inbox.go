@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"net/http"
+	"spaces-p/pkg/common"
+	"spaces-p/pkg/errors"
+	"spaces-p/pkg/services"
+	"spaces-p/pkg/tracing"
+	"spaces-p/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type InboxController struct {
+	logger       common.Logger
+	inboxService *services.InboxService
+}
+
+func NewInboxController(logger common.Logger, inboxService *services.InboxService) *InboxController {
+	return &InboxController{logger, inboxService}
+}
+
+func (ic *InboxController) FollowThread(c *gin.Context) {
+	const op errors.Op = "controllers.InboxController.FollowThread"
+	ctx, span := tracing.Start(c.Request.Context(), op)
+	defer span.End()
+
+	threadId, err := utils.GetThreadIdFromPath(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), ic.logger)
+		return
+	}
+
+	authenticatedUser, err := utils.GetUserFromContext(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusInternalServerError), ic.logger)
+		return
+	}
+
+	if err := ic.inboxService.FollowThread(ctx, threadId, authenticatedUser.ID); err != nil {
+		utils.WriteError(c, errors.E(op, err), ic.logger)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": "success"})
+}
+
+func (ic *InboxController) UnfollowThread(c *gin.Context) {
+	const op errors.Op = "controllers.InboxController.UnfollowThread"
+	ctx, span := tracing.Start(c.Request.Context(), op)
+	defer span.End()
+
+	threadId, err := utils.GetThreadIdFromPath(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), ic.logger)
+		return
+	}
+
+	authenticatedUser, err := utils.GetUserFromContext(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusInternalServerError), ic.logger)
+		return
+	}
+
+	if err := ic.inboxService.UnfollowThread(ctx, threadId, authenticatedUser.ID); err != nil {
+		utils.WriteError(c, errors.E(op, err), ic.logger)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": "success"})
+}
+
+func (ic *InboxController) GetInbox(c *gin.Context) {
+	const op errors.Op = "controllers.InboxController.GetInbox"
+	ctx, span := tracing.Start(c.Request.Context(), op)
+	defer span.End()
+	var query paginationQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), ic.logger)
+		return
+	}
+	if query.Count == 0 {
+		query.Count = 20
+	}
+
+	authenticatedUser, err := utils.GetUserFromContext(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusInternalServerError), ic.logger)
+		return
+	}
+
+	inboxMessages, err := ic.inboxService.GetInbox(ctx, authenticatedUser.ID, query.Offset, query.Count)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err), ic.logger)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": inboxMessages})
+}
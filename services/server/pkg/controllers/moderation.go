@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"net/http"
+	"spaces-p/pkg/common"
+	"spaces-p/pkg/errors"
+	"spaces-p/pkg/services"
+	"spaces-p/pkg/tracing"
+	"spaces-p/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ModerationController struct {
+	logger            common.Logger
+	moderationService *services.ModerationService
+}
+
+func NewModerationController(logger common.Logger, moderationService *services.ModerationService) *ModerationController {
+	return &ModerationController{logger, moderationService}
+}
+
+func (uc *ModerationController) GetHeldMessages(c *gin.Context) {
+	const op errors.Op = "controllers.ModerationController.GetHeldMessages"
+	ctx, span := tracing.Start(c.Request.Context(), op)
+	defer span.End()
+
+	var query paginationQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), uc.logger)
+		return
+	}
+	if query.Count == 0 {
+		query.Count = 10
+	}
+
+	spaceId, err := utils.GetSpaceIdFromPath(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), uc.logger)
+		return
+	}
+
+	heldMessages, err := uc.moderationService.GetHeldMessages(ctx, spaceId, query.Offset, query.Count)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err), uc.logger)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": heldMessages})
+}
+
+func (uc *ModerationController) ApproveHeldMessage(c *gin.Context) {
+	const op errors.Op = "controllers.ModerationController.ApproveHeldMessage"
+	ctx, span := tracing.Start(c.Request.Context(), op)
+	defer span.End()
+
+	spaceId, err := utils.GetSpaceIdFromPath(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), uc.logger)
+		return
+	}
+
+	heldMessageId, err := utils.GetHeldMessageIdFromPath(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), uc.logger)
+		return
+	}
+
+	messageId, err := uc.moderationService.ApproveHeldMessage(ctx, spaceId, heldMessageId)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err), uc.logger)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": map[string]any{"messageId": messageId}})
+}
+
+func (uc *ModerationController) RejectHeldMessage(c *gin.Context) {
+	const op errors.Op = "controllers.ModerationController.RejectHeldMessage"
+	ctx, span := tracing.Start(c.Request.Context(), op)
+	defer span.End()
+
+	spaceId, err := utils.GetSpaceIdFromPath(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), uc.logger)
+		return
+	}
+
+	heldMessageId, err := utils.GetHeldMessageIdFromPath(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), uc.logger)
+		return
+	}
+
+	if err := uc.moderationService.RejectHeldMessage(ctx, spaceId, heldMessageId); err != nil {
+		utils.WriteError(c, errors.E(op, err), uc.logger)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": "success"})
+}
@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"net/http"
+	"spaces-p/pkg/common"
+	"spaces-p/pkg/errors"
+	"spaces-p/pkg/services"
+	"spaces-p/pkg/tracing"
+	"spaces-p/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PinController struct {
+	logger     common.Logger
+	pinService *services.PinService
+}
+
+func NewPinController(logger common.Logger, pinService *services.PinService) *PinController {
+	return &PinController{logger, pinService}
+}
+
+func (pc *PinController) PinTopLevelThread(c *gin.Context) {
+	const op errors.Op = "controllers.PinController.PinTopLevelThread"
+	ctx, span := tracing.Start(c.Request.Context(), op)
+	defer span.End()
+
+	spaceId, err := utils.GetSpaceIdFromPath(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), pc.logger)
+		return
+	}
+
+	threadId, err := utils.GetThreadIdFromPath(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), pc.logger)
+		return
+	}
+
+	authenticatedUser, err := utils.GetUserFromContext(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusInternalServerError), pc.logger)
+		return
+	}
+
+	if err := pc.pinService.PinTopLevelThread(ctx, spaceId, threadId, authenticatedUser.ID); err != nil {
+		utils.WriteError(c, errors.E(op, err), pc.logger)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": "success"})
+}
+
+func (pc *PinController) UnpinTopLevelThread(c *gin.Context) {
+	const op errors.Op = "controllers.PinController.UnpinTopLevelThread"
+	ctx, span := tracing.Start(c.Request.Context(), op)
+	defer span.End()
+
+	spaceId, err := utils.GetSpaceIdFromPath(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), pc.logger)
+		return
+	}
+
+	threadId, err := utils.GetThreadIdFromPath(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), pc.logger)
+		return
+	}
+
+	authenticatedUser, err := utils.GetUserFromContext(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusInternalServerError), pc.logger)
+		return
+	}
+
+	if err := pc.pinService.UnpinTopLevelThread(ctx, spaceId, threadId, authenticatedUser.ID); err != nil {
+		utils.WriteError(c, errors.E(op, err), pc.logger)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": "success"})
+}
+
+func (pc *PinController) PinMessage(c *gin.Context) {
+	const op errors.Op = "controllers.PinController.PinMessage"
+	ctx, span := tracing.Start(c.Request.Context(), op)
+	defer span.End()
+
+	spaceId, err := utils.GetSpaceIdFromPath(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), pc.logger)
+		return
+	}
+
+	messageId, err := utils.GetMessageIdFromPath(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), pc.logger)
+		return
+	}
+
+	authenticatedUser, err := utils.GetUserFromContext(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusInternalServerError), pc.logger)
+		return
+	}
+
+	if err := pc.pinService.PinMessage(ctx, spaceId, messageId, authenticatedUser.ID); err != nil {
+		utils.WriteError(c, errors.E(op, err), pc.logger)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": "success"})
+}
+
+func (pc *PinController) UnpinMessage(c *gin.Context) {
+	const op errors.Op = "controllers.PinController.UnpinMessage"
+	ctx, span := tracing.Start(c.Request.Context(), op)
+	defer span.End()
+
+	spaceId, err := utils.GetSpaceIdFromPath(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), pc.logger)
+		return
+	}
+
+	messageId, err := utils.GetMessageIdFromPath(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), pc.logger)
+		return
+	}
+
+	authenticatedUser, err := utils.GetUserFromContext(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusInternalServerError), pc.logger)
+		return
+	}
+
+	if err := pc.pinService.UnpinMessage(ctx, spaceId, messageId, authenticatedUser.ID); err != nil {
+		utils.WriteError(c, errors.E(op, err), pc.logger)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": "success"})
+}
@@ -0,0 +1,66 @@
+package controllers
+
+import (
+	"net/http"
+	"spaces-p/pkg/common"
+	"spaces-p/pkg/errors"
+	"spaces-p/pkg/models"
+	"spaces-p/pkg/services"
+	"spaces-p/pkg/tracing"
+	"spaces-p/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PollController struct {
+	logger      common.Logger
+	pollService *services.PollService
+}
+
+func NewPollController(logger common.Logger, pollService *services.PollService) *PollController {
+	return &PollController{logger, pollService}
+}
+
+func (pc *PollController) VotePoll(c *gin.Context) {
+	const op errors.Op = "controllers.PollController.VotePoll"
+	ctx, span := tracing.Start(c.Request.Context(), op)
+	defer span.End()
+
+	var body models.PollVoteInput
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), pc.logger)
+		return
+	}
+
+	authenticatedUser, err := utils.GetUserFromContext(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusInternalServerError), pc.logger)
+		return
+	}
+
+	spaceId, err := utils.GetSpaceIdFromPath(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), pc.logger)
+		return
+	}
+
+	threadId, err := utils.GetThreadIdFromPath(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), pc.logger)
+		return
+	}
+
+	messageId, err := utils.GetMessageIdFromPath(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), pc.logger)
+		return
+	}
+
+	results, err := pc.pollService.VotePoll(ctx, spaceId, threadId, messageId, authenticatedUser.ID, body.Options)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err), pc.logger)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": results})
+}
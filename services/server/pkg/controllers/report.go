@@ -0,0 +1,242 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"spaces-p/pkg/common"
+	"spaces-p/pkg/errors"
+	"spaces-p/pkg/models"
+	"spaces-p/pkg/services"
+	"spaces-p/pkg/tracing"
+	"spaces-p/pkg/utils"
+	"spaces-p/pkg/uuid"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ReportController struct {
+	logger        common.Logger
+	reportService *services.ReportService
+}
+
+func NewReportController(logger common.Logger, reportService *services.ReportService) *ReportController {
+	return &ReportController{logger, reportService}
+}
+
+func (uc *ReportController) CreateSpaceReport(c *gin.Context) {
+	const op errors.Op = "controllers.ReportController.CreateSpaceReport"
+	ctx, span := tracing.Start(c.Request.Context(), op)
+	defer span.End()
+
+	spaceId, err := utils.GetSpaceIdFromPath(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), uc.logger)
+		return
+	}
+
+	var target = models.ReportTarget{Type: models.SpaceReportTarget, ID: spaceId.String()}
+	if err := uc.createReport(ctx, c, target, spaceId, uuid.Nil); err != nil {
+		utils.WriteError(c, errors.E(op, err), uc.logger)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": "success"})
+}
+
+func (uc *ReportController) CreateThreadReport(c *gin.Context) {
+	const op errors.Op = "controllers.ReportController.CreateThreadReport"
+	ctx, span := tracing.Start(c.Request.Context(), op)
+	defer span.End()
+
+	spaceId, err := utils.GetSpaceIdFromPath(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), uc.logger)
+		return
+	}
+
+	threadId, err := utils.GetThreadIdFromPath(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), uc.logger)
+		return
+	}
+
+	var target = models.ReportTarget{Type: models.ThreadReportTarget, ID: threadId.String()}
+	if err := uc.createReport(ctx, c, target, spaceId, threadId); err != nil {
+		utils.WriteError(c, errors.E(op, err), uc.logger)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": "success"})
+}
+
+func (uc *ReportController) CreateMessageReport(c *gin.Context) {
+	const op errors.Op = "controllers.ReportController.CreateMessageReport"
+	ctx, span := tracing.Start(c.Request.Context(), op)
+	defer span.End()
+
+	spaceId, err := utils.GetSpaceIdFromPath(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), uc.logger)
+		return
+	}
+
+	threadId, err := utils.GetThreadIdFromPath(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), uc.logger)
+		return
+	}
+
+	messageId, err := utils.GetMessageIdFromPath(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), uc.logger)
+		return
+	}
+
+	var target = models.ReportTarget{Type: models.MessageReportTarget, ID: messageId.String()}
+	if err := uc.createReport(ctx, c, target, spaceId, threadId); err != nil {
+		utils.WriteError(c, errors.E(op, err), uc.logger)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": "success"})
+}
+
+func (uc *ReportController) CreateUserReport(c *gin.Context) {
+	const op errors.Op = "controllers.ReportController.CreateUserReport"
+	ctx, span := tracing.Start(c.Request.Context(), op)
+	defer span.End()
+
+	var target = models.ReportTarget{Type: models.UserReportTarget, ID: string(utils.GetUserUidFromPath(c))}
+	if err := uc.createReport(ctx, c, target, uuid.Nil, uuid.Nil); err != nil {
+		utils.WriteError(c, errors.E(op, err), uc.logger)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": "success"})
+}
+
+func (uc *ReportController) GetSpaceReports(c *gin.Context) {
+	const op errors.Op = "controllers.ReportController.GetSpaceReports"
+	ctx, span := tracing.Start(c.Request.Context(), op)
+	defer span.End()
+
+	var query paginationQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), uc.logger)
+		return
+	}
+	if query.Count == 0 {
+		query.Count = 10
+	}
+
+	spaceId, err := utils.GetSpaceIdFromPath(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), uc.logger)
+		return
+	}
+
+	reports, err := uc.reportService.GetSpaceReports(ctx, spaceId, query.Offset, query.Count)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err), uc.logger)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": reports})
+}
+
+func (uc *ReportController) GetReports(c *gin.Context) {
+	const op errors.Op = "controllers.ReportController.GetReports"
+	ctx, span := tracing.Start(c.Request.Context(), op)
+	defer span.End()
+
+	var query paginationQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), uc.logger)
+		return
+	}
+	if query.Count == 0 {
+		query.Count = 10
+	}
+
+	reports, err := uc.reportService.GetReports(ctx, query.Offset, query.Count)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err), uc.logger)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": reports})
+}
+
+// ResolveSpaceReport resolves a report of the space's review queue as the space admin
+func (uc *ReportController) ResolveSpaceReport(c *gin.Context) {
+	const op errors.Op = "controllers.ReportController.ResolveSpaceReport"
+	ctx, span := tracing.Start(c.Request.Context(), op)
+	defer span.End()
+
+	spaceId, err := utils.GetSpaceIdFromPath(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), uc.logger)
+		return
+	}
+
+	if err := uc.resolveReport(ctx, c, spaceId); err != nil {
+		utils.WriteError(c, errors.E(op, err), uc.logger)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": "success"})
+}
+
+// ResolveReport resolves a report of the global review queue as a moderator
+func (uc *ReportController) ResolveReport(c *gin.Context) {
+	const op errors.Op = "controllers.ReportController.ResolveReport"
+	ctx, span := tracing.Start(c.Request.Context(), op)
+	defer span.End()
+
+	if err := uc.resolveReport(ctx, c, uuid.Nil); err != nil {
+		utils.WriteError(c, errors.E(op, err), uc.logger)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": "success"})
+}
+
+func (uc *ReportController) createReport(ctx context.Context, c *gin.Context, target models.ReportTarget, spaceId, threadId uuid.Uuid) error {
+	const op errors.Op = "controllers.ReportController.createReport"
+
+	var input models.NewReportInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		return errors.E(op, err, http.StatusBadRequest)
+	}
+
+	user, err := utils.GetUserFromContext(c)
+	if err != nil {
+		return errors.E(op, err, http.StatusInternalServerError)
+	}
+
+	if err := uc.reportService.CreateReport(ctx, target, spaceId, threadId, user.ID, input.Reason); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+func (uc *ReportController) resolveReport(ctx context.Context, c *gin.Context, spaceId uuid.Uuid) error {
+	const op errors.Op = "controllers.ReportController.resolveReport"
+
+	target, err := utils.GetReportTargetFromPath(c)
+	if err != nil {
+		return errors.E(op, err, http.StatusBadRequest)
+	}
+
+	var input models.ReportResolutionInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		return errors.E(op, err, http.StatusBadRequest)
+	}
+
+	if err := uc.reportService.ResolveReport(ctx, target, input.Action, spaceId); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
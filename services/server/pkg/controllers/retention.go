@@ -0,0 +1,47 @@
+package controllers
+
+import (
+	"net/http"
+	"spaces-p/pkg/common"
+	"spaces-p/pkg/errors"
+	"spaces-p/pkg/models"
+	"spaces-p/pkg/services"
+	"spaces-p/pkg/tracing"
+	"spaces-p/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RetentionController struct {
+	logger           common.Logger
+	retentionService *services.RetentionService
+}
+
+func NewRetentionController(logger common.Logger, retentionService *services.RetentionService) *RetentionController {
+	return &RetentionController{logger, retentionService}
+}
+
+func (rc *RetentionController) SetMessageRetention(c *gin.Context) {
+	const op errors.Op = "controllers.RetentionController.SetMessageRetention"
+	ctx, span := tracing.Start(c.Request.Context(), op)
+	defer span.End()
+
+	spaceId, err := utils.GetSpaceIdFromPath(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), rc.logger)
+		return
+	}
+
+	var input models.MessageRetentionInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), rc.logger)
+		return
+	}
+
+	if err := rc.retentionService.SetMessageRetention(ctx, spaceId, input.MessageRetentionHours); err != nil {
+		utils.WriteError(c, errors.E(op, err), rc.logger)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": "success"})
+}
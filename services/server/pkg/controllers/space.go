@@ -1,14 +1,17 @@
 package controllers
 
 import (
+	"io"
 	"net/http"
 	"spaces-p/pkg/common"
 	"spaces-p/pkg/errors"
 	"spaces-p/pkg/models"
 	"spaces-p/pkg/services"
+	"spaces-p/pkg/tracing"
 	"spaces-p/pkg/utils"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type SpaceController struct {
@@ -25,7 +28,8 @@ func NewSpaceController(logger common.Logger, spaceService *services.SpaceServic
 
 func (uc *SpaceController) GetSpace(c *gin.Context) {
 	const op errors.Op = "controllers.SpaceController.GetSpace"
-	var ctx = c.Request.Context()
+	ctx, span := tracing.Start(c.Request.Context(), op)
+	defer span.End()
 
 	spaceId, err := utils.GetSpaceIdFromPath(c)
 	if err != nil {
@@ -44,12 +48,16 @@ func (uc *SpaceController) GetSpace(c *gin.Context) {
 
 func (uc *SpaceController) GetSpaces(c *gin.Context) {
 	const op errors.Op = "controllers.SpaceController.GetSpaces"
-	var ctx = c.Request.Context()
+	ctx, span := tracing.Start(c.Request.Context(), op)
+	defer span.End()
 	var query struct {
 		paginationQuery
 		Location string         `form:"location"`
 		Radius   models.Radius  `form:"radius" binding:"min=0,max=1000"`
 		UserId   models.UserUid `form:"user_id"`
+		Sort     string         `form:"sort" binding:"oneof='distance' 'trending' ''"`
+		Category string         `form:"category"`
+		Tag      string         `form:"tag"`
 	}
 	if query.Count == 0 {
 		query.Count = 10
@@ -68,6 +76,10 @@ func (uc *SpaceController) GetSpaces(c *gin.Context) {
 		err := errors.New("either the \"location\" or \"user_id\" query parameter must be specified")
 		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), uc.logger)
 		return
+	case query.UserId != "" && (query.Sort != "" || query.Category != "" || query.Tag != ""):
+		err := errors.New("the \"sort\", \"category\" and \"tag\" query parameters can only be specified along with the \"location\" query parameter")
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), uc.logger)
+		return
 	case query.Location != "" && query.Radius == 0:
 		err := errors.New("when the \"location\" query parameter is specified, the \"radius\" query parameter must be specified as well")
 		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), uc.logger)
@@ -79,7 +91,34 @@ func (uc *SpaceController) GetSpaces(c *gin.Context) {
 			return
 		}
 
-		spaces, err := uc.spaceService.GetSpacesByLocation(ctx, location, query.Radius, int(query.Count), int(query.Offset))
+		var filter models.SpaceFilter
+		if query.Category != "" {
+			if err := filter.Category.ParseString(query.Category); err != nil {
+				utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), uc.logger)
+				return
+			}
+		}
+		if query.Tag != "" {
+			tag, err := models.NormalizeTag(query.Tag)
+			if err != nil {
+				utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), uc.logger)
+				return
+			}
+			filter.Tag = tag
+		}
+
+		if query.Sort == "trending" {
+			spaces, err := uc.spaceService.GetTrendingSpacesByLocation(ctx, location, query.Radius, filter, int(query.Count), int(query.Offset))
+			if err != nil {
+				utils.WriteError(c, errors.E(op, err), uc.logger)
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{"data": spaces})
+			return
+		}
+
+		spaces, err := uc.spaceService.GetSpacesByLocation(ctx, location, query.Radius, filter, int(query.Count), int(query.Offset))
 		if err != nil {
 			utils.WriteError(c, errors.E(op, err), uc.logger)
 			return
@@ -87,7 +126,13 @@ func (uc *SpaceController) GetSpaces(c *gin.Context) {
 
 		c.JSON(http.StatusOK, gin.H{"data": spaces})
 	case query.UserId != "":
-		spaces, err := uc.spaceService.GetSpacesByUser(ctx, query.UserId, query.Count, query.Offset)
+		authenticatedUser, err := utils.GetUserFromContext(c)
+		if err != nil {
+			utils.WriteError(c, errors.E(op, err, http.StatusInternalServerError), uc.logger)
+			return
+		}
+
+		spaces, err := uc.spaceService.GetSpacesByUser(ctx, query.UserId, query.Count, query.Offset, authenticatedUser.ID)
 		if err != nil {
 			utils.WriteError(c, errors.E(op, err), uc.logger)
 			return
@@ -97,9 +142,107 @@ func (uc *SpaceController) GetSpaces(c *gin.Context) {
 	}
 }
 
+func (uc *SpaceController) GetSpaceTiles(c *gin.Context) {
+	const op errors.Op = "controllers.SpaceController.GetSpaceTiles"
+	ctx, span := tracing.Start(c.Request.Context(), op)
+	defer span.End()
+	var query struct {
+		BoundingBox string `form:"bbox" binding:"required"`
+		Zoom        *int   `form:"zoom" binding:"required,min=0,max=22"` // max MUST be same as MaxTileZoom constant
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), uc.logger)
+		return
+	}
+
+	var box models.BoundingBox
+	if err := box.ParseString(query.BoundingBox); err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), uc.logger)
+		return
+	}
+
+	spaceTiles, err := uc.spaceService.GetSpaceTiles(ctx, box, *query.Zoom)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err), uc.logger)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": spaceTiles})
+}
+
+// SearchSpaces finds spaces by their names, e.g. to autocomplete them
+func (uc *SpaceController) SearchSpaces(c *gin.Context) {
+	const op errors.Op = "controllers.SpaceController.SearchSpaces"
+	ctx, span := tracing.Start(c.Request.Context(), op)
+	defer span.End()
+	var query struct {
+		Query    string `form:"q" binding:"required,max=100"`
+		Location string `form:"location"`
+		Count    int    `form:"count" binding:"min=0,max=50"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), uc.logger)
+		return
+	}
+	if query.Count == 0 {
+		query.Count = 10
+	}
+
+	var location *models.Location
+	if query.Location != "" {
+		location = &models.Location{}
+		if err := location.ParseString(query.Location); err != nil {
+			utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), uc.logger)
+			return
+		}
+	}
+
+	searchResults, err := uc.spaceService.SearchSpaces(ctx, query.Query, location, query.Count)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err), uc.logger)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": searchResults})
+}
+
+// GetTagSuggestions autocompletes the tags of spaces
+func (uc *SpaceController) GetTagSuggestions(c *gin.Context) {
+	const op errors.Op = "controllers.SpaceController.GetTagSuggestions"
+	ctx, span := tracing.Start(c.Request.Context(), op)
+	defer span.End()
+	var query struct {
+		Prefix string `form:"prefix" binding:"required"`
+		Count  int    `form:"count" binding:"min=0,max=50"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), uc.logger)
+		return
+	}
+	if query.Count == 0 {
+		query.Count = 10
+	}
+
+	// the prefix is normalized like the tags it is matched against
+	prefix, err := models.NormalizeTag(query.Prefix)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), uc.logger)
+		return
+	}
+
+	tagSuggestions, err := uc.spaceService.GetTagSuggestions(ctx, prefix, query.Count)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err), uc.logger)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": tagSuggestions})
+}
+
 func (uc *SpaceController) GetSpaceSubscribers(c *gin.Context) {
 	const op errors.Op = "controllers.SpaceController.GetSpaceSubscribers"
-	var ctx = c.Request.Context()
+	ctx, span := tracing.Start(c.Request.Context(), op)
+	defer span.End()
 
 	var query struct {
 		paginationQuery
@@ -130,7 +273,8 @@ func (uc *SpaceController) GetSpaceSubscribers(c *gin.Context) {
 
 func (uc *SpaceController) CreateSpace(c *gin.Context) {
 	const op errors.Op = "controllers.SpaceController.CreateSpace"
-	var ctx = c.Request.Context()
+	ctx, span := tracing.Start(c.Request.Context(), op)
+	defer span.End()
 
 	user, err := utils.GetUserFromContext(c)
 	if err != nil {
@@ -157,7 +301,8 @@ func (uc *SpaceController) CreateSpace(c *gin.Context) {
 
 func (uc *SpaceController) GetTopLevelThreads(c *gin.Context) {
 	const op errors.Op = "controllers.SpaceController.GetTopLevelThreads"
-	var ctx = c.Request.Context()
+	ctx, span := tracing.Start(c.Request.Context(), op)
+	defer span.End()
 	var query struct {
 		paginationQuery
 		Sort string `form:"sort" binding:"oneof='recent' 'popularity' ''"`
@@ -187,7 +332,13 @@ func (uc *SpaceController) GetTopLevelThreads(c *gin.Context) {
 		return
 	}
 
-	topLevelThreads, err := uc.spaceService.GetTopLevelThreads(ctx, spaceId, sort, query.Offset, query.Count)
+	user, err := utils.GetUserFromContext(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusInternalServerError), uc.logger)
+		return
+	}
+
+	topLevelThreads, err := uc.spaceService.GetTopLevelThreads(ctx, spaceId, sort, query.Offset, query.Count, user.ID)
 	if err != nil {
 		utils.WriteError(c, errors.E(op, err, http.StatusInternalServerError), uc.logger)
 		return
@@ -196,9 +347,45 @@ func (uc *SpaceController) GetTopLevelThreads(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"data": topLevelThreads})
 }
 
+func (uc *SpaceController) GetSpaceLocationPins(c *gin.Context) {
+	const op errors.Op = "controllers.SpaceController.GetSpaceLocationPins"
+	ctx, span := tracing.Start(c.Request.Context(), op)
+	defer span.End()
+	var query paginationQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), uc.logger)
+		return
+	}
+	// a map view shows many pins at once
+	if query.Count == 0 {
+		query.Count = 100
+	}
+
+	spaceId, err := utils.GetSpaceIdFromPath(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), uc.logger)
+		return
+	}
+
+	user, err := utils.GetUserFromContext(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusInternalServerError), uc.logger)
+		return
+	}
+
+	locationPins, err := uc.spaceService.GetSpaceLocationPins(ctx, spaceId, query.Offset, query.Count, user.ID)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err), uc.logger)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": locationPins})
+}
+
 func (uc *SpaceController) GetThreadWithMessages(c *gin.Context) {
 	const op errors.Op = "controllers.SpaceController.GetThreadWithMessages"
-	var ctx = c.Request.Context()
+	ctx, span := tracing.Start(c.Request.Context(), op)
+	defer span.End()
 	var query struct {
 		MessagesOffset int64  `form:"messages_offset" binding:"min=0"`
 		MessagesCount  int64  `form:"messages_count" binding:"min=0"`
@@ -235,7 +422,13 @@ func (uc *SpaceController) GetThreadWithMessages(c *gin.Context) {
 		return
 	}
 
-	threads, err := uc.spaceService.GetThreadWithMessages(ctx, spaceId, threadId, messagesSort, query.MessagesOffset, query.MessagesCount)
+	user, err := utils.GetUserFromContext(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusInternalServerError), uc.logger)
+		return
+	}
+
+	threads, err := uc.spaceService.GetThreadWithMessages(ctx, spaceId, threadId, messagesSort, query.MessagesOffset, query.MessagesCount, user.ID)
 	if err != nil {
 		utils.WriteError(c, errors.E(op, err), uc.logger)
 		return
@@ -246,7 +439,8 @@ func (uc *SpaceController) GetThreadWithMessages(c *gin.Context) {
 
 func (uc *SpaceController) SpaceConnect(c *gin.Context) {
 	const op errors.Op = "controllers.SpaceController.SpaceConnect"
-	var ctx = c.Request.Context()
+	ctx, span := tracing.Start(c.Request.Context(), op)
+	defer span.End()
 
 	user, err := utils.GetUserFromContext(c)
 	if err != nil {
@@ -260,14 +454,23 @@ func (uc *SpaceController) SpaceConnect(c *gin.Context) {
 		return
 	}
 
-	err = uc.spaceNotificationService.SpaceConnect(ctx, c, spaceId, *user)
+	// the session outlives the request, so the spans of the request end once the connection has been upgraded
+	onUpgrade := func() {
+		span.End()
+		trace.SpanFromContext(c.Request.Context()).End()
+	}
+
 	// don't write http status to response again
-	uc.logger.Error(err)
+	if err := uc.spaceNotificationService.SpaceConnect(ctx, c, spaceId, *user, onUpgrade); err != nil {
+		tracing.RecordError(ctx, err)
+		common.LoggerFromContext(ctx, uc.logger).Error(errors.WithTraceId(err, tracing.TraceId(ctx)))
+	}
 }
 
 func (uc *SpaceController) CreateTopLevelThread(c *gin.Context) {
 	const op errors.Op = "controllers.SpaceController.CreateTopLevelThread"
-	var ctx = c.Request.Context()
+	ctx, span := tracing.Start(c.Request.Context(), op)
+	defer span.End()
 
 	var body models.NewMessageInput
 	if err := c.ShouldBindJSON(&body); err != nil {
@@ -287,7 +490,46 @@ func (uc *SpaceController) CreateTopLevelThread(c *gin.Context) {
 		return
 	}
 
-	threadId, messageId, err := uc.threadService.CreateTopLevelThread(ctx, spaceId, models.NewTopLevelThreadFirstMessage{
+	threadId, messageId, heldMessage, err := uc.threadService.CreateTopLevelThread(ctx, spaceId, models.NewTopLevelThreadFirstMessage{
+		NewMessageInput: body,
+		SenderId:        authenticatedUser.ID,
+	})
+	switch {
+	case err != nil:
+		utils.WriteError(c, errors.E(op, err), uc.logger)
+		return
+	case heldMessage != nil:
+		c.JSON(http.StatusAccepted, gin.H{"data": map[string]any{"heldMessageId": heldMessage.ID}})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": map[string]any{"threadId": threadId, "firstMessageId": messageId}})
+}
+
+func (uc *SpaceController) CreateAnnouncement(c *gin.Context) {
+	const op errors.Op = "controllers.SpaceController.CreateAnnouncement"
+	ctx, span := tracing.Start(c.Request.Context(), op)
+	defer span.End()
+
+	var body models.NewMessageInput
+	if err := c.ShouldBindJSON(&body); err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), uc.logger)
+		return
+	}
+
+	authenticatedUser, err := utils.GetUserFromContext(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusInternalServerError), uc.logger)
+		return
+	}
+
+	spaceId, err := utils.GetSpaceIdFromPath(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), uc.logger)
+		return
+	}
+
+	threadId, messageId, err := uc.threadService.CreateAnnouncement(ctx, spaceId, models.NewTopLevelThreadFirstMessage{
 		NewMessageInput: body,
 		SenderId:        authenticatedUser.ID,
 	})
@@ -301,7 +543,8 @@ func (uc *SpaceController) CreateTopLevelThread(c *gin.Context) {
 
 func (uc *SpaceController) CreateThread(c *gin.Context) {
 	const op errors.Op = "controllers.SpaceController.CreateThread"
-	var ctx = c.Request.Context()
+	ctx, span := tracing.Start(c.Request.Context(), op)
+	defer span.End()
 
 	authenticatedUser, err := utils.GetUserFromContext(c)
 	if err != nil {
@@ -332,7 +575,8 @@ func (uc *SpaceController) CreateThread(c *gin.Context) {
 
 func (uc *SpaceController) CreateMessage(c *gin.Context) {
 	const op errors.Op = "controllers.SpaceController.CreateMessage"
-	var ctx = c.Request.Context()
+	ctx, span := tracing.Start(c.Request.Context(), op)
+	defer span.End()
 
 	var body models.NewMessageInput
 	if err := c.ShouldBindJSON(&body); err != nil {
@@ -358,14 +602,18 @@ func (uc *SpaceController) CreateMessage(c *gin.Context) {
 		return
 	}
 
-	messageId, err := uc.messageService.CreateMessage(ctx, spaceId, authenticatedUser.ID, models.NewMessage{
+	messageId, heldMessage, err := uc.messageService.CreateMessage(ctx, spaceId, authenticatedUser.ID, models.NewMessage{
 		BaseMessage: models.BaseMessage(body),
 		SenderId:    authenticatedUser.ID,
 		ThreadId:    threadId,
 	})
-	if err != nil {
+	switch {
+	case err != nil:
 		utils.WriteError(c, errors.E(op, err), uc.logger)
 		return
+	case heldMessage != nil:
+		c.JSON(http.StatusAccepted, gin.H{"data": map[string]any{"heldMessageId": heldMessage.ID}})
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"data": map[string]any{"messageId": messageId}})
@@ -373,7 +621,8 @@ func (uc *SpaceController) CreateMessage(c *gin.Context) {
 
 func (uc *SpaceController) GetMessage(c *gin.Context) {
 	const op errors.Op = "controllers.SpaceController.GetMessage"
-	var ctx = c.Request.Context()
+	ctx, span := tracing.Start(c.Request.Context(), op)
+	defer span.End()
 
 	messageId, err := utils.GetMessageIdFromPath(c)
 	if err != nil {
@@ -392,7 +641,8 @@ func (uc *SpaceController) GetMessage(c *gin.Context) {
 
 func (uc *SpaceController) LikeMessage(c *gin.Context) {
 	const op errors.Op = "controllers.SpaceController.LikeMessage"
-	var ctx = c.Request.Context()
+	ctx, span := tracing.Start(c.Request.Context(), op)
+	defer span.End()
 
 	authenticatedUser, err := utils.GetUserFromContext(c)
 	if err != nil {
@@ -426,9 +676,54 @@ func (uc *SpaceController) LikeMessage(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"data": "success"})
 }
 
+// SetReadMarker marks the space, or the thread of the path if there is one, as read. The marker's time defaults to now.
+func (uc *SpaceController) SetReadMarker(c *gin.Context) {
+	const op errors.Op = "controllers.SpaceController.SetReadMarker"
+	ctx, span := tracing.Start(c.Request.Context(), op)
+	defer span.End()
+
+	var body models.ReadMarkerInput
+	if err := c.ShouldBindJSON(&body); err != nil && !errors.Is(err, io.EOF) {
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), uc.logger)
+		return
+	}
+
+	authenticatedUser, err := utils.GetUserFromContext(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusInternalServerError), uc.logger)
+		return
+	}
+
+	spaceId, err := utils.GetSpaceIdFromPath(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), uc.logger)
+		return
+	}
+
+	// empty for the marker of the space
+	threadId, err := utils.GetThreadIdFromPath(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), uc.logger)
+		return
+	}
+
+	var readMarker = models.ReadMarker{SpaceId: spaceId, ThreadId: threadId}
+	if body.ReadAt != nil {
+		readMarker.ReadAt = *body.ReadAt
+	}
+
+	if err := uc.spaceService.SetReadMarker(ctx, authenticatedUser.ID, readMarker); err != nil {
+		utils.WriteError(c, errors.E(op, err), uc.logger)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": "success"})
+}
+
 func (uc *SpaceController) AddSpaceSubscriber(c *gin.Context) {
 	const op errors.Op = "controllers.SpaceController.AddSpaceSubscriber"
-	var ctx = c.Request.Context()
+	ctx, span := tracing.Start(c.Request.Context(), op)
+	defer span.End()
 
 	spaceId, err := utils.GetSpaceIdFromPath(c)
 	if err != nil {
@@ -449,3 +744,28 @@ func (uc *SpaceController) AddSpaceSubscriber(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"data": "success"})
 }
+
+func (uc *SpaceController) RemoveSpaceSubscriber(c *gin.Context) {
+	const op errors.Op = "controllers.SpaceController.RemoveSpaceSubscriber"
+	ctx, span := tracing.Start(c.Request.Context(), op)
+	defer span.End()
+
+	spaceId, err := utils.GetSpaceIdFromPath(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), uc.logger)
+		return
+	}
+
+	authenticatedUser, err := utils.GetUserFromContext(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusInternalServerError), uc.logger)
+		return
+	}
+
+	if err := uc.spaceService.RemoveSpaceSubscriber(ctx, spaceId, authenticatedUser.ID); err != nil {
+		utils.WriteError(c, errors.E(op, err), uc.logger)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": "success"})
+}
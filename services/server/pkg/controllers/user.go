@@ -5,24 +5,28 @@ import (
 	"spaces-p/pkg/common"
 	"spaces-p/pkg/errors"
 	"spaces-p/pkg/services"
+	"spaces-p/pkg/tracing"
 	"spaces-p/pkg/utils"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type UserController struct {
-	userService *services.UserService
-	logger      common.Logger
-	authClient  common.AuthClient
+	userService             *services.UserService
+	userNotificationService *services.UserNotificationsService
+	logger                  common.Logger
+	authClient              common.AuthClient
 }
 
-func NewUserController(logger common.Logger, userService *services.UserService, authClient common.AuthClient) *UserController {
-	return &UserController{userService, logger, authClient}
+func NewUserController(logger common.Logger, userService *services.UserService, userNotificationService *services.UserNotificationsService, authClient common.AuthClient) *UserController {
+	return &UserController{userService, userNotificationService, logger, authClient}
 }
 
 func (uc *UserController) CreateUserFromIdToken(c *gin.Context) {
 	const op errors.Op = "controllers.UserController.CreateUser"
-	var ctx = c.Request.Context()
+	ctx, span := tracing.Start(c.Request.Context(), op)
+	defer span.End()
 	var body struct {
 		IdToken string `json:"idToken" binding:"required"`
 	}
@@ -43,7 +47,8 @@ func (uc *UserController) CreateUserFromIdToken(c *gin.Context) {
 
 func (uc *UserController) GetUser(c *gin.Context) {
 	const op errors.Op = "controllers.UserController.GetUser"
-	var ctx = c.Request.Context()
+	ctx, span := tracing.Start(c.Request.Context(), op)
+	defer span.End()
 
 	userId := utils.GetUserUidFromPath(c)
 
@@ -67,3 +72,103 @@ func (uc *UserController) GetAuthedUser(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"data": user})
 }
+
+func (uc *UserController) BlockUser(c *gin.Context) {
+	const op errors.Op = "controllers.UserController.BlockUser"
+	ctx, span := tracing.Start(c.Request.Context(), op)
+	defer span.End()
+
+	authenticatedUser, err := utils.GetUserFromContext(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusInternalServerError), uc.logger)
+		return
+	}
+
+	if err := uc.userService.BlockUser(ctx, authenticatedUser.ID, utils.GetUserUidFromPath(c)); err != nil {
+		utils.WriteError(c, errors.E(op, err), uc.logger)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": "success"})
+}
+
+func (uc *UserController) UnblockUser(c *gin.Context) {
+	const op errors.Op = "controllers.UserController.UnblockUser"
+	ctx, span := tracing.Start(c.Request.Context(), op)
+	defer span.End()
+
+	authenticatedUser, err := utils.GetUserFromContext(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusInternalServerError), uc.logger)
+		return
+	}
+
+	if err := uc.userService.UnblockUser(ctx, authenticatedUser.ID, utils.GetUserUidFromPath(c)); err != nil {
+		utils.WriteError(c, errors.E(op, err), uc.logger)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": "success"})
+}
+
+func (uc *UserController) MuteUser(c *gin.Context) {
+	const op errors.Op = "controllers.UserController.MuteUser"
+	ctx, span := tracing.Start(c.Request.Context(), op)
+	defer span.End()
+
+	authenticatedUser, err := utils.GetUserFromContext(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusInternalServerError), uc.logger)
+		return
+	}
+
+	if err := uc.userService.MuteUser(ctx, authenticatedUser.ID, utils.GetUserUidFromPath(c)); err != nil {
+		utils.WriteError(c, errors.E(op, err), uc.logger)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": "success"})
+}
+
+func (uc *UserController) UnmuteUser(c *gin.Context) {
+	const op errors.Op = "controllers.UserController.UnmuteUser"
+	ctx, span := tracing.Start(c.Request.Context(), op)
+	defer span.End()
+
+	authenticatedUser, err := utils.GetUserFromContext(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusInternalServerError), uc.logger)
+		return
+	}
+
+	if err := uc.userService.UnmuteUser(ctx, authenticatedUser.ID, utils.GetUserUidFromPath(c)); err != nil {
+		utils.WriteError(c, errors.E(op, err), uc.logger)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": "success"})
+}
+
+func (uc *UserController) UserConnect(c *gin.Context) {
+	const op errors.Op = "controllers.UserController.UserConnect"
+	ctx, span := tracing.Start(c.Request.Context(), op)
+	defer span.End()
+
+	user, err := utils.GetUserFromContext(c)
+	if err != nil {
+		utils.WriteError(c, errors.E(op, err, http.StatusBadRequest), uc.logger)
+		return
+	}
+
+	// the session outlives the request, so the spans of the request end once the connection has been upgraded
+	onUpgrade := func() {
+		span.End()
+		trace.SpanFromContext(c.Request.Context()).End()
+	}
+
+	// don't write http status to response again
+	if err := uc.userNotificationService.UserConnect(ctx, c, *user, onUpgrade); err != nil {
+		tracing.RecordError(ctx, err)
+		common.LoggerFromContext(ctx, uc.logger).Error(errors.WithTraceId(err, tracing.TraceId(ctx)))
+	}
+}
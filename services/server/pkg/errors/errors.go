@@ -21,6 +21,7 @@ type Error struct {
 	status   int
 	username string
 	where    string
+	traceId  string
 	messages Messages
 }
 
@@ -44,6 +45,12 @@ func E(op Op, details ...any) error {
 		case *Error:
 			t.where = ""
 
+			// only the outermost error carries the trace id
+			if t.traceId != "" {
+				e.traceId = t.traceId
+				t.traceId = ""
+			}
+
 			for k, v := range t.Message() {
 				e.messages[k] = v
 			}
@@ -73,7 +80,9 @@ func E(op Op, details ...any) error {
 func (e *Error) Error() string {
 	var b strings.Builder
 
-	fmt.Fprintf(&b, "%s: ", string(e.op))
+	if e.op != "" {
+		fmt.Fprintf(&b, "%s: ", string(e.op))
+	}
 
 	if e.err != nil {
 		b.WriteString(e.err.Error())
@@ -87,6 +96,10 @@ func (e *Error) Error() string {
 		fmt.Fprintf(&b, "\nError occurred at: %s", e.where)
 	}
 
+	if e.traceId != "" {
+		fmt.Fprintf(&b, "\nTrace id: %s", e.traceId)
+	}
+
 	return b.String()
 }
 
@@ -101,8 +114,16 @@ func (e *Error) Message() Messages {
 			return Messages{"message": "You do not have permission to perform this action"}
 		case http.StatusNotFound:
 			return Messages{"message": "The requested resource was not found"}
+		case http.StatusConflict:
+			return Messages{"message": "The request conflicts with the current state of the resource"}
+		case http.StatusRequestEntityTooLarge:
+			return Messages{"message": "The uploaded content is too large"}
 		case http.StatusUnsupportedMediaType:
 			return Messages{"message": "Unsupported content-type"}
+		case http.StatusUnprocessableEntity:
+			return Messages{"message": "The request could not be processed"}
+		case http.StatusTooManyRequests:
+			return Messages{"message": "Too many requests, please try again later"}
 		default:
 			return Messages{"message": "Something went wrong"}
 		}
@@ -119,6 +140,25 @@ func (e *Error) Status() int {
 	return http.StatusInternalServerError
 }
 
+func (e *Error) TraceId() string {
+	return e.traceId
+}
+
+// WithTraceId attaches the id of the trace err occurred in to err. err is returned as is when traceId is empty.
+func WithTraceId(err error, traceId string) error {
+	if err == nil || traceId == "" {
+		return err
+	}
+
+	var e *Error
+	if As(err, &e) {
+		e.traceId = traceId
+		return err
+	}
+
+	return &Error{err: err, traceId: traceId, status: http.StatusInternalServerError, messages: Messages{}}
+}
+
 func (e *Error) Unwrap() error {
 	return e.err
 }
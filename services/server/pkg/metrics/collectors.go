@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	localmemory "spaces-p/pkg/repositories/local_memory"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	wsSessionsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "ws", "sessions"),
+		"Active websocket sessions of this instance.",
+		nil, nil,
+	)
+	wsPublishedUpdatesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "ws", "published_updates_total"),
+		"Space updates published to the websocket sessions of this instance by update type.",
+		[]string{"type"}, nil,
+	)
+	wsSlowConsumerDisconnectsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "ws", "slow_consumer_disconnects_total"),
+		"Websocket sessions that were closed because they could not keep up with their updates.",
+		nil, nil,
+	)
+	wsDroppedUpdatesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "ws", "dropped_updates_total"),
+		"Space updates that were dropped because the receiving session could not keep up.",
+		nil, nil,
+	)
+	wsResyncsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "ws", "resyncs_total"),
+		"Resync required updates sent to sessions that could not keep up.",
+		nil, nil,
+	)
+	geocodeCacheLookupsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "geocode", "cache_lookups_total"),
+		"Address lookups by cache result, misses are resolved by the geocoding API.",
+		[]string{"result"}, nil,
+	)
+)
+
+// localMemoryCollector reads the session stats of a LocalMemoryRepo at scrape time
+type localMemoryCollector struct {
+	localMemoryRepo *localmemory.LocalMemoryRepo
+}
+
+func NewLocalMemoryCollector(localMemoryRepo *localmemory.LocalMemoryRepo) prometheus.Collector {
+	return localMemoryCollector{localMemoryRepo}
+}
+
+func (c localMemoryCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- wsSessionsDesc
+	ch <- wsPublishedUpdatesDesc
+	ch <- wsSlowConsumerDisconnectsDesc
+	ch <- wsDroppedUpdatesDesc
+	ch <- wsResyncsDesc
+}
+
+func (c localMemoryCollector) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(wsSessionsDesc, prometheus.GaugeValue, float64(c.localMemoryRepo.SessionsCount()))
+
+	for updateType, count := range c.localMemoryRepo.PublishedUpdatesCount() {
+		ch <- prometheus.MustNewConstMetric(wsPublishedUpdatesDesc, prometheus.CounterValue, float64(count), updateType.String())
+	}
+
+	stats := c.localMemoryRepo.SlowConsumerStats()
+	ch <- prometheus.MustNewConstMetric(wsSlowConsumerDisconnectsDesc, prometheus.CounterValue, float64(stats.Disconnects))
+	ch <- prometheus.MustNewConstMetric(wsDroppedUpdatesDesc, prometheus.CounterValue, float64(stats.DroppedUpdates))
+	ch <- prometheus.MustNewConstMetric(wsResyncsDesc, prometheus.CounterValue, float64(stats.Resyncs))
+}
+
+type geocodeCacheStatser interface {
+	CacheStats() (hits, misses int64)
+}
+
+// geocodeCacheCollector reads the address cache stats of the address service at scrape time
+type geocodeCacheCollector struct {
+	addressService geocodeCacheStatser
+}
+
+func NewGeocodeCacheCollector(addressService geocodeCacheStatser) prometheus.Collector {
+	return geocodeCacheCollector{addressService}
+}
+
+func (c geocodeCacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- geocodeCacheLookupsDesc
+}
+
+func (c geocodeCacheCollector) Collect(ch chan<- prometheus.Metric) {
+	hits, misses := c.addressService.CacheStats()
+	ch <- prometheus.MustNewConstMetric(geocodeCacheLookupsDesc, prometheus.CounterValue, float64(hits), "hit")
+	ch <- prometheus.MustNewConstMetric(geocodeCacheLookupsDesc, prometheus.CounterValue, float64(misses), "miss")
+}
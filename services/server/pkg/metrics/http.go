@@ -0,0 +1,16 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+)
+
+// ObserveHTTPRequest records a request's latency. route is the route template, e.g. /v1/spaces/:spaceid,
+// so that the number of label values stays bounded.
+func (m *Metrics) ObserveHTTPRequest(route, method string, statusCode int, latency time.Duration) {
+	if route == "" {
+		route = "unmatched"
+	}
+
+	m.httpRequestDuration.WithLabelValues(route, method, strconv.Itoa(statusCode)).Observe(latency.Seconds())
+}
@@ -0,0 +1,42 @@
+package metrics_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"spaces-p/pkg/metrics"
+	"spaces-p/pkg/models"
+	localmemory "spaces-p/pkg/repositories/local_memory"
+	"spaces-p/pkg/uuid"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler(t *testing.T) {
+	lm := localmemory.NewLocalMemoryRepo(localmemory.DefaultConfig())
+	spaceId := uuid.New()
+	lm.AddSession(localmemory.NewSessionInput{
+		SpaceId:         spaceId,
+		UserId:          models.UserUid("user"),
+		NotificationsCh: make(chan models.SpaceUpdate, lm.Config().NotificationsBufferSize),
+		CloseSlow:       func() {},
+		GoAway:          func() {},
+	})
+	lm.PublishNewSpaceSubscriber(spaceId, "user")
+
+	m := metrics.New()
+	m.MustRegister(metrics.NewLocalMemoryCollector(lm))
+	m.ObserveHTTPRequest("/v1/spaces/:spaceid", http.MethodGet, http.StatusOK, 10*time.Millisecond)
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, string(body), `spaces_ws_sessions 1`)
+	assert.Contains(t, string(body), `spaces_ws_published_updates_total{type="new_subscriber"} 1`)
+	assert.Contains(t, string(body), `spaces_ws_slow_consumer_disconnects_total 0`)
+	assert.Contains(t, string(body), `spaces_http_request_duration_seconds_count{method="GET",route="/v1/spaces/:spaceid",status="200"} 1`)
+}
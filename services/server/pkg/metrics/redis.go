@@ -0,0 +1,49 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type redisHook struct {
+	metrics *Metrics
+}
+
+// RedisHook returns a go-redis hook that records the latency and errors of every command
+func (m *Metrics) RedisHook() redis.Hook {
+	return redisHook{m}
+}
+
+func (h redisHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h redisHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmd)
+		h.observe(cmd.Name(), time.Since(start), err)
+
+		return err
+	}
+}
+
+func (h redisHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmds)
+		h.observe("pipeline", time.Since(start), err)
+
+		return err
+	}
+}
+
+func (h redisHook) observe(command string, latency time.Duration, err error) {
+	h.metrics.redisCommandDuration.WithLabelValues(command).Observe(latency.Seconds())
+
+	if err != nil && err != redis.Nil {
+		h.metrics.redisCommandErrorsTotal.WithLabelValues(command).Inc()
+	}
+}
@@ -20,6 +20,7 @@ func GinZerologLogger(logger common.Logger) gin.HandlerFunc {
 		method := c.Request.Method
 		statusCode := c.Writer.Status()
 
-		logger.RequestInfo(method, path, clientIP, statusCode, latency)
+		// later handlers may have added fields to the request's logger, e.g. the user id
+		common.LoggerFromContext(c.Request.Context(), logger).RequestInfo(method, path, clientIP, statusCode, latency)
 	}
 }
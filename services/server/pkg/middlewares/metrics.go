@@ -0,0 +1,25 @@
+package middlewares
+
+import (
+	"spaces-p/pkg/metrics"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func Metrics(m *metrics.Metrics) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// websocket requests last as long as their sessions, which would distort the request latencies
+		if strings.EqualFold(c.GetHeader("Upgrade"), "websocket") {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+
+		c.Next()
+
+		m.ObserveHTTPRequest(c.FullPath(), c.Request.Method, c.Writer.Status(), time.Since(start))
+	}
+}
@@ -0,0 +1,36 @@
+package middlewares_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"spaces-p/pkg/metrics"
+	"spaces-p/pkg/middlewares"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	m := metrics.New()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middlewares.Metrics(m))
+	router.GET("/spaces/:spaceid", func(c *gin.Context) {})
+	router.GET("/spaces/:spaceid/ws", func(c *gin.Context) {})
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/spaces/space-1", nil))
+	req := httptest.NewRequest(http.MethodGet, "/spaces/space-1/ws", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+
+	assert.Contains(t, string(body), `spaces_http_request_duration_seconds_count{method="GET",route="/spaces/:spaceid",status="200"} 1`)
+	assert.NotContains(t, string(body), `route="/spaces/:spaceid/ws"`)
+}
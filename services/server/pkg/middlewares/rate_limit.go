@@ -0,0 +1,49 @@
+package middlewares
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"spaces-p/pkg/common"
+	"spaces-p/pkg/errors"
+	"spaces-p/pkg/models"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimit rejects requests with 429 once the policy's limit is reached. Requests are counted per authenticated
+// user, so the middleware has to come after EnsureAuthenticated, and per client IP for anonymous requests.
+func RateLimit(
+	logger common.Logger,
+	cacheRepo common.CacheRepository,
+	policy models.RateLimitPolicy,
+) gin.HandlerFunc {
+	const op errors.Op = "middlewares.RateLimit"
+
+	return func(c *gin.Context) {
+		var ctx = c.Request.Context()
+
+		subject := "ip:" + c.ClientIP()
+		if user, ok := c.Get("user"); ok {
+			subject = "user:" + string(user.(*models.User).ID)
+		}
+
+		allowed, retryAfter, err := cacheRepo.TakeRateLimitToken(ctx, policy, subject)
+		if err != nil {
+			// an unavailable rate limiter must not take the whole api down with it
+			common.LoggerFromContext(ctx, logger).Error(errors.E(op, err))
+			c.Next()
+			return
+		}
+
+		if !allowed {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			err := fmt.Errorf("rate limit %s of %d per %s exceeded by %s", policy.Name, policy.Limit, policy.Window, subject)
+			abortAndWriteError(c, errors.E(op, err, http.StatusTooManyRequests), logger)
+			return
+		}
+
+		c.Next()
+	}
+}
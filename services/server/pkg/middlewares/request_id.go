@@ -0,0 +1,56 @@
+package middlewares
+
+import (
+	"spaces-p/pkg/common"
+	"spaces-p/pkg/tracing"
+	"spaces-p/pkg/uuid"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	RequestIdHeader    = "X-Request-ID"
+	maxRequestIdLength = 128
+)
+
+// RequestId honors the X-Request-ID header of incoming requests and generates an id otherwise. The id is sent back
+// in the response and, together with the trace and space id, added to the logger carried by the request's context.
+func RequestId(logger common.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx = c.Request.Context()
+
+		requestId := c.GetHeader(RequestIdHeader)
+		if !isValidRequestId(requestId) {
+			requestId = uuid.New().String()
+		}
+		c.Header(RequestIdHeader, requestId)
+
+		fields := common.Fields{common.RequestIdLogField: requestId}
+		if traceId := tracing.TraceId(ctx); traceId != "" {
+			fields[common.TraceIdLogField] = traceId
+		}
+		// path parameters are already set because the route is matched before any handler runs
+		if spaceId := c.Param("spaceid"); spaceId != "" {
+			fields[common.SpaceIdLogField] = spaceId
+		}
+
+		c.Request = c.Request.WithContext(common.ContextWithLogger(ctx, logger.With(fields)))
+
+		c.Next()
+	}
+}
+
+// request ids are logged and echoed, so only short ids of printable ASCII characters are accepted
+func isValidRequestId(requestId string) bool {
+	if requestId == "" || len(requestId) > maxRequestIdLength {
+		return false
+	}
+
+	for _, r := range requestId {
+		if r < '!' || r > '~' {
+			return false
+		}
+	}
+
+	return true
+}
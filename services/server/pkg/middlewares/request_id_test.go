@@ -0,0 +1,61 @@
+package middlewares_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"spaces-p/pkg/middlewares"
+	"spaces-p/pkg/zerologger"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestId(t *testing.T) {
+	tests := []struct {
+		name          string
+		requestId     string
+		wantRequestId string
+	}{
+		{name: "honors header", requestId: "abc-123", wantRequestId: "abc-123"},
+		{name: "generates id without header"},
+		{name: "generates id for invalid header", requestId: "abc 123"},
+		{name: "generates id for too long header", requestId: strings.Repeat("a", 129)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			logger := zerologger.New(&logs)
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.Use(middlewares.RequestId(logger), middlewares.GinZerologLogger(logger))
+			router.GET("/spaces/:spaceid", func(c *gin.Context) {})
+
+			req := httptest.NewRequest(http.MethodGet, "/spaces/space-1", nil)
+			if test.requestId != "" {
+				req.Header.Set(middlewares.RequestIdHeader, test.requestId)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			requestId := rec.Header().Get(middlewares.RequestIdHeader)
+			if test.wantRequestId != "" {
+				assert.Equal(t, test.wantRequestId, requestId)
+			} else {
+				assert.NotEmpty(t, requestId)
+				assert.NotEqual(t, test.requestId, requestId)
+			}
+
+			var logLine map[string]any
+			require.NoError(t, json.Unmarshal(logs.Bytes(), &logLine))
+			assert.Equal(t, requestId, logLine["request_id"])
+			assert.Equal(t, "space-1", logLine["space_id"])
+		})
+	}
+}
@@ -3,9 +3,12 @@ package middlewares
 import (
 	"fmt"
 	"net/http"
+	"slices"
 	"spaces-p/pkg/common"
 	"spaces-p/pkg/errors"
+	"spaces-p/pkg/models"
 	"spaces-p/pkg/utils"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -55,7 +58,13 @@ func EnsureAuthenticated(
 			return
 		}
 
+		if user.IsBanned {
+			abortAndWriteError(c, errors.E(op, common.ErrUserBanned, http.StatusForbidden), logger)
+			return
+		}
+
 		c.Set("user", user)
+		c.Request = c.Request.WithContext(common.ContextWithLogFields(ctx, logger, common.Fields{common.UserIdLogField: user.ID}))
 
 		c.Next()
 	}
@@ -95,3 +104,140 @@ func IsSpaceSubscriber(
 		c.Next()
 	}
 }
+
+func IsSpaceAdmin(
+	logger common.Logger,
+	cacheRepo common.CacheRepository,
+) gin.HandlerFunc {
+	const op errors.Op = "middlewares.IsSpaceAdmin"
+
+	return func(c *gin.Context) {
+		var ctx = c.Request.Context()
+
+		spaceId, err := utils.GetSpaceIdFromPath(c)
+		if err != nil {
+			abortAndWriteError(c, errors.E(op, err, http.StatusBadRequest), logger)
+			return
+		}
+
+		user, err := utils.GetUserFromContext(c)
+		if err != nil {
+			abortAndWriteError(c, errors.E(op, err, http.StatusInternalServerError), logger)
+			return
+		}
+
+		space, err := cacheRepo.GetSpace(ctx, spaceId)
+		switch {
+		case errors.Is(err, common.ErrNotFound):
+			abortAndWriteError(c, errors.E(op, err, http.StatusNotFound), logger)
+			return
+		case err != nil:
+			abortAndWriteError(c, errors.E(op, err, http.StatusInternalServerError), logger)
+			return
+		case space.AdminId != user.ID:
+			err := fmt.Errorf("user %s is not the admin of space %s", user.ID, spaceId.String())
+			abortAndWriteError(c, errors.E(op, err, http.StatusForbidden), logger)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// IsModerator lets only the users through who review the global report queue
+func IsModerator(
+	logger common.Logger,
+	moderatorIds []models.UserUid,
+) gin.HandlerFunc {
+	const op errors.Op = "middlewares.IsModerator"
+
+	return func(c *gin.Context) {
+		user, err := utils.GetUserFromContext(c)
+		if err != nil {
+			abortAndWriteError(c, errors.E(op, err, http.StatusInternalServerError), logger)
+			return
+		}
+
+		if !slices.Contains(moderatorIds, user.ID) {
+			err := fmt.Errorf("user %s is not a moderator", user.ID)
+			abortAndWriteError(c, errors.E(op, err, http.StatusForbidden), logger)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// IsSpaceAdminOrModerator lets the admin of the space and the users who review the global report queue through
+func IsSpaceAdminOrModerator(
+	logger common.Logger,
+	cacheRepo common.CacheRepository,
+	moderatorIds []models.UserUid,
+) gin.HandlerFunc {
+	const op errors.Op = "middlewares.IsSpaceAdminOrModerator"
+
+	return func(c *gin.Context) {
+		var ctx = c.Request.Context()
+
+		spaceId, err := utils.GetSpaceIdFromPath(c)
+		if err != nil {
+			abortAndWriteError(c, errors.E(op, err, http.StatusBadRequest), logger)
+			return
+		}
+
+		user, err := utils.GetUserFromContext(c)
+		if err != nil {
+			abortAndWriteError(c, errors.E(op, err, http.StatusInternalServerError), logger)
+			return
+		}
+
+		space, err := cacheRepo.GetSpace(ctx, spaceId)
+		switch {
+		case errors.Is(err, common.ErrNotFound):
+			abortAndWriteError(c, errors.E(op, err, http.StatusNotFound), logger)
+			return
+		case err != nil:
+			abortAndWriteError(c, errors.E(op, err, http.StatusInternalServerError), logger)
+			return
+		case space.AdminId != user.ID && !slices.Contains(moderatorIds, user.ID):
+			err := fmt.Errorf("user %s is neither the admin of space %s nor a moderator", user.ID, spaceId.String())
+			abortAndWriteError(c, errors.E(op, err, http.StatusForbidden), logger)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// IsSpaceWritable rejects new content in spaces that have ended, which are read-only
+func IsSpaceWritable(
+	logger common.Logger,
+	cacheRepo common.CacheRepository,
+) gin.HandlerFunc {
+	const op errors.Op = "middlewares.IsSpaceWritable"
+
+	return func(c *gin.Context) {
+		var ctx = c.Request.Context()
+
+		spaceId, err := utils.GetSpaceIdFromPath(c)
+		if err != nil {
+			abortAndWriteError(c, errors.E(op, err, http.StatusBadRequest), logger)
+			return
+		}
+
+		space, err := cacheRepo.GetSpace(ctx, spaceId)
+		switch {
+		case errors.Is(err, common.ErrNotFound):
+			abortAndWriteError(c, errors.E(op, err, http.StatusNotFound), logger)
+			return
+		case err != nil:
+			abortAndWriteError(c, errors.E(op, err, http.StatusInternalServerError), logger)
+			return
+		case space.HasEnded(time.Now()):
+			abortAndWriteError(c, errors.E(op, common.ErrSpaceEnded, http.StatusForbidden), logger)
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"spaces-p/pkg/uuid"
+	"time"
+)
+
+type AttachmentVariant string
+
+const (
+	OriginalAttachmentVariant  AttachmentVariant = "original"
+	ThumbnailAttachmentVariant AttachmentVariant = "thumbnail" // only images have thumbnails
+)
+
+// NewAttachment is an uploaded file that messages of its space can reference. Type is either MessageTypeImage or
+// MessageTypeFile.
+type NewAttachment struct {
+	SpaceId              uuid.Uuid   `json:"spaceId"`
+	UploaderId           UserUid     `json:"uploaderId"`
+	Type                 MessageType `json:"type"`
+	FileName             string      `json:"fileName"`
+	ContentType          string      `json:"contentType"`
+	Size                 int64       `json:"size"`
+	Width                int         `json:"width,omitempty"`
+	Height               int         `json:"height,omitempty"`
+	ThumbnailContentType string      `json:"-"`
+}
+
+type Attachment struct {
+	NewAttachment
+	ID        uuid.Uuid `json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// VariantContentType returns the content type of the variant, or an empty string if the attachment has no such variant
+func (a *Attachment) VariantContentType(variant AttachmentVariant) string {
+	switch variant {
+	case OriginalAttachmentVariant:
+		return a.ContentType
+	case ThumbnailAttachmentVariant:
+		return a.ThumbnailContentType
+	default:
+		return ""
+	}
+}
+
+// AttachmentWithUrls holds signed download urls of the attachment that expire at ExpiresAt
+type AttachmentWithUrls struct {
+	Attachment
+	Url          string    `json:"url"`
+	ThumbnailUrl string    `json:"thumbnailUrl,omitempty"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+}
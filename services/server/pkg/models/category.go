@@ -0,0 +1,122 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"spaces-p/pkg/errors"
+	"strings"
+)
+
+const (
+	MaxSpaceTags = 10
+	maxTagLength = 32
+)
+
+type SpaceCategory string
+
+const (
+	FoodSpaceCategory       SpaceCategory = "food"
+	NightlifeSpaceCategory  SpaceCategory = "nightlife"
+	MusicSpaceCategory      SpaceCategory = "music"
+	SportsSpaceCategory     SpaceCategory = "sports"
+	OutdoorsSpaceCategory   SpaceCategory = "outdoors"
+	ArtsSpaceCategory       SpaceCategory = "arts"
+	EducationSpaceCategory  SpaceCategory = "education"
+	TechnologySpaceCategory SpaceCategory = "technology"
+	CommunitySpaceCategory  SpaceCategory = "community"
+	TravelSpaceCategory     SpaceCategory = "travel"
+	OtherSpaceCategory      SpaceCategory = "other"
+)
+
+var spaceCategories = map[SpaceCategory]bool{
+	FoodSpaceCategory:       true,
+	NightlifeSpaceCategory:  true,
+	MusicSpaceCategory:      true,
+	SportsSpaceCategory:     true,
+	OutdoorsSpaceCategory:   true,
+	ArtsSpaceCategory:       true,
+	EducationSpaceCategory:  true,
+	TechnologySpaceCategory: true,
+	CommunitySpaceCategory:  true,
+	TravelSpaceCategory:     true,
+	OtherSpaceCategory:      true,
+}
+
+func (c *SpaceCategory) ParseString(str string) error {
+	const op errors.Op = "models.SpaceCategory.ParseString"
+
+	category := SpaceCategory(str)
+	if !spaceCategories[category] {
+		err := fmt.Errorf("invalid space category: %s", str)
+		return errors.E(op, err)
+	}
+
+	*c = category
+
+	return nil
+}
+
+// NormalizeTag lowercases the tag and joins its words with dashes. Tags may only consist of letters, digits and dashes,
+// so that they can be used in redis keys and be stored comma-separated.
+func NormalizeTag(tag string) (string, error) {
+	const op errors.Op = "models.NormalizeTag"
+
+	normalizedTag := strings.Join(strings.Fields(strings.ToLower(tag)), "-")
+	if normalizedTag == "" || len(normalizedTag) > maxTagLength {
+		err := fmt.Errorf("tags must have between 1 and %d characters: %q", maxTagLength, tag)
+		return "", errors.E(op, err)
+	}
+
+	for _, r := range normalizedTag {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' {
+			err := fmt.Errorf("tags may only contain letters, digits and dashes: %q", tag)
+			return "", errors.E(op, err)
+		}
+	}
+
+	return normalizedTag, nil
+}
+
+// NormalizeTags normalizes every tag and removes the duplicates, keeping the order of the tags
+func NormalizeTags(tags []string) ([]string, error) {
+	const op errors.Op = "models.NormalizeTags"
+
+	var normalizedTags = make([]string, 0, len(tags))
+	var seen = make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		normalizedTag, err := NormalizeTag(tag)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+
+		if !seen[normalizedTag] {
+			seen[normalizedTag] = true
+			normalizedTags = append(normalizedTags, normalizedTag)
+		}
+	}
+
+	return normalizedTags, nil
+}
+
+func (s *BaseSpace) HasTag(tag string) bool {
+	return slices.Contains(s.Tags, tag)
+}
+
+// SpaceFilter narrows down the spaces nearby, its zero value matches every space
+type SpaceFilter struct {
+	Category SpaceCategory
+	Tag      string
+}
+
+func (f SpaceFilter) IsEmpty() bool {
+	return f.Category == "" && f.Tag == ""
+}
+
+func (f SpaceFilter) Matches(space *Space) bool {
+	return (f.Category == "" || space.Category == f.Category) && (f.Tag == "" || space.HasTag(f.Tag))
+}
+
+type TagSuggestion struct {
+	Tag         string `json:"tag"`
+	SpacesCount int64  `json:"spacesCount"`
+}
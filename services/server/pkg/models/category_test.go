@@ -0,0 +1,34 @@
+package models_test
+
+import (
+	"spaces-p/pkg/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	tags, err := models.NormalizeTags([]string{"Street Food", " vegan ", "street-food", "24h"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"street-food", "vegan", "24h"}, tags)
+
+	for _, tag := range []string{"", "   ", "café", "a,b", "{tag}", "this-tag-is-longer-than-thirty-two-chars"} {
+		_, err := models.NormalizeTag(tag)
+		assert.Error(t, err, tag)
+	}
+}
+
+func TestSpaceFilterMatches(t *testing.T) {
+	var space = &models.Space{BaseSpace: models.BaseSpace{Category: models.FoodSpaceCategory, Tags: []string{"vegan", "brunch"}}}
+
+	assert.True(t, models.SpaceFilter{}.Matches(space))
+	assert.True(t, models.SpaceFilter{Category: models.FoodSpaceCategory, Tag: "brunch"}.Matches(space))
+	assert.False(t, models.SpaceFilter{Category: models.MusicSpaceCategory}.Matches(space))
+	assert.False(t, models.SpaceFilter{Tag: "pizza"}.Matches(space))
+
+	var category models.SpaceCategory
+	assert.NoError(t, category.ParseString("food"))
+	assert.Equal(t, models.FoodSpaceCategory, category)
+	assert.Error(t, category.ParseString("cooking"))
+}
@@ -0,0 +1,9 @@
+package models
+
+import "spaces-p/pkg/uuid"
+
+// InboxMessage is a new message of a thread the user follows, or of a thread nested in it
+type InboxMessage struct {
+	MessageWithChildThreadMessagesCount
+	SpaceId uuid.Uuid `json:"spaceId"`
+}
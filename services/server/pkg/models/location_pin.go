@@ -0,0 +1,17 @@
+package models
+
+// LocationPin is the payload of location messages, a place shared on the space's map
+type LocationPin struct {
+	Location Location `json:"location"`
+	Label    string   `json:"label,omitempty"`
+	// reverse geocoded from the location, it is ignored when a location message is created
+	Address *Address `json:"address,omitempty"`
+}
+
+func (p *LocationPin) MessageType() MessageType {
+	return MessageTypeLocation
+}
+
+func (p *LocationPin) Text() string {
+	return p.Label
+}
@@ -3,21 +3,88 @@ package models
 import (
 	"encoding/json"
 	"fmt"
+	"regexp"
+	"slices"
 	"spaces-p/pkg/errors"
 	"spaces-p/pkg/uuid"
+	"strings"
 	"time"
 )
 
+// The Content of text messages is required, image and file messages reference an uploaded attachment and may have
+// an empty Content as caption. Messages of types that carry structured data (e.g. polls) hold it in their Payload,
+// whose implementation is tagged by the message's Type.
 type BaseMessage struct {
-	Content string      `json:"content" binding:"required"`
-	Type    MessageType `json:"type"`
+	Content      string         `json:"content"`
+	Type         MessageType    `json:"type"`
+	AttachmentId uuid.Uuid      `json:"attachmentId"`
+	Payload      MessagePayload `json:"payload,omitempty"`
+}
+
+// Text returns the user written text of the message, the content followed by the text of its payload
+func (m *BaseMessage) Text() string {
+	if m.Payload == nil {
+		return m.Content
+	}
+
+	return strings.TrimSpace(m.Content + "\n" + m.Payload.Text())
+}
+
+// MaxMentions is the number of users a message notifies at most, further mentions are ignored
+const MaxMentions = 10
+
+// mentions are an @ followed by a username, at the start of the text or after a whitespace, e.g. not in email addresses
+var mentionRegexp = regexp.MustCompile(`(?:^|\s)@([\p{L}\p{N}_.]+)`)
+
+// MentionedUsernames returns the lowercased usernames the text of the message mentions, each one once and at most
+// MaxMentions
+func (m *BaseMessage) MentionedUsernames() []string {
+	var usernames []string
+	for _, match := range mentionRegexp.FindAllStringSubmatch(m.Text(), -1) {
+		username := strings.ToLower(strings.TrimRight(match[1], "."))
+		if username == "" || slices.Contains(usernames, username) {
+			continue
+		}
+
+		usernames = append(usernames, username)
+		if len(usernames) == MaxMentions {
+			break
+		}
+	}
+
+	return usernames
 }
 
 type NewMessageInput BaseMessage
 
+// UnmarshalJSON decodes the payload into the implementation tagged by the message's type
+func (m *NewMessageInput) UnmarshalJSON(data []byte) error {
+	const op errors.Op = "models.NewMessageInput.UnmarshalJSON"
+
+	// the raw payload shadows the payload of the embedded BaseMessage
+	var input struct {
+		BaseMessage
+		Payload json.RawMessage `json:"payload"`
+	}
+	if err := json.Unmarshal(data, &input); err != nil {
+		return errors.E(op, err)
+	}
+
+	payload, err := ParseMessagePayload(input.Type, input.Payload)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	*m = NewMessageInput(input.BaseMessage)
+	m.Payload = payload
+
+	return nil
+}
+
 type NewTopLevelThreadFirstMessage struct {
 	NewMessageInput
-	SenderId UserUid `json:"senderId"`
+	SenderId     UserUid `json:"senderId"`
+	Announcement bool    `json:"announcement"`
 }
 
 type NewMessage struct {
@@ -32,6 +99,7 @@ type Message struct {
 	CreatedAt     time.Time `json:"createdAt"`
 	ChildThreadId uuid.Uuid `json:"childThreadId"`
 	Likes         int       `json:"likesCount"`
+	Hidden        bool      `json:"hidden"` // the content of hidden messages is not shown until their reports are reviewed
 }
 
 type MessageWithChildThreadMessagesCount struct {
@@ -43,10 +111,14 @@ type MessageType int
 
 const (
 	MessageTypeText MessageType = iota
+	MessageTypeImage
+	MessageTypeFile
+	MessageTypePoll
+	MessageTypeLocation
 )
 
-var messageTypeStrings = []string{"text"}
-var messageTypeStringMessageTypeMap = map[string]MessageType{"text": MessageTypeText}
+var messageTypeStrings = []string{"text", "image", "file", "poll", "location"}
+var messageTypeStringMessageTypeMap = map[string]MessageType{"text": MessageTypeText, "image": MessageTypeImage, "file": MessageTypeFile, "poll": MessageTypePoll, "location": MessageTypeLocation}
 
 func (m *MessageType) String() (string, error) {
 	const op errors.Op = "models.MessageType.String"
@@ -59,7 +131,7 @@ func (m *MessageType) String() (string, error) {
 	return messageTypeStrings[*m], nil
 }
 
-func (m *MessageType) MarshalJSON() ([]byte, error) {
+func (m MessageType) MarshalJSON() ([]byte, error) {
 	const op errors.Op = "models.MessageType.MarshalJSON"
 
 	messageTypeString, err := m.String()
@@ -103,3 +175,53 @@ func (m *MessageType) UnmarshalJSON(data []byte) error {
 
 	return nil
 }
+
+// MessagePayload is the structured data of a message. Every message type that carries a payload has its own
+// implementation.
+type MessagePayload interface {
+	MessageType() MessageType
+	// Text returns the user written text of the payload, which is moderated along with the message's content
+	Text() string
+}
+
+// newMessagePayload returns an empty payload of the message type, or nil if messages of the type don't carry one
+func newMessagePayload(messageType MessageType) MessagePayload {
+	switch messageType {
+	case MessageTypePoll:
+		return &Poll{}
+	case MessageTypeLocation:
+		return &LocationPin{}
+	default:
+		return nil
+	}
+}
+
+// ParseMessagePayload decodes the JSON payload of a message of the given type. Messages of types that carry a
+// payload require one, the other messages must not have one.
+func ParseMessagePayload(messageType MessageType, data []byte) (MessagePayload, error) {
+	const op errors.Op = "models.ParseMessagePayload"
+	var payload = newMessagePayload(messageType)
+	var isEmpty = len(data) == 0 || string(data) == "null"
+
+	messageTypeStr, err := messageType.String()
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	switch {
+	case payload == nil && isEmpty:
+		return nil, nil
+	case payload == nil:
+		err := errors.New(fmt.Sprintf("%s messages can't have a payload", messageTypeStr))
+		return nil, errors.E(op, err)
+	case isEmpty:
+		err := errors.New(fmt.Sprintf("%s messages require a payload", messageTypeStr))
+		return nil, errors.E(op, err)
+	}
+
+	if err := json.Unmarshal(data, payload); err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return payload, nil
+}
@@ -0,0 +1,125 @@
+package models_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"spaces-p/pkg/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMessageInputUnmarshalJSON(t *testing.T) {
+	t.Run("poll payload", func(t *testing.T) {
+		var input models.NewMessageInput
+		err := json.Unmarshal([]byte(`{"type":"poll","payload":{"question":"Lunch?","options":["pizza","sushi"],"multipleChoice":true}}`), &input)
+		require.NoError(t, err)
+
+		require.IsType(t, &models.Poll{}, input.Payload)
+		poll := input.Payload.(*models.Poll)
+		assert.Equal(t, models.MessageTypePoll, input.Type)
+		assert.Equal(t, "Lunch?", poll.Question)
+		assert.Equal(t, []string{"pizza", "sushi"}, poll.Options)
+		assert.True(t, poll.MultipleChoice)
+		assert.Nil(t, poll.ClosesAt)
+	})
+
+	t.Run("location payload", func(t *testing.T) {
+		var input models.NewMessageInput
+		err := json.Unmarshal([]byte(`{"type":"location","payload":{"location":{"longitude":13.4,"latitude":52.5},"label":"Meeting point"}}`), &input)
+		require.NoError(t, err)
+
+		require.IsType(t, &models.LocationPin{}, input.Payload)
+		pin := input.Payload.(*models.LocationPin)
+		assert.Equal(t, models.MessageTypeLocation, input.Type)
+		assert.Equal(t, models.Location{Long: 13.4, Lat: 52.5}, pin.Location)
+		assert.Equal(t, "Meeting point", pin.Label)
+		assert.Nil(t, pin.Address)
+	})
+
+	t.Run("text without payload", func(t *testing.T) {
+		var input models.NewMessageInput
+		err := json.Unmarshal([]byte(`{"type":"text","content":"hello"}`), &input)
+		require.NoError(t, err)
+
+		assert.Equal(t, "hello", input.Content)
+		assert.Nil(t, input.Payload)
+	})
+
+	invalidInputs := map[string]string{
+		"missing payload":    `{"type":"poll"}`,
+		"unexpected payload": `{"type":"text","content":"hello","payload":{"question":"Lunch?"}}`,
+		"invalid payload":    `{"type":"poll","payload":{"question":1}}`,
+	}
+	for name, data := range invalidInputs {
+		t.Run(name, func(t *testing.T) {
+			var input models.NewMessageInput
+			assert.Error(t, json.Unmarshal([]byte(data), &input))
+		})
+	}
+}
+
+func TestMessageMarshalJSON(t *testing.T) {
+	message := models.Message{
+		NewMessage: models.NewMessage{
+			BaseMessage: models.BaseMessage{
+				Type:    models.MessageTypePoll,
+				Payload: &models.Poll{Question: "Lunch?", Options: []string{"pizza", "sushi"}},
+			},
+		},
+	}
+
+	data, err := json.Marshal(message)
+	require.NoError(t, err)
+
+	var fields map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(data, &fields))
+	assert.JSONEq(t, `"poll"`, string(fields["type"]))
+	assert.JSONEq(t, `{"question":"Lunch?","options":["pizza","sushi"],"multipleChoice":false}`, string(fields["payload"]))
+}
+
+func TestMentionedUsernames(t *testing.T) {
+	tests := []struct {
+		name    string
+		message models.BaseMessage
+		want    []string
+	}{
+		{
+			name:    "no mentions",
+			message: models.BaseMessage{Content: "see you there"},
+			want:    nil,
+		},
+		{
+			name:    "mentions are lowercased and deduplicated",
+			message: models.BaseMessage{Content: "@Alice and @bob_1, @alice."},
+			want:    []string{"alice", "bob_1"},
+		},
+		{
+			name:    "email addresses are no mentions",
+			message: models.BaseMessage{Content: "write to alice@example.com"},
+			want:    nil,
+		},
+		{
+			name:    "mentions in the payload",
+			message: models.BaseMessage{Content: "lunch?", Payload: &models.Poll{Question: "@carol pizza or sushi?", Options: []string{"pizza", "sushi"}}},
+			want:    []string{"carol"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.message.MentionedUsernames())
+		})
+	}
+
+	t.Run("at most MaxMentions", func(t *testing.T) {
+		var content string
+		for i := 0; i < models.MaxMentions+5; i++ {
+			content += fmt.Sprintf("@user%d ", i)
+		}
+
+		message := models.BaseMessage{Content: content}
+		assert.Len(t, message.MentionedUsernames(), models.MaxMentions)
+	})
+}
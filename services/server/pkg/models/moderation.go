@@ -0,0 +1,26 @@
+package models
+
+import (
+	"spaces-p/pkg/uuid"
+	"time"
+)
+
+// The ThreadId of a NewHeldMessage is uuid.Nil for the first message of a new top-level thread
+type NewHeldMessage struct {
+	NewMessage
+	SpaceId uuid.Uuid `json:"spaceId"`
+	Reason  string    `json:"reason"`
+	Filter  string    `json:"filter"`
+}
+
+// HeldMessage is a message that waits in the review queue of its space for the space admin's decision
+type HeldMessage struct {
+	NewHeldMessage
+	ID     uuid.Uuid `json:"id"`
+	HeldAt time.Time `json:"heldAt"`
+}
+
+// IsTopLevelThread reports whether approving the message creates a new top-level thread
+func (m *HeldMessage) IsTopLevelThread() bool {
+	return m.ThreadId == uuid.Nil
+}
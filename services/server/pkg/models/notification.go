@@ -1,6 +1,10 @@
 package models
 
-import "spaces-p/pkg/uuid"
+import (
+	"encoding/json"
+	"fmt"
+	"spaces-p/pkg/uuid"
+)
 
 const (
 	NewTopLevelThreadSpaceUpdateType SpaceUpdateType = iota + 1
@@ -13,13 +17,34 @@ const (
 	ThreadPopularityIncrease
 	MessagePopularityIncrease
 	BatchSpaceUpdateType
+	// sent instead of the dropped updates when a client could not keep up, the client has to refetch the space
+	ResyncRequiredSpaceUpdateType
+	// sent when a poll has been voted on or closed
+	PollResultsSpaceUpdateType
+	// sent to the sessions of a user when one of the user's read markers moved forward
+	ReadMarkerSpaceUpdateType
+	// sent to the user's own channel when a new message has been sent in a thread the user follows
+	InboxMessageSpaceUpdateType
+	// sent when a toplevel thread or a message has been pinned or unpinned
+	PinSpaceUpdateType
+	// sent when a time-bounded space has opened at its start time or closed at its end time
+	SpaceStatusSpaceUpdateType
+	// sent when a toplevel thread has been removed along with all its messages, e.g. because it expired
+	DeleteTopLevelThreadSpaceUpdateType
+	// sent when a message has been removed along with its child thread, e.g. because it expired
+	DeleteMessageSpaceUpdateType
+	// sent to the user's own channel when the user has been mentioned in a message of one of the user's spaces
+	MentionSpaceUpdateType
 )
 
 type SpaceUpdate interface {
 	isSpaceUpdate()
+	GetType() SpaceUpdateType
+	// GetUserId returns the user who caused the update, it is empty for updates that were not caused by a user
+	GetUserId() UserUid
 }
 
-type SingleSpaceUpdate[T NewTopLevelThreadSpaceUpdatePayload | NewThreadSpaceUpdatePayload | NewSubscriberPayload | NewActiveSubscriberPayload | NewMessageSpaceUpdatePayload | RemoveActiveSubscriberPayload | IncreaseTopLevelThreadPopularityUpdatePayload | IncreaseThreadPopularityUpdatePayload | IncreaseMessagePopularityUpdatePayload] struct {
+type SingleSpaceUpdate[T NewTopLevelThreadSpaceUpdatePayload | NewThreadSpaceUpdatePayload | NewSubscriberPayload | NewActiveSubscriberPayload | NewMessageSpaceUpdatePayload | RemoveActiveSubscriberPayload | IncreaseTopLevelThreadPopularityUpdatePayload | IncreaseThreadPopularityUpdatePayload | IncreaseMessagePopularityUpdatePayload | ResyncRequiredPayload | PollResultsUpdatePayload | ReadMarkerUpdatePayload | InboxMessageUpdatePayload | PinUpdatePayload | SpaceStatusUpdatePayload | DeleteTopLevelThreadUpdatePayload | DeleteMessageUpdatePayload | MentionUpdatePayload] struct {
 	Type    SpaceUpdateType `json:"type"`
 	UserId  UserUid         `json:"userId"`
 	Payload T               `json:"payload"`
@@ -27,6 +52,14 @@ type SingleSpaceUpdate[T NewTopLevelThreadSpaceUpdatePayload | NewThreadSpaceUpd
 
 func (SingleSpaceUpdate[T]) isSpaceUpdate() {}
 
+func (u SingleSpaceUpdate[T]) GetType() SpaceUpdateType {
+	return u.Type
+}
+
+func (u SingleSpaceUpdate[T]) GetUserId() UserUid {
+	return u.UserId
+}
+
 // TODO: implement publish update function
 type MultiSpaceUpdate struct {
 	Type    SpaceUpdateType `json:"type"`
@@ -35,6 +68,51 @@ type MultiSpaceUpdate struct {
 
 func (MultiSpaceUpdate) isSpaceUpdate() {}
 
+func (u MultiSpaceUpdate) GetType() SpaceUpdateType {
+	return u.Type
+}
+
+func (u MultiSpaceUpdate) GetUserId() UserUid {
+	return ""
+}
+
+// SpaceScopedUpdate is an update of a space multiplexed into a user's own channel. It is serialized like the wrapped
+// update with the id of the space added.
+type SpaceScopedUpdate struct {
+	SpaceId uuid.Uuid
+	Update  SpaceUpdate
+}
+
+func (SpaceScopedUpdate) isSpaceUpdate() {}
+
+func (u SpaceScopedUpdate) GetType() SpaceUpdateType {
+	return u.Update.GetType()
+}
+
+func (u SpaceScopedUpdate) GetUserId() UserUid {
+	return u.Update.GetUserId()
+}
+
+func (u SpaceScopedUpdate) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(u.Update)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	spaceId, err := json.Marshal(u.SpaceId)
+	if err != nil {
+		return nil, err
+	}
+	fields["spaceId"] = spaceId
+
+	return json.Marshal(fields)
+}
+
 type NewTopLevelThreadSpaceUpdatePayload struct {
 	TopLevelThread TopLevelThread `json:"newToplevelThread"`
 }
@@ -73,4 +151,82 @@ type IncreaseMessagePopularityUpdatePayload struct {
 	MessageId uuid.Uuid `json:"messageId"`
 }
 
+type ResyncRequiredPayload struct {
+	DroppedUpdates int `json:"droppedUpdates"`
+}
+
+type PollResultsUpdatePayload struct {
+	ThreadId  uuid.Uuid   `json:"threadId"`
+	MessageId uuid.Uuid   `json:"messageId"`
+	Results   PollResults `json:"results"`
+}
+
+type ReadMarkerUpdatePayload struct {
+	ReadMarker ReadMarker `json:"readMarker"`
+}
+
+type InboxMessageUpdatePayload struct {
+	InboxMessage InboxMessage `json:"inboxMessage"`
+}
+
+type MentionUpdatePayload struct {
+	Message InboxMessage `json:"message"`
+}
+
+type PinUpdatePayload struct {
+	Pin    Pin  `json:"pin"`
+	Pinned bool `json:"pinned"` // false when the item has been unpinned
+}
+
+type SpaceStatus string
+
+const (
+	SpaceOpened SpaceStatus = "opened"
+	SpaceClosed SpaceStatus = "closed"
+)
+
+type SpaceStatusUpdatePayload struct {
+	SpaceId uuid.Uuid   `json:"spaceId"`
+	Status  SpaceStatus `json:"status"`
+}
+
+type DeleteTopLevelThreadUpdatePayload struct {
+	ThreadId uuid.Uuid `json:"threadId"`
+}
+
+type DeleteMessageUpdatePayload struct {
+	ThreadId  uuid.Uuid `json:"threadId"`
+	MessageId uuid.Uuid `json:"messageId"`
+}
+
 type SpaceUpdateType int
+
+var spaceUpdateTypeStrings = map[SpaceUpdateType]string{
+	NewTopLevelThreadSpaceUpdateType:      "new_toplevel_thread",
+	NewThreadSpaceUpdateType:              "new_thread",
+	NewMessageSpaceUpdateType:             "new_message",
+	NewSubscriberSpaceUpdateType:          "new_subscriber",
+	NewActiveSubscriberSpaceUpdateType:    "new_active_subscriber",
+	RemoveActiveSubscriberSpaceUpdateType: "remove_active_subscriber",
+	TopLevelThreadPopularityIncrease:      "toplevel_thread_popularity_increase",
+	ThreadPopularityIncrease:              "thread_popularity_increase",
+	MessagePopularityIncrease:             "message_popularity_increase",
+	BatchSpaceUpdateType:                  "batch",
+	ResyncRequiredSpaceUpdateType:         "resync_required",
+	PollResultsSpaceUpdateType:            "poll_results",
+	ReadMarkerSpaceUpdateType:             "read_marker",
+	InboxMessageSpaceUpdateType:           "inbox_message",
+	PinSpaceUpdateType:                    "pin",
+	SpaceStatusSpaceUpdateType:            "space_status",
+	DeleteTopLevelThreadSpaceUpdateType:   "delete_toplevel_thread",
+	DeleteMessageSpaceUpdateType:          "delete_message",
+	MentionSpaceUpdateType:                "mention",
+}
+
+func (t SpaceUpdateType) String() string {
+	if str, ok := spaceUpdateTypeStrings[t]; ok {
+		return str
+	}
+
+	return fmt.Sprintf("unknown(%d)", int(t))
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"spaces-p/pkg/uuid"
+	"time"
+)
+
+type PinTargetType string
+
+const (
+	ThreadPinTarget  PinTargetType = "thread" // only toplevel threads can be pinned
+	MessagePinTarget PinTargetType = "message"
+)
+
+// Pin is a toplevel thread or a message that space admins or moderators keep at the top of the space
+type Pin struct {
+	TargetType PinTargetType `json:"targetType"`
+	TargetId   uuid.Uuid     `json:"targetId"`
+	PinnedAt   time.Time     `json:"pinnedAt"`
+}
+
+// SpacePins are the pinned items of a space, the most recently pinned first
+type SpacePins struct {
+	TopLevelThreads []TopLevelThread                      `json:"toplevelThreads"`
+	Messages        []MessageWithChildThreadMessagesCount `json:"messages"`
+}
+
+// TopLevelThreadsWithPins returns the pinned items of a space separately from its toplevel threads, which still
+// include the pinned ones
+type TopLevelThreadsWithPins struct {
+	Pinned          SpacePins                       `json:"pinned"`
+	TopLevelThreads []TopLevelThreadWithUnreadCount `json:"toplevelThreads"`
+	// threads hidden from the user are skipped, so the next page starts at this offset rather than offset + count
+	NextOffset int64 `json:"nextOffset"`
+}
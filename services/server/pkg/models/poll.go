@@ -0,0 +1,40 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Poll is the payload of poll messages. Voters choose one of the Options, or several if MultipleChoice is set.
+// Polls with a ClosesAt time are closed automatically once it has passed.
+type Poll struct {
+	Question       string     `json:"question"`
+	Options        []string   `json:"options"`
+	MultipleChoice bool       `json:"multipleChoice"`
+	ClosesAt       *time.Time `json:"closesAt,omitempty"`
+	// tallied separately from the poll, it is ignored when a poll is created
+	Results *PollResults `json:"results,omitempty"`
+}
+
+func (p *Poll) MessageType() MessageType {
+	return MessageTypePoll
+}
+
+func (p *Poll) Text() string {
+	return p.Question + "\n" + strings.Join(p.Options, "\n")
+}
+
+// IsExpired reports whether the poll's close time has passed, even if it hasn't been closed yet
+func (p *Poll) IsExpired(now time.Time) bool {
+	return p.ClosesAt != nil && !now.Before(*p.ClosesAt)
+}
+
+type PollResults struct {
+	Votes       []int64 `json:"votes"` // the votes of every option, in the order of the options
+	VotersCount int64   `json:"votersCount"`
+	Closed      bool    `json:"closed"`
+}
+
+type PollVoteInput struct {
+	Options []int `json:"options" binding:"required"` // indexes of the chosen options
+}
@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// RateLimitPolicy allows at most Limit actions per subject (a user or a client IP) within any Window
+type RateLimitPolicy struct {
+	Name   string // identifies the policy's counters, must be unique
+	Limit  int
+	Window time.Duration
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"spaces-p/pkg/uuid"
+	"time"
+)
+
+// ReadMarker is the time up to which a user has read the toplevel threads of a space, or the messages of a thread if
+// ThreadId is set. Markers only move forward.
+type ReadMarker struct {
+	SpaceId  uuid.Uuid `json:"spaceId"`
+	ThreadId uuid.Uuid `json:"threadId"`
+	ReadAt   time.Time `json:"readAt"`
+}
+
+type ReadMarkerInput struct {
+	ReadAt *time.Time `json:"readAt"` // defaults to now
+}
+
+type SpaceWithUnreadCount struct {
+	Space
+	// the toplevel threads started since the space has been read, only known for the authenticated user's own spaces
+	UnreadCount *int64 `json:"unreadCount,omitempty"`
+}
+
+type TopLevelThreadWithUnreadCount struct {
+	TopLevelThread
+	UnreadCount int64 `json:"unreadCount"` // the messages sent since the thread has been read
+}
@@ -0,0 +1,114 @@
+package models
+
+import (
+	"fmt"
+	"spaces-p/pkg/errors"
+	"spaces-p/pkg/uuid"
+	"strings"
+	"time"
+)
+
+type ReportTargetType string
+
+const (
+	MessageReportTarget ReportTargetType = "message"
+	ThreadReportTarget  ReportTargetType = "thread" // only top-level threads can be reported
+	SpaceReportTarget   ReportTargetType = "space"
+	UserReportTarget    ReportTargetType = "user"
+)
+
+// ReportTarget identifies the reported message, thread, space or user
+type ReportTarget struct {
+	Type ReportTargetType `json:"type"`
+	ID   string           `json:"id"` // a uuid, or a user uid for users
+}
+
+// String returns e.g. message:[messageid]
+func (t ReportTarget) String() string {
+	return string(t.Type) + ":" + t.ID
+}
+
+func (t *ReportTarget) ParseString(data string) error {
+	const op errors.Op = "models.ReportTarget.ParseString"
+
+	targetType, id, found := strings.Cut(data, ":")
+	if !found || id == "" {
+		err := errors.New(fmt.Sprintf("%s is not a valid report target", data))
+		return errors.E(op, err)
+	}
+
+	switch ReportTargetType(targetType) {
+	case MessageReportTarget, ThreadReportTarget, SpaceReportTarget:
+		if _, err := uuid.Parse(id); err != nil {
+			return errors.E(op, err)
+		}
+	case UserReportTarget:
+	default:
+		err := errors.New(fmt.Sprintf("%s is not a valid report target type", targetType))
+		return errors.E(op, err)
+	}
+
+	*t = ReportTarget{Type: ReportTargetType(targetType), ID: id}
+
+	return nil
+}
+
+// HasSpaceQueue reports whether reports of the target also go into the review queue of the target's space.
+// Reports of spaces and users are only reviewed by the global moderators.
+func (t ReportTarget) HasSpaceQueue() bool {
+	return t.Type == MessageReportTarget || t.Type == ThreadReportTarget
+}
+
+type ReportReason string
+
+const (
+	SpamReportReason          ReportReason = "spam"
+	HarassmentReportReason    ReportReason = "harassment"
+	HateSpeechReportReason    ReportReason = "hate_speech"
+	ViolenceReportReason      ReportReason = "violence"
+	SexualContentReportReason ReportReason = "sexual_content"
+	OtherReportReason         ReportReason = "other"
+)
+
+type NewReportInput struct {
+	Reason ReportReason `json:"reason" binding:"required,oneof=spam harassment hate_speech violence sexual_content other"`
+}
+
+type NewReport struct {
+	Target     ReportTarget
+	SpaceId    uuid.Uuid // uuid.Nil for users
+	ThreadId   uuid.Uuid // only for messages and threads
+	AuthorId   UserUid   // the user who is banned when the report is resolved with a ban
+	Excerpt    string    // the reported content at the time of the first report
+	ReporterId UserUid
+	Reason     ReportReason
+}
+
+// Report aggregates all reports of a target
+type Report struct {
+	Target          ReportTarget         `json:"target"`
+	SpaceId         uuid.Uuid            `json:"spaceId"`
+	ThreadId        uuid.Uuid            `json:"threadId"`
+	AuthorId        UserUid              `json:"authorId"`
+	Excerpt         string               `json:"excerpt"`
+	ReportsCount    int                  `json:"reportsCount"`
+	Reasons         map[ReportReason]int `json:"reasons"`
+	Hidden          bool                 `json:"hidden"`
+	FirstReportedAt time.Time            `json:"firstReportedAt"`
+	LastReportedAt  time.Time            `json:"lastReportedAt"`
+}
+
+type ReportResolutionAction string
+
+const (
+	// deletes the reported content
+	DeleteResolutionAction ReportResolutionAction = "delete"
+	// deletes the reported content and bans its author
+	BanResolutionAction ReportResolutionAction = "ban"
+	// discards the reports and shows the content again if it has been hidden
+	DismissResolutionAction ReportResolutionAction = "dismiss"
+)
+
+type ReportResolutionInput struct {
+	Action ReportResolutionAction `json:"action" binding:"required,oneof=delete ban dismiss"`
+}
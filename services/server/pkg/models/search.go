@@ -0,0 +1,127 @@
+package models
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	// query terms that are less similar to every word of a name don't match it
+	minFuzzySimilarity = 0.6
+	fuzzyMatchWeight   = 0.7
+)
+
+// SpaceSearchResult is a space whose name matches a search query. The distance is only set if the search was biased
+// by a location.
+type SpaceSearchResult struct {
+	Space
+	Distance  *float64 `json:"distance,omitempty"`
+	Relevance float64  `json:"relevance"`
+}
+
+// SpaceNameTerms splits the name into its lowercased words, without duplicates
+func SpaceNameTerms(name string) []string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	var terms = make([]string, 0, len(words))
+	var seen = make(map[string]bool, len(words))
+	for _, word := range words {
+		if !seen[word] {
+			seen[word] = true
+			terms = append(terms, word)
+		}
+	}
+
+	return terms
+}
+
+// TermTrigrams returns the sequences of three characters of the term, terms shorter than that are their only trigram
+func TermTrigrams(term string) []string {
+	runes := []rune(term)
+	if len(runes) <= 3 {
+		return []string{term}
+	}
+
+	var trigrams = make([]string, 0, len(runes)-2)
+	for i := 0; i+3 <= len(runes); i++ {
+		trigrams = append(trigrams, string(runes[i:i+3]))
+	}
+
+	return trigrams
+}
+
+// SpaceNameRelevance rates between 0 and 1 how well the name matches the query terms. Every query term is matched
+// against the closest word of the name: equal words match best, then words the term is a prefix of, e.g. while the
+// query is typed, then words the term is similar to, e.g. with a typo.
+func SpaceNameRelevance(queryTerms []string, name string) float64 {
+	if len(queryTerms) == 0 {
+		return 0
+	}
+
+	nameTerms := SpaceNameTerms(name)
+
+	var relevance float64
+	for _, queryTerm := range queryTerms {
+		var bestMatch float64
+		for _, nameTerm := range nameTerms {
+			bestMatch = max(bestMatch, termMatch(queryTerm, nameTerm))
+		}
+		relevance += bestMatch
+	}
+
+	return relevance / float64(len(queryTerms))
+}
+
+func termMatch(queryTerm, nameTerm string) float64 {
+	queryRunes, nameRunes := []rune(queryTerm), []rune(nameTerm)
+
+	switch {
+	case queryTerm == nameTerm:
+		return 1
+	case strings.HasPrefix(nameTerm, queryTerm):
+		return 0.5 + 0.5*float64(len(queryRunes))/float64(len(nameRunes))
+	}
+
+	// a typo while the query is typed only shows in the beginning of the word
+	similarity := termSimilarity(queryRunes, nameRunes)
+	if len(nameRunes) > len(queryRunes) {
+		similarity = max(similarity, termSimilarity(queryRunes, nameRunes[:len(queryRunes)]))
+	}
+	if similarity < minFuzzySimilarity {
+		return 0
+	}
+
+	return fuzzyMatchWeight * similarity
+}
+
+// termSimilarity is 1 minus the edit distance of both terms relative to the length of the longer one. Swapped
+// neighboring characters count as a single edit, since they are a common typo.
+func termSimilarity(a, b []rune) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+
+	// the last three rows of the distance matrix
+	var beforePrevious, previous, current = make([]int, len(b)+1), make([]int, len(b)+1), make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			substitutionCost := 1
+			if a[i-1] == b[j-1] {
+				substitutionCost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+substitutionCost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				current[j] = min(current[j], beforePrevious[j-2]+1)
+			}
+		}
+		beforePrevious, previous, current = previous, current, beforePrevious
+	}
+
+	return 1 - float64(previous[len(b)])/float64(max(len(a), len(b)))
+}
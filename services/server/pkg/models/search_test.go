@@ -0,0 +1,37 @@
+package models_test
+
+import (
+	"spaces-p/pkg/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpaceNameTerms(t *testing.T) {
+	assert.Equal(t, []string{"thulestraße", "31"}, models.SpaceNameTerms("Thulestraße 31"))
+	assert.Equal(t, []string{"haus", "am", "park"}, models.SpaceNameTerms("  Haus am Park, Haus!"))
+	assert.Empty(t, models.SpaceNameTerms(" - "))
+
+	assert.Equal(t, []string{"par", "ark"}, models.TermTrigrams("park"))
+	assert.Equal(t, []string{"am"}, models.TermTrigrams("am"))
+	assert.Equal(t, []string{"taß", "aße"}, models.TermTrigrams("taße"))
+}
+
+func TestSpaceNameRelevance(t *testing.T) {
+	const name = "Haus am Park"
+
+	exact := models.SpaceNameRelevance([]string{"park"}, name)
+	prefix := models.SpaceNameRelevance([]string{"pa"}, name)
+	typo := models.SpaceNameRelevance([]string{"prak"}, name)
+	typedTypo := models.SpaceNameRelevance([]string{"hasu"}, "Hausbar")
+
+	assert.Equal(t, 1.0, exact)
+	assert.Equal(t, 0.75, prefix)
+	assert.Greater(t, prefix, typo)
+	assert.Greater(t, typo, 0.0)
+	assert.Greater(t, typedTypo, 0.0)
+
+	assert.Equal(t, 0.5, models.SpaceNameRelevance([]string{"park", "zoo"}, name))
+	assert.Zero(t, models.SpaceNameRelevance([]string{"zoo"}, name))
+	assert.Zero(t, models.SpaceNameRelevance(nil, name))
+}
@@ -0,0 +1,17 @@
+package models
+
+import "spaces-p/pkg/uuid"
+
+// SpaceSubscriberSession is a websocket session of a space subscriber, owned by the server instance the client is connected to
+type SpaceSubscriberSession struct {
+	ID         uuid.Uuid `json:"id"`
+	SpaceId    uuid.Uuid `json:"spaceId"`
+	UserId     UserUid   `json:"userId"`
+	InstanceId string    `json:"instanceId"`
+}
+
+// UserUpdatesFilter is sent by clients of the user's own channel to choose the spaces whose updates they get. Nil
+// SpaceIds let the updates of all the user's spaces through, updates addressed to the user are never filtered.
+type UserUpdatesFilter struct {
+	SpaceIds []uuid.Uuid `json:"spaceIds"`
+}
@@ -2,6 +2,7 @@ package models
 
 import (
 	"fmt"
+	"math"
 	"spaces-p/pkg/errors"
 	"spaces-p/pkg/uuid"
 	"strconv"
@@ -14,6 +15,9 @@ import (
 
 const (
 	MaxSpaceRadiusM = 100
+
+	earthRadiusM        = 6371008.8
+	earthCircumferenceM = 2 * math.Pi * earthRadiusM
 )
 
 type BaseSpace struct {
@@ -21,6 +25,25 @@ type BaseSpace struct {
 	ThemeColorHexaCode string   `json:"themeColorHexaCode" binding:"required,hexcolor"`
 	Radius             float64  `json:"radius" binding:"required,min=0,max=100"` // max MUST be same as MaxSpaceRadiusM constant
 	Location           Location `json:"location" binding:"required"`
+	// the category is one of the SpaceCategory constants, which the oneof values MUST be the same as
+	Category SpaceCategory `json:"category,omitempty" binding:"omitempty,oneof=food nightlife music sports outdoors arts education technology community travel other"`
+	Tags     []string      `json:"tags,omitempty" binding:"max=10,dive,required"` // max MUST be same as MaxSpaceTags constant
+	// spaces of events are time-bounded: they are hidden from the spaces nearby until StartsAt and become read-only at
+	// EndsAt
+	StartsAt *time.Time `json:"startsAt,omitempty"`
+	EndsAt   *time.Time `json:"endsAt,omitempty"`
+	// toplevel threads expire along with all their messages once they are older than the retention, 0 keeps them
+	MessageRetentionHours int `json:"messageRetentionHours,omitempty" binding:"min=0,max=8760"`
+}
+
+// HasStarted reports whether the space is visible to the users nearby, which spaces without a start time always are
+func (s *BaseSpace) HasStarted(now time.Time) bool {
+	return s.StartsAt == nil || !now.Before(*s.StartsAt)
+}
+
+// HasEnded reports whether the space has become read-only, which spaces without an end time never do
+func (s *BaseSpace) HasEnded(now time.Time) bool {
+	return s.EndsAt != nil && !now.Before(*s.EndsAt)
 }
 
 type Space struct {
@@ -28,6 +51,9 @@ type Space struct {
 	AdminId   UserUid   `json:"adminId" binding:"required"`
 	ID        uuid.Uuid `json:"id"`
 	CreatedAt time.Time `json:"createdAt"`
+	Hidden    bool      `json:"hidden"` // hidden spaces have been reported too often and wait for review
+	// archived spaces ended longer than the retention period ago, they can't be found by location anymore
+	Archived bool `json:"archived"`
 }
 
 type SpaceWithDistance struct {
@@ -35,6 +61,17 @@ type SpaceWithDistance struct {
 	Distance float64 `json:"distance"`
 }
 
+// SpaceTransition is a scheduled change of a time-bounded space: it opens at its start time, closes at its end time and
+// is archived once the retention period after its end time has passed
+type SpaceTransition struct {
+	SpaceId uuid.Uuid
+	At      time.Time
+}
+
+type MessageRetentionInput struct {
+	MessageRetentionHours int `json:"messageRetentionHours" binding:"min=0,max=8760"` // max MUST be same as in BaseSpace
+}
+
 type NewSpace struct {
 	BaseSpace
 	AdminId UserUid
@@ -100,5 +137,16 @@ func (loc *Location) GeoHash(precision int) string {
 	return geohash.Encode(loc.Lat, loc.Long)[:precision]
 }
 
+// DistanceM returns the great-circle distance in meters between both locations
+func (loc *Location) DistanceM(other Location) float64 {
+	lat1, lat2 := loc.Lat*math.Pi/180, other.Lat*math.Pi/180
+	deltaLat := lat2 - lat1
+	deltaLong := (other.Long - loc.Long) * math.Pi / 180
+
+	a := math.Sin(deltaLat/2)*math.Sin(deltaLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(deltaLong/2)*math.Sin(deltaLong/2)
+
+	return 2 * earthRadiusM * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
 type Radius float64
 type Distance float64
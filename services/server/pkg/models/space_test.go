@@ -0,0 +1,32 @@
+package models_test
+
+import (
+	"spaces-p/pkg/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBaseSpaceTimes(t *testing.T) {
+	now := time.Now()
+	before, after := now.Add(-time.Hour), now.Add(time.Hour)
+
+	t.Run("without times", func(t *testing.T) {
+		space := models.BaseSpace{}
+		assert.True(t, space.HasStarted(now))
+		assert.False(t, space.HasEnded(now))
+	})
+
+	t.Run("before start", func(t *testing.T) {
+		space := models.BaseSpace{StartsAt: &after}
+		assert.False(t, space.HasStarted(now))
+		assert.False(t, space.HasEnded(now))
+	})
+
+	t.Run("after end", func(t *testing.T) {
+		space := models.BaseSpace{StartsAt: &before, EndsAt: &now}
+		assert.True(t, space.HasStarted(now))
+		assert.True(t, space.HasEnded(now))
+	})
+}
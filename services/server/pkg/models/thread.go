@@ -14,6 +14,9 @@ type BaseThread struct {
 	MessagesCount int       `json:"messagesCount"`
 	SpaceId       uuid.Uuid `json:"spaceId"`
 	CreatedAt     time.Time `json:"createdAt"`
+	Hidden        bool      `json:"hidden"` // hidden threads have been reported too often and wait for review
+	// announcements are toplevel threads of space admins or moderators that have been delivered to every subscriber
+	Announcement bool `json:"announcement"`
 }
 
 type TopLevelThread struct {
@@ -29,6 +32,8 @@ type Thread struct {
 type ThreadWithMessages struct {
 	Thread
 	Messages []MessageWithChildThreadMessagesCount `json:"messages"`
+	// messages hidden from the user are skipped, so the next page starts at this offset rather than offset + count
+	NextMessagesOffset int64 `json:"nextMessagesOffset"`
 }
 
 type Sorting int
@@ -0,0 +1,133 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"spaces-p/pkg/errors"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/mmcloughlin/geohash"
+)
+
+const (
+	MaxTileZoom = 22
+	// below this zoom the spaces of a tile are summarized as a cluster
+	MinUnclusteredTileZoom = 14
+)
+
+// BoundingBox is the viewport of a map, it must not cross the antimeridian
+type BoundingBox struct {
+	MinLong float64 `validate:"min=-180,max=180"`
+	MinLat  float64 `validate:"min=-90,max=90"`
+	MaxLong float64 `validate:"min=-180,max=180,gtefield=MinLong"`
+	MaxLat  float64 `validate:"min=-90,max=90,gtefield=MinLat"`
+}
+
+// ParseString parses a bounding box in the form minLong,minLat,maxLong,maxLat
+func (box *BoundingBox) ParseString(str string) error {
+	const op errors.Op = "models.BoundingBox.ParseString"
+
+	parts := strings.Split(str, ",")
+	if len(parts) != 4 {
+		err := fmt.Errorf("invalid bounding box format: %s", str)
+		return errors.E(op, err)
+	}
+
+	var coordinates [4]float64
+	for i, part := range parts {
+		coordinate, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			err := fmt.Errorf("invalid bounding box coordinate: %s", part)
+			return errors.E(op, err)
+		}
+		coordinates[i] = coordinate
+	}
+
+	newBox := BoundingBox{MinLong: coordinates[0], MinLat: coordinates[1], MaxLong: coordinates[2], MaxLat: coordinates[3]}
+	if err := validator.New().Struct(newBox); err != nil {
+		return errors.E(op, err)
+	}
+
+	*box = newBox
+
+	return nil
+}
+
+func (box *BoundingBox) Center() Location {
+	return Location{Long: (box.MinLong + box.MaxLong) / 2, Lat: (box.MinLat + box.MaxLat) / 2}
+}
+
+// SizeM returns the width and height in meters of a box around the center that covers the bounding box. The width is
+// taken along the latitude closest to the equator, where the bounding box is the widest, and covers at most the whole
+// circumference.
+func (box *BoundingBox) SizeM() (float64, float64) {
+	var widestLat float64
+	if box.MinLat > 0 || box.MaxLat < 0 {
+		widestLat = math.Min(math.Abs(box.MinLat), math.Abs(box.MaxLat))
+	}
+
+	// the great-circle distance between both edges would take the short way around for spans above 180 degrees
+	width := (box.MaxLong - box.MinLong) / 360 * earthCircumferenceM * math.Cos(widestLat*math.Pi/180)
+	south, north := Location{Long: box.MinLong, Lat: box.MinLat}, Location{Long: box.MinLong, Lat: box.MaxLat}
+
+	return math.Min(width, earthCircumferenceM), south.DistanceM(north)
+}
+
+func (box *BoundingBox) Contains(loc Location) bool {
+	return loc.Long >= box.MinLong && loc.Long <= box.MaxLong && loc.Lat >= box.MinLat && loc.Lat <= box.MaxLat
+}
+
+// TileGeoHashPrecision returns the geohash precision whose cells are about the size of a map tile at the zoom
+func TileGeoHashPrecision(zoom int) int {
+	// every geohash character divides the cell by 32, which is about 2.5 zoom levels
+	return min(max(int(math.Ceil(float64(zoom)/2.5)), 1), 9)
+}
+
+// GeoHashCells returns the geohash cells of the precision that cover the bounding box, row by row from the south west.
+// It returns false instead if the bounding box is covered by more than maxCells cells.
+func (box *BoundingBox) GeoHashCells(precision, maxCells int) ([]string, bool) {
+	cell := geohash.BoundingBox(geohash.EncodeWithPrecision(box.MinLat, box.MinLong, uint(precision)))
+	cellHeight, cellWidth := cell.MaxLat-cell.MinLat, cell.MaxLng-cell.MinLng
+
+	rows := max(int(math.Ceil((box.MaxLat-cell.MinLat)/cellHeight)), 1)
+	columns := max(int(math.Ceil((box.MaxLong-cell.MinLng)/cellWidth)), 1)
+	if rows*columns > maxCells {
+		return nil, false
+	}
+
+	var cells = make([]string, 0, rows*columns)
+	for row := 0; row < rows; row++ {
+		for column := 0; column < columns; column++ {
+			// the center is safely inside the cell, unlike its edges
+			lat := cell.MinLat + (float64(row)+0.5)*cellHeight
+			long := cell.MinLng + (float64(column)+0.5)*cellWidth
+			cells = append(cells, geohash.EncodeWithPrecision(lat, long, uint(precision)))
+		}
+	}
+
+	return cells, true
+}
+
+func GeoHashCenter(geoHash string) Location {
+	lat, long := geohash.DecodeCenter(geoHash)
+
+	return Location{Long: long, Lat: lat}
+}
+
+// SpaceTile summarizes the spaces of a geohash cell. The spaces themselves are only part of the tile at zooms high
+// enough to show them, otherwise the tile is a cluster.
+type SpaceTile struct {
+	GeoHash  string   `json:"geohash"`
+	Count    int      `json:"count"`
+	Location Location `json:"location"` // the center of the tile's spaces, or of the tile itself for clusters
+	Spaces   []Space  `json:"spaces,omitempty"`
+}
+
+type SpaceTiles struct {
+	Precision int         `json:"precision"`
+	Clustered bool        `json:"clustered"`
+	Truncated bool        `json:"truncated"` // the bounding box held more spaces than could be shown
+	Tiles     []SpaceTile `json:"tiles"`
+}
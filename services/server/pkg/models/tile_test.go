@@ -0,0 +1,83 @@
+package models_test
+
+import (
+	"spaces-p/pkg/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBoundingBoxParseString(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		var box models.BoundingBox
+		require.NoError(t, box.ParseString("13.3,52.4,13.5,52.6"))
+
+		assert.Equal(t, models.BoundingBox{MinLong: 13.3, MinLat: 52.4, MaxLong: 13.5, MaxLat: 52.6}, box)
+		assert.True(t, box.Contains(models.Location{Long: 13.4, Lat: 52.5}))
+		assert.False(t, box.Contains(models.Location{Long: 13.6, Lat: 52.5}))
+	})
+
+	for name, str := range map[string]string{
+		"too few coordinates":  "13.3,52.4,13.5",
+		"not a number":         "13.3,north,13.5,52.6",
+		"out of range":         "13.3,52.4,190,52.6",
+		"crosses antimeridian": "170,52.4,-170,52.6",
+		"min above max":        "13.3,52.6,13.5,52.4",
+	} {
+		t.Run(name, func(t *testing.T) {
+			var box models.BoundingBox
+			assert.Error(t, box.ParseString(str))
+		})
+	}
+}
+
+func TestTileGeoHashPrecision(t *testing.T) {
+	assert.Equal(t, 1, models.TileGeoHashPrecision(0))
+	assert.Equal(t, 4, models.TileGeoHashPrecision(10))
+	assert.Equal(t, 9, models.TileGeoHashPrecision(models.MaxTileZoom))
+}
+
+func TestBoundingBoxSizeM(t *testing.T) {
+	var world models.BoundingBox
+	require.NoError(t, world.ParseString("-180,-85,180,85"))
+	width, height := world.SizeM()
+	assert.InDelta(t, 40_030_000, width, 10_000)
+	assert.InDelta(t, 18_900_000, height, 10_000)
+
+	// wider than half the world, which the great-circle distance between the edges would take the short way around
+	var box models.BoundingBox
+	require.NoError(t, box.ParseString("-170,-60,170,60"))
+	width, _ = box.SizeM()
+	assert.InDelta(t, 37_800_000, width, 10_000)
+
+	var berlin models.BoundingBox
+	require.NoError(t, berlin.ParseString("13.3,52.4,13.5,52.6"))
+	width, height = berlin.SizeM()
+	assert.InDelta(t, 13_570, width, 10)
+	assert.InDelta(t, 22_240, height, 10)
+}
+
+func TestBoundingBoxGeoHashCells(t *testing.T) {
+	var world models.BoundingBox
+	require.NoError(t, world.ParseString("-180,-85,180,85"))
+	cells, ok := world.GeoHashCells(1, 1000)
+	require.True(t, ok)
+	assert.Len(t, cells, 32)
+
+	_, ok = world.GeoHashCells(3, 1000)
+	assert.False(t, ok)
+
+	var berlin models.BoundingBox
+	require.NoError(t, berlin.ParseString("13.41,52.55,13.43,52.56"))
+	cells, ok = berlin.GeoHashCells(4, 1000)
+	require.True(t, ok)
+	assert.Equal(t, []string{"u33d", "u33e"}, cells)
+
+	cells, ok = berlin.GeoHashCells(6, 1000)
+	require.True(t, ok)
+	for _, cell := range cells {
+		assert.Len(t, cell, 6)
+	}
+	assert.Contains(t, cells, (&models.Location{Long: 13.42, Lat: 52.555}).GeoHash(6))
+}
@@ -0,0 +1,32 @@
+package models
+
+const (
+	recentMessageWeight    = 3
+	activeSubscriberWeight = 2
+	newSubscriberWeight    = 1
+
+	// the distance at which the activity of a space counts half
+	trendingDistanceScaleM = 500
+)
+
+// SpaceActivity is the recent activity of a space that its trending score is based on
+type SpaceActivity struct {
+	RecentMessagesCount    int64 `json:"recentMessagesCount"`
+	ActiveSubscribersCount int64 `json:"activeSubscribersCount"`
+	NewSubscribersCount    int64 `json:"newSubscribersCount"`
+}
+
+type TrendingSpace struct {
+	SpaceWithDistance
+	Activity      SpaceActivity `json:"activity"`
+	TrendingScore float64       `json:"trendingScore"`
+}
+
+// TrendingScore weighs the activity of a space, messages the most, and discounts it by the distance in meters
+func (activity SpaceActivity) TrendingScore(distanceM float64) float64 {
+	var score = float64(recentMessageWeight*activity.RecentMessagesCount +
+		activeSubscriberWeight*activity.ActiveSubscribersCount +
+		newSubscriberWeight*activity.NewSubscribersCount)
+
+	return score / (1 + distanceM/trendingDistanceScaleM)
+}
@@ -0,0 +1,20 @@
+package models_test
+
+import (
+	"spaces-p/pkg/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpaceActivityTrendingScore(t *testing.T) {
+	var activity = models.SpaceActivity{RecentMessagesCount: 2, ActiveSubscribersCount: 3, NewSubscribersCount: 4}
+
+	assert.Equal(t, 16.0, activity.TrendingScore(0))
+	assert.Equal(t, 8.0, activity.TrendingScore(500))
+	assert.Zero(t, models.SpaceActivity{}.TrendingScore(0))
+
+	var busy = models.SpaceActivity{RecentMessagesCount: 10}
+	var quiet = models.SpaceActivity{NewSubscribersCount: 1}
+	assert.Greater(t, busy.TrendingScore(1000), quiet.TrendingScore(0))
+}
@@ -16,6 +16,7 @@ type BaseUser struct {
 type User struct {
 	BaseUser
 	IsSignedUp bool `json:"isSignedUp"`
+	IsBanned   bool `json:"isBanned"`
 }
 
 type NewUser BaseUser
@@ -0,0 +1,142 @@
+package moderation
+
+import (
+	"fmt"
+	"spaces-p/pkg/errors"
+	"spaces-p/pkg/models"
+	"spaces-p/pkg/utils"
+	"strings"
+)
+
+type ClassifierKind string
+
+const (
+	NoClassifier ClassifierKind = "none"
+	// scores texts by the terms of FakeClassifierScores, only meant for local development and tests
+	FakeClassifierKind ClassifierKind = "fake"
+)
+
+// FakeClassifierScores are the terms the fake classifier reacts to
+var FakeClassifierScores = map[string]float64{
+	"buy followers": 0.6,
+	"free crypto":   0.95,
+}
+
+type Config struct {
+	BlockedWords   []string // messages containing one of the words are rejected
+	HeldWords      []string // messages containing one of the words are held for review
+	BlockedPattern string   // messages matching the regular expression are rejected
+	BlockedDomains []string // messages linking to one of the domains or their subdomains are rejected
+
+	Classifier      ClassifierKind
+	HoldThreshold   float64 // messages with a classifier score of at least HoldThreshold are held for review
+	RejectThreshold float64 // messages with a classifier score of at least RejectThreshold are rejected
+
+	ReportsAutoHideThreshold int              // reported messages, threads and spaces are hidden after this many reports, 0 disables hiding
+	Moderators               []models.UserUid // uids of the users who review the global report queue
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Classifier:      NoClassifier,
+		HoldThreshold:   0.5,
+		RejectThreshold: 0.9,
+
+		ReportsAutoHideThreshold: 5,
+	}
+}
+
+// NewConfigFromEnv builds a Config from the MODERATION_*, REPORTS_AUTO_HIDE_THRESHOLD and MODERATOR_USER_IDS environment
+// variables. Lists are comma separated.
+// Variables that are not set fall back to the values of DefaultConfig.
+func NewConfigFromEnv(getenv func(string) (string, error)) (Config, error) {
+	const op errors.Op = "moderation.NewConfigFromEnv"
+	var cfg = DefaultConfig()
+	var err error
+
+	cfg.BlockedWords = splitList(utils.LookupEnv(getenv, "MODERATION_BLOCKED_WORDS"))
+	cfg.HeldWords = splitList(utils.LookupEnv(getenv, "MODERATION_HELD_WORDS"))
+	cfg.BlockedPattern = utils.LookupEnv(getenv, "MODERATION_BLOCKED_PATTERN")
+	cfg.BlockedDomains = splitList(utils.LookupEnv(getenv, "MODERATION_BLOCKED_DOMAINS"))
+
+	if classifier := utils.LookupEnv(getenv, "MODERATION_CLASSIFIER"); classifier != "" {
+		cfg.Classifier = ClassifierKind(classifier)
+	}
+	if cfg.HoldThreshold, err = utils.ParseFloatEnv(getenv, "MODERATION_HOLD_THRESHOLD", cfg.HoldThreshold); err != nil {
+		return Config{}, errors.E(op, err)
+	}
+	if cfg.RejectThreshold, err = utils.ParseFloatEnv(getenv, "MODERATION_REJECT_THRESHOLD", cfg.RejectThreshold); err != nil {
+		return Config{}, errors.E(op, err)
+	}
+
+	if cfg.ReportsAutoHideThreshold, err = utils.ParseIntEnv(getenv, "REPORTS_AUTO_HIDE_THRESHOLD", cfg.ReportsAutoHideThreshold); err != nil {
+		return Config{}, errors.E(op, err)
+	}
+	for _, moderatorId := range splitList(utils.LookupEnv(getenv, "MODERATOR_USER_IDS")) {
+		cfg.Moderators = append(cfg.Moderators, models.UserUid(moderatorId))
+	}
+
+	if err := cfg.validate(); err != nil {
+		return Config{}, errors.E(op, err)
+	}
+
+	return cfg, nil
+}
+
+func (cfg Config) validate() error {
+	switch cfg.Classifier {
+	case NoClassifier, FakeClassifierKind:
+	default:
+		return fmt.Errorf("unknown moderation classifier: %s", cfg.Classifier)
+	}
+
+	if cfg.HoldThreshold > cfg.RejectThreshold {
+		return fmt.Errorf("moderation hold threshold %v must not be greater than the reject threshold %v", cfg.HoldThreshold, cfg.RejectThreshold)
+	}
+
+	if cfg.ReportsAutoHideThreshold < 0 {
+		return fmt.Errorf("reports auto hide threshold must not be negative: %d", cfg.ReportsAutoHideThreshold)
+	}
+
+	return nil
+}
+
+// NewPipelineFromConfig returns a pipeline with a filter for every configured rule. The cheap local filters run
+// before the classifier.
+func NewPipelineFromConfig(cfg Config) (*Pipeline, error) {
+	const op errors.Op = "moderation.NewPipelineFromConfig"
+	var filters []Filter
+
+	if len(cfg.BlockedWords) > 0 {
+		filters = append(filters, NewWordListFilter("blocked_words", cfg.BlockedWords, Reject))
+	}
+	if cfg.BlockedPattern != "" {
+		filter, err := NewPatternFilter("blocked_pattern", cfg.BlockedPattern, Reject)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+		filters = append(filters, filter)
+	}
+	if len(cfg.BlockedDomains) > 0 {
+		filters = append(filters, NewLinkBlocklistFilter(cfg.BlockedDomains))
+	}
+	if len(cfg.HeldWords) > 0 {
+		filters = append(filters, NewWordListFilter("held_words", cfg.HeldWords, Hold))
+	}
+	if cfg.Classifier == FakeClassifierKind {
+		filters = append(filters, NewClassifierFilter(NewFakeClassifier(FakeClassifierScores), cfg.HoldThreshold, cfg.RejectThreshold))
+	}
+
+	return NewPipeline(filters...), nil
+}
+
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
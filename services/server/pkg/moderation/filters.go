@@ -0,0 +1,148 @@
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// PatternFilter matches the text of a message against a regular expression
+type PatternFilter struct {
+	name    string
+	pattern *regexp.Regexp
+	verdict Verdict
+}
+
+// NewWordListFilter returns a filter matching any of words case-insensitively. Words only match as a whole,
+// so blocking "ass" does not block "class".
+func NewWordListFilter(name string, words []string, verdict Verdict) *PatternFilter {
+	var quotedWords = make([]string, len(words))
+	for i, word := range words {
+		quotedWords[i] = regexp.QuoteMeta(word)
+	}
+
+	pattern := regexp.MustCompile(`(?i)\b(?:` + strings.Join(quotedWords, "|") + `)\b`)
+
+	return &PatternFilter{name, pattern, verdict}
+}
+
+func NewPatternFilter(name, pattern string, verdict Verdict) (*PatternFilter, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid moderation pattern: %w", err)
+	}
+
+	return &PatternFilter{name, re, verdict}, nil
+}
+
+func (f *PatternFilter) Name() string {
+	return f.name
+}
+
+func (f *PatternFilter) Check(ctx context.Context, content Content) (Decision, error) {
+	match := f.pattern.FindString(content.Text)
+	if match == "" {
+		return Decision{Verdict: Allow}, nil
+	}
+
+	return Decision{Verdict: f.verdict, Reason: fmt.Sprintf("message contains %q", match), Filter: f.name}, nil
+}
+
+// links with or without scheme, e.g. https://example.com/path or www.example.com
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://)?((?:[a-z0-9](?:[a-z0-9-]*[a-z0-9])?\.)+[a-z]{2,})\b`)
+
+// LinkBlocklistFilter rejects messages linking to blocked domains or their subdomains
+type LinkBlocklistFilter struct {
+	domains []string
+}
+
+func NewLinkBlocklistFilter(domains []string) *LinkBlocklistFilter {
+	var normalizedDomains = make([]string, len(domains))
+	for i, domain := range domains {
+		normalizedDomains[i] = strings.TrimPrefix(strings.ToLower(domain), ".")
+	}
+
+	return &LinkBlocklistFilter{normalizedDomains}
+}
+
+func (f *LinkBlocklistFilter) Name() string {
+	return "link_blocklist"
+}
+
+func (f *LinkBlocklistFilter) Check(ctx context.Context, content Content) (Decision, error) {
+	for _, match := range linkPattern.FindAllStringSubmatch(content.Text, -1) {
+		host := strings.ToLower(match[1])
+
+		for _, domain := range f.domains {
+			if host == domain || strings.HasSuffix(host, "."+domain) {
+				return Decision{Verdict: Reject, Reason: fmt.Sprintf("links to %s are not allowed", domain), Filter: f.Name()}, nil
+			}
+		}
+	}
+
+	return Decision{Verdict: Allow}, nil
+}
+
+// Classifier rates how likely a text is abusive, from 0 (harmless) to 1 (abusive)
+type Classifier interface {
+	Classify(ctx context.Context, text string) (float64, error)
+}
+
+// ClassifierFilter holds or rejects messages based on the score of a Classifier
+type ClassifierFilter struct {
+	classifier      Classifier
+	holdThreshold   float64
+	rejectThreshold float64
+}
+
+func NewClassifierFilter(classifier Classifier, holdThreshold, rejectThreshold float64) *ClassifierFilter {
+	return &ClassifierFilter{classifier, holdThreshold, rejectThreshold}
+}
+
+func (f *ClassifierFilter) Name() string {
+	return "classifier"
+}
+
+// Check holds the message when the classifier fails, so an outage of an external classifier neither blocks
+// all messages nor lets them through unchecked
+func (f *ClassifierFilter) Check(ctx context.Context, content Content) (Decision, error) {
+	score, err := f.classifier.Classify(ctx, content.Text)
+	switch {
+	case err != nil:
+		return Decision{Verdict: Hold, Reason: fmt.Sprintf("classifier failed: %s", err), Filter: f.Name()}, nil
+	case score >= f.rejectThreshold:
+		return Decision{Verdict: Reject, Reason: "message was classified as abusive", Filter: f.Name()}, nil
+	case score >= f.holdThreshold:
+		return Decision{Verdict: Hold, Reason: fmt.Sprintf("abuse score %.2f", score), Filter: f.Name()}, nil
+	default:
+		return Decision{Verdict: Allow}, nil
+	}
+}
+
+// FakeClassifier is a local stand-in for an external classification service. A text gets the highest score of
+// the terms it contains, or 0 if it contains none of them.
+type FakeClassifier struct {
+	scores map[string]float64
+}
+
+func NewFakeClassifier(scores map[string]float64) *FakeClassifier {
+	var lowerCaseScores = make(map[string]float64, len(scores))
+	for term, score := range scores {
+		lowerCaseScores[strings.ToLower(term)] = score
+	}
+
+	return &FakeClassifier{lowerCaseScores}
+}
+
+func (c *FakeClassifier) Classify(ctx context.Context, text string) (float64, error) {
+	var maxScore float64
+	var lowerCaseText = strings.ToLower(text)
+	for term, score := range c.scores {
+		if strings.Contains(lowerCaseText, term) && score > maxScore {
+			maxScore = score
+		}
+	}
+
+	return maxScore, nil
+}
@@ -0,0 +1,79 @@
+package moderation
+
+import (
+	"context"
+	"spaces-p/pkg/errors"
+	"spaces-p/pkg/models"
+	"spaces-p/pkg/tracing"
+	"spaces-p/pkg/uuid"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Verdict is the outcome of moderating a message
+type Verdict string
+
+const (
+	// the message is stored and published right away
+	Allow Verdict = "allow"
+	// the message is refused, the sender gets the decision's reason
+	Reject Verdict = "reject"
+	// the message is put into the space's review queue until the space admin approves or rejects it
+	Hold Verdict = "hold"
+)
+
+type Decision struct {
+	Verdict Verdict
+	Reason  string
+	Filter  string // name of the filter that made the decision, empty for allowed messages
+}
+
+// Content is the part of a new message that is moderated
+type Content struct {
+	SpaceId  uuid.Uuid
+	SenderId models.UserUid
+	Text     string
+}
+
+type Filter interface {
+	Name() string
+	Check(ctx context.Context, content Content) (Decision, error)
+}
+
+// Pipeline runs a chain of filters on new messages
+type Pipeline struct {
+	filters []Filter
+}
+
+// NewPipeline returns a pipeline running filters in the given order. A pipeline without filters allows every message.
+func NewPipeline(filters ...Filter) *Pipeline {
+	return &Pipeline{filters}
+}
+
+// Moderate returns the decision of the first filter that rejects the content. If no filter rejects it, the decision
+// of the first filter that holds it is returned, so a cheap hold never skips a later reject.
+func (p *Pipeline) Moderate(ctx context.Context, content Content) (Decision, error) {
+	const op errors.Op = "moderation.Pipeline.Moderate"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	var decision = Decision{Verdict: Allow}
+	for _, filter := range p.filters {
+		filterDecision, err := filter.Check(ctx, content)
+		if err != nil {
+			return Decision{}, errors.E(op, err)
+		}
+
+		if filterDecision.Verdict == Reject {
+			decision = filterDecision
+			break
+		}
+		if filterDecision.Verdict == Hold && decision.Verdict == Allow {
+			decision = filterDecision
+		}
+	}
+
+	span.SetAttributes(attribute.String("moderation.verdict", string(decision.Verdict)), attribute.String("moderation.filter", decision.Filter))
+
+	return decision, nil
+}
@@ -0,0 +1,69 @@
+package moderation_test
+
+import (
+	"context"
+	"errors"
+	"spaces-p/pkg/moderation"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type failingClassifier struct{}
+
+func (failingClassifier) Classify(ctx context.Context, text string) (float64, error) {
+	return 0, errors.New("service unavailable")
+}
+
+func TestPipeline(t *testing.T) {
+	blockedPattern, err := moderation.NewPatternFilter("blocked_pattern", `\d{4}-\d{4}-\d{4}-\d{4}`, moderation.Reject)
+	require.NoError(t, err)
+
+	pipeline := moderation.NewPipeline(
+		moderation.NewWordListFilter("held_words", []string{"meetup"}, moderation.Hold),
+		moderation.NewWordListFilter("blocked_words", []string{"idiot"}, moderation.Reject),
+		blockedPattern,
+		moderation.NewLinkBlocklistFilter([]string{"spam.example"}),
+		moderation.NewClassifierFilter(moderation.NewFakeClassifier(map[string]float64{"maybe spam": 0.6, "surely spam": 0.95}), 0.5, 0.9),
+	)
+
+	tests := []struct {
+		text        string
+		wantVerdict moderation.Verdict
+		wantFilter  string
+	}{
+		{text: "hello everyone", wantVerdict: moderation.Allow},
+		{text: "you IDIOT", wantVerdict: moderation.Reject, wantFilter: "blocked_words"},
+		{text: "idiotic is not a blocked word", wantVerdict: moderation.Allow},
+		{text: "my card is 1234-5678-9012-3456", wantVerdict: moderation.Reject, wantFilter: "blocked_pattern"},
+		{text: "see https://www.spam.example/offer", wantVerdict: moderation.Reject, wantFilter: "link_blocklist"},
+		{text: "see nospam.example", wantVerdict: moderation.Allow},
+		{text: "maybe spam", wantVerdict: moderation.Hold, wantFilter: "classifier"},
+		{text: "surely spam", wantVerdict: moderation.Reject, wantFilter: "classifier"},
+		{text: "meetup tonight", wantVerdict: moderation.Hold, wantFilter: "held_words"},
+		// a later reject wins over an earlier hold
+		{text: "meetup tonight, idiot", wantVerdict: moderation.Reject, wantFilter: "blocked_words"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			decision, err := pipeline.Moderate(context.Background(), moderation.Content{Text: tt.text})
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.wantVerdict, decision.Verdict)
+			assert.Equal(t, tt.wantFilter, decision.Filter)
+			if tt.wantVerdict != moderation.Allow {
+				assert.NotEmpty(t, decision.Reason)
+			}
+		})
+	}
+
+	t.Run("failing classifier holds message", func(t *testing.T) {
+		pipeline := moderation.NewPipeline(moderation.NewClassifierFilter(failingClassifier{}, 0.5, 0.9))
+
+		decision, err := pipeline.Moderate(context.Background(), moderation.Content{Text: "hello"})
+		require.NoError(t, err)
+		assert.Equal(t, moderation.Hold, decision.Verdict)
+	})
+}
@@ -0,0 +1,126 @@
+package redis
+
+import (
+	"net"
+	"spaces-p/pkg/errors"
+	"spaces-p/pkg/utils"
+	"strings"
+	"time"
+)
+
+type Mode string
+
+const (
+	StandaloneMode Mode = "standalone"
+	SentinelMode   Mode = "sentinel"
+	ClusterMode    Mode = "cluster"
+)
+
+type TLSConfig struct {
+	Enabled            bool
+	InsecureSkipVerify bool
+	ServerName         string
+	CAFile             string // PEM encoded CA certificate(s); system pool is used when empty
+}
+
+type Config struct {
+	Mode Mode
+	// standalone: the first address is used, sentinel: sentinel addresses, cluster: seed node addresses
+	Addrs      []string
+	MasterName string // only for sentinel mode
+
+	// ACL user and password
+	Username string
+	Password string
+
+	// only for sentinel mode, credentials of the sentinel nodes themselves
+	SentinelUsername string
+	SentinelPassword string
+
+	DB  int // must be 0 in cluster mode
+	TLS TLSConfig
+
+	PoolSize     int
+	MinIdleConns int
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	PoolTimeout  time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Mode:         StandaloneMode,
+		DialTimeout:  5 * time.Second,
+		ReadTimeout:  20 * time.Second,
+		WriteTimeout: 20 * time.Second,
+	}
+}
+
+// NewConfigFromEnv builds a Config from the REDIS_* environment variables. Only REDIS_ADDRS, or alternatively
+// REDIS_HOST and REDIS_PORT, are required. All other variables fall back to the values of DefaultConfig.
+func NewConfigFromEnv(getenv func(string) (string, error)) (Config, error) {
+	const op errors.Op = "redis.NewConfigFromEnv"
+	var cfg = DefaultConfig()
+	var err error
+
+	if mode := utils.LookupEnv(getenv, "REDIS_MODE"); mode != "" {
+		cfg.Mode = Mode(mode)
+	}
+
+	if addrs := utils.LookupEnv(getenv, "REDIS_ADDRS"); addrs != "" {
+		for _, addr := range strings.Split(addrs, ",") {
+			cfg.Addrs = append(cfg.Addrs, strings.TrimSpace(addr))
+		}
+	} else {
+		redisHost, err := getenv("REDIS_HOST")
+		if err != nil {
+			return Config{}, errors.E(op, err)
+		}
+
+		redisPort, err := getenv("REDIS_PORT")
+		if err != nil {
+			return Config{}, errors.E(op, err)
+		}
+
+		cfg.Addrs = []string{net.JoinHostPort(redisHost, redisPort)}
+	}
+
+	cfg.MasterName = utils.LookupEnv(getenv, "REDIS_MASTER_NAME")
+	cfg.Username = utils.LookupEnv(getenv, "REDIS_USERNAME")
+	cfg.Password = utils.LookupEnv(getenv, "REDIS_PASSWORD")
+	cfg.SentinelUsername = utils.LookupEnv(getenv, "REDIS_SENTINEL_USERNAME")
+	cfg.SentinelPassword = utils.LookupEnv(getenv, "REDIS_SENTINEL_PASSWORD")
+	cfg.TLS.ServerName = utils.LookupEnv(getenv, "REDIS_TLS_SERVER_NAME")
+	cfg.TLS.CAFile = utils.LookupEnv(getenv, "REDIS_TLS_CA_FILE")
+
+	if cfg.DB, err = utils.ParseIntEnv(getenv, "REDIS_DB", cfg.DB); err != nil {
+		return Config{}, errors.E(op, err)
+	}
+	if cfg.TLS.Enabled, err = utils.ParseBoolEnv(getenv, "REDIS_TLS", cfg.TLS.Enabled); err != nil {
+		return Config{}, errors.E(op, err)
+	}
+	if cfg.TLS.InsecureSkipVerify, err = utils.ParseBoolEnv(getenv, "REDIS_TLS_INSECURE_SKIP_VERIFY", cfg.TLS.InsecureSkipVerify); err != nil {
+		return Config{}, errors.E(op, err)
+	}
+	if cfg.PoolSize, err = utils.ParseIntEnv(getenv, "REDIS_POOL_SIZE", cfg.PoolSize); err != nil {
+		return Config{}, errors.E(op, err)
+	}
+	if cfg.MinIdleConns, err = utils.ParseIntEnv(getenv, "REDIS_MIN_IDLE_CONNS", cfg.MinIdleConns); err != nil {
+		return Config{}, errors.E(op, err)
+	}
+	if cfg.DialTimeout, err = utils.ParseDurationEnv(getenv, "REDIS_DIAL_TIMEOUT", cfg.DialTimeout); err != nil {
+		return Config{}, errors.E(op, err)
+	}
+	if cfg.ReadTimeout, err = utils.ParseDurationEnv(getenv, "REDIS_READ_TIMEOUT", cfg.ReadTimeout); err != nil {
+		return Config{}, errors.E(op, err)
+	}
+	if cfg.WriteTimeout, err = utils.ParseDurationEnv(getenv, "REDIS_WRITE_TIMEOUT", cfg.WriteTimeout); err != nil {
+		return Config{}, errors.E(op, err)
+	}
+	if cfg.PoolTimeout, err = utils.ParseDurationEnv(getenv, "REDIS_POOL_TIMEOUT", cfg.PoolTimeout); err != nil {
+		return Config{}, errors.E(op, err)
+	}
+
+	return cfg, nil
+}
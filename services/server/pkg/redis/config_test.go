@@ -0,0 +1,104 @@
+package redis_test
+
+import (
+	"fmt"
+	"spaces-p/pkg/redis"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func envGetter(env map[string]string) func(string) (string, error) {
+	return func(key string) (string, error) {
+		val, ok := env[key]
+		if !ok || val == "" {
+			return "", fmt.Errorf("no value found for key: %s", key)
+		}
+
+		return val, nil
+	}
+}
+
+func TestNewConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    redis.Config
+		wantErr bool
+	}{
+		{
+			name: "standalone from host and port",
+			env:  map[string]string{"REDIS_HOST": "localhost", "REDIS_PORT": "6379"},
+			want: func() redis.Config {
+				cfg := redis.DefaultConfig()
+				cfg.Addrs = []string{"localhost:6379"}
+				return cfg
+			}(),
+		},
+		{
+			name: "sentinel with acl user, tls and pool settings",
+			env: map[string]string{
+				"REDIS_MODE":           "sentinel",
+				"REDIS_ADDRS":          "sentinel-1:26379, sentinel-2:26379",
+				"REDIS_MASTER_NAME":    "mymaster",
+				"REDIS_USERNAME":       "spaces",
+				"REDIS_PASSWORD":       "secret",
+				"REDIS_TLS":            "true",
+				"REDIS_POOL_SIZE":      "50",
+				"REDIS_MIN_IDLE_CONNS": "5",
+				"REDIS_READ_TIMEOUT":   "3s",
+			},
+			want: func() redis.Config {
+				cfg := redis.DefaultConfig()
+				cfg.Mode = redis.SentinelMode
+				cfg.Addrs = []string{"sentinel-1:26379", "sentinel-2:26379"}
+				cfg.MasterName = "mymaster"
+				cfg.Username = "spaces"
+				cfg.Password = "secret"
+				cfg.TLS.Enabled = true
+				cfg.PoolSize = 50
+				cfg.MinIdleConns = 5
+				cfg.ReadTimeout = 3 * time.Second
+				return cfg
+			}(),
+		},
+		{
+			name:    "missing address",
+			env:     map[string]string{"REDIS_MODE": "cluster"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid timeout",
+			env:     map[string]string{"REDIS_ADDRS": "localhost:6379", "REDIS_DIAL_TIMEOUT": "5"},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg, err := redis.NewConfigFromEnv(envGetter(test.env))
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.want, cfg)
+		})
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	cfg := redis.DefaultConfig()
+	cfg.Mode = redis.SentinelMode
+	cfg.Addrs = []string{"localhost:26379"}
+
+	_, err := redis.NewClient(cfg)
+	assert.Error(t, err, "sentinel mode without master name")
+
+	cfg.Mode = redis.ClusterMode
+	cfg.DB = 1
+	_, err = redis.NewClient(cfg)
+	assert.Error(t, err, "cluster mode with non zero db")
+}
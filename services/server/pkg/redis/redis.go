@@ -1,33 +1,134 @@
 package redis
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"spaces-p/pkg/errors"
 	"sync"
-	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
-const (
-	redisPassword = ""
-	redisDbname   = 0
-)
-
 var (
-	redisClient *redis.Client
-	once        sync.Once
+	redisClient    redis.UniversalClient
+	redisClientErr error
+	once           sync.Once
 )
 
-// TODO: is this logical passing in arguments with the singleton pattern?
-func GetRedisClient(redisHost, redisPort string) *redis.Client {
+// GetRedisClient returns the process wide redis client. The client is created from cfg on the first call,
+// subsequent calls return the same client and ignore cfg.
+func GetRedisClient(cfg Config) (redis.UniversalClient, error) {
 	once.Do(func() {
-		redisClient = redis.NewClient(&redis.Options{
-			Addr:         redisHost + ":" + redisPort,
-			Password:     redisPassword,
-			DB:           redisDbname,
-			ReadTimeout:  20 * time.Second,
-			WriteTimeout: 20 * time.Second,
-		})
+		redisClient, redisClientErr = NewClient(cfg)
 	})
 
-	return redisClient
+	return redisClient, redisClientErr
+}
+
+// NewClient creates a standalone, sentinel (failover) or cluster client depending on cfg.Mode
+func NewClient(cfg Config) (redis.UniversalClient, error) {
+	const op errors.Op = "redis.NewClient"
+
+	if len(cfg.Addrs) == 0 {
+		err := errors.New("at least one redis address must be specified")
+		return nil, errors.E(op, err)
+	}
+
+	tlsConfig, err := cfg.TLS.build()
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	switch cfg.Mode {
+	case StandaloneMode, "":
+		return redis.NewClient(&redis.Options{
+			Addr:         cfg.Addrs[0],
+			Username:     cfg.Username,
+			Password:     cfg.Password,
+			DB:           cfg.DB,
+			TLSConfig:    tlsConfig,
+			PoolSize:     cfg.PoolSize,
+			MinIdleConns: cfg.MinIdleConns,
+			DialTimeout:  cfg.DialTimeout,
+			ReadTimeout:  cfg.ReadTimeout,
+			WriteTimeout: cfg.WriteTimeout,
+			PoolTimeout:  cfg.PoolTimeout,
+		}), nil
+	case SentinelMode:
+		if cfg.MasterName == "" {
+			err := errors.New("a master name must be specified in sentinel mode")
+			return nil, errors.E(op, err)
+		}
+
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:       cfg.MasterName,
+			SentinelAddrs:    cfg.Addrs,
+			SentinelUsername: cfg.SentinelUsername,
+			SentinelPassword: cfg.SentinelPassword,
+			Username:         cfg.Username,
+			Password:         cfg.Password,
+			DB:               cfg.DB,
+			TLSConfig:        tlsConfig,
+			PoolSize:         cfg.PoolSize,
+			MinIdleConns:     cfg.MinIdleConns,
+			DialTimeout:      cfg.DialTimeout,
+			ReadTimeout:      cfg.ReadTimeout,
+			WriteTimeout:     cfg.WriteTimeout,
+			PoolTimeout:      cfg.PoolTimeout,
+		}), nil
+	case ClusterMode:
+		if cfg.DB != 0 {
+			err := errors.New("redis cluster only supports database 0")
+			return nil, errors.E(op, err)
+		}
+
+		return redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:        cfg.Addrs,
+			Username:     cfg.Username,
+			Password:     cfg.Password,
+			TLSConfig:    tlsConfig,
+			PoolSize:     cfg.PoolSize,
+			MinIdleConns: cfg.MinIdleConns,
+			DialTimeout:  cfg.DialTimeout,
+			ReadTimeout:  cfg.ReadTimeout,
+			WriteTimeout: cfg.WriteTimeout,
+			PoolTimeout:  cfg.PoolTimeout,
+		}), nil
+	default:
+		err := fmt.Errorf("invalid redis mode: %s", cfg.Mode)
+		return nil, errors.E(op, err)
+	}
+}
+
+// build returns nil when TLS is disabled
+func (tc TLSConfig) build() (*tls.Config, error) {
+	const op errors.Op = "redis.TLSConfig.build"
+
+	if !tc.Enabled {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         tc.ServerName,
+		InsecureSkipVerify: tc.InsecureSkipVerify,
+	}
+
+	if tc.CAFile != "" {
+		caCert, err := os.ReadFile(tc.CAFile)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+
+		certPool := x509.NewCertPool()
+		if !certPool.AppendCertsFromPEM(caCert) {
+			err := fmt.Errorf("no valid certificates found in %s", tc.CAFile)
+			return nil, errors.E(op, err)
+		}
+		tlsConfig.RootCAs = certPool
+	}
+
+	return tlsConfig, nil
 }
@@ -9,6 +9,7 @@ import (
 	"net/url"
 	"spaces-p/pkg/errors"
 	"spaces-p/pkg/models"
+	"spaces-p/pkg/tracing"
 	"spaces-p/pkg/utils"
 	"strconv"
 	"strings"
@@ -40,6 +41,8 @@ type ResponseBody struct {
 
 func (gcr *GoogleGeocodeRepo) GetAddress(ctx context.Context, location models.Location) (*models.Address, error) {
 	const op errors.Op = "googlegeocode.GoogleGeocodeRepo.GetAddress"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
 
 	url, err := gcr.constructReverseGeoCodeUrl(location)
 	if err != nil {
@@ -0,0 +1,119 @@
+package localblobstore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"spaces-p/pkg/common"
+	"spaces-p/pkg/errors"
+	"spaces-p/pkg/tracing"
+)
+
+// LocalBlobStore keeps blobs as files below a root directory. It is meant for development and single instance
+// deployments, the instances behind a load balancer need a shared blob store.
+type LocalBlobStore struct {
+	rootDir string
+}
+
+func NewLocalBlobStore(rootDir string) (*LocalBlobStore, error) {
+	const op errors.Op = "localblobstore.NewLocalBlobStore"
+
+	if err := os.MkdirAll(rootDir, 0o750); err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return &LocalBlobStore{rootDir}, nil
+}
+
+// PutBlob writes the blob to a temporary file first, so that readers never see partially written blobs. The content type
+// is not kept, attachments store it with their metadata.
+func (store *LocalBlobStore) PutBlob(ctx context.Context, key string, content io.Reader, size int64, contentType string) error {
+	const op errors.Op = "localblobstore.LocalBlobStore.PutBlob"
+	_, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	blobPath, err := store.blobPath(key)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(blobPath), 0o750); err != nil {
+		return errors.E(op, err)
+	}
+
+	tmpFile, err := os.CreateTemp(filepath.Dir(blobPath), ".upload-*")
+	if err != nil {
+		return errors.E(op, err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	written, err := io.Copy(tmpFile, content)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	switch {
+	case err != nil:
+		return errors.E(op, err)
+	case written != size:
+		err := fmt.Errorf("wrote %d bytes of blob %s, expected %d", written, key, size)
+		return errors.E(op, err)
+	}
+
+	if err := os.Rename(tmpFile.Name(), blobPath); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+func (store *LocalBlobStore) GetBlob(ctx context.Context, key string) (io.ReadCloser, error) {
+	const op errors.Op = "localblobstore.LocalBlobStore.GetBlob"
+	_, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	blobPath, err := store.blobPath(key)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	file, err := os.Open(blobPath)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil, errors.E(op, common.ErrNotFound)
+	case err != nil:
+		return nil, errors.E(op, err)
+	}
+
+	return file, nil
+}
+
+func (store *LocalBlobStore) DeleteBlob(ctx context.Context, key string) error {
+	const op errors.Op = "localblobstore.LocalBlobStore.DeleteBlob"
+	_, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	blobPath, err := store.blobPath(key)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	if err := os.Remove(blobPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// blobPath maps the key to a path below the root directory, keys can't escape it
+func (store *LocalBlobStore) blobPath(key string) (string, error) {
+	cleanKey := path.Clean("/" + key)
+	if cleanKey == "/" {
+		return "", fmt.Errorf("invalid blob key: %q", key)
+	}
+
+	return filepath.Join(store.rootDir, filepath.FromSlash(cleanKey)), nil
+}
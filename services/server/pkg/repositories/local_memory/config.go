@@ -0,0 +1,78 @@
+package localmemory
+
+import (
+	"fmt"
+	"spaces-p/pkg/errors"
+	"spaces-p/pkg/utils"
+	"time"
+)
+
+// SlowConsumerPolicy decides what happens to a session whose notifications buffer is full
+type SlowConsumerPolicy string
+
+const (
+	// the session's connection is closed, the client has to reconnect
+	DisconnectPolicy SlowConsumerPolicy = "disconnect"
+	// the oldest buffered update is discarded to make room for the new one
+	DropOldestPolicy SlowConsumerPolicy = "drop_oldest"
+	// all buffered updates are discarded and replaced by a single update telling the client to refetch the space
+	ResyncPolicy SlowConsumerPolicy = "resync"
+)
+
+type Config struct {
+	NotificationsBufferSize int
+	// the maximum time writing a single update to a websocket connection may take
+	WriteTimeout       time.Duration
+	SlowConsumerPolicy SlowConsumerPolicy
+}
+
+func DefaultConfig() Config {
+	return Config{
+		NotificationsBufferSize: 16,
+		WriteTimeout:            5 * time.Second,
+		SlowConsumerPolicy:      DisconnectPolicy,
+	}
+}
+
+// NewConfigFromEnv builds a Config from the WS_* environment variables. Variables that are not set fall back
+// to the values of DefaultConfig.
+func NewConfigFromEnv(getenv func(string) (string, error)) (Config, error) {
+	const op errors.Op = "localmemory.NewConfigFromEnv"
+	var cfg = DefaultConfig()
+	var err error
+
+	if policy := utils.LookupEnv(getenv, "WS_SLOW_CONSUMER_POLICY"); policy != "" {
+		cfg.SlowConsumerPolicy = SlowConsumerPolicy(policy)
+	}
+
+	if cfg.NotificationsBufferSize, err = utils.ParseIntEnv(getenv, "WS_NOTIFICATIONS_BUFFER_SIZE", cfg.NotificationsBufferSize); err != nil {
+		return Config{}, errors.E(op, err)
+	}
+	if cfg.WriteTimeout, err = utils.ParseDurationEnv(getenv, "WS_WRITE_TIMEOUT", cfg.WriteTimeout); err != nil {
+		return Config{}, errors.E(op, err)
+	}
+
+	if err := cfg.validate(); err != nil {
+		return Config{}, errors.E(op, err)
+	}
+
+	return cfg, nil
+}
+
+func (cfg Config) validate() error {
+	switch cfg.SlowConsumerPolicy {
+	case DisconnectPolicy, DropOldestPolicy, ResyncPolicy:
+	default:
+		return fmt.Errorf("unknown slow consumer policy: %s", cfg.SlowConsumerPolicy)
+	}
+
+	if cfg.NotificationsBufferSize < 1 {
+		return fmt.Errorf("notifications buffer size must be positive, got %d", cfg.NotificationsBufferSize)
+	}
+
+	if cfg.WriteTimeout <= 0 {
+		return fmt.Errorf("write timeout must be positive, got %s", cfg.WriteTimeout)
+	}
+
+	return nil
+}
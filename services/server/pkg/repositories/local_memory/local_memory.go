@@ -1,36 +1,77 @@
 package localmemory
 
 import (
+	"context"
+	"maps"
+	"spaces-p/pkg/errors"
 	"spaces-p/pkg/models"
 	"spaces-p/pkg/uuid"
 	"sync"
 )
 
-const NotificationsBufferSize = 16
-
+// BaseSession is either a session of a space, or a session of the user's own channel, which has no SpaceId and gets
+// the updates that are addressed to its user as well as the updates of the user's spaces
 type BaseSession struct {
 	SpaceId         uuid.Uuid
 	UserId          models.UserUid
 	NotificationsCh chan models.SpaceUpdate
 	CloseSlow       func()
+	// GoAway tells the client that the server is shutting down and that it should reconnect
+	GoAway func()
+	// updates caused by these users, e.g. because the session's user has blocked or muted them, are not published to the session
+	HiddenUserIds []models.UserUid
+	// the spaces whose updates are multiplexed into a session of the user's own channel
+	SpaceIds []uuid.Uuid
 }
 
 type Session struct {
 	SessionId uuid.Uuid
 	BaseSession
+	closingSlow   bool                    // guarded by LocalMemoryRepo.mu
+	hiddenUserIds map[models.UserUid]bool // guarded by LocalMemoryRepo.mu
+	spaceIds      map[uuid.Uuid]bool      // guarded by LocalMemoryRepo.mu
+	// the spaces the client of a user channel session wants updates of, nil for all of spaceIds. Guarded by LocalMemoryRepo.mu
+	spaceFilter map[uuid.Uuid]bool
 }
 
 type NewSessionInput BaseSession
 
 type space map[uuid.Uuid]*Session
 
+// SlowConsumerStats counts how often sessions could not keep up with their updates since the repo was created
+type SlowConsumerStats struct {
+	Disconnects    int64
+	DroppedUpdates int64
+	Resyncs        int64
+}
+
 type LocalMemoryRepo struct {
-	mu     sync.Mutex
-	spaces map[uuid.Uuid]space
+	config            Config
+	mu                sync.Mutex
+	spaces            map[uuid.Uuid]space
+	userSessions      map[models.UserUid]space // the sessions of the users' own channels
+	channelSpaces     map[uuid.Uuid]space      // the sessions of the users' own channels by multiplexed space
+	slowConsumerStats SlowConsumerStats
+	publishedUpdates  map[models.SpaceUpdateType]int64
+	sessionsCount     int
+	draining          bool
+	drained           bool
+	drainedCh         chan struct{} // closed when all sessions have been deleted after Drain was called
 }
 
-func NewLocalMemoryRepo() *LocalMemoryRepo {
-	return &LocalMemoryRepo{spaces: map[uuid.Uuid]space{}}
+func NewLocalMemoryRepo(config Config) *LocalMemoryRepo {
+	return &LocalMemoryRepo{
+		config:           config,
+		spaces:           map[uuid.Uuid]space{},
+		userSessions:     map[models.UserUid]space{},
+		channelSpaces:    map[uuid.Uuid]space{},
+		publishedUpdates: map[models.SpaceUpdateType]int64{},
+		drainedCh:        make(chan struct{}),
+	}
+}
+
+func (lm *LocalMemoryRepo) Config() Config {
+	return lm.config
 }
 
 func (lm *LocalMemoryRepo) AddSession(newSessionInput NewSessionInput) *Session {
@@ -40,8 +81,9 @@ func (lm *LocalMemoryRepo) AddSession(newSessionInput NewSessionInput) *Session
 	defer lm.mu.Unlock()
 
 	newSession := &Session{
-		SessionId:   newSessionId,
-		BaseSession: BaseSession(newSessionInput),
+		SessionId:     newSessionId,
+		BaseSession:   BaseSession(newSessionInput),
+		hiddenUserIds: toUserIdSet(newSessionInput.HiddenUserIds),
 	}
 
 	_, spaceExists := lm.spaces[newSession.SpaceId]
@@ -50,6 +92,46 @@ func (lm *LocalMemoryRepo) AddSession(newSessionInput NewSessionInput) *Session
 	}
 
 	lm.spaces[newSession.SpaceId][newSessionId] = newSession
+	lm.sessionsCount++
+
+	// the session was established while the server is already shutting down
+	if lm.draining {
+		go newSession.GoAway()
+	}
+
+	return newSession
+}
+
+// AddUserSession adds a session of the user's own channel, the SpaceId of the input is ignored
+func (lm *LocalMemoryRepo) AddUserSession(newSessionInput NewSessionInput) *Session {
+	var newSessionId = uuid.New()
+
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	newSession := &Session{
+		SessionId:     newSessionId,
+		BaseSession:   BaseSession(newSessionInput),
+		hiddenUserIds: toUserIdSet(newSessionInput.HiddenUserIds),
+		spaceIds:      make(map[uuid.Uuid]bool, len(newSessionInput.SpaceIds)),
+	}
+	newSession.SpaceId = uuid.Nil
+
+	for _, spaceId := range newSessionInput.SpaceIds {
+		lm.addUserChannelSpace(newSession, spaceId)
+	}
+
+	_, userExists := lm.userSessions[newSession.UserId]
+	if !userExists {
+		lm.userSessions[newSession.UserId] = make(space)
+	}
+
+	lm.userSessions[newSession.UserId][newSessionId] = newSession
+	lm.sessionsCount++
+
+	if lm.draining {
+		go newSession.GoAway()
+	}
 
 	return newSession
 }
@@ -64,24 +146,282 @@ func (lm *LocalMemoryRepo) DeleteSession(spaceId, sessionId uuid.Uuid) {
 		return
 	}
 
+	if _, sessionExists := space[sessionId]; !sessionExists {
+		return
+	}
+
 	delete(space, sessionId)
+	lm.sessionsCount--
 
 	if len(space) == 0 {
 		delete(lm.spaces, spaceId)
 	}
+
+	if lm.draining && lm.sessionsCount == 0 {
+		lm.closeDrainedCh()
+	}
 }
 
-// becomes no-op when space does not exist
-func (lm *LocalMemoryRepo) publishNotificationToSpaceSessions(spaceId uuid.Uuid, spaceUpdate models.SpaceUpdate) {
+// becomes no-op when user or session does not exist
+func (lm *LocalMemoryRepo) DeleteUserSession(userId models.UserUid, sessionId uuid.Uuid) {
 	lm.mu.Lock()
 	defer lm.mu.Unlock()
 
-	space, spaceExists := lm.spaces[spaceId]
-	if !spaceExists {
+	sessions, userExists := lm.userSessions[userId]
+	if !userExists {
+		return
+	}
+
+	if _, sessionExists := sessions[sessionId]; !sessionExists {
+		return
+	}
+
+	for spaceId := range sessions[sessionId].spaceIds {
+		lm.removeUserChannelSpace(sessions[sessionId], spaceId)
+	}
+
+	delete(sessions, sessionId)
+	lm.sessionsCount--
+
+	if len(sessions) == 0 {
+		delete(lm.userSessions, userId)
+	}
+
+	if lm.draining && lm.sessionsCount == 0 {
+		lm.closeDrainedCh()
+	}
+}
+
+// AddUserChannelSpace multiplexes the space into the user's channel sessions, e.g. after the user has subscribed to it
+func (lm *LocalMemoryRepo) AddUserChannelSpace(userId models.UserUid, spaceId uuid.Uuid) {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	for _, session := range lm.userSessions[userId] {
+		lm.addUserChannelSpace(session, spaceId)
+	}
+}
+
+// RemoveUserChannelSpace stops multiplexing the space into the user's channel sessions, e.g. after the user has left
+// or been banned from it
+func (lm *LocalMemoryRepo) RemoveUserChannelSpace(userId models.UserUid, spaceId uuid.Uuid) {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	for _, session := range lm.userSessions[userId] {
+		lm.removeUserChannelSpace(session, spaceId)
+	}
+}
+
+// RemoveChannelSpace stops multiplexing the space into the channel sessions of all users, e.g. after it has been
+// deleted
+func (lm *LocalMemoryRepo) RemoveChannelSpace(spaceId uuid.Uuid) {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	for _, session := range lm.channelSpaces[spaceId] {
+		lm.removeUserChannelSpace(session, spaceId)
+	}
+}
+
+// SetUserSessionSpaceFilter limits the space updates of a session of the user's channel to the spaces, nil spaceIds
+// let the updates of all the user's spaces through. Becomes no-op when the session does not exist.
+func (lm *LocalMemoryRepo) SetUserSessionSpaceFilter(userId models.UserUid, sessionId uuid.Uuid, spaceIds []uuid.Uuid) {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	session, sessionExists := lm.userSessions[userId][sessionId]
+	if !sessionExists {
+		return
+	}
+
+	if spaceIds == nil {
+		session.spaceFilter = nil
+		return
+	}
+
+	session.spaceFilter = make(map[uuid.Uuid]bool, len(spaceIds))
+	for _, spaceId := range spaceIds {
+		session.spaceFilter[spaceId] = true
+	}
+}
+
+// must be called with lm.mu held
+func (lm *LocalMemoryRepo) addUserChannelSpace(session *Session, spaceId uuid.Uuid) {
+	session.spaceIds[spaceId] = true
+
+	if _, spaceExists := lm.channelSpaces[spaceId]; !spaceExists {
+		lm.channelSpaces[spaceId] = make(space)
+	}
+	lm.channelSpaces[spaceId][session.SessionId] = session
+}
+
+// must be called with lm.mu held
+func (lm *LocalMemoryRepo) removeUserChannelSpace(session *Session, spaceId uuid.Uuid) {
+	delete(session.spaceIds, spaceId)
+
+	delete(lm.channelSpaces[spaceId], session.SessionId)
+	if len(lm.channelSpaces[spaceId]) == 0 {
+		delete(lm.channelSpaces, spaceId)
+	}
+}
+
+// SetUserHiddenUsers replaces the hidden users of all sessions of the user on this server instance
+func (lm *LocalMemoryRepo) SetUserHiddenUsers(userId models.UserUid, hiddenUserIds []models.UserUid) {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	for _, space := range lm.spaces {
+		for _, session := range space {
+			if session.UserId == userId {
+				session.hiddenUserIds = toUserIdSet(hiddenUserIds)
+			}
+		}
+	}
+
+	for _, session := range lm.userSessions[userId] {
+		session.hiddenUserIds = toUserIdSet(hiddenUserIds)
+	}
+}
+
+// Drain tells all sessions to go away and blocks until every session has been deleted or ctx is done.
+// Sessions are expected to clean up their state (e.g. their redis session keys) before they are deleted.
+func (lm *LocalMemoryRepo) Drain(ctx context.Context) error {
+	const op errors.Op = "localmemory.LocalMemoryRepo.Drain"
+
+	lm.mu.Lock()
+	lm.draining = true
+	var sessions = make([]*Session, 0, lm.sessionsCount)
+	for _, space := range lm.spaces {
+		for _, session := range space {
+			sessions = append(sessions, session)
+		}
+	}
+	for _, userSessions := range lm.userSessions {
+		for _, session := range userSessions {
+			sessions = append(sessions, session)
+		}
+	}
+	if lm.sessionsCount == 0 {
+		lm.closeDrainedCh()
+	}
+	lm.mu.Unlock()
+
+	// closing a connection blocks until the close handshake has finished
+	for _, session := range sessions {
+		go session.GoAway()
+	}
+
+	select {
+	case <-lm.drainedCh:
+		return nil
+	case <-ctx.Done():
+		return errors.E(op, ctx.Err())
+	}
+}
+
+// SessionsCount returns the number of sessions of this server instance
+func (lm *LocalMemoryRepo) SessionsCount() int {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	return lm.sessionsCount
+}
+
+// PublishedUpdatesCount returns how many updates of each type have been published since the repo was created
+func (lm *LocalMemoryRepo) PublishedUpdatesCount() map[models.SpaceUpdateType]int64 {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	return maps.Clone(lm.publishedUpdates)
+}
+
+func (lm *LocalMemoryRepo) SlowConsumerStats() SlowConsumerStats {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	return lm.slowConsumerStats
+}
+
+// must be called with lm.mu held
+func (lm *LocalMemoryRepo) closeDrainedCh() {
+	if lm.drained {
 		return
 	}
 
-	for _, session := range space {
+	lm.drained = true
+	close(lm.drainedCh)
+}
+
+func toUserIdSet(userIds []models.UserUid) map[models.UserUid]bool {
+	var userIdSet = make(map[models.UserUid]bool, len(userIds))
+	for _, userId := range userIds {
+		userIdSet[userId] = true
+	}
+
+	return userIdSet
+}
+
+// publishes the update to the sessions of the space and to the user channel sessions multiplexing it
+func (lm *LocalMemoryRepo) publishNotificationToSpaceSessions(spaceId uuid.Uuid, spaceUpdate models.SpaceUpdate) {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	lm.publishedUpdates[spaceUpdate.GetType()]++
+
+	for _, session := range lm.spaces[spaceId] {
+		if session.hiddenUserIds[spaceUpdate.GetUserId()] {
+			continue
+		}
+
+		lm.publishNotification(session, spaceUpdate)
+	}
+
+	var spaceScopedUpdate = &models.SpaceScopedUpdate{SpaceId: spaceId, Update: spaceUpdate}
+	for _, session := range lm.channelSpaces[spaceId] {
+		if session.hiddenUserIds[spaceUpdate.GetUserId()] {
+			continue
+		}
+		if session.spaceFilter != nil && !session.spaceFilter[spaceId] {
+			continue
+		}
+
+		lm.publishNotification(session, spaceScopedUpdate)
+	}
+}
+
+// publishes the update to the sessions of the user in all spaces and to the user's own channel
+func (lm *LocalMemoryRepo) publishNotificationToUserSessions(userId models.UserUid, spaceUpdate models.SpaceUpdate) {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	lm.publishedUpdates[spaceUpdate.GetType()]++
+
+	for _, space := range lm.spaces {
+		for _, session := range space {
+			if session.UserId == userId {
+				lm.publishNotification(session, spaceUpdate)
+			}
+		}
+	}
+
+	for _, session := range lm.userSessions[userId] {
+		lm.publishNotification(session, spaceUpdate)
+	}
+}
+
+// publishes the update to the user's own channel only, becomes no-op when the user has no open channel
+func (lm *LocalMemoryRepo) publishNotificationToUserChannel(userId models.UserUid, spaceUpdate models.SpaceUpdate) {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	lm.publishedUpdates[spaceUpdate.GetType()]++
+
+	for _, session := range lm.userSessions[userId] {
+		if session.hiddenUserIds[spaceUpdate.GetUserId()] {
+			continue
+		}
+
 		lm.publishNotification(session, spaceUpdate)
 	}
 }
@@ -176,10 +516,140 @@ func (lm *LocalMemoryRepo) PublishMessagePopularityIncrease(spaceId uuid.Uuid, u
 	lm.publishNotificationToSpaceSessions(spaceId, u)
 }
 
+// PublishPollResults publishes the current results of a poll. The userId is empty for polls that have been closed
+// because they expired.
+func (lm *LocalMemoryRepo) PublishPollResults(spaceId uuid.Uuid, userId models.UserUid, threadId, messageId uuid.Uuid, results models.PollResults) {
+	u := &models.SingleSpaceUpdate[models.PollResultsUpdatePayload]{
+		Type:    models.PollResultsSpaceUpdateType,
+		UserId:  userId,
+		Payload: models.PollResultsUpdatePayload{ThreadId: threadId, MessageId: messageId, Results: results},
+	}
+
+	lm.publishNotificationToSpaceSessions(spaceId, u)
+}
+
+// PublishPin publishes that the item has been pinned, or unpinned if pinned is false
+func (lm *LocalMemoryRepo) PublishPin(spaceId uuid.Uuid, userId models.UserUid, pin models.Pin, pinned bool) {
+	u := &models.SingleSpaceUpdate[models.PinUpdatePayload]{
+		Type:    models.PinSpaceUpdateType,
+		UserId:  userId,
+		Payload: models.PinUpdatePayload{Pin: pin, Pinned: pinned},
+	}
+
+	lm.publishNotificationToSpaceSessions(spaceId, u)
+}
+
+// PublishSpaceStatus publishes that a time-bounded space has opened or closed
+func (lm *LocalMemoryRepo) PublishSpaceStatus(spaceId uuid.Uuid, status models.SpaceStatus) {
+	u := &models.SingleSpaceUpdate[models.SpaceStatusUpdatePayload]{
+		Type:    models.SpaceStatusSpaceUpdateType,
+		Payload: models.SpaceStatusUpdatePayload{SpaceId: spaceId, Status: status},
+	}
+
+	lm.publishNotificationToSpaceSessions(spaceId, u)
+}
+
+// PublishDeleteTopLevelThread publishes that the toplevel thread has been removed along with all its messages
+func (lm *LocalMemoryRepo) PublishDeleteTopLevelThread(spaceId uuid.Uuid, threadId uuid.Uuid) {
+	u := &models.SingleSpaceUpdate[models.DeleteTopLevelThreadUpdatePayload]{
+		Type:    models.DeleteTopLevelThreadSpaceUpdateType,
+		Payload: models.DeleteTopLevelThreadUpdatePayload{ThreadId: threadId},
+	}
+
+	lm.publishNotificationToSpaceSessions(spaceId, u)
+}
+
+// PublishDeleteMessage publishes that the message has been removed along with its child thread
+func (lm *LocalMemoryRepo) PublishDeleteMessage(spaceId uuid.Uuid, threadId, messageId uuid.Uuid) {
+	u := &models.SingleSpaceUpdate[models.DeleteMessageUpdatePayload]{
+		Type:    models.DeleteMessageSpaceUpdateType,
+		Payload: models.DeleteMessageUpdatePayload{ThreadId: threadId, MessageId: messageId},
+	}
+
+	lm.publishNotificationToSpaceSessions(spaceId, u)
+}
+
+// PublishReadMarker syncs a read marker of the user to the user's other devices
+func (lm *LocalMemoryRepo) PublishReadMarker(userId models.UserUid, readMarker models.ReadMarker) {
+	u := &models.SingleSpaceUpdate[models.ReadMarkerUpdatePayload]{
+		Type:    models.ReadMarkerSpaceUpdateType,
+		UserId:  userId,
+		Payload: models.ReadMarkerUpdatePayload{ReadMarker: readMarker},
+	}
+
+	lm.publishNotificationToUserSessions(userId, u)
+}
+
+// PublishInboxMessage delivers a new message of a thread the user follows to the user's own channel
+func (lm *LocalMemoryRepo) PublishInboxMessage(userId models.UserUid, inboxMessage models.InboxMessage) {
+	u := &models.SingleSpaceUpdate[models.InboxMessageUpdatePayload]{
+		Type:    models.InboxMessageSpaceUpdateType,
+		UserId:  inboxMessage.SenderId,
+		Payload: models.InboxMessageUpdatePayload{InboxMessage: inboxMessage},
+	}
+
+	lm.publishNotificationToUserChannel(userId, u)
+}
+
+// PublishMention notifies the user's own channel that the user has been mentioned in the message
+func (lm *LocalMemoryRepo) PublishMention(userId models.UserUid, message models.InboxMessage) {
+	u := &models.SingleSpaceUpdate[models.MentionUpdatePayload]{
+		Type:    models.MentionSpaceUpdateType,
+		UserId:  message.SenderId,
+		Payload: models.MentionUpdatePayload{Message: message},
+	}
+
+	lm.publishNotificationToUserChannel(userId, u)
+}
+
+// must be called with lm.mu held. Only publishers write to a session's notifications channel,
+// so the channel cannot fill up again while the lock is held.
 func (lm *LocalMemoryRepo) publishNotification(session *Session, spaceUpdate models.SpaceUpdate) {
 	select {
 	case session.NotificationsCh <- spaceUpdate:
+		return
+	default:
+	}
+
+	switch lm.config.SlowConsumerPolicy {
+	case DropOldestPolicy:
+		select {
+		case <-session.NotificationsCh:
+		default: // the session consumed an update in the meantime
+		}
+
+		session.NotificationsCh <- spaceUpdate
+		lm.slowConsumerStats.DroppedUpdates++
+	case ResyncPolicy:
+		var droppedUpdates = 1 // the new update
+	drain:
+		for {
+			select {
+			case bufferedUpdate := <-session.NotificationsCh:
+				if resyncUpdate, ok := bufferedUpdate.(*models.SingleSpaceUpdate[models.ResyncRequiredPayload]); ok {
+					droppedUpdates += resyncUpdate.Payload.DroppedUpdates
+					continue
+				}
+				droppedUpdates++
+			default:
+				break drain
+			}
+		}
+
+		session.NotificationsCh <- &models.SingleSpaceUpdate[models.ResyncRequiredPayload]{
+			Type:    models.ResyncRequiredSpaceUpdateType,
+			Payload: models.ResyncRequiredPayload{DroppedUpdates: droppedUpdates},
+		}
+		lm.slowConsumerStats.DroppedUpdates += int64(droppedUpdates)
+		lm.slowConsumerStats.Resyncs++
 	default:
+		// the session stays registered until its connection has been closed
+		if session.closingSlow {
+			return
+		}
+
+		session.closingSlow = true
+		lm.slowConsumerStats.Disconnects++
 		go session.CloseSlow()
 	}
 }
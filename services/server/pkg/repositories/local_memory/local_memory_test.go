@@ -0,0 +1,303 @@
+package localmemory_test
+
+import (
+	"context"
+	"slices"
+	"spaces-p/pkg/models"
+	localmemory "spaces-p/pkg/repositories/local_memory"
+	"spaces-p/pkg/uuid"
+	"testing"
+	"time"
+)
+
+func TestDrain(t *testing.T) {
+	lm := localmemory.NewLocalMemoryRepo(localmemory.DefaultConfig())
+	spaceId := uuid.New()
+
+	for i := 0; i < 3; i++ {
+		var session *localmemory.Session
+		session = lm.AddSession(localmemory.NewSessionInput{
+			SpaceId:         spaceId,
+			UserId:          models.UserUid("user"),
+			NotificationsCh: make(chan models.SpaceUpdate, lm.Config().NotificationsBufferSize),
+			CloseSlow:       func() {},
+			GoAway: func() {
+				// simulates the session's cleanup
+				lm.DeleteSession(session.SpaceId, session.SessionId)
+			},
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := lm.Drain(ctx); err != nil {
+		t.Fatalf("lm.Drain() err = %s; want nil", err)
+	}
+
+	if count := lm.SessionsCount(); count != 0 {
+		t.Errorf("lm.SessionsCount() = %d; want 0", count)
+	}
+}
+
+func TestDrainTimeout(t *testing.T) {
+	lm := localmemory.NewLocalMemoryRepo(localmemory.DefaultConfig())
+
+	lm.AddSession(localmemory.NewSessionInput{
+		SpaceId:         uuid.New(),
+		UserId:          models.UserUid("user"),
+		NotificationsCh: make(chan models.SpaceUpdate, lm.Config().NotificationsBufferSize),
+		CloseSlow:       func() {},
+		GoAway:          func() {}, // never cleans up
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := lm.Drain(ctx); err == nil {
+		t.Error("lm.Drain() err = nil; want deadline exceeded")
+	}
+}
+
+func TestSlowConsumerPolicies(t *testing.T) {
+	tests := []struct {
+		policy    localmemory.SlowConsumerPolicy
+		wantTypes []models.SpaceUpdateType
+		wantStats localmemory.SlowConsumerStats
+	}{
+		{
+			policy:    localmemory.DisconnectPolicy,
+			wantTypes: []models.SpaceUpdateType{models.NewSubscriberSpaceUpdateType, models.NewSubscriberSpaceUpdateType},
+			wantStats: localmemory.SlowConsumerStats{Disconnects: 1},
+		},
+		{
+			policy:    localmemory.DropOldestPolicy,
+			wantTypes: []models.SpaceUpdateType{models.NewActiveSubscriberSpaceUpdateType, models.NewActiveSubscriberSpaceUpdateType},
+			wantStats: localmemory.SlowConsumerStats{DroppedUpdates: 2},
+		},
+		{
+			policy: localmemory.ResyncPolicy,
+			// updates published after the overflow are delivered after the marker
+			wantTypes: []models.SpaceUpdateType{models.ResyncRequiredSpaceUpdateType, models.NewActiveSubscriberSpaceUpdateType},
+			wantStats: localmemory.SlowConsumerStats{DroppedUpdates: 3, Resyncs: 1},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.policy), func(t *testing.T) {
+			cfg := localmemory.DefaultConfig()
+			cfg.NotificationsBufferSize = 2
+			cfg.SlowConsumerPolicy = test.policy
+			lm := localmemory.NewLocalMemoryRepo(cfg)
+			spaceId := uuid.New()
+
+			var closeSlowCalls = make(chan struct{}, 10)
+			session := lm.AddSession(localmemory.NewSessionInput{
+				SpaceId:         spaceId,
+				UserId:          models.UserUid("user"),
+				NotificationsCh: make(chan models.SpaceUpdate, cfg.NotificationsBufferSize),
+				CloseSlow:       func() { closeSlowCalls <- struct{}{} },
+				GoAway:          func() {},
+			})
+
+			lm.PublishNewSpaceSubscriber(spaceId, "user")
+			lm.PublishNewSpaceSubscriber(spaceId, "user")
+			lm.PublishNewActiveSpaceSubscriber(spaceId, "user")
+			lm.PublishNewActiveSpaceSubscriber(spaceId, "user")
+
+			var gotTypes []models.SpaceUpdateType
+			for len(session.NotificationsCh) > 0 {
+				switch update := (<-session.NotificationsCh).(type) {
+				case *models.SingleSpaceUpdate[models.NewSubscriberPayload]:
+					gotTypes = append(gotTypes, update.Type)
+				case *models.SingleSpaceUpdate[models.NewActiveSubscriberPayload]:
+					gotTypes = append(gotTypes, update.Type)
+				case *models.SingleSpaceUpdate[models.ResyncRequiredPayload]:
+					gotTypes = append(gotTypes, update.Type)
+					if update.Payload.DroppedUpdates != 3 {
+						t.Errorf("resync DroppedUpdates = %d; want 3", update.Payload.DroppedUpdates)
+					}
+				}
+			}
+
+			if !slices.Equal(gotTypes, test.wantTypes) {
+				t.Errorf("received update types = %v; want %v", gotTypes, test.wantTypes)
+			}
+
+			if stats := lm.SlowConsumerStats(); stats != test.wantStats {
+				t.Errorf("lm.SlowConsumerStats() = %+v; want %+v", stats, test.wantStats)
+			}
+
+			if test.policy == localmemory.DisconnectPolicy {
+				<-closeSlowCalls
+				if len(closeSlowCalls) != 0 {
+					t.Error("CloseSlow called more than once")
+				}
+			}
+		})
+	}
+}
+
+func TestHiddenUsers(t *testing.T) {
+	lm := localmemory.NewLocalMemoryRepo(localmemory.DefaultConfig())
+	spaceId := uuid.New()
+
+	newSession := func(userId models.UserUid, hiddenUserIds []models.UserUid) *localmemory.Session {
+		return lm.AddSession(localmemory.NewSessionInput{
+			SpaceId:         spaceId,
+			UserId:          userId,
+			NotificationsCh: make(chan models.SpaceUpdate, lm.Config().NotificationsBufferSize),
+			CloseSlow:       func() {},
+			GoAway:          func() {},
+			HiddenUserIds:   hiddenUserIds,
+		})
+	}
+	blockingSession := newSession("blocker", []models.UserUid{"blocked"})
+	otherSession := newSession("other", nil)
+
+	lm.PublishNewSpaceSubscriber(spaceId, "blocked")
+	lm.PublishNewSpaceSubscriber(spaceId, "other")
+
+	if got := len(blockingSession.NotificationsCh); got != 1 {
+		t.Errorf("len(blockingSession.NotificationsCh) = %d; want 1", got)
+	}
+	if got := len(otherSession.NotificationsCh); got != 2 {
+		t.Errorf("len(otherSession.NotificationsCh) = %d; want 2", got)
+	}
+
+	// unblocking applies to the open sessions right away
+	lm.SetUserHiddenUsers("blocker", nil)
+	lm.PublishNewSpaceSubscriber(spaceId, "blocked")
+
+	if got := len(blockingSession.NotificationsCh); got != 2 {
+		t.Errorf("len(blockingSession.NotificationsCh) = %d; want 2", got)
+	}
+}
+
+func TestPublishReadMarker(t *testing.T) {
+	lm := localmemory.NewLocalMemoryRepo(localmemory.DefaultConfig())
+
+	newSession := func(spaceId uuid.Uuid, userId models.UserUid) *localmemory.Session {
+		return lm.AddSession(localmemory.NewSessionInput{
+			SpaceId:         spaceId,
+			UserId:          userId,
+			NotificationsCh: make(chan models.SpaceUpdate, lm.Config().NotificationsBufferSize),
+			CloseSlow:       func() {},
+			GoAway:          func() {},
+		})
+	}
+	readerSessions := []*localmemory.Session{newSession(uuid.New(), "reader"), newSession(uuid.New(), "reader")}
+	otherSession := newSession(readerSessions[0].SpaceId, "other")
+
+	lm.PublishReadMarker("reader", models.ReadMarker{SpaceId: readerSessions[0].SpaceId, ReadAt: time.Now()})
+
+	// the markers of a space are shown in the spaces list of every other space as well
+	for i, session := range readerSessions {
+		if got := len(session.NotificationsCh); got != 1 {
+			t.Errorf("len(readerSessions[%d].NotificationsCh) = %d; want 1", i, got)
+		}
+	}
+	if got := len(otherSession.NotificationsCh); got != 0 {
+		t.Errorf("len(otherSession.NotificationsCh) = %d; want 0", got)
+	}
+}
+
+func TestUserSessions(t *testing.T) {
+	lm := localmemory.NewLocalMemoryRepo(localmemory.DefaultConfig())
+	newSessionInput := func(spaceId uuid.Uuid, userId models.UserUid) localmemory.NewSessionInput {
+		return localmemory.NewSessionInput{
+			SpaceId:         spaceId,
+			UserId:          userId,
+			NotificationsCh: make(chan models.SpaceUpdate, lm.Config().NotificationsBufferSize),
+			CloseSlow:       func() {},
+			GoAway:          func() {},
+		}
+	}
+	spaceSession := lm.AddSession(newSessionInput(uuid.New(), "follower"))
+	userSession := lm.AddUserSession(newSessionInput(uuid.Nil, "follower"))
+	otherUserSession := lm.AddUserSession(newSessionInput(uuid.Nil, "other"))
+
+	lm.PublishInboxMessage("follower", models.InboxMessage{SpaceId: spaceSession.SpaceId})
+	lm.PublishReadMarker("follower", models.ReadMarker{SpaceId: spaceSession.SpaceId, ReadAt: time.Now()})
+
+	// inbox messages are only delivered to the user's own channel, the space sessions get the new message anyway
+	if got := len(spaceSession.NotificationsCh); got != 1 {
+		t.Errorf("len(spaceSession.NotificationsCh) = %d; want 1", got)
+	}
+	if got := len(userSession.NotificationsCh); got != 2 {
+		t.Errorf("len(userSession.NotificationsCh) = %d; want 2", got)
+	}
+	if got := len(otherUserSession.NotificationsCh); got != 0 {
+		t.Errorf("len(otherUserSession.NotificationsCh) = %d; want 0", got)
+	}
+
+	lm.DeleteUserSession("follower", userSession.SessionId)
+	if got := lm.SessionsCount(); got != 2 {
+		t.Errorf("SessionsCount() = %d; want 2", got)
+	}
+}
+
+func TestUserChannelSpaces(t *testing.T) {
+	lm := localmemory.NewLocalMemoryRepo(localmemory.DefaultConfig())
+	spaceId, otherSpaceId, newSpaceId := uuid.New(), uuid.New(), uuid.New()
+	session := lm.AddUserSession(localmemory.NewSessionInput{
+		UserId:          "user",
+		NotificationsCh: make(chan models.SpaceUpdate, lm.Config().NotificationsBufferSize),
+		CloseSlow:       func() {},
+		GoAway:          func() {},
+		SpaceIds:        []uuid.Uuid{spaceId, otherSpaceId},
+	})
+
+	lm.PublishNewSpaceSubscriber(spaceId, "other")
+	lm.PublishNewSpaceSubscriber(newSpaceId, "other")
+	lm.AddUserChannelSpace("user", newSpaceId)
+	lm.PublishNewSpaceSubscriber(newSpaceId, "other")
+
+	var gotSpaceIds []uuid.Uuid
+	for len(session.NotificationsCh) > 0 {
+		update, ok := (<-session.NotificationsCh).(*models.SpaceScopedUpdate)
+		if !ok {
+			t.Fatalf("update type = %T; want *models.SpaceScopedUpdate", update)
+		}
+		gotSpaceIds = append(gotSpaceIds, update.SpaceId)
+	}
+	if want := []uuid.Uuid{spaceId, newSpaceId}; !slices.Equal(gotSpaceIds, want) {
+		t.Errorf("space ids of the updates = %v; want %v", gotSpaceIds, want)
+	}
+
+	lm.SetUserSessionSpaceFilter("user", session.SessionId, []uuid.Uuid{otherSpaceId})
+	lm.PublishNewSpaceSubscriber(spaceId, "other")
+	lm.PublishNewSpaceSubscriber(otherSpaceId, "other")
+	// updates addressed to the user are not filtered
+	lm.PublishInboxMessage("user", models.InboxMessage{SpaceId: spaceId})
+	if got := len(session.NotificationsCh); got != 2 {
+		t.Errorf("len(session.NotificationsCh) = %d; want 2", got)
+	}
+
+	// the user has left the space
+	lm.RemoveUserChannelSpace("user", otherSpaceId)
+	lm.PublishNewSpaceSubscriber(otherSpaceId, "other")
+	if got := len(session.NotificationsCh); got != 2 {
+		t.Errorf("len(session.NotificationsCh) after leaving = %d; want 2", got)
+	}
+
+	// the space has been deleted
+	lm.SetUserSessionSpaceFilter("user", session.SessionId, nil)
+	lm.RemoveChannelSpace(spaceId)
+	lm.PublishNewSpaceSubscriber(spaceId, "other")
+	if got := len(session.NotificationsCh); got != 2 {
+		t.Errorf("len(session.NotificationsCh) after deleting the space = %d; want 2", got)
+	}
+
+	lm.PublishMention("user", models.InboxMessage{SpaceId: spaceId})
+	lm.PublishMention("other", models.InboxMessage{SpaceId: spaceId})
+	if got := len(session.NotificationsCh); got != 3 {
+		t.Errorf("len(session.NotificationsCh) after the mentions = %d; want 3", got)
+	}
+
+	lm.DeleteUserSession("user", session.SessionId)
+	lm.PublishNewSpaceSubscriber(newSpaceId, "other")
+	if got := len(session.NotificationsCh); got != 3 {
+		t.Errorf("len(session.NotificationsCh) after deletion = %d; want 3", got)
+	}
+}
@@ -6,12 +6,15 @@ import (
 	"spaces-p/pkg/common"
 	"spaces-p/pkg/errors"
 	"spaces-p/pkg/models"
+	"spaces-p/pkg/tracing"
 
 	"github.com/redis/go-redis/v9"
 )
 
 func (repo *RedisRepository) GetAddress(ctx context.Context, geoHash string) (*models.Address, error) {
 	const op errors.Op = "redis_repo.RedisRepository.GetAddress"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
 	var addressKey = getAddressKey(geoHash)
 
 	r, err := repo.redisClient.Get(ctx, addressKey).Result()
@@ -32,6 +35,8 @@ func (repo *RedisRepository) GetAddress(ctx context.Context, geoHash string) (*m
 
 func (repo *RedisRepository) SetAddress(ctx context.Context, newAddress models.Address) error {
 	const op errors.Op = "redis_repo.RedisRepository.SetAddress"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
 	var addressKey = getAddressKey(newAddress.GeoHash)
 
 	newAddressJson, err := json.Marshal(newAddress)
@@ -0,0 +1,264 @@
+package redis_repo
+
+import (
+	"context"
+	"spaces-p/pkg/common"
+	"spaces-p/pkg/errors"
+	"spaces-p/pkg/models"
+	"spaces-p/pkg/tracing"
+	"spaces-p/pkg/utils"
+	"spaces-p/pkg/uuid"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// SetAttachment stores the attachment's metadata. The id is chosen by the caller, because the attachment's content is
+// uploaded to the blob store under keys derived from it before the metadata is stored. The attachment is unattached
+// until a message claims it with AttachAttachment.
+func (repo *RedisRepository) SetAttachment(ctx context.Context, attachmentId uuid.Uuid, newAttachment models.NewAttachment) (*models.Attachment, error) {
+	const op errors.Op = "redis_repo.RedisRepository.SetAttachment"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+	var attachmentKey = getAttachmentKey(attachmentId)
+	var createdAt = time.Now()
+
+	attachmentTypeStr, err := newAttachment.Type.String()
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	// tracked first, so that the uploaded content is collected even if storing the metadata fails
+	if err := repo.redisClient.ZAdd(ctx, getUnattachedAttachmentsKey(), redis.Z{
+		Score:  float64(createdAt.UnixMilli()),
+		Member: attachmentId.String(),
+	}).Err(); err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	if err := repo.redisClient.HSet(ctx, attachmentKey, map[string]any{
+		attachmentFields.spaceIdField:              newAttachment.SpaceId.String(),
+		attachmentFields.uploaderIdField:           string(newAttachment.UploaderId),
+		attachmentFields.typeField:                 attachmentTypeStr,
+		attachmentFields.fileNameField:             newAttachment.FileName,
+		attachmentFields.contentTypeField:          newAttachment.ContentType,
+		attachmentFields.sizeField:                 strconv.FormatInt(newAttachment.Size, 10),
+		attachmentFields.widthField:                strconv.Itoa(newAttachment.Width),
+		attachmentFields.heightField:               strconv.Itoa(newAttachment.Height),
+		attachmentFields.thumbnailContentTypeField: newAttachment.ThumbnailContentType,
+		attachmentFields.createdAtField:            strconv.FormatInt(createdAt.UnixMilli(), 10),
+	}).Err(); err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return &models.Attachment{
+		NewAttachment: newAttachment,
+		ID:            attachmentId,
+		CreatedAt:     createdAt,
+	}, nil
+}
+
+func (repo *RedisRepository) GetAttachment(ctx context.Context, attachmentId uuid.Uuid) (*models.Attachment, error) {
+	const op errors.Op = "redis_repo.RedisRepository.GetAttachment"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+	var attachmentKey = getAttachmentKey(attachmentId)
+
+	attachmentMap, err := repo.redisClient.HGetAll(ctx, attachmentKey).Result()
+	switch {
+	case err != nil:
+		return nil, errors.E(op, err)
+	case len(attachmentMap) == 0:
+		return nil, errors.E(op, common.ErrNotFound)
+	}
+
+	spaceId, err := uuid.Parse(attachmentMap[attachmentFields.spaceIdField])
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	var attachmentType models.MessageType
+	if err := attachmentType.Parse(attachmentMap[attachmentFields.typeField]); err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	size, err := strconv.ParseInt(attachmentMap[attachmentFields.sizeField], 10, 64)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	width, err := strconv.Atoi(attachmentMap[attachmentFields.widthField])
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	height, err := strconv.Atoi(attachmentMap[attachmentFields.heightField])
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	createdAt, err := utils.StringToTime(attachmentMap[attachmentFields.createdAtField])
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return &models.Attachment{
+		NewAttachment: models.NewAttachment{
+			SpaceId:              spaceId,
+			UploaderId:           models.UserUid(attachmentMap[attachmentFields.uploaderIdField]),
+			Type:                 attachmentType,
+			FileName:             attachmentMap[attachmentFields.fileNameField],
+			ContentType:          attachmentMap[attachmentFields.contentTypeField],
+			Size:                 size,
+			Width:                width,
+			Height:               height,
+			ThumbnailContentType: attachmentMap[attachmentFields.thumbnailContentTypeField],
+		},
+		ID:        attachmentId,
+		CreatedAt: createdAt,
+	}, nil
+}
+
+// AttachAttachment claims the unattached attachment for a message. It fails with common.ErrAttachmentAttached if
+// another message has claimed it already or it has been collected for staying unattached too long.
+func (repo *RedisRepository) AttachAttachment(ctx context.Context, attachmentId uuid.Uuid) error {
+	const op errors.Op = "redis_repo.RedisRepository.AttachAttachment"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	removedCount, err := repo.redisClient.ZRem(ctx, getUnattachedAttachmentsKey(), attachmentId.String()).Result()
+	switch {
+	case err != nil:
+		return errors.E(op, err)
+	case removedCount == 0:
+		return errors.E(op, common.ErrAttachmentAttached)
+	}
+
+	return nil
+}
+
+// DetachAttachment releases the attachment claimed for a message that hasn't been stored in the end. It is collected
+// if it isn't claimed again in time.
+func (repo *RedisRepository) DetachAttachment(ctx context.Context, attachmentId uuid.Uuid) error {
+	const op errors.Op = "redis_repo.RedisRepository.DetachAttachment"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if err := repo.redisClient.ZAdd(ctx, getUnattachedAttachmentsKey(), redis.Z{
+		Score:  float64(time.Now().UnixMilli()),
+		Member: attachmentId.String(),
+	}).Err(); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// GetUnattachedAttachmentIds returns the ids of up to count attachments that have been unattached since before
+// uploadedBefore
+func (repo *RedisRepository) GetUnattachedAttachmentIds(ctx context.Context, uploadedBefore time.Time, count int64) ([]uuid.Uuid, error) {
+	const op errors.Op = "redis_repo.RedisRepository.GetUnattachedAttachmentIds"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	attachmentIdStrs, err := repo.redisClient.ZRangeByScore(ctx, getUnattachedAttachmentsKey(), &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   "(" + strconv.FormatInt(uploadedBefore.UnixMilli(), 10),
+		Count: count,
+	}).Result()
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	var attachmentIds = make([]uuid.Uuid, 0, len(attachmentIdStrs))
+	for _, attachmentIdStr := range attachmentIdStrs {
+		attachmentId, err := uuid.Parse(attachmentIdStr)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+
+		attachmentIds = append(attachmentIds, attachmentId)
+	}
+
+	return attachmentIds, nil
+}
+
+// DeleteUnattachedAttachment removes the attachment unless a message has claimed it in the meantime
+func (repo *RedisRepository) DeleteUnattachedAttachment(ctx context.Context, attachmentId uuid.Uuid) error {
+	const op errors.Op = "redis_repo.RedisRepository.DeleteUnattachedAttachment"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	// removing the entry is the claim that a concurrent AttachAttachment competes for
+	removedCount, err := repo.redisClient.ZRem(ctx, getUnattachedAttachmentsKey(), attachmentId.String()).Result()
+	switch {
+	case err != nil:
+		return errors.E(op, err)
+	case removedCount == 0:
+		return nil
+	}
+
+	if err := repo.deleteAttachment(ctx, attachmentId); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// GetDeletedAttachmentIds returns the ids of up to count removed attachments whose content still has to be deleted from
+// the blob store
+func (repo *RedisRepository) GetDeletedAttachmentIds(ctx context.Context, count int64) ([]uuid.Uuid, error) {
+	const op errors.Op = "redis_repo.RedisRepository.GetDeletedAttachmentIds"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	attachmentIdStrs, err := repo.redisClient.SRandMemberN(ctx, getDeletedAttachmentsKey(), count).Result()
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	var attachmentIds = make([]uuid.Uuid, 0, len(attachmentIdStrs))
+	for _, attachmentIdStr := range attachmentIdStrs {
+		attachmentId, err := uuid.Parse(attachmentIdStr)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+
+		attachmentIds = append(attachmentIds, attachmentId)
+	}
+
+	return attachmentIds, nil
+}
+
+// DeleteDeletedAttachmentId marks the content of the removed attachment as deleted from the blob store
+func (repo *RedisRepository) DeleteDeletedAttachmentId(ctx context.Context, attachmentId uuid.Uuid) error {
+	const op errors.Op = "redis_repo.RedisRepository.DeleteDeletedAttachmentId"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if err := repo.redisClient.SRem(ctx, getDeletedAttachmentsKey(), attachmentId.String()).Err(); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// deleteAttachment removes the attachment's metadata and queues its content for deletion from the blob store
+func (repo *RedisRepository) deleteAttachment(ctx context.Context, attachmentId uuid.Uuid) error {
+	const op errors.Op = "redis_repo.RedisRepository.deleteAttachment"
+
+	if err := repo.redisClient.SAdd(ctx, getDeletedAttachmentsKey(), attachmentId.String()).Err(); err != nil {
+		return errors.E(op, err)
+	}
+
+	if err := repo.redisClient.ZRem(ctx, getUnattachedAttachmentsKey(), attachmentId.String()).Err(); err != nil {
+		return errors.E(op, err)
+	}
+
+	if err := repo.redisClient.Del(ctx, getAttachmentKey(attachmentId)).Err(); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
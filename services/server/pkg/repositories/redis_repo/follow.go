@@ -0,0 +1,96 @@
+package redis_repo
+
+import (
+	"context"
+	"spaces-p/pkg/errors"
+	"spaces-p/pkg/models"
+	"spaces-p/pkg/tracing"
+	"spaces-p/pkg/uuid"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// SetThreadFollower makes the user follow the thread, following it again keeps the original following time
+func (repo *RedisRepository) SetThreadFollower(ctx context.Context, threadId uuid.Uuid, userId models.UserUid) error {
+	const op errors.Op = "redis_repo.RedisRepository.SetThreadFollower"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if err := repo.setUserRelation(ctx, getThreadFollowersKey(threadId), userId); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+func (repo *RedisRepository) DeleteThreadFollower(ctx context.Context, threadId uuid.Uuid, userId models.UserUid) error {
+	const op errors.Op = "redis_repo.RedisRepository.DeleteThreadFollower"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if err := repo.redisClient.ZRem(ctx, getThreadFollowersKey(threadId), string(userId)).Err(); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+func (repo *RedisRepository) GetThreadFollowers(ctx context.Context, threadId uuid.Uuid) ([]models.UserUid, error) {
+	const op errors.Op = "redis_repo.RedisRepository.GetThreadFollowers"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	followerIdStrs, err := repo.redisClient.ZRange(ctx, getThreadFollowersKey(threadId), 0, -1).Result()
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	var followerIds = make([]models.UserUid, 0, len(followerIdStrs))
+	for _, followerIdStr := range followerIdStrs {
+		followerIds = append(followerIds, models.UserUid(followerIdStr))
+	}
+
+	return followerIds, nil
+}
+
+// AddInboxMessage adds the message to the inboxes of the users. Every inbox keeps only its maxSize most recent messages.
+func (repo *RedisRepository) AddInboxMessage(ctx context.Context, userIds []models.UserUid, message models.Message, maxSize int64) error {
+	const op errors.Op = "redis_repo.RedisRepository.AddInboxMessage"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if len(userIds) == 0 {
+		return nil
+	}
+
+	pipe := repo.redisClient.Pipeline()
+	for _, userId := range userIds {
+		var userInboxKey = getUserInboxKey(userId)
+		pipe.ZAdd(ctx, userInboxKey, redis.Z{
+			Score:  float64(message.CreatedAt.UnixMilli()),
+			Member: message.ID.String(),
+		})
+		pipe.ZRemRangeByRank(ctx, userInboxKey, 0, -maxSize-1)
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// GetUserInbox returns the messages of the user's inbox, the most recent first. Messages that have been deleted since
+// they were added are left out.
+func (repo *RedisRepository) GetUserInbox(ctx context.Context, userId models.UserUid, offset, count int64) ([]models.MessageWithChildThreadMessagesCount, error) {
+	const op errors.Op = "redis_repo.RedisRepository.GetUserInbox"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	messages, err := repo.getThreadMessages(ctx, getUserInboxKey(userId), offset, count)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return messages, nil
+}
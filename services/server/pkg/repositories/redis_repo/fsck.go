@@ -0,0 +1,1956 @@
+package redis_repo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"spaces-p/pkg/errors"
+	"spaces-p/pkg/models"
+	"spaces-p/pkg/uuid"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type InconsistencyKind string
+
+const (
+	OrphanedSpaceCoordinates   InconsistencyKind = "orphaned_space_coordinates"
+	MissingSpaceCoordinates    InconsistencyKind = "missing_space_coordinates"
+	OrphanedKey                InconsistencyKind = "orphaned_key"
+	DanglingSetMember          InconsistencyKind = "dangling_set_member"
+	MissingSubscriptionMember  InconsistencyKind = "missing_subscription_member"
+	StaleActiveSubscriber      InconsistencyKind = "stale_active_subscriber"
+	UntrackedSession           InconsistencyKind = "untracked_session"
+	OrphanedThread             InconsistencyKind = "orphaned_thread"
+	MissingTopLevelThreadEntry InconsistencyKind = "missing_toplevel_thread_entry"
+	MessagesCountDrift         InconsistencyKind = "messages_count_drift"
+	OrphanedMessage            InconsistencyKind = "orphaned_message"
+	DanglingChildThread        InconsistencyKind = "dangling_child_thread"
+	InvalidAddress             InconsistencyKind = "invalid_address"
+	MissingExpiration          InconsistencyKind = "missing_expiration"
+	MissingHeldMessageEntry    InconsistencyKind = "missing_held_message_entry"
+	MissingReportEntry         InconsistencyKind = "missing_report_entry"
+	DanglingReadMarker         InconsistencyKind = "dangling_read_marker"
+	MissingTagEntry            InconsistencyKind = "missing_tag_entry"
+	MissingSpaceNameEntry      InconsistencyKind = "missing_space_name_entry"
+	MissingTileEntry           InconsistencyKind = "missing_tile_entry"
+	MissingUsernameEntry       InconsistencyKind = "missing_username_entry"
+)
+
+type Inconsistency struct {
+	Kind     InconsistencyKind
+	Key      string
+	Detail   string
+	Repaired bool
+}
+
+func (i Inconsistency) String() string {
+	var repaired string
+	if i.Repaired {
+		repaired = " (repaired)"
+	}
+
+	return fmt.Sprintf("[%s] %s: %s%s", i.Kind, i.Key, i.Detail, repaired)
+}
+
+// consistencyCheck holds the state of a single CheckConsistency run
+type consistencyCheck struct {
+	repo            *RedisRepository
+	repair          bool
+	inconsistencies []Inconsistency
+}
+
+// CheckConsistency scans all key families of keys.go and reports referential and counter inconsistencies.
+// When repair is true, every inconsistency is fixed right after it was found.
+//
+// Repairs can cascade (e.g. deleting an orphaned thread orphans its messages), so a repairing run should be repeated
+// until it reports no more inconsistencies.
+func (repo *RedisRepository) CheckConsistency(ctx context.Context, repair bool) ([]Inconsistency, error) {
+	const op errors.Op = "redis_repo.RedisRepository.CheckConsistency"
+	var check = &consistencyCheck{repo: repo, repair: repair}
+
+	steps := []func(ctx context.Context) error{
+		check.checkSpaceCoordinates,
+		check.checkSessionKeys,
+		check.checkSpaceKeys,
+		check.checkUserKeys,
+		check.checkUsernames,
+		check.checkThreadKeys,
+		check.checkMessageKeys,
+		check.checkHeldMessageKeys,
+		check.checkAttachmentKeys,
+		check.checkPollKeys,
+		check.checkPollClosings,
+		check.checkSpaceTransitions,
+		check.checkMessageRetentionSpaces,
+		check.checkTagKeys,
+		check.checkSpaceTags,
+		check.checkSpaceNames,
+		check.checkSpaceGeoHashes,
+		check.checkReportKeys,
+		check.checkReportsQueue,
+		check.checkAddressKeys,
+		check.checkRateLimitKeys,
+	}
+	for _, step := range steps {
+		if err := step(ctx); err != nil {
+			return check.inconsistencies, errors.E(op, err)
+		}
+	}
+
+	return check.inconsistencies, nil
+}
+
+// report records an inconsistency and runs fix if the check is repairing
+func (check *consistencyCheck) report(kind InconsistencyKind, key, detail string, fix func() error) error {
+	var inconsistency = Inconsistency{Kind: kind, Key: key, Detail: detail}
+
+	if check.repair && fix != nil {
+		if err := fix(); err != nil {
+			return err
+		}
+		inconsistency.Repaired = true
+	}
+
+	check.inconsistencies = append(check.inconsistencies, inconsistency)
+
+	return nil
+}
+
+func (check *consistencyCheck) checkSpaceCoordinates(ctx context.Context) error {
+	const op errors.Op = "redis_repo.consistencyCheck.checkSpaceCoordinates"
+	var client = check.repo.redisClient
+	var spaceCoordinatesKey = getSpaceCoordinatesKey()
+
+	members, err := client.ZRange(ctx, spaceCoordinatesKey, 0, -1).Result()
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	for _, member := range members {
+		spaceId, err := uuid.Parse(member)
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		exists, err := check.exists(ctx, getSpaceKey(spaceId))
+		if err != nil {
+			return errors.E(op, err)
+		}
+		if exists {
+			continue
+		}
+
+		if err := check.report(OrphanedSpaceCoordinates, spaceCoordinatesKey, fmt.Sprintf("space %s does not exist", member), func() error {
+			return client.ZRem(ctx, spaceCoordinatesKey, member).Err()
+		}); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	return nil
+}
+
+// checks that every session hash has an expiration, so the session reaper will eventually remove it, and vice versa
+func (check *consistencyCheck) checkSessionKeys(ctx context.Context) error {
+	const op errors.Op = "redis_repo.consistencyCheck.checkSessionKeys"
+	var client = check.repo.redisClient
+	var sessionExpirationsKey = getSessionExpirationsKey()
+
+	keys, err := check.repo.scanKeys(ctx, "sessions:*")
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	for _, key := range keys {
+		sessionIdStr := strings.TrimPrefix(key, "sessions:")
+
+		_, err := client.ZScore(ctx, sessionExpirationsKey, sessionIdStr).Result()
+		switch {
+		case errors.Is(err, redis.Nil):
+		case err != nil:
+			return errors.E(op, err)
+		default:
+			continue
+		}
+
+		if err := check.report(UntrackedSession, key, fmt.Sprintf("session is not part of %s", sessionExpirationsKey), func() error {
+			return client.Del(ctx, key).Err()
+		}); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	sessionIdStrs, err := client.ZRange(ctx, sessionExpirationsKey, 0, -1).Result()
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	for _, sessionIdStr := range sessionIdStrs {
+		sessionId, err := uuid.Parse(sessionIdStr)
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		exists, err := check.exists(ctx, getSessionKey(sessionId))
+		if err != nil {
+			return errors.E(op, err)
+		}
+		if exists {
+			continue
+		}
+
+		if err := check.report(DanglingSetMember, sessionExpirationsKey, fmt.Sprintf("session %s does not exist", sessionIdStr), func() error {
+			return client.ZRem(ctx, sessionExpirationsKey, sessionIdStr).Err()
+		}); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	return nil
+}
+
+func (check *consistencyCheck) checkSpaceKeys(ctx context.Context) error {
+	const op errors.Op = "redis_repo.consistencyCheck.checkSpaceKeys"
+	var client = check.repo.redisClient
+
+	keys, err := check.repo.scanKeys(ctx, "spaces:*")
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	for _, key := range keys {
+		// key might have been removed by an earlier repair of this run
+		keyExists, err := check.exists(ctx, key)
+		if err != nil {
+			return errors.E(op, err)
+		}
+		if !keyExists {
+			continue
+		}
+
+		spaceIdStr, suffix, ok := splitHashTaggedKey(key, "spaces:")
+		if !ok {
+			continue
+		}
+		spaceId, err := uuid.Parse(spaceIdStr)
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		spaceExists, err := check.exists(ctx, getSpaceKey(spaceId))
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		switch {
+		case suffix == "":
+			err = check.checkSpace(ctx, spaceId)
+			if err == nil {
+				err = check.checkSpaceTagEntries(ctx, spaceId)
+			}
+			if err == nil {
+				err = check.checkSpaceNameEntries(ctx, spaceId)
+			}
+			if err == nil {
+				err = check.checkSpaceTileEntry(ctx, spaceId)
+			}
+		case !spaceExists:
+			err = check.report(OrphanedKey, key, fmt.Sprintf("space %s does not exist", spaceId), func() error {
+				return client.Del(ctx, key).Err()
+			})
+		case suffix == ":subscribers":
+			err = check.checkSpaceSubscribers(ctx, spaceId)
+		case suffix == ":active_subscribers":
+			err = check.checkSpaceActiveSubscribers(ctx, spaceId)
+		case suffix == ":toplevel_threads_by_time", suffix == ":toplevel_threads_by_popularity":
+			err = check.checkSpaceTopLevelThreads(ctx, spaceId, key)
+		case suffix == ":held_messages":
+			err = check.checkSpaceHeldMessages(ctx, spaceId)
+		case suffix == ":reports":
+			err = check.checkReportsCollection(ctx, key)
+		case suffix == ":location_pins":
+			err = check.checkSpaceLocationPins(ctx, spaceId)
+		case suffix == ":pins":
+			err = check.checkSpacePins(ctx, spaceId)
+		case strings.HasPrefix(suffix, ":subscribers:") && strings.HasSuffix(suffix, ":sessions"):
+			err = check.checkSpaceSubscriberSessions(ctx, key)
+		}
+		if err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	return nil
+}
+
+func (check *consistencyCheck) checkSpace(ctx context.Context, spaceId uuid.Uuid) error {
+	const op errors.Op = "redis_repo.consistencyCheck.checkSpace"
+	var client = check.repo.redisClient
+	var spaceCoordinatesKey = getSpaceCoordinatesKey()
+
+	_, err := client.ZScore(ctx, spaceCoordinatesKey, spaceId.String()).Result()
+	switch {
+	case errors.Is(err, redis.Nil):
+	case err != nil:
+		return errors.E(op, err)
+	default:
+		return nil
+	}
+
+	spaceMap, err := client.HMGet(ctx, getSpaceKey(spaceId), spaceFields.locationField, spaceFields.archivedField).Result()
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	// archived spaces are removed from the coordinates on purpose
+	if spaceMap[1] == "1" {
+		return nil
+	}
+
+	var location models.Location
+	var fix func() error
+	if locationStr, ok := spaceMap[0].(string); ok && location.ParseString(locationStr) == nil {
+		fix = func() error {
+			return client.GeoAdd(ctx, spaceCoordinatesKey, &redis.GeoLocation{
+				Name:      spaceId.String(),
+				Longitude: location.Long,
+				Latitude:  location.Lat,
+			}).Err()
+		}
+	}
+
+	if err := check.report(MissingSpaceCoordinates, getSpaceKey(spaceId), fmt.Sprintf("space is not part of %s", spaceCoordinatesKey), fix); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// checks that a space that can be found by location is part of the indexes of its tags
+func (check *consistencyCheck) checkSpaceTagEntries(ctx context.Context, spaceId uuid.Uuid) error {
+	const op errors.Op = "redis_repo.consistencyCheck.checkSpaceTagEntries"
+	var client = check.repo.redisClient
+
+	spaceMap, err := client.HMGet(ctx, getSpaceKey(spaceId), spaceFields.tagsField, spaceFields.archivedField).Result()
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	tagsStr, ok := spaceMap[0].(string)
+	if !ok || spaceMap[1] == "1" {
+		return nil
+	}
+
+	for _, tag := range strings.Split(tagsStr, ",") {
+		var tagSpacesKey = getTagSpacesKey(tag)
+
+		isMember, err := client.SIsMember(ctx, tagSpacesKey, spaceId.String()).Result()
+		if err != nil {
+			return errors.E(op, err)
+		}
+		if isMember {
+			continue
+		}
+
+		if err := check.report(MissingTagEntry, tagSpacesKey, fmt.Sprintf("space %s is missing", spaceId), func() error {
+			return check.repo.indexSpaceTags(ctx, spaceId, []string{tag})
+		}); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	return nil
+}
+
+// checks that the name of a space that can be found by location can be searched for
+func (check *consistencyCheck) checkSpaceNameEntries(ctx context.Context, spaceId uuid.Uuid) error {
+	const op errors.Op = "redis_repo.consistencyCheck.checkSpaceNameEntries"
+	var client = check.repo.redisClient
+
+	spaceMap, err := client.HMGet(ctx, getSpaceKey(spaceId), spaceFields.nameField, spaceFields.archivedField).Result()
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	name, ok := spaceMap[0].(string)
+	if !ok || spaceMap[1] == "1" {
+		return nil
+	}
+
+	termMembers, trigramMembers := getSpaceNameMembers(spaceId, name)
+	for _, member := range termMembers {
+		if err := check.ensureSetMember(ctx, getSpaceNameTermsKey(), member, 0); err != nil {
+			return errors.E(op, err)
+		}
+	}
+	for _, member := range trigramMembers {
+		if err := check.ensureSetMember(ctx, getSpaceNameTrigramsKey(), member, 0); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	return nil
+}
+
+// checks that a space shown on the map is counted in the tiles
+func (check *consistencyCheck) checkSpaceTileEntry(ctx context.Context, spaceId uuid.Uuid) error {
+	const op errors.Op = "redis_repo.consistencyCheck.checkSpaceTileEntry"
+
+	spaceMap, err := check.repo.redisClient.HMGet(ctx, getSpaceKey(spaceId), spaceTileFields...).Result()
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	member, shown, ok := getSpaceTileEntry(spaceId, spaceMap, time.Now())
+	if !ok || !shown {
+		return nil
+	}
+
+	if err := check.ensureSetMember(ctx, getSpaceGeoHashesKey(), member, 0); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// checks that every subscriber exists and that the subscription is also stored in the user's spaces set
+func (check *consistencyCheck) checkSpaceSubscribers(ctx context.Context, spaceId uuid.Uuid) error {
+	const op errors.Op = "redis_repo.consistencyCheck.checkSpaceSubscribers"
+	var client = check.repo.redisClient
+	var spaceSubscribersKey = getSpaceSubscribersKey(spaceId)
+
+	subscribers, err := client.ZRangeWithScores(ctx, spaceSubscribersKey, 0, -1).Result()
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	for _, subscriber := range subscribers {
+		userId := models.UserUid(subscriber.Member)
+
+		userExists, err := check.exists(ctx, getUserKey(userId))
+		if err != nil {
+			return errors.E(op, err)
+		}
+		if !userExists {
+			if err := check.report(DanglingSetMember, spaceSubscribersKey, fmt.Sprintf("user %s does not exist", userId), func() error {
+				return client.ZRem(ctx, spaceSubscribersKey, string(userId)).Err()
+			}); err != nil {
+				return errors.E(op, err)
+			}
+
+			continue
+		}
+
+		var userSpacesKey = getUserSpacesKey(userId)
+		if err := check.ensureSetMember(ctx, userSpacesKey, spaceId.String(), subscriber.Score); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	return nil
+}
+
+// checks that every active subscriber is a subscriber and has at least one session left
+func (check *consistencyCheck) checkSpaceActiveSubscribers(ctx context.Context, spaceId uuid.Uuid) error {
+	const op errors.Op = "redis_repo.consistencyCheck.checkSpaceActiveSubscribers"
+	var client = check.repo.redisClient
+	var spaceActiveSubscribersKey = getSpaceActiveSubscribersKey(spaceId)
+
+	activeSubscribers, err := client.ZRange(ctx, spaceActiveSubscribersKey, 0, -1).Result()
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	for _, activeSubscriber := range activeSubscribers {
+		userId := models.UserUid(activeSubscriber)
+
+		_, err := client.ZScore(ctx, getSpaceSubscribersKey(spaceId), activeSubscriber).Result()
+		isSubscriber := err == nil
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return errors.E(op, err)
+		}
+
+		sessionsCount, err := client.ZCard(ctx, getSpaceActiveSubscriberSessionsKey(spaceId, userId)).Result()
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		var detail string
+		switch {
+		case !isSubscriber:
+			detail = fmt.Sprintf("user %s is not a subscriber", userId)
+		case sessionsCount == 0:
+			detail = fmt.Sprintf("user %s has no sessions left", userId)
+		default:
+			continue
+		}
+
+		if err := check.report(StaleActiveSubscriber, spaceActiveSubscribersKey, detail, func() error {
+			return client.ZRem(ctx, spaceActiveSubscribersKey, activeSubscriber).Err()
+		}); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	return nil
+}
+
+// sessions without an expiration would never be reaped
+func (check *consistencyCheck) checkSpaceSubscriberSessions(ctx context.Context, collectionKey string) error {
+	const op errors.Op = "redis_repo.consistencyCheck.checkSpaceSubscriberSessions"
+	var client = check.repo.redisClient
+	var sessionExpirationsKey = getSessionExpirationsKey()
+
+	sessionIdStrs, err := client.ZRange(ctx, collectionKey, 0, -1).Result()
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	for _, sessionIdStr := range sessionIdStrs {
+		_, err := client.ZScore(ctx, sessionExpirationsKey, sessionIdStr).Result()
+		switch {
+		case errors.Is(err, redis.Nil):
+		case err != nil:
+			return errors.E(op, err)
+		default:
+			continue
+		}
+
+		if err := check.report(UntrackedSession, collectionKey, fmt.Sprintf("session %s is not part of %s", sessionIdStr, sessionExpirationsKey), func() error {
+			return client.ZRem(ctx, collectionKey, sessionIdStr).Err()
+		}); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	return nil
+}
+
+func (check *consistencyCheck) checkSpaceTopLevelThreads(ctx context.Context, spaceId uuid.Uuid, collectionKey string) error {
+	const op errors.Op = "redis_repo.consistencyCheck.checkSpaceTopLevelThreads"
+	var client = check.repo.redisClient
+
+	threadIdStrs, err := client.ZRange(ctx, collectionKey, 0, -1).Result()
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	for _, threadIdStr := range threadIdStrs {
+		threadId, err := uuid.Parse(threadIdStr)
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		threadSpaceId, err := client.HGet(ctx, getThreadKey(threadId), threadFields.spaceIdField).Result()
+		switch {
+		case errors.Is(err, redis.Nil):
+		case err != nil:
+			return errors.E(op, err)
+		case threadSpaceId == spaceId.String():
+			continue
+		}
+
+		if err := check.report(DanglingSetMember, collectionKey, fmt.Sprintf("thread %s does not exist in this space", threadIdStr), func() error {
+			return client.ZRem(ctx, collectionKey, threadIdStr).Err()
+		}); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	return nil
+}
+
+func (check *consistencyCheck) checkSpaceHeldMessages(ctx context.Context, spaceId uuid.Uuid) error {
+	const op errors.Op = "redis_repo.consistencyCheck.checkSpaceHeldMessages"
+	var client = check.repo.redisClient
+	var spaceHeldMessagesKey = getSpaceHeldMessagesKey(spaceId)
+
+	heldMessageIdStrs, err := client.ZRange(ctx, spaceHeldMessagesKey, 0, -1).Result()
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	for _, heldMessageIdStr := range heldMessageIdStrs {
+		heldMessageId, err := uuid.Parse(heldMessageIdStr)
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		heldMessageSpaceId, err := client.HGet(ctx, getHeldMessageKey(heldMessageId), heldMessageFields.spaceIdField).Result()
+		switch {
+		case errors.Is(err, redis.Nil):
+		case err != nil:
+			return errors.E(op, err)
+		case heldMessageSpaceId == spaceId.String():
+			continue
+		}
+
+		if err := check.report(DanglingSetMember, spaceHeldMessagesKey, fmt.Sprintf("held message %s does not exist in this space", heldMessageIdStr), func() error {
+			return client.ZRem(ctx, spaceHeldMessagesKey, heldMessageIdStr).Err()
+		}); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	return nil
+}
+
+// checks that every location pin is a message of a thread of the space
+func (check *consistencyCheck) checkSpaceLocationPins(ctx context.Context, spaceId uuid.Uuid) error {
+	const op errors.Op = "redis_repo.consistencyCheck.checkSpaceLocationPins"
+	var client = check.repo.redisClient
+	var spaceLocationPinsKey = getSpaceLocationPinsKey(spaceId)
+
+	messageIdStrs, err := client.ZRange(ctx, spaceLocationPinsKey, 0, -1).Result()
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	for _, messageIdStr := range messageIdStrs {
+		messageId, err := uuid.Parse(messageIdStr)
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		threadIdStr, err := client.HGet(ctx, getMessageKey(messageId), messageFields.threadIdField).Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return errors.E(op, err)
+		}
+
+		if threadId, err := uuid.Parse(threadIdStr); err == nil {
+			threadSpaceId, err := client.HGet(ctx, getThreadKey(threadId), threadFields.spaceIdField).Result()
+			switch {
+			case errors.Is(err, redis.Nil):
+			case err != nil:
+				return errors.E(op, err)
+			case threadSpaceId == spaceId.String():
+				continue
+			}
+		}
+
+		if err := check.report(DanglingSetMember, spaceLocationPinsKey, fmt.Sprintf("location message %s does not exist in this space", messageIdStr), func() error {
+			return client.ZRem(ctx, spaceLocationPinsKey, messageIdStr).Err()
+		}); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	return nil
+}
+
+// checkSpacePins removes pins of toplevel threads and messages that don't exist in the space anymore
+func (check *consistencyCheck) checkSpacePins(ctx context.Context, spaceId uuid.Uuid) error {
+	const op errors.Op = "redis_repo.consistencyCheck.checkSpacePins"
+	var client = check.repo.redisClient
+	var spacePinsKey = getSpacePinsKey(spaceId)
+
+	members, err := client.ZRange(ctx, spacePinsKey, 0, -1).Result()
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	for _, member := range members {
+		var threadId uuid.Uuid
+		targetType, targetId, err := parsePinMember(member)
+		switch {
+		case err != nil:
+		case targetType == models.ThreadPinTarget:
+			// only toplevel threads can be pinned
+			firstMessageId, err := client.HGet(ctx, getThreadKey(targetId), threadFields.firstMessageIdField).Result()
+			switch {
+			case errors.Is(err, redis.Nil):
+			case err != nil:
+				return errors.E(op, err)
+			case firstMessageId != "":
+				threadId = targetId
+			}
+		case targetType == models.MessagePinTarget:
+			threadIdStr, err := client.HGet(ctx, getMessageKey(targetId), messageFields.threadIdField).Result()
+			if err != nil && !errors.Is(err, redis.Nil) {
+				return errors.E(op, err)
+			}
+			threadId, _ = uuid.Parse(threadIdStr)
+		}
+
+		if threadId != uuid.Nil {
+			threadSpaceId, err := client.HGet(ctx, getThreadKey(threadId), threadFields.spaceIdField).Result()
+			switch {
+			case errors.Is(err, redis.Nil):
+			case err != nil:
+				return errors.E(op, err)
+			case threadSpaceId == spaceId.String():
+				continue
+			}
+		}
+
+		if err := check.report(DanglingSetMember, spacePinsKey, fmt.Sprintf("pinned item %s does not exist in this space", member), func() error {
+			return client.ZRem(ctx, spacePinsKey, member).Err()
+		}); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	return nil
+}
+
+func (check *consistencyCheck) checkUserKeys(ctx context.Context) error {
+	const op errors.Op = "redis_repo.consistencyCheck.checkUserKeys"
+	var client = check.repo.redisClient
+
+	keys, err := check.repo.scanKeys(ctx, "users:*")
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	for _, key := range keys {
+		// key might have been removed by an earlier repair of this run
+		keyExists, err := check.exists(ctx, key)
+		if err != nil {
+			return errors.E(op, err)
+		}
+		if !keyExists {
+			continue
+		}
+
+		userIdStr, suffix, ok := splitHashTaggedKey(key, "users:")
+		if ok && suffix == "" {
+			if err := check.checkUserUsername(ctx, models.UserUid(userIdStr)); err != nil {
+				return errors.E(op, err)
+			}
+
+			continue
+		}
+		if !ok || (suffix != ":spaces" && suffix != ":blocked_users" && suffix != ":muted_users" && suffix != ":read_markers" && suffix != ":inbox") {
+			continue
+		}
+		userId := models.UserUid(userIdStr)
+
+		userExists, err := check.exists(ctx, getUserKey(userId))
+		if err != nil {
+			return errors.E(op, err)
+		}
+		if !userExists {
+			if err := check.report(OrphanedKey, key, fmt.Sprintf("user %s does not exist", userId), func() error {
+				return client.Del(ctx, key).Err()
+			}); err != nil {
+				return errors.E(op, err)
+			}
+
+			continue
+		}
+
+		if suffix == ":read_markers" {
+			if err := check.checkUserReadMarkers(ctx, key); err != nil {
+				return errors.E(op, err)
+			}
+
+			continue
+		}
+
+		if suffix == ":inbox" {
+			if err := check.checkUserInbox(ctx, key); err != nil {
+				return errors.E(op, err)
+			}
+
+			continue
+		}
+
+		if suffix != ":spaces" {
+			if err := check.checkUserRelations(ctx, key); err != nil {
+				return errors.E(op, err)
+			}
+
+			continue
+		}
+
+		userSpaces, err := client.ZRangeWithScores(ctx, key, 0, -1).Result()
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		for _, userSpace := range userSpaces {
+			spaceIdStr := userSpace.Member
+			spaceId, err := uuid.Parse(spaceIdStr)
+			if err != nil {
+				return errors.E(op, err)
+			}
+
+			spaceExists, err := check.exists(ctx, getSpaceKey(spaceId))
+			if err != nil {
+				return errors.E(op, err)
+			}
+			if !spaceExists {
+				if err := check.report(DanglingSetMember, key, fmt.Sprintf("space %s does not exist", spaceIdStr), func() error {
+					return client.ZRem(ctx, key, spaceIdStr).Err()
+				}); err != nil {
+					return errors.E(op, err)
+				}
+
+				continue
+			}
+
+			if err := check.ensureSetMember(ctx, getSpaceSubscribersKey(spaceId), string(userId), userSpace.Score); err != nil {
+				return errors.E(op, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// users that have a username can be mentioned by it
+func (check *consistencyCheck) checkUserUsername(ctx context.Context, userId models.UserUid) error {
+	const op errors.Op = "redis_repo.consistencyCheck.checkUserUsername"
+
+	username, err := check.repo.redisClient.HGet(ctx, getUserKey(userId), userFields.userUsernameField).Result()
+	switch {
+	case errors.Is(err, redis.Nil):
+		return nil
+	case err != nil:
+		return errors.E(op, err)
+	}
+
+	if member := getUsernameMember(userId, username); member != "" {
+		if err := check.ensureSetMember(ctx, getUsernamesKey(), member, 0); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	return nil
+}
+
+// the usernames of users that were deleted or have changed their username no longer mention them
+func (check *consistencyCheck) checkUsernames(ctx context.Context) error {
+	const op errors.Op = "redis_repo.consistencyCheck.checkUsernames"
+	var client = check.repo.redisClient
+	var usernamesKey = getUsernamesKey()
+
+	members, err := client.ZRange(ctx, usernamesKey, 0, -1).Result()
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	for _, member := range members {
+		userId := models.UserUid(member[strings.LastIndex(member, ":")+1:])
+
+		username, err := client.HGet(ctx, getUserKey(userId), userFields.userUsernameField).Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return errors.E(op, err)
+		}
+		if getUsernameMember(userId, username) == member {
+			continue
+		}
+
+		if err := check.report(DanglingSetMember, usernamesKey, fmt.Sprintf("%s is not the username of the user", member), func() error {
+			return client.ZRem(ctx, usernamesKey, member).Err()
+		}); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	return nil
+}
+
+// blocked and muted users that were deleted are no longer hidden from anyone
+func (check *consistencyCheck) checkUserRelations(ctx context.Context, collectionKey string) error {
+	const op errors.Op = "redis_repo.consistencyCheck.checkUserRelations"
+	var client = check.repo.redisClient
+
+	otherUserIdStrs, err := client.ZRange(ctx, collectionKey, 0, -1).Result()
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	for _, otherUserIdStr := range otherUserIdStrs {
+		otherUserExists, err := check.exists(ctx, getUserKey(models.UserUid(otherUserIdStr)))
+		if err != nil {
+			return errors.E(op, err)
+		}
+		if otherUserExists {
+			continue
+		}
+
+		if err := check.report(DanglingSetMember, collectionKey, fmt.Sprintf("user %s does not exist", otherUserIdStr), func() error {
+			return client.ZRem(ctx, collectionKey, otherUserIdStr).Err()
+		}); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	return nil
+}
+
+// checks that every read marker belongs to an existing space or thread
+func (check *consistencyCheck) checkUserReadMarkers(ctx context.Context, readMarkersKey string) error {
+	const op errors.Op = "redis_repo.consistencyCheck.checkUserReadMarkers"
+	var client = check.repo.redisClient
+
+	fields, err := client.HKeys(ctx, readMarkersKey).Result()
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	for _, field := range fields {
+		var targetKey string
+		if spaceIdStr, ok := strings.CutPrefix(field, "space:"); ok {
+			if spaceId, err := uuid.Parse(spaceIdStr); err == nil {
+				targetKey = getSpaceKey(spaceId)
+			}
+		} else if threadIdStr, ok := strings.CutPrefix(field, "thread:"); ok {
+			if threadId, err := uuid.Parse(threadIdStr); err == nil {
+				targetKey = getThreadKey(threadId)
+			}
+		}
+
+		if targetKey != "" {
+			targetExists, err := check.exists(ctx, targetKey)
+			if err != nil {
+				return errors.E(op, err)
+			}
+			if targetExists {
+				continue
+			}
+		}
+
+		if err := check.report(DanglingReadMarker, readMarkersKey, fmt.Sprintf("%s does not exist", field), func() error {
+			return client.HDel(ctx, readMarkersKey, field).Err()
+		}); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	return nil
+}
+
+// inboxes are not cleaned up when messages are deleted
+func (check *consistencyCheck) checkUserInbox(ctx context.Context, inboxKey string) error {
+	const op errors.Op = "redis_repo.consistencyCheck.checkUserInbox"
+	var client = check.repo.redisClient
+
+	messageIdStrs, err := client.ZRange(ctx, inboxKey, 0, -1).Result()
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	for _, messageIdStr := range messageIdStrs {
+		messageId, err := uuid.Parse(messageIdStr)
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		messageExists, err := check.exists(ctx, getMessageKey(messageId))
+		if err != nil {
+			return errors.E(op, err)
+		}
+		if messageExists {
+			continue
+		}
+
+		if err := check.report(DanglingSetMember, inboxKey, fmt.Sprintf("message %s does not exist", messageIdStr), func() error {
+			return client.ZRem(ctx, inboxKey, messageIdStr).Err()
+		}); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	return nil
+}
+
+func (check *consistencyCheck) checkThreadKeys(ctx context.Context) error {
+	const op errors.Op = "redis_repo.consistencyCheck.checkThreadKeys"
+	var client = check.repo.redisClient
+
+	keys, err := check.repo.scanKeys(ctx, "threads:*")
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	for _, key := range keys {
+		// key might have been removed by an earlier repair of this run
+		keyExists, err := check.exists(ctx, key)
+		if err != nil {
+			return errors.E(op, err)
+		}
+		if !keyExists {
+			continue
+		}
+
+		threadIdStr, suffix, ok := splitHashTaggedKey(key, "threads:")
+		if !ok {
+			continue
+		}
+		threadId, err := uuid.Parse(threadIdStr)
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		threadExists, err := check.exists(ctx, getThreadKey(threadId))
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		switch {
+		case suffix == "":
+			err = check.checkThread(ctx, threadId)
+		case !threadExists:
+			err = check.report(OrphanedKey, key, fmt.Sprintf("thread %s does not exist", threadId), func() error {
+				return client.Del(ctx, key).Err()
+			})
+		case suffix == ":messages_by_time", suffix == ":messages_by_popularity":
+			err = check.checkThreadMessages(ctx, threadId, key)
+		case suffix == ":followers":
+			err = check.checkUserRelations(ctx, key)
+		}
+		if err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	return nil
+}
+
+func (check *consistencyCheck) checkThread(ctx context.Context, threadId uuid.Uuid) error {
+	const op errors.Op = "redis_repo.consistencyCheck.checkThread"
+	var client = check.repo.redisClient
+	var threadKey = getThreadKey(threadId)
+
+	threadMap, err := client.HGetAll(ctx, threadKey).Result()
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	spaceId, err := uuid.Parse(threadMap[threadFields.spaceIdField])
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	// referenced entities that must exist
+	var references = map[string]string{getSpaceKey(spaceId): "space " + spaceId.String()}
+	if parentMessageIdStr := threadMap[threadFields.parentMessageIdField]; parentMessageIdStr != "" {
+		parentMessageId, err := uuid.Parse(parentMessageIdStr)
+		if err != nil {
+			return errors.E(op, err)
+		}
+		references[getMessageKey(parentMessageId)] = "parent message " + parentMessageIdStr
+	}
+	if firstMessageIdStr := threadMap[threadFields.firstMessageIdField]; firstMessageIdStr != "" {
+		firstMessageId, err := uuid.Parse(firstMessageIdStr)
+		if err != nil {
+			return errors.E(op, err)
+		}
+		references[getMessageKey(firstMessageId)] = "first message " + firstMessageIdStr
+	}
+
+	for referenceKey, reference := range references {
+		exists, err := check.exists(ctx, referenceKey)
+		if err != nil {
+			return errors.E(op, err)
+		}
+		if exists {
+			continue
+		}
+
+		if err := check.report(OrphanedThread, threadKey, fmt.Sprintf("%s does not exist", reference), func() error {
+			return check.deleteThread(ctx, spaceId, threadId)
+		}); err != nil {
+			return errors.E(op, err)
+		}
+
+		return nil
+	}
+
+	// toplevel threads must be part of their space's toplevel thread sets
+	if threadMap[threadFields.parentMessageIdField] == "" {
+		likes, err := strconv.ParseFloat(threadMap[threadFields.likesField], 64)
+		if err != nil {
+			return errors.E(op, err)
+		}
+		createdAt, err := strconv.ParseFloat(threadMap[threadFields.createdAtField], 64)
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		if err := check.ensureSetMember(ctx, getSpaceToplevelThreadsByTimeKey(spaceId), threadId.String(), createdAt); err != nil {
+			return errors.E(op, err)
+		}
+		if err := check.ensureSetMember(ctx, getSpaceToplevelThreadsByPopularityKey(spaceId), threadId.String(), likes); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	messagesCount, err := client.ZCard(ctx, getThreadMessagesByTimeKey(threadId)).Result()
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	storedMessagesCount := threadMap[threadFields.messagesCountField]
+	if storedMessagesCount != strconv.FormatInt(messagesCount, 10) {
+		detail := fmt.Sprintf("%s is %s, but thread has %d messages", threadFields.messagesCountField, storedMessagesCount, messagesCount)
+		if err := check.report(MessagesCountDrift, threadKey, detail, func() error {
+			return client.HSet(ctx, threadKey, threadFields.messagesCountField, messagesCount).Err()
+		}); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	return nil
+}
+
+func (check *consistencyCheck) checkThreadMessages(ctx context.Context, threadId uuid.Uuid, collectionKey string) error {
+	const op errors.Op = "redis_repo.consistencyCheck.checkThreadMessages"
+	var client = check.repo.redisClient
+
+	messageIdStrs, err := client.ZRange(ctx, collectionKey, 0, -1).Result()
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	for _, messageIdStr := range messageIdStrs {
+		messageId, err := uuid.Parse(messageIdStr)
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		messageThreadId, err := client.HGet(ctx, getMessageKey(messageId), messageFields.threadIdField).Result()
+		switch {
+		case errors.Is(err, redis.Nil):
+		case err != nil:
+			return errors.E(op, err)
+		case messageThreadId == threadId.String():
+			continue
+		}
+
+		if err := check.report(DanglingSetMember, collectionKey, fmt.Sprintf("message %s does not exist in this thread", messageIdStr), func() error {
+			return client.ZRem(ctx, collectionKey, messageIdStr).Err()
+		}); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	return nil
+}
+
+func (check *consistencyCheck) checkMessageKeys(ctx context.Context) error {
+	const op errors.Op = "redis_repo.consistencyCheck.checkMessageKeys"
+	var client = check.repo.redisClient
+
+	keys, err := check.repo.scanKeys(ctx, "messages:*")
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	for _, key := range keys {
+		// key might have been removed by an earlier repair of this run
+		keyExists, err := check.exists(ctx, key)
+		if err != nil {
+			return errors.E(op, err)
+		}
+		if !keyExists {
+			continue
+		}
+
+		messageMap, err := client.HGetAll(ctx, key).Result()
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		threadId, err := uuid.Parse(messageMap[messageFields.threadIdField])
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		threadExists, err := check.exists(ctx, getThreadKey(threadId))
+		if err != nil {
+			return errors.E(op, err)
+		}
+		if !threadExists {
+			if err := check.report(OrphanedMessage, key, fmt.Sprintf("thread %s does not exist", threadId), func() error {
+				return client.Del(ctx, key).Err()
+			}); err != nil {
+				return errors.E(op, err)
+			}
+
+			continue
+		}
+
+		childThreadIdStr := messageMap[messageFields.childThreadIdField]
+		if childThreadIdStr == "" {
+			continue
+		}
+		childThreadId, err := uuid.Parse(childThreadIdStr)
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		childThreadExists, err := check.exists(ctx, getThreadKey(childThreadId))
+		if err != nil {
+			return errors.E(op, err)
+		}
+		if !childThreadExists {
+			if err := check.report(DanglingChildThread, key, fmt.Sprintf("child thread %s does not exist", childThreadIdStr), func() error {
+				return client.HSet(ctx, key, messageFields.childThreadIdField, "").Err()
+			}); err != nil {
+				return errors.E(op, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// checks that every held message belongs to an existing space and is part of the space's review queue
+func (check *consistencyCheck) checkHeldMessageKeys(ctx context.Context) error {
+	const op errors.Op = "redis_repo.consistencyCheck.checkHeldMessageKeys"
+	var client = check.repo.redisClient
+
+	keys, err := check.repo.scanKeys(ctx, "held_messages:*")
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	for _, key := range keys {
+		heldMessageMap, err := client.HGetAll(ctx, key).Result()
+		switch {
+		case err != nil:
+			return errors.E(op, err)
+		case len(heldMessageMap) == 0:
+			// removed in the meantime
+			continue
+		}
+
+		spaceId, err := uuid.Parse(heldMessageMap[heldMessageFields.spaceIdField])
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		spaceExists, err := check.exists(ctx, getSpaceKey(spaceId))
+		if err != nil {
+			return errors.E(op, err)
+		}
+		if !spaceExists {
+			if err := check.report(OrphanedKey, key, fmt.Sprintf("space %s does not exist", spaceId), func() error {
+				return client.Del(ctx, key).Err()
+			}); err != nil {
+				return errors.E(op, err)
+			}
+
+			continue
+		}
+
+		heldAtMilli, err := strconv.ParseInt(heldMessageMap[heldMessageFields.heldAtField], 10, 64)
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		heldMessageIdStr := strings.TrimPrefix(key, "held_messages:")
+		if err := check.ensureSetMember(ctx, getSpaceHeldMessagesKey(spaceId), heldMessageIdStr, float64(heldAtMilli)); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	return nil
+}
+
+// the content of orphaned attachments is left in the blob store, only their metadata is removed
+func (check *consistencyCheck) checkAttachmentKeys(ctx context.Context) error {
+	const op errors.Op = "redis_repo.consistencyCheck.checkAttachmentKeys"
+	var client = check.repo.redisClient
+
+	keys, err := check.repo.scanKeys(ctx, "attachments:*")
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	for _, key := range keys {
+		spaceIdStr, err := client.HGet(ctx, key, attachmentFields.spaceIdField).Result()
+		switch {
+		case errors.Is(err, redis.Nil):
+			// removed in the meantime
+			continue
+		case err != nil:
+			return errors.E(op, err)
+		}
+
+		spaceId, err := uuid.Parse(spaceIdStr)
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		spaceExists, err := check.exists(ctx, getSpaceKey(spaceId))
+		if err != nil {
+			return errors.E(op, err)
+		}
+		if spaceExists {
+			continue
+		}
+
+		attachmentId, err := uuid.Parse(strings.TrimPrefix(key, "attachments:"))
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		// the content is queued for deletion from the blob store as well
+		if err := check.report(OrphanedKey, key, fmt.Sprintf("space %s does not exist", spaceId), func() error {
+			return check.repo.deleteAttachment(ctx, attachmentId)
+		}); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	return nil
+}
+
+// checks that the message of every poll exists
+func (check *consistencyCheck) checkPollKeys(ctx context.Context) error {
+	const op errors.Op = "redis_repo.consistencyCheck.checkPollKeys"
+	var client = check.repo.redisClient
+
+	keys, err := check.repo.scanKeys(ctx, "polls:*")
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	for _, key := range keys {
+		messageIdStr, _, ok := splitHashTaggedKey(key, "polls:")
+		if !ok {
+			continue
+		}
+		messageId, err := uuid.Parse(messageIdStr)
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		messageExists, err := check.exists(ctx, getMessageKey(messageId))
+		if err != nil {
+			return errors.E(op, err)
+		}
+		if messageExists {
+			continue
+		}
+
+		if err := check.report(OrphanedKey, key, fmt.Sprintf("message %s does not exist", messageId), func() error {
+			return client.Del(ctx, key).Err()
+		}); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	return nil
+}
+
+// checks that every scheduled poll closing belongs to an existing poll that is still open
+func (check *consistencyCheck) checkPollClosings(ctx context.Context) error {
+	const op errors.Op = "redis_repo.consistencyCheck.checkPollClosings"
+	var client = check.repo.redisClient
+	var pollClosingsKey = getPollClosingsKey()
+
+	messageIdStrs, err := client.ZRange(ctx, pollClosingsKey, 0, -1).Result()
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	for _, messageIdStr := range messageIdStrs {
+		messageId, err := uuid.Parse(messageIdStr)
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		closed, err := client.HGet(ctx, getPollKey(messageId), pollFields.closedField).Result()
+		switch {
+		case errors.Is(err, redis.Nil):
+		case err != nil:
+			return errors.E(op, err)
+		case closed == "0":
+			continue
+		}
+
+		if err := check.report(DanglingSetMember, pollClosingsKey, fmt.Sprintf("poll %s does not exist or is closed", messageIdStr), func() error {
+			return client.ZRem(ctx, pollClosingsKey, messageIdStr).Err()
+		}); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	return nil
+}
+
+// checks that every scheduled space transition belongs to an existing time-bounded space that isn't archived yet
+func (check *consistencyCheck) checkSpaceTransitions(ctx context.Context) error {
+	const op errors.Op = "redis_repo.consistencyCheck.checkSpaceTransitions"
+	var client = check.repo.redisClient
+	var spaceTransitionsKey = getSpaceTransitionsKey()
+
+	spaceIdStrs, err := client.ZRange(ctx, spaceTransitionsKey, 0, -1).Result()
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	for _, spaceIdStr := range spaceIdStrs {
+		spaceId, err := uuid.Parse(spaceIdStr)
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		spaceMap, err := client.HMGet(ctx, getSpaceKey(spaceId), spaceFields.startsAtField, spaceFields.endsAtField, spaceFields.archivedField).Result()
+		if err != nil {
+			return errors.E(op, err)
+		}
+		if (spaceMap[0] != nil || spaceMap[1] != nil) && spaceMap[2] != "1" {
+			continue
+		}
+
+		if err := check.report(DanglingSetMember, spaceTransitionsKey, fmt.Sprintf("space %s does not exist, is not time-bounded or is archived", spaceIdStr), func() error {
+			return client.ZRem(ctx, spaceTransitionsKey, spaceIdStr).Err()
+		}); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	return nil
+}
+
+// checks that every space with a message retention exists and still has its retention set
+func (check *consistencyCheck) checkMessageRetentionSpaces(ctx context.Context) error {
+	const op errors.Op = "redis_repo.consistencyCheck.checkMessageRetentionSpaces"
+	var client = check.repo.redisClient
+	var messageRetentionSpacesKey = getMessageRetentionSpacesKey()
+
+	spaceIdStrs, err := client.SMembers(ctx, messageRetentionSpacesKey).Result()
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	for _, spaceIdStr := range spaceIdStrs {
+		spaceId, err := uuid.Parse(spaceIdStr)
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		hasRetention, err := client.HExists(ctx, getSpaceKey(spaceId), spaceFields.retentionField).Result()
+		if err != nil {
+			return errors.E(op, err)
+		}
+		if hasRetention {
+			continue
+		}
+
+		if err := check.report(DanglingSetMember, messageRetentionSpacesKey, fmt.Sprintf("space %s does not exist or has no message retention", spaceIdStr), func() error {
+			return client.SRem(ctx, messageRetentionSpacesKey, spaceIdStr).Err()
+		}); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	return nil
+}
+
+func (check *consistencyCheck) checkTagKeys(ctx context.Context) error {
+	const op errors.Op = "redis_repo.consistencyCheck.checkTagKeys"
+
+	keys, err := check.repo.scanKeys(ctx, "tags:*")
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	for _, key := range keys {
+		tag, suffix, ok := splitHashTaggedKey(key, "tags:")
+		if !ok || suffix != ":spaces" {
+			continue
+		}
+
+		if err := check.checkTagSpaces(ctx, tag); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	return nil
+}
+
+// checks that every space of the tag exists, can be found by location and still carries the tag, and that the tag can
+// be looked up by prefix
+func (check *consistencyCheck) checkTagSpaces(ctx context.Context, tag string) error {
+	const op errors.Op = "redis_repo.consistencyCheck.checkTagSpaces"
+	var client = check.repo.redisClient
+	var tagSpacesKey = getTagSpacesKey(tag)
+
+	spaceIdStrs, err := client.SMembers(ctx, tagSpacesKey).Result()
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	for _, spaceIdStr := range spaceIdStrs {
+		spaceId, err := uuid.Parse(spaceIdStr)
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		spaceMap, err := client.HMGet(ctx, getSpaceKey(spaceId), spaceFields.tagsField, spaceFields.archivedField).Result()
+		if err != nil {
+			return errors.E(op, err)
+		}
+		if tagsStr, ok := spaceMap[0].(string); ok && spaceMap[1] != "1" && slices.Contains(strings.Split(tagsStr, ","), tag) {
+			continue
+		}
+
+		if err := check.report(DanglingSetMember, tagSpacesKey, fmt.Sprintf("space %s does not exist, is archived or does not carry the tag", spaceIdStr), func() error {
+			return client.SRem(ctx, tagSpacesKey, spaceIdStr).Err()
+		}); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	// the set is removed along with its last member
+	tagSpacesExist, err := check.exists(ctx, tagSpacesKey)
+	if err != nil {
+		return errors.E(op, err)
+	}
+	if !tagSpacesExist {
+		return nil
+	}
+
+	if err := check.ensureSetMember(ctx, getSpaceTagsKey(), tag, 0); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// checks that every tag is still carried by a space
+func (check *consistencyCheck) checkSpaceTags(ctx context.Context) error {
+	const op errors.Op = "redis_repo.consistencyCheck.checkSpaceTags"
+	var client = check.repo.redisClient
+	var spaceTagsKey = getSpaceTagsKey()
+
+	tags, err := client.ZRange(ctx, spaceTagsKey, 0, -1).Result()
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	for _, tag := range tags {
+		tagSpacesExist, err := check.exists(ctx, getTagSpacesKey(tag))
+		if err != nil {
+			return errors.E(op, err)
+		}
+		if tagSpacesExist {
+			continue
+		}
+
+		if err := check.report(DanglingSetMember, spaceTagsKey, fmt.Sprintf("no space carries the tag %s", tag), func() error {
+			return client.ZRem(ctx, spaceTagsKey, tag).Err()
+		}); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	return nil
+}
+
+// checks that every entry of the space name sets belongs to the current name of a space that can be found by location
+func (check *consistencyCheck) checkSpaceNames(ctx context.Context) error {
+	const op errors.Op = "redis_repo.consistencyCheck.checkSpaceNames"
+	var client = check.repo.redisClient
+
+	// the entries that the spaces should have, by space id
+	var spaceNameMembers = make(map[string]map[string]bool)
+	for _, collectionKey := range []string{getSpaceNameTermsKey(), getSpaceNameTrigramsKey()} {
+		members, err := client.ZRange(ctx, collectionKey, 0, -1).Result()
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		for _, member := range members {
+			spaceIdStr := spaceIdOfNameMember(member)
+			if _, ok := spaceNameMembers[spaceIdStr]; !ok {
+				spaceNameMembers[spaceIdStr], err = check.getSpaceNameMembers(ctx, spaceIdStr)
+				if err != nil {
+					return errors.E(op, err)
+				}
+			}
+			if spaceNameMembers[spaceIdStr][member] {
+				continue
+			}
+
+			if err := check.report(DanglingSetMember, collectionKey, fmt.Sprintf("%s does not belong to the name of a space that can be found by location", member), func() error {
+				return client.ZRem(ctx, collectionKey, member).Err()
+			}); err != nil {
+				return errors.E(op, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// checks that every entry of the space geohashes belongs to the current location of a space shown on the map
+func (check *consistencyCheck) checkSpaceGeoHashes(ctx context.Context) error {
+	const op errors.Op = "redis_repo.consistencyCheck.checkSpaceGeoHashes"
+	var client = check.repo.redisClient
+	var spaceGeoHashesKey = getSpaceGeoHashesKey()
+
+	members, err := client.ZRange(ctx, spaceGeoHashesKey, 0, -1).Result()
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	var now = time.Now()
+	for _, member := range members {
+		var shownMember string
+		if _, spaceIdStr, ok := strings.Cut(member, ":"); ok {
+			if spaceId, err := uuid.Parse(spaceIdStr); err == nil {
+				spaceMap, err := client.HMGet(ctx, getSpaceKey(spaceId), spaceTileFields...).Result()
+				if err != nil {
+					return errors.E(op, err)
+				}
+				if entry, shown, ok := getSpaceTileEntry(spaceId, spaceMap, now); ok && shown {
+					shownMember = entry
+				}
+			}
+		}
+		if member == shownMember {
+			continue
+		}
+
+		if err := check.report(DanglingSetMember, spaceGeoHashesKey, fmt.Sprintf("%s does not belong to the location of a space shown on the map", member), func() error {
+			return client.ZRem(ctx, spaceGeoHashesKey, member).Err()
+		}); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	return nil
+}
+
+// getSpaceNameMembers returns the entries of the space's name in the space name sets, none if the space does not exist
+// or is archived
+func (check *consistencyCheck) getSpaceNameMembers(ctx context.Context, spaceIdStr string) (map[string]bool, error) {
+	const op errors.Op = "redis_repo.consistencyCheck.getSpaceNameMembers"
+
+	spaceId, err := uuid.Parse(spaceIdStr)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	spaceMap, err := check.repo.redisClient.HMGet(ctx, getSpaceKey(spaceId), spaceFields.nameField, spaceFields.archivedField).Result()
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	var members = make(map[string]bool)
+	if name, ok := spaceMap[0].(string); ok && spaceMap[1] != "1" {
+		termMembers, trigramMembers := getSpaceNameMembers(spaceId, name)
+		for _, member := range append(termMembers, trigramMembers...) {
+			members[member] = true
+		}
+	}
+
+	return members, nil
+}
+
+// checks that the target of every report exists and that the report is part of its review queues
+func (check *consistencyCheck) checkReportKeys(ctx context.Context) error {
+	const op errors.Op = "redis_repo.consistencyCheck.checkReportKeys"
+	var client = check.repo.redisClient
+
+	keys, err := check.repo.scanKeys(ctx, "reports:*")
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	for _, key := range keys {
+		// key might have been removed by an earlier repair of this run
+		keyExists, err := check.exists(ctx, key)
+		if err != nil {
+			return errors.E(op, err)
+		}
+		if !keyExists {
+			continue
+		}
+
+		targetStr, suffix, ok := splitHashTaggedKey(key, "reports:")
+		if !ok {
+			continue
+		}
+		var target models.ReportTarget
+		if err := target.ParseString(targetStr); err != nil {
+			return errors.E(op, err)
+		}
+
+		switch suffix {
+		case "":
+			err = check.checkReport(ctx, target)
+		case ":reporters":
+			var reportExists bool
+			reportExists, err = check.exists(ctx, getReportKey(target))
+			if err == nil && !reportExists {
+				err = check.report(OrphanedKey, key, fmt.Sprintf("report of %s does not exist", targetStr), func() error {
+					return client.Del(ctx, key).Err()
+				})
+			}
+		}
+		if err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	return nil
+}
+
+func (check *consistencyCheck) checkReport(ctx context.Context, target models.ReportTarget) error {
+	const op errors.Op = "redis_repo.consistencyCheck.checkReport"
+	var reportKey = getReportKey(target)
+
+	report, err := check.repo.GetReport(ctx, target)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	targetKey, err := getReportTargetKey(target)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	targetExists, err := check.exists(ctx, targetKey)
+	if err != nil {
+		return errors.E(op, err)
+	}
+	if !targetExists {
+		if err := check.report(OrphanedKey, reportKey, fmt.Sprintf("reported %s does not exist", target.Type), func() error {
+			return check.repo.DeleteReport(ctx, target, report.SpaceId)
+		}); err != nil {
+			return errors.E(op, err)
+		}
+
+		return nil
+	}
+
+	if err := check.ensureSetMember(ctx, getReportsQueueKey(), target.String(), float64(report.ReportsCount)); err != nil {
+		return errors.E(op, err)
+	}
+
+	if target.HasSpaceQueue() {
+		if err := check.ensureSetMember(ctx, getSpaceReportsKey(report.SpaceId), target.String(), float64(report.ReportsCount)); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	return nil
+}
+
+func (check *consistencyCheck) checkReportsQueue(ctx context.Context) error {
+	const op errors.Op = "redis_repo.consistencyCheck.checkReportsQueue"
+
+	if err := check.checkReportsCollection(ctx, getReportsQueueKey()); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// checkReportsCollection checks that the report of every target in the review queue exists
+func (check *consistencyCheck) checkReportsCollection(ctx context.Context, collectionKey string) error {
+	const op errors.Op = "redis_repo.consistencyCheck.checkReportsCollection"
+	var client = check.repo.redisClient
+
+	targetStrs, err := client.ZRange(ctx, collectionKey, 0, -1).Result()
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	for _, targetStr := range targetStrs {
+		var target models.ReportTarget
+		if err := target.ParseString(targetStr); err != nil {
+			return errors.E(op, err)
+		}
+
+		reportExists, err := check.exists(ctx, getReportKey(target))
+		if err != nil {
+			return errors.E(op, err)
+		}
+		if reportExists {
+			continue
+		}
+
+		if err := check.report(DanglingSetMember, collectionKey, fmt.Sprintf("report of %s does not exist", targetStr), func() error {
+			return client.ZRem(ctx, collectionKey, targetStr).Err()
+		}); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	return nil
+}
+
+func (check *consistencyCheck) checkAddressKeys(ctx context.Context) error {
+	const op errors.Op = "redis_repo.consistencyCheck.checkAddressKeys"
+	var client = check.repo.redisClient
+
+	keys, err := check.repo.scanKeys(ctx, "addresses:*")
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	for _, key := range keys {
+		addressJson, err := client.Get(ctx, key).Result()
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		var address models.Address
+		if err := json.Unmarshal([]byte(addressJson), &address); err == nil {
+			continue
+		}
+
+		if err := check.report(InvalidAddress, key, "value is not a valid address", func() error {
+			return client.Del(ctx, key).Err()
+		}); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	return nil
+}
+
+// rate limit keys are only cleaned up by their expiration
+func (check *consistencyCheck) checkRateLimitKeys(ctx context.Context) error {
+	const op errors.Op = "redis_repo.consistencyCheck.checkRateLimitKeys"
+	var client = check.repo.redisClient
+
+	keys, err := check.repo.scanKeys(ctx, "rate_limits:*")
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	for _, key := range keys {
+		ttl, err := client.PTTL(ctx, key).Result()
+		if err != nil {
+			return errors.E(op, err)
+		}
+		// -2 means that the key has expired in the meantime
+		if ttl != -1 {
+			continue
+		}
+
+		if err := check.report(MissingExpiration, key, "rate limit key never expires", func() error {
+			return client.Del(ctx, key).Err()
+		}); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	return nil
+}
+
+// ensureSetMember reports and, when repairing, adds member to the sorted set if it is missing
+func (check *consistencyCheck) ensureSetMember(ctx context.Context, collectionKey, member string, score float64) error {
+	const op errors.Op = "redis_repo.consistencyCheck.ensureSetMember"
+	var client = check.repo.redisClient
+
+	_, err := client.ZScore(ctx, collectionKey, member).Result()
+	switch {
+	case errors.Is(err, redis.Nil):
+	case err != nil:
+		return errors.E(op, err)
+	default:
+		return nil
+	}
+
+	kind := MissingSubscriptionMember
+	switch {
+	case strings.Contains(collectionKey, ":toplevel_threads_by_"):
+		kind = MissingTopLevelThreadEntry
+	case strings.HasSuffix(collectionKey, ":held_messages"):
+		kind = MissingHeldMessageEntry
+	case strings.HasSuffix(collectionKey, ":reports"), collectionKey == getReportsQueueKey():
+		kind = MissingReportEntry
+	case collectionKey == getSpaceTagsKey():
+		kind = MissingTagEntry
+	case collectionKey == getSpaceNameTermsKey(), collectionKey == getSpaceNameTrigramsKey():
+		kind = MissingSpaceNameEntry
+	case collectionKey == getSpaceGeoHashesKey():
+		kind = MissingTileEntry
+	case collectionKey == getUsernamesKey():
+		kind = MissingUsernameEntry
+	}
+
+	if err := check.report(kind, collectionKey, fmt.Sprintf("%s is missing", member), func() error {
+		return client.ZAdd(ctx, collectionKey, redis.Z{Score: score, Member: member}).Err()
+	}); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// deleteThread removes the thread and its message sets. Its messages are left to the orphaned message check.
+func (check *consistencyCheck) deleteThread(ctx context.Context, spaceId, threadId uuid.Uuid) error {
+	var client = check.repo.redisClient
+
+	if err := client.Del(ctx, getThreadKey(threadId), getThreadMessagesByTimeKey(threadId), getThreadMessagesByPopularityKey(threadId)).Err(); err != nil {
+		return err
+	}
+
+	if err := client.ZRem(ctx, getSpaceToplevelThreadsByTimeKey(spaceId), threadId.String()).Err(); err != nil {
+		return err
+	}
+
+	return client.ZRem(ctx, getSpaceToplevelThreadsByPopularityKey(spaceId), threadId.String()).Err()
+}
+
+func (check *consistencyCheck) exists(ctx context.Context, key string) (bool, error) {
+	n, err := check.repo.redisClient.Exists(ctx, key).Result()
+
+	return n > 0, err
+}
+
+// scanKeys returns all keys matching pattern. In cluster mode all master nodes are scanned.
+func (repo *RedisRepository) scanKeys(ctx context.Context, pattern string) ([]string, error) {
+	const op errors.Op = "redis_repo.RedisRepository.scanKeys"
+	var mu sync.Mutex
+	var keys []string
+
+	scan := func(ctx context.Context, client redis.Cmdable) error {
+		iter := client.Scan(ctx, 0, pattern, 1000).Iterator()
+		for iter.Next(ctx) {
+			mu.Lock()
+			keys = append(keys, iter.Val())
+			mu.Unlock()
+		}
+
+		return iter.Err()
+	}
+
+	var err error
+	if clusterClient, ok := repo.redisClient.(*redis.ClusterClient); ok {
+		err = clusterClient.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+			return scan(ctx, client)
+		})
+	} else {
+		err = scan(ctx, repo.redisClient)
+	}
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return keys, nil
+}
+
+// splitHashTaggedKey splits e.g. spaces:{[spaceid]}:subscribers into "[spaceid]" and ":subscribers"
+func splitHashTaggedKey(key, prefix string) (id, suffix string, ok bool) {
+	rest, found := strings.CutPrefix(key, prefix+"{")
+	if !found {
+		return "", "", false
+	}
+
+	id, suffix, found = strings.Cut(rest, "}")
+	if !found {
+		return "", "", false
+	}
+
+	return id, suffix, true
+}
@@ -0,0 +1,200 @@
+package redis_repo
+
+import (
+	"context"
+	"spaces-p/pkg/common"
+	"spaces-p/pkg/errors"
+	"spaces-p/pkg/models"
+	"spaces-p/pkg/tracing"
+	"spaces-p/pkg/utils"
+	"spaces-p/pkg/uuid"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func (repo *RedisRepository) SetHeldMessage(ctx context.Context, newHeldMessage models.NewHeldMessage) (*models.HeldMessage, error) {
+	const op errors.Op = "redis_repo.RedisRepository.SetHeldMessage"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+	var heldMessageId = uuid.New()
+	var heldMessageKey = getHeldMessageKey(heldMessageId)
+	var spaceHeldMessagesKey = getSpaceHeldMessagesKey(newHeldMessage.SpaceId)
+	var heldAt = time.Now()
+
+	messageTypeStr, err := newHeldMessage.Type.String()
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	var threadIdStr string
+	if newHeldMessage.ThreadId != uuid.Nil {
+		threadIdStr = newHeldMessage.ThreadId.String()
+	}
+
+	var attachmentIdStr string
+	if newHeldMessage.AttachmentId != uuid.Nil {
+		attachmentIdStr = newHeldMessage.AttachmentId.String()
+	}
+
+	payloadStr, err := formatMessagePayload(newHeldMessage.Payload)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	if err := repo.redisClient.HSet(ctx, heldMessageKey, map[string]any{
+		heldMessageFields.spaceIdField:      newHeldMessage.SpaceId.String(),
+		heldMessageFields.threadIdField:     threadIdStr,
+		heldMessageFields.senderIdField:     string(newHeldMessage.SenderId),
+		heldMessageFields.contentField:      newHeldMessage.Content,
+		heldMessageFields.typeField:         messageTypeStr,
+		heldMessageFields.attachmentIdField: attachmentIdStr,
+		heldMessageFields.payloadField:      payloadStr,
+		heldMessageFields.reasonField:       newHeldMessage.Reason,
+		heldMessageFields.filterField:       newHeldMessage.Filter,
+		heldMessageFields.heldAtField:       strconv.FormatInt(heldAt.UnixMilli(), 10),
+	}).Err(); err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	if err := repo.redisClient.ZAdd(ctx, spaceHeldMessagesKey, redis.Z{
+		Score:  float64(heldAt.UnixMilli()),
+		Member: heldMessageId.String(),
+	}).Err(); err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return &models.HeldMessage{
+		NewHeldMessage: newHeldMessage,
+		ID:             heldMessageId,
+		HeldAt:         heldAt,
+	}, nil
+}
+
+func (repo *RedisRepository) GetHeldMessage(ctx context.Context, heldMessageId uuid.Uuid) (*models.HeldMessage, error) {
+	const op errors.Op = "redis_repo.RedisRepository.GetHeldMessage"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+	var heldMessageKey = getHeldMessageKey(heldMessageId)
+
+	heldMessageMap, err := repo.redisClient.HGetAll(ctx, heldMessageKey).Result()
+	switch {
+	case err != nil:
+		return nil, errors.E(op, err)
+	case len(heldMessageMap) == 0:
+		return nil, errors.E(op, common.ErrNotFound)
+	}
+
+	heldMessage, err := parseHeldMessage(heldMessageMap)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+	heldMessage.ID = heldMessageId
+
+	return heldMessage, nil
+}
+
+// GetSpaceHeldMessages returns the space's held messages, the most recently held first
+func (repo *RedisRepository) GetSpaceHeldMessages(ctx context.Context, spaceId uuid.Uuid, offset, count int64) ([]models.HeldMessage, error) {
+	const op errors.Op = "redis_repo.RedisRepository.GetSpaceHeldMessages"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+	var spaceHeldMessagesKey = getSpaceHeldMessagesKey(spaceId)
+
+	heldMessageMaps, heldMessageIds, err := getCollectionValues(ctx, repo, spaceHeldMessagesKey, offset, count, getHeldMessageKey)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	var heldMessages = make([]models.HeldMessage, 0, len(heldMessageMaps))
+	for i, heldMessageMap := range heldMessageMaps {
+		// deleted in the meantime
+		if len(heldMessageMap) == 0 {
+			continue
+		}
+
+		heldMessage, err := parseHeldMessage(heldMessageMap)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+		heldMessage.ID = heldMessageIds[i]
+
+		heldMessages = append(heldMessages, *heldMessage)
+	}
+
+	return heldMessages, nil
+}
+
+func (repo *RedisRepository) DeleteHeldMessage(ctx context.Context, spaceId, heldMessageId uuid.Uuid) error {
+	const op errors.Op = "redis_repo.RedisRepository.DeleteHeldMessage"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+	var spaceHeldMessagesKey = getSpaceHeldMessagesKey(spaceId)
+
+	if err := repo.redisClient.ZRem(ctx, spaceHeldMessagesKey, heldMessageId.String()).Err(); err != nil {
+		return errors.E(op, err)
+	}
+
+	if err := repo.redisClient.Del(ctx, getHeldMessageKey(heldMessageId)).Err(); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+func parseHeldMessage(heldMessageMap map[string]string) (*models.HeldMessage, error) {
+	const op errors.Op = "redis_repo.parseHeldMessage"
+
+	spaceId, err := uuid.Parse(heldMessageMap[heldMessageFields.spaceIdField])
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	var threadId = uuid.Nil
+	if threadIdStr := heldMessageMap[heldMessageFields.threadIdField]; threadIdStr != "" {
+		threadId, err = uuid.Parse(threadIdStr)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+	}
+
+	var messageType models.MessageType
+	if err := messageType.Parse(heldMessageMap[heldMessageFields.typeField]); err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	attachmentId, err := uuid.Parse(heldMessageMap[heldMessageFields.attachmentIdField])
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	payload, err := parseMessagePayload(messageType, heldMessageMap[heldMessageFields.payloadField])
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	heldAt, err := utils.StringToTime(heldMessageMap[heldMessageFields.heldAtField])
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return &models.HeldMessage{
+		NewHeldMessage: models.NewHeldMessage{
+			NewMessage: models.NewMessage{
+				BaseMessage: models.BaseMessage{
+					Content:      heldMessageMap[heldMessageFields.contentField],
+					Type:         messageType,
+					AttachmentId: attachmentId,
+					Payload:      payload,
+				},
+				SenderId: models.UserUid(heldMessageMap[heldMessageFields.senderIdField]),
+				ThreadId: threadId,
+			},
+			SpaceId: spaceId,
+			Reason:  heldMessageMap[heldMessageFields.reasonField],
+			Filter:  heldMessageMap[heldMessageFields.filterField],
+		},
+		HeldAt: heldAt,
+	}, nil
+}
@@ -3,8 +3,16 @@ package redis_repo
 import (
 	"spaces-p/pkg/models"
 	"spaces-p/pkg/uuid"
+	"strings"
 )
 
+// Entity ids of users, spaces and threads are wrapped in a hash tag ({...}), so that an entity's hash and all keys
+// derived from it map to the same slot in Redis Cluster. This keeps multi-key operations on them (e.g. WATCH + MULTI)
+// possible in cluster mode.
+func hashTag(id string) string {
+	return "{" + id + "}"
+}
+
 // ---- USER ----
 
 var userFields = struct {
@@ -12,22 +20,81 @@ var userFields = struct {
 	userLastNameField  string
 	userUsernameField  string
 	userAvatarUrlField string
-}{userFirstNameField: "first_name", userLastNameField: "last_name", userUsernameField: "username", userAvatarUrlField: "avatar_url"}
+	userIsBannedField  string
+}{userFirstNameField: "first_name", userLastNameField: "last_name", userUsernameField: "username", userAvatarUrlField: "avatar_url", userIsBannedField: "is_banned"}
 
-// getUserKey returns a redis key: users:[user_uid]
+// getUserKey returns a redis key: users:{[user_uid]}
 //
-// The keys holds a HASH value with the following fields: "is_signed_up", "first_name", "last_name" "username", "avatar_url"
+// The keys holds a HASH value with the following fields: "is_signed_up", "first_name", "last_name" "username", "avatar_url",
+// "is_banned"
 func getUserKey(userId models.UserUid) string {
-	return "users:" + string(userId)
+	return "users:" + hashTag(string(userId))
 }
 
-// getUserSpacesKey returns a redis key: users:[user_uid]:spaces
+// getUsernamesKey returns a redis key: usernames
+//
+// The key holds a SORTED SET value with the lowercased usernames of the users as [username]:[user_uid] MEMBERS, all with
+// a SCORE of 0, so that the users of a username can be looked up lexicographically. Usernames are not unique.
+func getUsernamesKey() string {
+	return "usernames"
+}
+
+// getUsernameMember returns the member of the user in usernames, an empty string if the user has no username
+func getUsernameMember(userId models.UserUid, username string) string {
+	if username == "" {
+		return ""
+	}
+
+	return strings.ToLower(username) + ":" + string(userId)
+}
+
+// getUserSpacesKey returns a redis key: users:{[user_uid]}:spaces
 //
 // The keys hold SORTED SET values with the space ids as MEMBERS and joining time as SCORES
 func getUserSpacesKey(userId models.UserUid) string {
 	return getUserKey(userId) + ":spaces"
 }
 
+// getUserBlockedUsersKey returns a redis key: users:{[user_uid]}:blocked_users
+//
+// The key holds a SORTED SET value with the ids of the users the user has blocked as MEMBERS and the blocking time as SCORES
+func getUserBlockedUsersKey(userId models.UserUid) string {
+	return getUserKey(userId) + ":blocked_users"
+}
+
+// getUserMutedUsersKey returns a redis key: users:{[user_uid]}:muted_users
+//
+// The key holds a SORTED SET value with the ids of the users the user has muted as MEMBERS and the muting time as SCORES
+func getUserMutedUsersKey(userId models.UserUid) string {
+	return getUserKey(userId) + ":muted_users"
+}
+
+// getUserReadMarkersKey returns a redis key: users:{[user_uid]}:read_markers
+//
+// The key holds a HASH value with the read spaces and threads (space:[spaceid] and thread:[threadid]) as FIELDS and the
+// unix times in ms up to which the user has read them as VALUES
+func getUserReadMarkersKey(userId models.UserUid) string {
+	return getUserKey(userId) + ":read_markers"
+}
+
+// getUserInboxKey returns a redis key: users:{[user_uid]}:inbox
+//
+// The key holds a SORTED SET value with the ids of the new messages of the threads the user follows as MEMBERS and their
+// creation times as SCORES. Only the most recent messages are kept.
+func getUserInboxKey(userId models.UserUid) string {
+	return getUserKey(userId) + ":inbox"
+}
+
+// getSpaceReadMarkerField returns the field of the space's marker in users:{[user_uid]}:read_markers
+func getSpaceReadMarkerField(spaceId uuid.Uuid) string {
+	return "space:" + spaceId.String()
+}
+
+// getThreadReadMarkerField returns the field of the thread's marker in users:{[user_uid]}:read_markers
+func getThreadReadMarkerField(threadId uuid.Uuid) string {
+	return "thread:" + threadId.String()
+}
+
 // ---- SPACE COORDINATES ----
 
 // space_coords
@@ -44,6 +111,13 @@ var spaceFields = struct {
 	locationField           string
 	createdAtField          string
 	adminIdField            string
+	hiddenField             string
+	startsAtField           string
+	endsAtField             string
+	archivedField           string
+	retentionField          string
+	categoryField           string
+	tagsField               string
 }{
 	nameField:               "name",
 	themeColorHexaCodeField: "color",
@@ -51,48 +125,160 @@ var spaceFields = struct {
 	locationField:           "location",
 	createdAtField:          "created_at",
 	adminIdField:            "admin",
+	hiddenField:             "hidden",
+	startsAtField:           "starts_at",
+	endsAtField:             "ends_at",
+	archivedField:           "archived",
+	retentionField:          "message_retention_hours",
+	categoryField:           "category",
+	tagsField:               "tags", // comma-separated
 }
 
-// spaces:[spaceid] hash of space data
+// spaces:{[spaceid]} hash of space data
 func getSpaceKey(spaceId uuid.Uuid) string {
-	return "spaces:" + spaceId.String()
+	return "spaces:" + hashTag(spaceId.String())
 }
 
-// must be subset of users:[user_uid]
+// message_retention_spaces
 //
-// spaces:[spaceid]:subscribers
+// The key holds a SET value with the ids of the spaces that have a message retention as MEMBERS
+func getMessageRetentionSpacesKey() string {
+	return "message_retention_spaces"
+}
+
+// space_geohashes
+//
+// The key holds a SORTED SET value with the geohashes of the spaces that are shown on the map, i.e. spaces that have
+// started and are neither hidden nor archived, followed by a colon and the space id (e.g. u33dc0cpp:[spaceid]), as
+// MEMBERS and 0 as SCORES, so that the spaces of a geohash cell can be counted by its prefix
+func getSpaceGeoHashesKey() string {
+	return "space_geohashes"
+}
+
+// space_transitions
+//
+// The key holds a SORTED SET value with the ids of the time-bounded spaces that still have to open, close or be
+// archived as MEMBERS and the times of their next transitions in unix milliseconds as SCORES
+func getSpaceTransitionsKey() string {
+	return "space_transitions"
+}
+
+// must be subset of users:{[user_uid]}
+//
+// spaces:{[spaceid]}:subscribers
 //
 // The keys hold SORTED SET values with the user ids as MEMBERS and joining time as SCORES
 func getSpaceSubscribersKey(spaceId uuid.Uuid) string {
 	return getSpaceKey(spaceId) + ":subscribers"
 }
 
-// spaces:[spaceid]:subscribers[userid]:sessions
+// spaces:{[spaceid]}:subscribers:[userid]:sessions
 //
 // The keys holds a SORTED SET value with the session ids as MEMBERS and starting session time as SCORES
 func getSpaceActiveSubscriberSessionsKey(spaceId uuid.Uuid, userId models.UserUid) string {
 	return getSpaceSubscribersKey(spaceId) + ":" + string(userId) + ":sessions"
 }
 
-// must be subset of spaces:[spaceid]:subscribers
+// must be subset of spaces:{[spaceid]}:subscribers
 //
-// spaces:[spaceid]:active_subscribers
+// spaces:{[spaceid]}:active_subscribers
 //
 // The keys hold SORTED SET values with the user ids as MEMBERS and joining times as SCORES
 func getSpaceActiveSubscribersKey(spaceId uuid.Uuid) string {
 	return getSpaceKey(spaceId) + ":active_subscribers"
 }
 
-// spaces:[spaceid]:toplevel_threads_by_time
+// spaces:{[spaceid]}:toplevel_threads_by_time
 func getSpaceToplevelThreadsByTimeKey(spaceId uuid.Uuid) string {
 	return getSpaceKey(spaceId) + ":toplevel_threads_by_time"
 }
 
-// spaces:[spaceid]:toplevel_threads_by_popularity
+// spaces:{[spaceid]}:toplevel_threads_by_popularity
 func getSpaceToplevelThreadsByPopularityKey(spaceId uuid.Uuid) string {
 	return getSpaceKey(spaceId) + ":toplevel_threads_by_popularity"
 }
 
+// spaces:{[spaceid]}:held_messages
+//
+// The key holds a SORTED SET value with the ids of the space's messages that wait for review as MEMBERS and the times
+// they were held as SCORES
+func getSpaceHeldMessagesKey(spaceId uuid.Uuid) string {
+	return getSpaceKey(spaceId) + ":held_messages"
+}
+
+// spaces:{[spaceid]}:reports
+//
+// The key holds a SORTED SET value with the targets of the reports of the space's messages and threads
+// (e.g. message:[messageid]) as MEMBERS and their reports counts as SCORES
+func getSpaceReportsKey(spaceId uuid.Uuid) string {
+	return getSpaceKey(spaceId) + ":reports"
+}
+
+// spaces:{[spaceid]}:recent_messages
+//
+// The key holds a SORTED SET value with the ids of the messages sent in the space during the last trending window as
+// MEMBERS and their creation times as SCORES. It expires when no messages have been sent for a window.
+func getSpaceRecentMessagesKey(spaceId uuid.Uuid) string {
+	return getSpaceKey(spaceId) + ":recent_messages"
+}
+
+// spaces:{[spaceid]}:banned_users
+//
+// The key holds a SORTED SET value with the ids of the users banned from the space as MEMBERS and the ban times
+// as SCORES
+func getSpaceBannedUsersKey(spaceId uuid.Uuid) string {
+	return getSpaceKey(spaceId) + ":banned_users"
+}
+
+// spaces:{[spaceid]}:location_pins
+//
+// The key holds a SORTED SET value with the ids of the space's location messages as MEMBERS and their creation times
+// as SCORES
+func getSpaceLocationPinsKey(spaceId uuid.Uuid) string {
+	return getSpaceKey(spaceId) + ":location_pins"
+}
+
+// spaces:{[spaceid]}:pins
+//
+// The key holds a SORTED SET value with the space's pinned toplevel threads and messages (thread:[threadid] and
+// message:[messageid]) as MEMBERS and the times they were pinned as SCORES
+func getSpacePinsKey(spaceId uuid.Uuid) string {
+	return getSpaceKey(spaceId) + ":pins"
+}
+
+func getPinMember(targetType models.PinTargetType, targetId uuid.Uuid) string {
+	return string(targetType) + ":" + targetId.String()
+}
+
+// ---- SESSION ----
+
+var sessionFields = struct {
+	spaceIdField    string
+	userIdField     string
+	instanceIdField string
+	createdAtField  string
+}{
+	spaceIdField:    "space_id",
+	userIdField:     "user_id",
+	instanceIdField: "instance_id",
+	createdAtField:  "created_at",
+}
+
+// sessions:[sessionid]
+//
+// The key holds a HASH value with the following fields: "space_id", "user_id", "instance_id", "created_at"
+func getSessionKey(sessionId uuid.Uuid) string {
+	return "sessions:" + sessionId.String()
+}
+
+// session_expirations
+//
+// The key holds a SORTED SET value with the session ids as MEMBERS and the unix time in ms at which a session
+// is considered stale as SCORES. The scores are pushed forward by the heartbeats of the owning server instance.
+func getSessionExpirationsKey() string {
+	return "session_expirations"
+}
+
 // ---- THREAD ----
 
 var threadFields = struct {
@@ -102,6 +288,8 @@ var threadFields = struct {
 	createdAtField       string
 	parentMessageIdField string
 	firstMessageIdField  string
+	hiddenField          string
+	announcementField    string
 }{
 	likesField:           "likes",
 	messagesCountField:   "messages_count",
@@ -109,23 +297,33 @@ var threadFields = struct {
 	parentMessageIdField: "parent_message_id", // only for sublevel threads
 	firstMessageIdField:  "first_message_id",  // only for toplevel threads
 	createdAtField:       "created_at",
+	hiddenField:          "hidden",
+	announcementField:    "announcement", // only for toplevel threads
 }
 
-// threads:[threadid]
+// threads:{[threadid]}
 func getThreadKey(threadId uuid.Uuid) string {
-	return "threads:" + threadId.String()
+	return "threads:" + hashTag(threadId.String())
 }
 
-// threads:[threadid]:messages_by_time
+// threads:{[threadid]}:messages_by_time
 func getThreadMessagesByTimeKey(threadId uuid.Uuid) string {
 	return getThreadKey(threadId) + ":messages_by_time"
 }
 
-// threads:[threadid]:messages_by_popularity
+// threads:{[threadid]}:messages_by_popularity
 func getThreadMessagesByPopularityKey(threadId uuid.Uuid) string {
 	return getThreadKey(threadId) + ":messages_by_popularity"
 }
 
+// threads:{[threadid]}:followers
+//
+// The key holds a SORTED SET value with the ids of the users who follow the thread as MEMBERS and the following times
+// as SCORES
+func getThreadFollowersKey(threadId uuid.Uuid) string {
+	return getThreadKey(threadId) + ":followers"
+}
+
 // ---- MESSAGE ----
 
 var messageFields = struct {
@@ -136,6 +334,9 @@ var messageFields = struct {
 	childThreadIdField string
 	threadIdField      string
 	likesField         string
+	hiddenField        string
+	attachmentIdField  string
+	payloadField       string
 }{
 	contentField:       "content",
 	senderIdField:      "sender_id",
@@ -144,6 +345,9 @@ var messageFields = struct {
 	childThreadIdField: "child_thread_id",
 	threadIdField:      "thread_id",
 	likesField:         "likes",
+	hiddenField:        "hidden",
+	attachmentIdField:  "attachment_id", // empty for text messages
+	payloadField:       "payload",       // stringified JSON, empty for messages without payload
 }
 
 // messages:[messageid]
@@ -151,6 +355,219 @@ func getMessageKey(messageId uuid.Uuid) string {
 	return "messages:" + messageId.String()
 }
 
+// ---- POLL ----
+
+var pollFields = struct {
+	votersCountField string
+	closedField      string
+	votesFieldPrefix string
+}{
+	votersCountField: "voters_count",
+	closedField:      "closed",
+	votesFieldPrefix: "votes:", // followed by the index of the option, e.g. votes:0
+}
+
+// getPollKey returns a redis key: polls:{[messageid]}
+//
+// The key holds a HASH value with the tallied results of the poll message with the following fields: "voters_count",
+// "closed" and a "votes:[index]" counter for every option. The poll itself is the payload of the message.
+func getPollKey(messageId uuid.Uuid) string {
+	return "polls:" + hashTag(messageId.String())
+}
+
+// getPollVotersKey returns a redis key: polls:{[messageid]}:voters
+//
+// The key holds a HASH value with the ids of the voters as FIELDS and the comma separated indexes of the options they
+// voted for as VALUES
+func getPollVotersKey(messageId uuid.Uuid) string {
+	return getPollKey(messageId) + ":voters"
+}
+
+// poll_closings
+//
+// The key holds a SORTED SET value with the message ids of the open polls that close automatically as MEMBERS and
+// their closing times in unix milliseconds as SCORES
+func getPollClosingsKey() string {
+	return "poll_closings"
+}
+
+// ---- ATTACHMENT ----
+
+var attachmentFields = struct {
+	spaceIdField              string
+	uploaderIdField           string
+	typeField                 string
+	fileNameField             string
+	contentTypeField          string
+	sizeField                 string
+	widthField                string
+	heightField               string
+	thumbnailContentTypeField string
+	createdAtField            string
+}{
+	spaceIdField:              "space_id",
+	uploaderIdField:           "uploader_id",
+	typeField:                 "type",
+	fileNameField:             "file_name",
+	contentTypeField:          "content_type",
+	sizeField:                 "size",
+	widthField:                "width",                  // 0 for files
+	heightField:               "height",                 // 0 for files
+	thumbnailContentTypeField: "thumbnail_content_type", // empty for files
+	createdAtField:            "created_at",
+}
+
+// attachments:[attachmentid]
+//
+// The key holds a HASH value with the following fields: "space_id", "uploader_id", "type", "file_name", "content_type",
+// "size", "width", "height", "thumbnail_content_type", "created_at". The content itself is kept in the blob store.
+func getAttachmentKey(attachmentId uuid.Uuid) string {
+	return "attachments:" + attachmentId.String()
+}
+
+// unattached_attachments
+//
+// The key holds a SORTED SET value with the ids of the attachments that no message refers to yet as MEMBERS and their
+// upload or release times as SCORES
+func getUnattachedAttachmentsKey() string {
+	return "unattached_attachments"
+}
+
+// deleted_attachments
+//
+// The key holds a SET value with the ids of the removed attachments whose content still has to be deleted from the blob
+// store as MEMBERS
+func getDeletedAttachmentsKey() string {
+	return "deleted_attachments"
+}
+
+// ---- HELD MESSAGE ----
+
+var heldMessageFields = struct {
+	spaceIdField      string
+	threadIdField     string
+	senderIdField     string
+	contentField      string
+	typeField         string
+	attachmentIdField string
+	payloadField      string
+	reasonField       string
+	filterField       string
+	heldAtField       string
+}{
+	spaceIdField:      "space_id",
+	threadIdField:     "thread_id", // empty for the first message of a new toplevel thread
+	senderIdField:     "sender_id",
+	contentField:      "content",
+	typeField:         "type",
+	attachmentIdField: "attachment_id",
+	payloadField:      "payload",
+	reasonField:       "reason",
+	filterField:       "filter",
+	heldAtField:       "held_at",
+}
+
+// held_messages:[heldmessageid]
+//
+// The key holds a HASH value with the following fields: "space_id", "thread_id", "sender_id", "content", "type",
+// "attachment_id", "payload", "reason", "filter", "held_at"
+func getHeldMessageKey(heldMessageId uuid.Uuid) string {
+	return "held_messages:" + heldMessageId.String()
+}
+
+// ---- REPORT ----
+
+var reportFields = struct {
+	targetTypeField      string
+	targetIdField        string
+	spaceIdField         string
+	threadIdField        string
+	authorIdField        string
+	excerptField         string
+	reportsCountField    string
+	hiddenField          string
+	firstReportedAtField string
+	lastReportedAtField  string
+	reasonFieldPrefix    string
+}{
+	targetTypeField:      "target_type",
+	targetIdField:        "target_id",
+	spaceIdField:         "space_id",  // empty for users
+	threadIdField:        "thread_id", // only for messages and threads
+	authorIdField:        "author_id",
+	excerptField:         "excerpt",
+	reportsCountField:    "reports_count",
+	hiddenField:          "hidden",
+	firstReportedAtField: "first_reported_at",
+	lastReportedAtField:  "last_reported_at",
+	reasonFieldPrefix:    "reason:", // followed by the reason code, e.g. reason:spam
+}
+
+// getReportKey returns a redis key: reports:{[targettype]:[targetid]}
+//
+// The key holds a HASH value aggregating all reports of the target with the following fields: "target_type",
+// "target_id", "space_id", "thread_id", "author_id", "excerpt", "reports_count", "hidden", "first_reported_at",
+// "last_reported_at" and a "reason:[reason]" counter for every reason the target was reported for
+func getReportKey(target models.ReportTarget) string {
+	return "reports:" + hashTag(target.String())
+}
+
+// getReportReportersKey returns a redis key: reports:{[targettype]:[targetid]}:reporters
+//
+// The key holds a HASH value with the ids of the users who reported the target as FIELDS and their reasons as VALUES.
+// Every user can report a target only once.
+func getReportReportersKey(target models.ReportTarget) string {
+	return getReportKey(target) + ":reporters"
+}
+
+// reports_queue
+//
+// The key holds a SORTED SET value with the targets of all reports as MEMBERS and their reports counts as SCORES
+func getReportsQueueKey() string {
+	return "reports_queue"
+}
+
+// ---- SPACE NAME SEARCH ----
+
+// space_name_terms
+//
+// The key holds a SORTED SET value with the words of the names of the spaces that can be found by location, followed
+// by a colon and the space id (e.g. park:[spaceid]), as MEMBERS and 0 as SCORES, so that the spaces can be looked up by
+// the prefix of a word. The entries of a space have to be replaced whenever its name changes.
+func getSpaceNameTermsKey() string {
+	return "space_name_terms"
+}
+
+// space_name_trigrams
+//
+// The key holds a SORTED SET value with the trigrams of the words of the names of the spaces that can be found by
+// location, followed by a colon and the space id (e.g. par:[spaceid]), as MEMBERS and 0 as SCORES, so that the spaces
+// with similar names can be looked up
+func getSpaceNameTrigramsKey() string {
+	return "space_name_trigrams"
+}
+
+func getSpaceNameMember(termOrTrigram string, spaceId uuid.Uuid) string {
+	return termOrTrigram + ":" + spaceId.String()
+}
+
+// ---- TAG ----
+
+// getTagSpacesKey returns a redis key: tags:{[tag]}:spaces
+//
+// The key holds a SET value with the ids of the spaces that carry the tag and can be found by location as MEMBERS
+func getTagSpacesKey(tag string) string {
+	return "tags:" + hashTag(tag) + ":spaces"
+}
+
+// space_tags
+//
+// The key holds a SORTED SET value with the tags of the spaces as MEMBERS and 0 as SCORES, so that the tags can be
+// looked up by prefix. Tags are removed once no space carries them anymore.
+func getSpaceTagsKey() string {
+	return "space_tags"
+}
+
 // ---- ADDRESS ----
 
 // getAddressKey returns a redis key: addresses:[geohash]
@@ -159,3 +576,13 @@ func getMessageKey(messageId uuid.Uuid) string {
 func getAddressKey(geohash string) string {
 	return "addresses:" + geohash
 }
+
+// ---- RATE LIMIT ----
+
+// getRateLimitKey returns a redis key: rate_limits:{[policy]:[subject]}
+//
+// The key holds a SORTED SET of the subject's actions within the policy's window, scored by their unix milliseconds.
+// It expires one window after the last action.
+func getRateLimitKey(policyName, subject string) string {
+	return "rate_limits:" + hashTag(policyName+":"+subject)
+}
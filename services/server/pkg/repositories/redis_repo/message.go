@@ -2,9 +2,11 @@ package redis_repo
 
 import (
 	"context"
+	"encoding/json"
 	"spaces-p/pkg/common"
 	"spaces-p/pkg/errors"
 	"spaces-p/pkg/models"
+	"spaces-p/pkg/tracing"
 	"spaces-p/pkg/utils"
 	"spaces-p/pkg/uuid"
 	"strconv"
@@ -15,6 +17,8 @@ import (
 
 func (repo *RedisRepository) GetMessage(ctx context.Context, messageId uuid.Uuid) (*models.MessageWithChildThreadMessagesCount, error) {
 	const op errors.Op = "redis_repo.RedisRepository.GetMessage"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
 	var messageKey = getMessageKey(messageId)
 
 	messageMap, err := repo.redisClient.HGetAll(ctx, messageKey).Result()
@@ -32,6 +36,9 @@ func (repo *RedisRepository) GetMessage(ctx context.Context, messageId uuid.Uuid
 	threadIdStr := messageMap[messageFields.threadIdField]
 	createdAtMilliStr := messageMap[messageFields.createdAtField]
 	messageTypeStr := messageMap[messageFields.typeField]
+	hidden := messageMap[messageFields.hiddenField] == "1"
+	attachmentIdStr := messageMap[messageFields.attachmentIdField]
+	payloadStr := messageMap[messageFields.payloadField]
 
 	childThreadId, err := uuid.Parse(childThreadIdStr)
 	switch {
@@ -69,6 +76,20 @@ func (repo *RedisRepository) GetMessage(ctx context.Context, messageId uuid.Uuid
 		return &models.MessageWithChildThreadMessagesCount{}, errors.E(op, err)
 	}
 
+	attachmentId, err := uuid.Parse(attachmentIdStr)
+	if err != nil {
+		return &models.MessageWithChildThreadMessagesCount{}, errors.E(op, err)
+	}
+
+	payload, err := parseMessagePayload(messageType, payloadStr)
+	if err != nil {
+		return &models.MessageWithChildThreadMessagesCount{}, errors.E(op, err)
+	}
+
+	if err := repo.addPollResults(ctx, messageId, payload); err != nil {
+		return &models.MessageWithChildThreadMessagesCount{}, errors.E(op, err)
+	}
+
 	return &models.MessageWithChildThreadMessagesCount{
 		ChildThreadMessagesCount: childThreadMessagesCount,
 		Message: models.Message{
@@ -76,10 +97,13 @@ func (repo *RedisRepository) GetMessage(ctx context.Context, messageId uuid.Uuid
 			CreatedAt:     createdAt,
 			ChildThreadId: childThreadId,
 			Likes:         likes,
+			Hidden:        hidden,
 			NewMessage: models.NewMessage{
 				BaseMessage: models.BaseMessage{
-					Content: content,
-					Type:    messageType,
+					Content:      content,
+					Type:         messageType,
+					AttachmentId: attachmentId,
+					Payload:      payload,
 				},
 				SenderId: senderId,
 				ThreadId: threadId,
@@ -89,6 +113,8 @@ func (repo *RedisRepository) GetMessage(ctx context.Context, messageId uuid.Uuid
 
 func (repo *RedisRepository) SetMessage(ctx context.Context, newMessage models.NewMessage) (*models.Message, error) {
 	const op errors.Op = "redis_repo.RedisRepository.SetMessage"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
 	var threadKey = getThreadKey(newMessage.ThreadId)
 	var createdAt = time.Now()
 
@@ -119,11 +145,44 @@ func (repo *RedisRepository) SetMessage(ctx context.Context, newMessage models.N
 		return nil, errors.E(op, err)
 	}
 
+	if _, ok := newMessage.Payload.(*models.LocationPin); ok {
+		spaceIdStr, err := repo.redisClient.HGet(ctx, threadKey, threadFields.spaceIdField).Result()
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+
+		spaceId, err := uuid.Parse(spaceIdStr)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+
+		if err := repo.addLocationPin(ctx, spaceId, createdMessage); err != nil {
+			return nil, errors.E(op, err)
+		}
+	}
+
 	return createdMessage, nil
 }
 
+// GetSpaceLocationPins returns the location messages of the space, the most recent first
+func (repo *RedisRepository) GetSpaceLocationPins(ctx context.Context, spaceId uuid.Uuid, offset, count int64) ([]models.MessageWithChildThreadMessagesCount, error) {
+	const op errors.Op = "redis_repo.RedisRepository.GetSpaceLocationPins"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+	var spaceLocationPinsKey = getSpaceLocationPinsKey(spaceId)
+
+	messages, err := repo.getThreadMessages(ctx, spaceLocationPinsKey, offset, count)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return messages, nil
+}
+
 func (repo *RedisRepository) IncrementMessageLikesBy(ctx context.Context, threadId, messageId uuid.Uuid, increment int64) error {
 	const op errors.Op = "redis_repo.RedisRepository.IncrementMessageLikesBy"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
 	var messageKey = getMessageKey(messageId)
 	var threadMessagesByPopularityKey = getThreadMessagesByPopularityKey(threadId)
 
@@ -138,8 +197,127 @@ func (repo *RedisRepository) IncrementMessageLikesBy(ctx context.Context, thread
 	return nil
 }
 
+// DeleteMessage removes the message from its thread along with its child thread and the child thread's messages
+func (repo *RedisRepository) DeleteMessage(ctx context.Context, threadId, messageId uuid.Uuid) error {
+	const op errors.Op = "redis_repo.RedisRepository.DeleteMessage"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+	var threadKey = getThreadKey(threadId)
+
+	if err := repo.deleteChildThread(ctx, messageId); err != nil {
+		return errors.E(op, err)
+	}
+
+	removed, err := repo.redisClient.ZRem(ctx, getThreadMessagesByTimeKey(threadId), messageId.String()).Result()
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	if err := repo.redisClient.ZRem(ctx, getThreadMessagesByPopularityKey(threadId), messageId.String()).Err(); err != nil {
+		return errors.E(op, err)
+	}
+
+	// don't decrement the count twice if the message has been deleted concurrently
+	if removed > 0 {
+		if err := repo.redisClient.HIncrBy(ctx, threadKey, threadFields.messagesCountField, -1).Err(); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	if err := repo.deleteMessageData(ctx, threadId, messageId); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// deleteMessageData removes the message along with its poll and attachment and everything that refers to it outside of
+// its thread, i.e. the inbox entries of the thread's followers and the location pin, pin and recent message entry of its
+// space. The thread might have been removed already, the space's entries are left to the dangling member check then.
+func (repo *RedisRepository) deleteMessageData(ctx context.Context, threadId, messageId uuid.Uuid) error {
+	const op errors.Op = "redis_repo.RedisRepository.deleteMessageData"
+	var messageKey = getMessageKey(messageId)
+
+	attachmentIdStr, err := repo.redisClient.HGet(ctx, messageKey, messageFields.attachmentIdField).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return errors.E(op, err)
+	}
+
+	if attachmentId, err := uuid.Parse(attachmentIdStr); err == nil && attachmentId != uuid.Nil {
+		if err := repo.deleteAttachment(ctx, attachmentId); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	if err := repo.redisClient.Del(ctx, messageKey).Err(); err != nil {
+		return errors.E(op, err)
+	}
+
+	if err := repo.deletePoll(ctx, messageId); err != nil {
+		return errors.E(op, err)
+	}
+
+	followerIdStrs, err := repo.redisClient.ZRange(ctx, getThreadFollowersKey(threadId), 0, -1).Result()
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	if len(followerIdStrs) > 0 {
+		pipe := repo.redisClient.Pipeline()
+		for _, followerIdStr := range followerIdStrs {
+			pipe.ZRem(ctx, getUserInboxKey(models.UserUid(followerIdStr)), messageId.String())
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	spaceIdStr, err := repo.redisClient.HGet(ctx, getThreadKey(threadId), threadFields.spaceIdField).Result()
+	switch {
+	case errors.Is(err, redis.Nil):
+		return nil
+	case err != nil:
+		return errors.E(op, err)
+	}
+
+	spaceId, err := uuid.Parse(spaceIdStr)
+	if err != nil {
+		return nil
+	}
+
+	if err := repo.redisClient.ZRem(ctx, getSpaceLocationPinsKey(spaceId), messageId.String()).Err(); err != nil {
+		return errors.E(op, err)
+	}
+
+	if err := repo.redisClient.ZRem(ctx, getSpacePinsKey(spaceId), getPinMember(models.MessagePinTarget, messageId)).Err(); err != nil {
+		return errors.E(op, err)
+	}
+
+	if err := repo.redisClient.ZRem(ctx, getSpaceRecentMessagesKey(spaceId), messageId.String()).Err(); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// addLocationPin adds a location message to the location pins of its space
+func (repo *RedisRepository) addLocationPin(ctx context.Context, spaceId uuid.Uuid, message *models.Message) error {
+	const op errors.Op = "redis_repo.RedisRepository.addLocationPin"
+
+	if err := repo.redisClient.ZAdd(ctx, getSpaceLocationPinsKey(spaceId), redis.Z{
+		Score:  float64(message.CreatedAt.UnixMilli()),
+		Member: message.ID.String(),
+	}).Err(); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
 func (repo *RedisRepository) setMessage(ctx context.Context, createdAt time.Time, newMessage models.NewMessage) (*models.Message, error) {
 	const op errors.Op = "redis_repo.RedisRepository.setMessage"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
 
 	var messageId = uuid.New()
 	var messageKey = getMessageKey(messageId)
@@ -152,6 +330,16 @@ func (repo *RedisRepository) setMessage(ctx context.Context, createdAt time.Time
 		return nil, errors.E(op, err)
 	}
 
+	var attachmentIdStr string
+	if newMessage.AttachmentId != uuid.Nil {
+		attachmentIdStr = newMessage.AttachmentId.String()
+	}
+
+	payloadStr, err := formatMessagePayload(newMessage.Payload)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
 	var createdMessage = &models.Message{
 		ID:         messageId,
 		NewMessage: newMessage,
@@ -166,9 +354,56 @@ func (repo *RedisRepository) setMessage(ctx context.Context, createdAt time.Time
 		messageFields.threadIdField:      threadIdStr,
 		messageFields.createdAtField:     createdAtStr,
 		messageFields.typeField:          messageTypeStr,
+		messageFields.attachmentIdField:  attachmentIdStr,
+		messageFields.payloadField:       payloadStr,
 	}).Err(); err != nil {
 		return nil, errors.E(op, err)
 	}
 
+	if poll, ok := newMessage.Payload.(*models.Poll); ok {
+		results, err := repo.setPoll(ctx, messageId, poll)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+
+		var createdPoll = *poll
+		createdPoll.Results = results
+		createdMessage.Payload = &createdPoll
+	}
+
 	return createdMessage, nil
 }
+
+// formatMessagePayload returns the stringified JSON of the payload, or an empty string for messages without payload.
+// The results of polls are tallied separately and are not stored with the poll.
+func formatMessagePayload(payload models.MessagePayload) (string, error) {
+	const op errors.Op = "redis_repo.formatMessagePayload"
+
+	if payload == nil {
+		return "", nil
+	}
+
+	if poll, ok := payload.(*models.Poll); ok {
+		var storedPoll = *poll
+		storedPoll.Results = nil
+		payload = &storedPoll
+	}
+
+	payloadJson, err := json.Marshal(payload)
+	if err != nil {
+		return "", errors.E(op, err)
+	}
+
+	return string(payloadJson), nil
+}
+
+func parseMessagePayload(messageType models.MessageType, payloadStr string) (models.MessagePayload, error) {
+	const op errors.Op = "redis_repo.parseMessagePayload"
+
+	payload, err := models.ParseMessagePayload(messageType, []byte(payloadStr))
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return payload, nil
+}
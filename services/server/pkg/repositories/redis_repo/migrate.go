@@ -0,0 +1,115 @@
+package redis_repo
+
+import (
+	"context"
+	"fmt"
+	"spaces-p/pkg/errors"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// the prefixes of the keys whose entity ids used to be stored without a hash tag, e.g. users:[user_uid]:spaces
+var hashTaggedKeyPrefixes = []string{"users:", "spaces:", "threads:"}
+
+type KeyMigration struct {
+	OldKey string
+	NewKey string
+	// Skipped is set if the new key exists already. The old key is kept then, so that it can be merged by hand.
+	Skipped bool
+}
+
+func (m KeyMigration) String() string {
+	if m.Skipped {
+		return fmt.Sprintf("%s -> %s: skipped, the new key exists already", m.OldKey, m.NewKey)
+	}
+
+	return fmt.Sprintf("%s -> %s", m.OldKey, m.NewKey)
+}
+
+// MigrateHashTaggedKeys moves the keys of users, spaces and threads that were written before their entity ids were
+// wrapped in a hash tag to their current names, e.g. users:[user_uid]:spaces to users:{[user_uid]}:spaces. It must be
+// run before the server is started against the old data, as the server doesn't read the old keys.
+//
+// The old and new name of a key may map to different slots in Redis Cluster, so keys are moved with DUMP and RESTORE
+// instead of RENAME.
+func (repo *RedisRepository) MigrateHashTaggedKeys(ctx context.Context) ([]KeyMigration, error) {
+	const op errors.Op = "redis_repo.RedisRepository.MigrateHashTaggedKeys"
+
+	var migrations []KeyMigration
+	for _, prefix := range hashTaggedKeyPrefixes {
+		keys, err := repo.scanKeys(ctx, prefix+"*")
+		if err != nil {
+			return migrations, errors.E(op, err)
+		}
+
+		for _, oldKey := range keys {
+			newKey, ok := getHashTaggedKey(oldKey, prefix)
+			if !ok {
+				continue
+			}
+
+			migration, err := repo.moveKey(ctx, oldKey, newKey)
+			if err != nil {
+				return migrations, errors.E(op, err)
+			}
+			migrations = append(migrations, migration)
+		}
+	}
+
+	return migrations, nil
+}
+
+// getHashTaggedKey returns the current name of a key written without a hash tag, e.g. users:{[user_uid]}:spaces for
+// users:[user_uid]:spaces. It returns false for keys that are hash tagged already.
+func getHashTaggedKey(key, prefix string) (string, bool) {
+	rest, found := strings.CutPrefix(key, prefix)
+	if !found || rest == "" || strings.HasPrefix(rest, "{") {
+		return "", false
+	}
+
+	id, suffix, _ := strings.Cut(rest, ":")
+	if suffix != "" {
+		suffix = ":" + suffix
+	}
+
+	return prefix + hashTag(id) + suffix, true
+}
+
+func (repo *RedisRepository) moveKey(ctx context.Context, oldKey, newKey string) (KeyMigration, error) {
+	const op errors.Op = "redis_repo.RedisRepository.moveKey"
+	var migration = KeyMigration{OldKey: oldKey, NewKey: newKey}
+
+	value, err := repo.redisClient.Dump(ctx, oldKey).Result()
+	switch {
+	case errors.Is(err, redis.Nil):
+		// deleted since the scan
+		return migration, nil
+	case err != nil:
+		return migration, errors.E(op, err)
+	}
+
+	ttl, err := repo.redisClient.PTTL(ctx, oldKey).Result()
+	if err != nil {
+		return migration, errors.E(op, err)
+	}
+	// keys without an expiration are restored with a TTL of 0
+	if ttl < 0 {
+		ttl = 0
+	}
+
+	err = repo.redisClient.Restore(ctx, newKey, ttl, value).Err()
+	switch {
+	case err != nil && strings.HasPrefix(err.Error(), "BUSYKEY"):
+		migration.Skipped = true
+		return migration, nil
+	case err != nil:
+		return migration, errors.E(op, err)
+	}
+
+	if err := repo.redisClient.Del(ctx, oldKey).Err(); err != nil {
+		return migration, errors.E(op, err)
+	}
+
+	return migration, nil
+}
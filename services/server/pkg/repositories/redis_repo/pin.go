@@ -0,0 +1,114 @@
+package redis_repo
+
+import (
+	"context"
+	"fmt"
+	"spaces-p/pkg/common"
+	"spaces-p/pkg/errors"
+	"spaces-p/pkg/models"
+	"spaces-p/pkg/tracing"
+	"spaces-p/pkg/uuid"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// pins an item unless the space already has the maximum number of pins. Returns 1 if the item has been pinned, 0 if it
+// already was pinned and -1 if the limit has been reached.
+var setSpacePinScript = redis.NewScript(`
+local pinsKey = KEYS[1]
+local member = ARGV[1]
+local pinnedAt = ARGV[2]
+local maxPins = tonumber(ARGV[3])
+
+if redis.call('ZSCORE', pinsKey, member) then
+	return 0
+end
+
+if redis.call('ZCARD', pinsKey) >= maxPins then
+	return -1
+end
+
+redis.call('ZADD', pinsKey, pinnedAt, member)
+return 1
+`)
+
+// SetSpacePin pins the item to the space, pinning it again keeps the original pinning time. It reports whether the item
+// has been pinned and fails with common.ErrPinLimitReached if the space already has maxPins pins.
+func (repo *RedisRepository) SetSpacePin(ctx context.Context, spaceId uuid.Uuid, pin models.Pin, maxPins int64) (bool, error) {
+	const op errors.Op = "redis_repo.RedisRepository.SetSpacePin"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	var member = getPinMember(pin.TargetType, pin.TargetId)
+	pinned, err := setSpacePinScript.Run(ctx, repo.redisClient, []string{getSpacePinsKey(spaceId)}, member, pin.PinnedAt.UnixMilli(), maxPins).Int()
+	switch {
+	case err != nil:
+		return false, errors.E(op, err)
+	case pinned == -1:
+		return false, errors.E(op, common.ErrPinLimitReached)
+	}
+
+	return pinned == 1, nil
+}
+
+// DeleteSpacePin unpins the item and reports whether it had been pinned
+func (repo *RedisRepository) DeleteSpacePin(ctx context.Context, spaceId uuid.Uuid, targetType models.PinTargetType, targetId uuid.Uuid) (bool, error) {
+	const op errors.Op = "redis_repo.RedisRepository.DeleteSpacePin"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	removed, err := repo.redisClient.ZRem(ctx, getSpacePinsKey(spaceId), getPinMember(targetType, targetId)).Result()
+	if err != nil {
+		return false, errors.E(op, err)
+	}
+
+	return removed > 0, nil
+}
+
+// GetSpacePins returns the pins of the space, the most recently pinned first
+func (repo *RedisRepository) GetSpacePins(ctx context.Context, spaceId uuid.Uuid) ([]models.Pin, error) {
+	const op errors.Op = "redis_repo.RedisRepository.GetSpacePins"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	members, err := repo.redisClient.ZRevRangeWithScores(ctx, getSpacePinsKey(spaceId), 0, -1).Result()
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	var pins = make([]models.Pin, 0, len(members))
+	for _, member := range members {
+		targetType, targetId, err := parsePinMember(member.Member)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+
+		pins = append(pins, models.Pin{
+			TargetType: targetType,
+			TargetId:   targetId,
+			PinnedAt:   time.UnixMilli(int64(member.Score)),
+		})
+	}
+
+	return pins, nil
+}
+
+func parsePinMember(member string) (models.PinTargetType, uuid.Uuid, error) {
+	const op errors.Op = "redis_repo.parsePinMember"
+
+	targetTypeStr, targetIdStr, _ := strings.Cut(member, ":")
+	var targetType = models.PinTargetType(targetTypeStr)
+	if targetType != models.ThreadPinTarget && targetType != models.MessagePinTarget {
+		err := errors.New(fmt.Sprintf("pin %s has an unknown target type", member))
+		return "", uuid.Nil, errors.E(op, err)
+	}
+
+	targetId, err := uuid.Parse(targetIdStr)
+	if err != nil {
+		return "", uuid.Nil, errors.E(op, err)
+	}
+
+	return targetType, targetId, nil
+}
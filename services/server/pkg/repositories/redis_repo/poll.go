@@ -0,0 +1,249 @@
+package redis_repo
+
+import (
+	"context"
+	"spaces-p/pkg/common"
+	"spaces-p/pkg/errors"
+	"spaces-p/pkg/models"
+	"spaces-p/pkg/tracing"
+	"spaces-p/pkg/uuid"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// records the vote of a voter for the options of an open poll and replaces the voter's earlier vote. Returns 1 for
+// recorded votes, 0 if the poll is closed and -1 if it doesn't exist.
+var votePollScript = redis.NewScript(`
+local pollKey = KEYS[1]
+local votersKey = KEYS[2]
+local voterId = ARGV[1]
+local options = ARGV[2]
+local closedField = ARGV[3]
+local votersCountField = ARGV[4]
+local votesFieldPrefix = ARGV[5]
+
+local closed = redis.call('HGET', pollKey, closedField)
+if not closed then
+	return -1
+end
+if closed ~= '0' then
+	return 0
+end
+
+local previousOptions = redis.call('HGET', votersKey, voterId)
+if previousOptions then
+	for option in string.gmatch(previousOptions, '[^,]+') do
+		redis.call('HINCRBY', pollKey, votesFieldPrefix .. option, -1)
+	end
+else
+	redis.call('HINCRBY', pollKey, votersCountField, 1)
+end
+
+for option in string.gmatch(options, '[^,]+') do
+	redis.call('HINCRBY', pollKey, votesFieldPrefix .. option, 1)
+end
+redis.call('HSET', votersKey, voterId, options)
+
+return 1
+`)
+
+// closes an open poll. Returns 1 if the poll has been closed and 0 if it was closed already or doesn't exist.
+var closePollScript = redis.NewScript(`
+if redis.call('HGET', KEYS[1], ARGV[1]) ~= '0' then
+	return 0
+end
+
+redis.call('HSET', KEYS[1], ARGV[1], '1')
+
+return 1
+`)
+
+// SetPollVote records the voter's vote for the options with the given indexes, replacing an earlier vote of the voter.
+// It returns common.ErrPollClosed if the poll has been closed.
+func (repo *RedisRepository) SetPollVote(ctx context.Context, messageId uuid.Uuid, voterId models.UserUid, options []int) error {
+	const op errors.Op = "redis_repo.RedisRepository.SetPollVote"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	var optionStrs = make([]string, 0, len(options))
+	for _, option := range options {
+		optionStrs = append(optionStrs, strconv.Itoa(option))
+	}
+
+	result, err := votePollScript.Run(
+		ctx,
+		repo.redisClient,
+		[]string{getPollKey(messageId), getPollVotersKey(messageId)},
+		string(voterId),
+		strings.Join(optionStrs, ","),
+		pollFields.closedField,
+		pollFields.votersCountField,
+		pollFields.votesFieldPrefix,
+	).Int()
+	switch {
+	case err != nil:
+		return errors.E(op, err)
+	case result == -1:
+		return errors.E(op, common.ErrNotFound)
+	case result == 0:
+		return errors.E(op, common.ErrPollClosed)
+	}
+
+	return nil
+}
+
+// GetPollResults returns the tallied results of the poll with optionsCount options
+func (repo *RedisRepository) GetPollResults(ctx context.Context, messageId uuid.Uuid, optionsCount int) (*models.PollResults, error) {
+	const op errors.Op = "redis_repo.RedisRepository.GetPollResults"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	pollMap, err := repo.redisClient.HGetAll(ctx, getPollKey(messageId)).Result()
+	switch {
+	case err != nil:
+		return nil, errors.E(op, err)
+	case len(pollMap) == 0:
+		return nil, errors.E(op, common.ErrNotFound)
+	}
+
+	votersCount, err := strconv.ParseInt(pollMap[pollFields.votersCountField], 10, 64)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	var votes = make([]int64, optionsCount)
+	for i := range votes {
+		votesStr, ok := pollMap[pollFields.votesFieldPrefix+strconv.Itoa(i)]
+		if !ok {
+			continue
+		}
+
+		votes[i], err = strconv.ParseInt(votesStr, 10, 64)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+	}
+
+	return &models.PollResults{
+		Votes:       votes,
+		VotersCount: votersCount,
+		Closed:      pollMap[pollFields.closedField] == "1",
+	}, nil
+}
+
+// GetExpiredPolls returns the message ids of up to count open polls whose closing time lies before until
+func (repo *RedisRepository) GetExpiredPolls(ctx context.Context, until time.Time, count int64) ([]uuid.Uuid, error) {
+	const op errors.Op = "redis_repo.RedisRepository.GetExpiredPolls"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	messageIdStrs, err := repo.redisClient.ZRangeByScore(ctx, getPollClosingsKey(), &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(until.UnixMilli(), 10),
+		Count: count,
+	}).Result()
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	var messageIds = make([]uuid.Uuid, 0, len(messageIdStrs))
+	for _, messageIdStr := range messageIdStrs {
+		messageId, err := uuid.Parse(messageIdStr)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+
+		messageIds = append(messageIds, messageId)
+	}
+
+	return messageIds, nil
+}
+
+// ClosePoll closes the poll, so that it doesn't accept votes anymore. It returns false if the poll has been closed
+// before, e.g. concurrently by another server instance.
+func (repo *RedisRepository) ClosePoll(ctx context.Context, messageId uuid.Uuid) (bool, error) {
+	const op errors.Op = "redis_repo.RedisRepository.ClosePoll"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	closed, err := closePollScript.Run(ctx, repo.redisClient, []string{getPollKey(messageId)}, pollFields.closedField).Int()
+	if err != nil {
+		return false, errors.E(op, err)
+	}
+
+	// the closings are in another hash slot than the poll, so they cannot be updated by the script
+	if err := repo.redisClient.ZRem(ctx, getPollClosingsKey(), messageId.String()).Err(); err != nil {
+		return false, errors.E(op, err)
+	}
+
+	return closed == 1, nil
+}
+
+// setPoll creates the empty results of a new poll message and schedules its closing
+func (repo *RedisRepository) setPoll(ctx context.Context, messageId uuid.Uuid, poll *models.Poll) (*models.PollResults, error) {
+	const op errors.Op = "redis_repo.RedisRepository.setPoll"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	var results = &models.PollResults{Votes: make([]int64, len(poll.Options))}
+	var pollMap = map[string]any{
+		pollFields.votersCountField: "0",
+		pollFields.closedField:      "0",
+	}
+	for i := range poll.Options {
+		pollMap[pollFields.votesFieldPrefix+strconv.Itoa(i)] = "0"
+	}
+
+	if err := repo.redisClient.HSet(ctx, getPollKey(messageId), pollMap).Err(); err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	if poll.ClosesAt != nil {
+		if err := repo.redisClient.ZAdd(ctx, getPollClosingsKey(), redis.Z{
+			Score:  float64(poll.ClosesAt.UnixMilli()),
+			Member: messageId.String(),
+		}).Err(); err != nil {
+			return nil, errors.E(op, err)
+		}
+	}
+
+	return results, nil
+}
+
+// deletePoll removes the results and the scheduled closing of a poll message
+func (repo *RedisRepository) deletePoll(ctx context.Context, messageId uuid.Uuid) error {
+	const op errors.Op = "redis_repo.RedisRepository.deletePoll"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if err := repo.redisClient.Del(ctx, getPollKey(messageId), getPollVotersKey(messageId)).Err(); err != nil {
+		return errors.E(op, err)
+	}
+
+	if err := repo.redisClient.ZRem(ctx, getPollClosingsKey(), messageId.String()).Err(); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// addPollResults sets the results of the payload if it is a poll
+func (repo *RedisRepository) addPollResults(ctx context.Context, messageId uuid.Uuid, payload models.MessagePayload) error {
+	const op errors.Op = "redis_repo.RedisRepository.addPollResults"
+
+	poll, ok := payload.(*models.Poll)
+	if !ok {
+		return nil
+	}
+
+	results, err := repo.GetPollResults(ctx, messageId, len(poll.Options))
+	if err != nil {
+		return errors.E(op, err)
+	}
+	poll.Results = results
+
+	return nil
+}
@@ -0,0 +1,56 @@
+package redis_repo
+
+import (
+	"context"
+	"spaces-p/pkg/errors"
+	"spaces-p/pkg/models"
+	"spaces-p/pkg/tracing"
+	"spaces-p/pkg/uuid"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// sliding window log: an action is allowed if fewer than limit actions happened within the last window.
+// Returns {1, 0} for allowed actions and {0, retry after in ms} for rejected ones.
+var rateLimitScript = redis.NewScript(`
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+
+if redis.call('ZCARD', key) < limit then
+	redis.call('ZADD', key, now, ARGV[4])
+	redis.call('PEXPIRE', key, window)
+	return {1, 0}
+end
+
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+return {0, tonumber(oldest[2]) + window - now}
+`)
+
+// TakeRateLimitToken registers an action of subject under policy if the policy's limit has not been reached yet.
+// Rejected actions are not registered, retryAfter tells when the next action will be allowed.
+func (repo *RedisRepository) TakeRateLimitToken(ctx context.Context, policy models.RateLimitPolicy, subject string) (allowed bool, retryAfter time.Duration, err error) {
+	const op errors.Op = "redis_repo.RedisRepository.TakeRateLimitToken"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+	var rateLimitKey = getRateLimitKey(policy.Name, subject)
+
+	result, err := rateLimitScript.Run(
+		ctx,
+		repo.redisClient,
+		[]string{rateLimitKey},
+		time.Now().UnixMilli(),
+		policy.Window.Milliseconds(),
+		policy.Limit,
+		uuid.New().String(), // actions in the same millisecond must not overwrite each other
+	).Int64Slice()
+	if err != nil {
+		return false, 0, errors.E(op, err)
+	}
+
+	return result[0] == 1, time.Duration(result[1]) * time.Millisecond, nil
+}
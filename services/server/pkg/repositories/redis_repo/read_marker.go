@@ -0,0 +1,154 @@
+package redis_repo
+
+import (
+	"context"
+	"spaces-p/pkg/errors"
+	"spaces-p/pkg/models"
+	"spaces-p/pkg/tracing"
+	"spaces-p/pkg/uuid"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// moves a read marker forward. Returns 1 if the marker has been moved and 0 if it already was at or past the time.
+var setReadMarkerScript = redis.NewScript(`
+local readMarkersKey = KEYS[1]
+local field = ARGV[1]
+local readAt = tonumber(ARGV[2])
+
+local current = tonumber(redis.call('HGET', readMarkersKey, field))
+if current and current >= readAt then
+	return 0
+end
+
+redis.call('HSET', readMarkersKey, field, readAt)
+return 1
+`)
+
+// SetReadMarker moves the user's marker of the space, or of the thread if the marker's ThreadId is set, forward.
+// It reports whether the marker has been moved.
+func (repo *RedisRepository) SetReadMarker(ctx context.Context, userId models.UserUid, readMarker models.ReadMarker) (bool, error) {
+	const op errors.Op = "redis_repo.RedisRepository.SetReadMarker"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	var field = getSpaceReadMarkerField(readMarker.SpaceId)
+	if readMarker.ThreadId != uuid.Nil {
+		field = getThreadReadMarkerField(readMarker.ThreadId)
+	}
+
+	moved, err := setReadMarkerScript.Run(ctx, repo.redisClient, []string{getUserReadMarkersKey(userId)}, field, readMarker.ReadAt.UnixMilli()).Int()
+	if err != nil {
+		return false, errors.E(op, err)
+	}
+
+	return moved == 1, nil
+}
+
+// GetSpacesUnreadCounts returns how many toplevel threads have been started in each of the spaces since the user has
+// read it, in the order of the space ids
+func (repo *RedisRepository) GetSpacesUnreadCounts(ctx context.Context, userId models.UserUid, spaceIds []uuid.Uuid) ([]int64, error) {
+	const op errors.Op = "redis_repo.RedisRepository.GetSpacesUnreadCounts"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	var fields = make([]string, 0, len(spaceIds))
+	var collectionKeys = make([]string, 0, len(spaceIds))
+	for _, spaceId := range spaceIds {
+		fields = append(fields, getSpaceReadMarkerField(spaceId))
+		collectionKeys = append(collectionKeys, getSpaceToplevelThreadsByTimeKey(spaceId))
+	}
+
+	unreadCounts, err := repo.getUnreadCounts(ctx, userId, fields, collectionKeys)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return unreadCounts, nil
+}
+
+// GetThreadsUnreadCounts returns how many messages have been sent in each of the threads since the user has read it,
+// in the order of the thread ids
+func (repo *RedisRepository) GetThreadsUnreadCounts(ctx context.Context, userId models.UserUid, threadIds []uuid.Uuid) ([]int64, error) {
+	const op errors.Op = "redis_repo.RedisRepository.GetThreadsUnreadCounts"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	var fields = make([]string, 0, len(threadIds))
+	var collectionKeys = make([]string, 0, len(threadIds))
+	for _, threadId := range threadIds {
+		fields = append(fields, getThreadReadMarkerField(threadId))
+		collectionKeys = append(collectionKeys, getThreadMessagesByTimeKey(threadId))
+	}
+
+	unreadCounts, err := repo.getUnreadCounts(ctx, userId, fields, collectionKeys)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return unreadCounts, nil
+}
+
+// getUnreadCounts counts the members of each collection whose creation time scores are past the read marker stored
+// in the field of the same index. Everything is unread in collections without a marker.
+func (repo *RedisRepository) getUnreadCounts(ctx context.Context, userId models.UserUid, fields, collectionKeys []string) ([]int64, error) {
+	const op errors.Op = "redis_repo.RedisRepository.getUnreadCounts"
+
+	if len(fields) == 0 {
+		return []int64{}, nil
+	}
+
+	readAts, err := repo.redisClient.HMGet(ctx, getUserReadMarkersKey(userId), fields...).Result()
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	pipe := repo.redisClient.Pipeline()
+	var cmds = make([]*redis.IntCmd, 0, len(collectionKeys))
+	for i, collectionKey := range collectionKeys {
+		var min = "-inf"
+		if readAtStr, ok := readAts[i].(string); ok {
+			if _, err := strconv.ParseInt(readAtStr, 10, 64); err != nil {
+				return nil, errors.E(op, err)
+			}
+			min = "(" + readAtStr
+		}
+
+		cmds = append(cmds, pipe.ZCount(ctx, collectionKey, min, "+inf"))
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	var unreadCounts = make([]int64, 0, len(cmds))
+	for _, cmd := range cmds {
+		unreadCounts = append(unreadCounts, cmd.Val())
+	}
+
+	return unreadCounts, nil
+}
+
+// deleteThreadReadMarkers removes the markers of the thread from the read markers of the space's subscribers
+func (repo *RedisRepository) deleteThreadReadMarkers(ctx context.Context, spaceId, threadId uuid.Uuid) error {
+	const op errors.Op = "redis_repo.RedisRepository.deleteThreadReadMarkers"
+
+	subscriberIdStrs, err := repo.redisClient.ZRange(ctx, getSpaceSubscribersKey(spaceId), 0, -1).Result()
+	switch {
+	case err != nil:
+		return errors.E(op, err)
+	case len(subscriberIdStrs) == 0:
+		return nil
+	}
+
+	pipe := repo.redisClient.Pipeline()
+	for _, subscriberIdStr := range subscriberIdStrs {
+		pipe.HDel(ctx, getUserReadMarkersKey(models.UserUid(subscriberIdStr)), getThreadReadMarkerField(threadId))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
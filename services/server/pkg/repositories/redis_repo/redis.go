@@ -12,10 +12,10 @@ import (
 const txRetries = 10
 
 type RedisRepository struct {
-	redisClient *redis.Client
+	redisClient redis.UniversalClient
 }
 
-func NewRedisRepository(redisClient *redis.Client) *RedisRepository {
+func NewRedisRepository(redisClient redis.UniversalClient) *RedisRepository {
 	return &RedisRepository{redisClient}
 }
 
@@ -27,7 +27,20 @@ func (repo *RedisRepository) DeleteAllKeys() error {
 		return errors.E(op, common.ErrOnlyAllowedInDevEnv)
 	}
 
-	if err := repo.redisClient.FlushAll(context.Background()).Err(); err != nil {
+	ctx := context.Background()
+
+	// in cluster mode FLUSHALL only affects the node that receives the command
+	if clusterClient, ok := repo.redisClient.(*redis.ClusterClient); ok {
+		if err := clusterClient.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+			return client.FlushAll(ctx).Err()
+		}); err != nil {
+			return errors.E(op, err)
+		}
+
+		return nil
+	}
+
+	if err := repo.redisClient.FlushAll(ctx).Err(); err != nil {
 		return errors.E(op, err)
 	}
 
@@ -0,0 +1,374 @@
+package redis_repo
+
+import (
+	"context"
+	"fmt"
+	"spaces-p/pkg/common"
+	"spaces-p/pkg/errors"
+	"spaces-p/pkg/models"
+	"spaces-p/pkg/tracing"
+	"spaces-p/pkg/utils"
+	"spaces-p/pkg/uuid"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// adds a report to the aggregated report of its target unless the reporter has reported the target before.
+// The fields describing the target are only written by the first report. Returns {1, reports count} for added reports
+// and {0, reports count} for repeated ones.
+var addReportScript = redis.NewScript(`
+local reportKey = KEYS[1]
+local reportersKey = KEYS[2]
+local reporterId = ARGV[1]
+local reason = ARGV[2]
+local reasonField = ARGV[3]
+local reportsCountField = ARGV[4]
+local lastReportedAtField = ARGV[5]
+local now = ARGV[6]
+
+if redis.call('HSETNX', reportersKey, reporterId, reason) == 0 then
+	return {0, tonumber(redis.call('HGET', reportKey, reportsCountField) or '0')}
+end
+
+if redis.call('EXISTS', reportKey) == 0 then
+	redis.call('HSET', reportKey, unpack(ARGV, 7))
+end
+
+redis.call('HSET', reportKey, lastReportedAtField, now)
+redis.call('HINCRBY', reportKey, reasonField, 1)
+
+return {1, redis.call('HINCRBY', reportKey, reportsCountField, 1)}
+`)
+
+// sets a field of a hash only if the hash exists, so that hiding removed content does not recreate it partially
+var hSetIfExistsScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+
+return redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+`)
+
+// AddReport records the reporter's report of the target. Every user can report a target only once, repeated reports
+// are ignored. It returns whether the report has been added and the target's reports count.
+func (repo *RedisRepository) AddReport(ctx context.Context, newReport models.NewReport) (bool, int, error) {
+	const op errors.Op = "redis_repo.RedisRepository.AddReport"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+	var reportKey = getReportKey(newReport.Target)
+	var reportersKey = getReportReportersKey(newReport.Target)
+	var nowStr = strconv.FormatInt(time.Now().UnixMilli(), 10)
+
+	var spaceIdStr, threadIdStr string
+	if newReport.SpaceId != uuid.Nil {
+		spaceIdStr = newReport.SpaceId.String()
+	}
+	if newReport.ThreadId != uuid.Nil {
+		threadIdStr = newReport.ThreadId.String()
+	}
+
+	result, err := addReportScript.Run(
+		ctx,
+		repo.redisClient,
+		[]string{reportKey, reportersKey},
+		string(newReport.ReporterId),
+		string(newReport.Reason),
+		reportFields.reasonFieldPrefix+string(newReport.Reason),
+		reportFields.reportsCountField,
+		reportFields.lastReportedAtField,
+		nowStr,
+		// fields of the first report
+		reportFields.targetTypeField, string(newReport.Target.Type),
+		reportFields.targetIdField, newReport.Target.ID,
+		reportFields.spaceIdField, spaceIdStr,
+		reportFields.threadIdField, threadIdStr,
+		reportFields.authorIdField, string(newReport.AuthorId),
+		reportFields.excerptField, newReport.Excerpt,
+		reportFields.hiddenField, "0",
+		reportFields.firstReportedAtField, nowStr,
+	).Int64Slice()
+	if err != nil {
+		return false, 0, errors.E(op, err)
+	}
+
+	added, reportsCount := result[0] == 1, result[1]
+	if !added {
+		return false, int(reportsCount), nil
+	}
+
+	// the queues are in other hash slots than the report, so they cannot be updated by the script
+	var queueKeys = []string{getReportsQueueKey()}
+	if newReport.Target.HasSpaceQueue() {
+		queueKeys = append(queueKeys, getSpaceReportsKey(newReport.SpaceId))
+	}
+	for _, queueKey := range queueKeys {
+		if err := repo.redisClient.ZAdd(ctx, queueKey, redis.Z{
+			Score:  float64(reportsCount),
+			Member: newReport.Target.String(),
+		}).Err(); err != nil {
+			return false, 0, errors.E(op, err)
+		}
+	}
+
+	return true, int(reportsCount), nil
+}
+
+func (repo *RedisRepository) GetReport(ctx context.Context, target models.ReportTarget) (*models.Report, error) {
+	const op errors.Op = "redis_repo.RedisRepository.GetReport"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	reportMap, err := repo.redisClient.HGetAll(ctx, getReportKey(target)).Result()
+	switch {
+	case err != nil:
+		return nil, errors.E(op, err)
+	case len(reportMap) == 0:
+		return nil, errors.E(op, common.ErrNotFound)
+	}
+
+	report, err := parseReport(reportMap)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return report, nil
+}
+
+// GetSpaceReports returns the reports of the space's messages and threads, the most reported first
+func (repo *RedisRepository) GetSpaceReports(ctx context.Context, spaceId uuid.Uuid, offset, count int64) ([]models.Report, error) {
+	const op errors.Op = "redis_repo.RedisRepository.GetSpaceReports"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	reports, err := repo.getReports(ctx, getSpaceReportsKey(spaceId), offset, count)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return reports, nil
+}
+
+// GetReports returns the reports of all targets, the most reported first
+func (repo *RedisRepository) GetReports(ctx context.Context, offset, count int64) ([]models.Report, error) {
+	const op errors.Op = "redis_repo.RedisRepository.GetReports"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	reports, err := repo.getReports(ctx, getReportsQueueKey(), offset, count)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return reports, nil
+}
+
+// DeleteReport removes the target's reports and their queue entries
+func (repo *RedisRepository) DeleteReport(ctx context.Context, target models.ReportTarget, spaceId uuid.Uuid) error {
+	const op errors.Op = "redis_repo.RedisRepository.DeleteReport"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if err := repo.redisClient.ZRem(ctx, getReportsQueueKey(), target.String()).Err(); err != nil {
+		return errors.E(op, err)
+	}
+
+	if target.HasSpaceQueue() {
+		if err := repo.redisClient.ZRem(ctx, getSpaceReportsKey(spaceId), target.String()).Err(); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	if err := repo.redisClient.Del(ctx, getReportKey(target), getReportReportersKey(target)).Err(); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// SetReportTargetHidden hides or shows the reported message, thread or space. Users cannot be hidden.
+func (repo *RedisRepository) SetReportTargetHidden(ctx context.Context, target models.ReportTarget, hidden bool) error {
+	const op errors.Op = "redis_repo.RedisRepository.SetReportTargetHidden"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	var hiddenField string
+	switch target.Type {
+	case models.MessageReportTarget:
+		hiddenField = messageFields.hiddenField
+	case models.ThreadReportTarget:
+		hiddenField = threadFields.hiddenField
+	case models.SpaceReportTarget:
+		hiddenField = spaceFields.hiddenField
+	default:
+		err := fmt.Errorf("targets of type %s cannot be hidden", target.Type)
+		return errors.E(op, err)
+	}
+
+	targetKey, err := getReportTargetKey(target)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	if hidden {
+		err = hSetIfExistsScript.Run(ctx, repo.redisClient, []string{targetKey}, hiddenField, "1").Err()
+	} else {
+		err = repo.redisClient.HDel(ctx, targetKey, hiddenField).Err()
+	}
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	if target.Type == models.SpaceReportTarget {
+		spaceId, err := uuid.Parse(target.ID)
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		if err := repo.UpdateSpaceTileEntry(ctx, spaceId); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	var hiddenStr = "0"
+	if hidden {
+		hiddenStr = "1"
+	}
+	if err := hSetIfExistsScript.Run(ctx, repo.redisClient, []string{getReportKey(target)}, reportFields.hiddenField, hiddenStr).Err(); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+func (repo *RedisRepository) getReports(ctx context.Context, collectionKey string, offset, count int64) ([]models.Report, error) {
+	const op errors.Op = "redis_repo.RedisRepository.getReports"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	targetStrs, err := repo.redisClient.ZRevRange(ctx, collectionKey, offset, offset+count-1).Result()
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	pipe := repo.redisClient.Pipeline()
+	var cmds = make([]*redis.MapStringStringCmd, 0, len(targetStrs))
+	for _, targetStr := range targetStrs {
+		var target models.ReportTarget
+		if err := target.ParseString(targetStr); err != nil {
+			return nil, errors.E(op, err)
+		}
+
+		cmds = append(cmds, pipe.HGetAll(ctx, getReportKey(target)))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	var reports = make([]models.Report, 0, len(cmds))
+	for _, cmd := range cmds {
+		reportMap := cmd.Val()
+		// resolved in the meantime
+		if len(reportMap) == 0 {
+			continue
+		}
+
+		report, err := parseReport(reportMap)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+
+		reports = append(reports, *report)
+	}
+
+	return reports, nil
+}
+
+func parseReport(reportMap map[string]string) (*models.Report, error) {
+	const op errors.Op = "redis_repo.parseReport"
+
+	var target models.ReportTarget
+	if err := target.ParseString(reportMap[reportFields.targetTypeField] + ":" + reportMap[reportFields.targetIdField]); err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	var spaceId, threadId = uuid.Nil, uuid.Nil
+	var err error
+	if spaceIdStr := reportMap[reportFields.spaceIdField]; spaceIdStr != "" {
+		if spaceId, err = uuid.Parse(spaceIdStr); err != nil {
+			return nil, errors.E(op, err)
+		}
+	}
+	if threadIdStr := reportMap[reportFields.threadIdField]; threadIdStr != "" {
+		if threadId, err = uuid.Parse(threadIdStr); err != nil {
+			return nil, errors.E(op, err)
+		}
+	}
+
+	reportsCount, err := strconv.Atoi(reportMap[reportFields.reportsCountField])
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	firstReportedAt, err := utils.StringToTime(reportMap[reportFields.firstReportedAtField])
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	lastReportedAt, err := utils.StringToTime(reportMap[reportFields.lastReportedAtField])
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	var reasons = map[models.ReportReason]int{}
+	for field, value := range reportMap {
+		reason, found := strings.CutPrefix(field, reportFields.reasonFieldPrefix)
+		if !found {
+			continue
+		}
+
+		reasonCount, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+		reasons[models.ReportReason(reason)] = reasonCount
+	}
+
+	return &models.Report{
+		Target:          target,
+		SpaceId:         spaceId,
+		ThreadId:        threadId,
+		AuthorId:        models.UserUid(reportMap[reportFields.authorIdField]),
+		Excerpt:         reportMap[reportFields.excerptField],
+		ReportsCount:    reportsCount,
+		Reasons:         reasons,
+		Hidden:          reportMap[reportFields.hiddenField] == "1",
+		FirstReportedAt: firstReportedAt,
+		LastReportedAt:  lastReportedAt,
+	}, nil
+}
+
+// getReportTargetKey returns the key of the hash of the reported message, thread, space or user
+func getReportTargetKey(target models.ReportTarget) (string, error) {
+	if target.Type == models.UserReportTarget {
+		return getUserKey(models.UserUid(target.ID)), nil
+	}
+
+	id, err := uuid.Parse(target.ID)
+	if err != nil {
+		return "", err
+	}
+
+	switch target.Type {
+	case models.MessageReportTarget:
+		return getMessageKey(id), nil
+	case models.ThreadReportTarget:
+		return getThreadKey(id), nil
+	case models.SpaceReportTarget:
+		return getSpaceKey(id), nil
+	default:
+		return "", fmt.Errorf("unknown report target type: %s", target.Type)
+	}
+}
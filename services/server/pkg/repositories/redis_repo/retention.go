@@ -0,0 +1,210 @@
+package redis_repo
+
+import (
+	"context"
+	"spaces-p/pkg/errors"
+	"spaces-p/pkg/tracing"
+	"spaces-p/pkg/uuid"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// SetSpaceMessageRetention sets the age in hours after which the messages of the space expire, 0 removes the retention
+func (repo *RedisRepository) SetSpaceMessageRetention(ctx context.Context, spaceId uuid.Uuid, retentionHours int) error {
+	const op errors.Op = "redis_repo.RedisRepository.SetSpaceMessageRetention"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+	var spaceKey = getSpaceKey(spaceId)
+	var messageRetentionSpacesKey = getMessageRetentionSpacesKey()
+
+	if retentionHours == 0 {
+		if err := repo.redisClient.HDel(ctx, spaceKey, spaceFields.retentionField).Err(); err != nil {
+			return errors.E(op, err)
+		}
+
+		if err := repo.redisClient.SRem(ctx, messageRetentionSpacesKey, spaceId.String()).Err(); err != nil {
+			return errors.E(op, err)
+		}
+
+		return nil
+	}
+
+	// a space that has been removed concurrently leaves a dangling member, which the consistency check removes
+	if err := hSetIfExistsScript.Run(ctx, repo.redisClient, []string{spaceKey}, spaceFields.retentionField, strconv.Itoa(retentionHours)).Err(); err != nil {
+		return errors.E(op, err)
+	}
+
+	if err := repo.redisClient.SAdd(ctx, messageRetentionSpacesKey, spaceId.String()).Err(); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// GetMessageRetentionSpaceIds returns the ids of all spaces that have a message retention
+func (repo *RedisRepository) GetMessageRetentionSpaceIds(ctx context.Context) ([]uuid.Uuid, error) {
+	const op errors.Op = "redis_repo.RedisRepository.GetMessageRetentionSpaceIds"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	spaceIdStrs, err := repo.redisClient.SMembers(ctx, getMessageRetentionSpacesKey()).Result()
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	var spaceIds = make([]uuid.Uuid, 0, len(spaceIdStrs))
+	for _, spaceIdStr := range spaceIdStrs {
+		spaceId, err := uuid.Parse(spaceIdStr)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+
+		spaceIds = append(spaceIds, spaceId)
+	}
+
+	return spaceIds, nil
+}
+
+// GetExpiredTopLevelThreadIds returns the ids of up to count toplevel threads of the space that have been created
+// before until, the oldest first, skipping the first offset ones
+func (repo *RedisRepository) GetExpiredTopLevelThreadIds(ctx context.Context, spaceId uuid.Uuid, until time.Time, offset, count int64) ([]uuid.Uuid, error) {
+	const op errors.Op = "redis_repo.RedisRepository.GetExpiredTopLevelThreadIds"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	threadIds, err := repo.getIdsCreatedBefore(ctx, getSpaceToplevelThreadsByTimeKey(spaceId), until, offset, count)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return threadIds, nil
+}
+
+// GetExpiredThreadMessageIds returns the ids of up to count messages of the thread that have been created before until,
+// the oldest first, skipping the first offset ones
+func (repo *RedisRepository) GetExpiredThreadMessageIds(ctx context.Context, threadId uuid.Uuid, until time.Time, offset, count int64) ([]uuid.Uuid, error) {
+	const op errors.Op = "redis_repo.RedisRepository.GetExpiredThreadMessageIds"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	messageIds, err := repo.getIdsCreatedBefore(ctx, getThreadMessagesByTimeKey(threadId), until, offset, count)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return messageIds, nil
+}
+
+// ExpireTopLevelThread removes the toplevel thread along with all its messages and their child threads. It returns
+// false if the thread has been removed from the space before, e.g. concurrently by another server instance.
+func (repo *RedisRepository) ExpireTopLevelThread(ctx context.Context, spaceId, threadId uuid.Uuid) (bool, error) {
+	const op errors.Op = "redis_repo.RedisRepository.ExpireTopLevelThread"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	removed, err := repo.deleteTopLevelThread(ctx, spaceId, threadId)
+	if err != nil {
+		return false, errors.E(op, err)
+	}
+
+	return removed, nil
+}
+
+// getIdsCreatedBefore returns up to count ids of the sorted set scored by creation time that have been created before
+// until, skipping the first offset ones
+func (repo *RedisRepository) getIdsCreatedBefore(ctx context.Context, collectionKey string, until time.Time, offset, count int64) ([]uuid.Uuid, error) {
+	const op errors.Op = "redis_repo.RedisRepository.getIdsCreatedBefore"
+
+	idStrs, err := repo.redisClient.ZRangeByScore(ctx, collectionKey, &redis.ZRangeBy{
+		Min:    "-inf",
+		Max:    "(" + strconv.FormatInt(until.UnixMilli(), 10),
+		Offset: offset,
+		Count:  count,
+	}).Result()
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	var ids = make([]uuid.Uuid, 0, len(idStrs))
+	for _, idStr := range idStrs {
+		id, err := uuid.Parse(idStr)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// deleteThreadMessages removes the messages of the thread one by one along with their child threads, so that the
+// thread's messages count and popularity set stay consistent if it fails halfway
+func (repo *RedisRepository) deleteThreadMessages(ctx context.Context, threadId uuid.Uuid) error {
+	const op errors.Op = "redis_repo.RedisRepository.deleteThreadMessages"
+
+	messageIdStrs, err := repo.redisClient.ZRange(ctx, getThreadMessagesByTimeKey(threadId), 0, -1).Result()
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	for _, messageIdStr := range messageIdStrs {
+		messageId, err := uuid.Parse(messageIdStr)
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		if err := repo.DeleteMessage(ctx, threadId, messageId); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	return nil
+}
+
+// deleteChildThread removes the child thread of the message with all its messages, if the message has one
+func (repo *RedisRepository) deleteChildThread(ctx context.Context, messageId uuid.Uuid) error {
+	const op errors.Op = "redis_repo.RedisRepository.deleteChildThread"
+
+	childThreadIdStr, err := repo.redisClient.HGet(ctx, getMessageKey(messageId), messageFields.childThreadIdField).Result()
+	switch {
+	case errors.Is(err, redis.Nil):
+		return nil
+	case err != nil:
+		return errors.E(op, err)
+	}
+
+	childThreadId, err := uuid.Parse(childThreadIdStr)
+	if err != nil {
+		return nil
+	}
+
+	if err := repo.deleteThreadMessages(ctx, childThreadId); err != nil {
+		return errors.E(op, err)
+	}
+
+	spaceIdStr, err := repo.redisClient.HGet(ctx, getThreadKey(childThreadId), threadFields.spaceIdField).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return errors.E(op, err)
+	}
+
+	if spaceId, err := uuid.Parse(spaceIdStr); err == nil {
+		if err := repo.deleteThreadReadMarkers(ctx, spaceId, childThreadId); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	if err := repo.redisClient.Del(
+		ctx,
+		getThreadKey(childThreadId),
+		getThreadMessagesByTimeKey(childThreadId),
+		getThreadMessagesByPopularityKey(childThreadId),
+		getThreadFollowersKey(childThreadId),
+	).Err(); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
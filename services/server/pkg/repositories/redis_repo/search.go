@@ -0,0 +1,188 @@
+package redis_repo
+
+import (
+	"context"
+	"sort"
+	"spaces-p/pkg/errors"
+	"spaces-p/pkg/models"
+	"spaces-p/pkg/tracing"
+	"spaces-p/pkg/uuid"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// the maximum number of entries that are looked at per word or trigram of a query
+	maxSpaceNameEntries = 200
+	// the maximum number of spaces that are returned to be ranked for a query
+	maxSpaceNameCandidates = 100
+)
+
+// GetSpacesByName returns the spaces with a word starting with one of the terms, followed by the spaces whose words
+// share the most trigrams or the first letter with the terms. The spaces are not ranked by the relevance of their
+// names.
+func (repo *RedisRepository) GetSpacesByName(ctx context.Context, terms []string) ([]models.Space, error) {
+	const op errors.Op = "redis_repo.RedisRepository.GetSpacesByName"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	var termCmds = make([]*redis.StringSliceCmd, 0, len(terms))
+	var similarTermCmds []*redis.StringSliceCmd
+	pipe := repo.redisClient.Pipeline()
+	for _, term := range terms {
+		// words only consist of letters and digits, so every entry of a word starting with term sorts before term
+		// followed by 0xff, which is no valid utf-8
+		termCmds = append(termCmds, pipe.ZRangeByLex(ctx, getSpaceNameTermsKey(), &redis.ZRangeBy{
+			Min:   "[" + term,
+			Max:   "[" + term + "\xff",
+			Count: maxSpaceNameEntries,
+		}))
+
+		// short words with a typo might not share a trigram, e.g. swapped letters, but likely start with the same letter
+		firstLetter := string([]rune(term)[:1])
+		similarTermCmds = append(similarTermCmds, pipe.ZRangeByLex(ctx, getSpaceNameTermsKey(), &redis.ZRangeBy{
+			Min:   "[" + firstLetter,
+			Max:   "[" + firstLetter + "\xff",
+			Count: maxSpaceNameEntries,
+		}))
+
+		for _, trigram := range models.TermTrigrams(term) {
+			similarTermCmds = append(similarTermCmds, pipe.ZRangeByLex(ctx, getSpaceNameTrigramsKey(), &redis.ZRangeBy{
+				Min:   "[" + trigram + ":",
+				Max:   "[" + trigram + ":\xff",
+				Count: maxSpaceNameEntries,
+			}))
+		}
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	// a word starting with a term outweighs any number of shared trigrams and first letters
+	var hits = make(map[string]int)
+	for _, cmd := range termCmds {
+		for _, member := range cmd.Val() {
+			hits[spaceIdOfNameMember(member)] += len(similarTermCmds) + 1
+		}
+	}
+	for _, cmd := range similarTermCmds {
+		for _, member := range cmd.Val() {
+			hits[spaceIdOfNameMember(member)]++
+		}
+	}
+
+	var spaceIdStrs = make([]string, 0, len(hits))
+	for spaceIdStr := range hits {
+		spaceIdStrs = append(spaceIdStrs, spaceIdStr)
+	}
+	sort.Slice(spaceIdStrs, func(i, j int) bool {
+		return hits[spaceIdStrs[i]] > hits[spaceIdStrs[j]]
+	})
+	spaceIdStrs = spaceIdStrs[:min(maxSpaceNameCandidates, len(spaceIdStrs))]
+
+	var spaceIds = make([]uuid.Uuid, len(spaceIdStrs))
+	var hGetAllCmds = make([]*redis.MapStringStringCmd, len(spaceIdStrs))
+	pipe = repo.redisClient.Pipeline()
+	for i, spaceIdStr := range spaceIdStrs {
+		spaceId, err := uuid.Parse(spaceIdStr)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+
+		spaceIds[i] = spaceId
+		hGetAllCmds[i] = pipe.HGetAll(ctx, getSpaceKey(spaceId))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	var spaces = make([]models.Space, 0, len(spaceIds))
+	for i, cmd := range hGetAllCmds {
+		spaceMap := cmd.Val()
+		// the space might have been removed concurrently
+		if len(spaceMap) == 0 {
+			continue
+		}
+
+		space, err := repo.parseSpace(spaceMap)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+		space.ID = spaceIds[i]
+
+		spaces = append(spaces, *space)
+	}
+
+	return spaces, nil
+}
+
+// getSpaceNameMembers returns the entries of the space in the word and trigram sets
+func getSpaceNameMembers(spaceId uuid.Uuid, name string) ([]string, []string) {
+	var termMembers, trigramMembers []string
+	var seenTrigrams = make(map[string]bool)
+	for _, term := range models.SpaceNameTerms(name) {
+		termMembers = append(termMembers, getSpaceNameMember(term, spaceId))
+
+		for _, trigram := range models.TermTrigrams(term) {
+			if !seenTrigrams[trigram] {
+				seenTrigrams[trigram] = true
+				trigramMembers = append(trigramMembers, getSpaceNameMember(trigram, spaceId))
+			}
+		}
+	}
+
+	return termMembers, trigramMembers
+}
+
+func spaceIdOfNameMember(member string) string {
+	return member[strings.LastIndex(member, ":")+1:]
+}
+
+// indexSpaceName adds the entries of the space's name to the word and trigram sets
+func (repo *RedisRepository) indexSpaceName(ctx context.Context, spaceId uuid.Uuid, name string) error {
+	const op errors.Op = "redis_repo.RedisRepository.indexSpaceName"
+
+	termMembers, trigramMembers := getSpaceNameMembers(spaceId, name)
+
+	pipe := repo.redisClient.Pipeline()
+	for _, member := range termMembers {
+		pipe.ZAdd(ctx, getSpaceNameTermsKey(), redis.Z{Score: 0, Member: member})
+	}
+	for _, member := range trigramMembers {
+		pipe.ZAdd(ctx, getSpaceNameTrigramsKey(), redis.Z{Score: 0, Member: member})
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// unindexSpaceName removes the entries of the name stored in the space's hash from the word and trigram sets
+func (repo *RedisRepository) unindexSpaceName(ctx context.Context, spaceId uuid.Uuid) error {
+	const op errors.Op = "redis_repo.RedisRepository.unindexSpaceName"
+
+	name, err := repo.redisClient.HGet(ctx, getSpaceKey(spaceId), spaceFields.nameField).Result()
+	switch {
+	case errors.Is(err, redis.Nil):
+		return nil
+	case err != nil:
+		return errors.E(op, err)
+	}
+
+	termMembers, trigramMembers := getSpaceNameMembers(spaceId, name)
+
+	pipe := repo.redisClient.Pipeline()
+	for _, member := range termMembers {
+		pipe.ZRem(ctx, getSpaceNameTermsKey(), member)
+	}
+	for _, member := range trigramMembers {
+		pipe.ZRem(ctx, getSpaceNameTrigramsKey(), member)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
@@ -5,9 +5,11 @@ import (
 	"spaces-p/pkg/common"
 	"spaces-p/pkg/errors"
 	"spaces-p/pkg/models"
+	"spaces-p/pkg/tracing"
 	"spaces-p/pkg/utils"
 	"spaces-p/pkg/uuid"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -15,6 +17,8 @@ import (
 
 func (repo *RedisRepository) GetSpace(ctx context.Context, spaceid uuid.Uuid) (*models.Space, error) {
 	const op errors.Op = "redis_repo.RedisRepository.GetSpace"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
 	var spaceKey = getSpaceKey(spaceid)
 
 	r, err := repo.redisClient.HGetAll(ctx, spaceKey).Result()
@@ -36,6 +40,8 @@ func (repo *RedisRepository) GetSpace(ctx context.Context, spaceid uuid.Uuid) (*
 
 func (repo *RedisRepository) GetSpacesByUserId(ctx context.Context, userId models.UserUid, count, offset int64) ([]models.Space, error) {
 	const op errors.Op = "redis_repo.RedisRepository.GetSpacesByUserId"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
 	var userSpacesKey = getUserSpacesKey(userId)
 
 	spaceMaps, spaceIds, err := getCollectionValues(ctx, repo, userSpacesKey, offset, count, getSpaceKey)
@@ -58,6 +64,30 @@ func (repo *RedisRepository) GetSpacesByUserId(ctx context.Context, userId model
 	return spaces, nil
 }
 
+// GetUserSpaceIds returns the ids of all spaces the user has subscribed to
+func (repo *RedisRepository) GetUserSpaceIds(ctx context.Context, userId models.UserUid) ([]uuid.Uuid, error) {
+	const op errors.Op = "redis_repo.RedisRepository.GetUserSpaceIds"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	spaceIdStrs, err := repo.redisClient.ZRange(ctx, getUserSpacesKey(userId), 0, -1).Result()
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	var spaceIds = make([]uuid.Uuid, 0, len(spaceIdStrs))
+	for _, spaceIdStr := range spaceIdStrs {
+		spaceId, err := uuid.Parse(spaceIdStr)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+
+		spaceIds = append(spaceIds, spaceId)
+	}
+
+	return spaceIds, nil
+}
+
 func (repo *RedisRepository) GetSpacesByLocation(
 	ctx context.Context,
 	location models.Location,
@@ -79,6 +109,80 @@ func (repo *RedisRepository) GetSpacesByLocation(
 		return nil, errors.E(op, err)
 	}
 
+	spaces, err := repo.getSpacesWithDistance(ctx, geoLocations, searchRadius)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return spaces, nil
+}
+
+// GetSpacesByLocationAndTag is GetSpacesByLocation for the spaces that carry the tag. The spaces near the location are
+// intersected with the tag's index, so that count spaces are returned no matter how many spaces without the tag are
+// closer.
+func (repo *RedisRepository) GetSpacesByLocationAndTag(
+	ctx context.Context,
+	location models.Location,
+	searchRadius models.Radius,
+	tag string,
+	count int,
+) ([]models.SpaceWithDistance, error) {
+	const op errors.Op = "redis_repo.RedisRepository.GetSpacesByLocationAndTag"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	geoLocations, err := repo.redisClient.GeoRadius(ctx, getSpaceCoordinatesKey(), location.Long, location.Lat, &redis.GeoRadiusQuery{
+		Radius:   float64(searchRadius) + models.MaxSpaceRadiusM,
+		Unit:     "m",
+		WithDist: true,
+		Sort:     "asc",
+	}).Result()
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+	if len(geoLocations) == 0 {
+		return []models.SpaceWithDistance{}, nil
+	}
+
+	var spaceIdStrs = make([]any, len(geoLocations))
+	for i, geoLocation := range geoLocations {
+		spaceIdStrs[i] = geoLocation.Name
+	}
+
+	carriesTag, err := repo.redisClient.SMIsMember(ctx, getTagSpacesKey(tag), spaceIdStrs...).Result()
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	var taggedGeoLocations = make([]redis.GeoLocation, 0, count)
+	for i, geoLocation := range geoLocations {
+		if !carriesTag[i] {
+			continue
+		}
+
+		taggedGeoLocations = append(taggedGeoLocations, geoLocation)
+		if len(taggedGeoLocations) == count {
+			break
+		}
+	}
+
+	spaces, err := repo.getSpacesWithDistance(ctx, taggedGeoLocations, searchRadius)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return spaces, nil
+}
+
+// getSpacesWithDistance returns the spaces of the locations found around a location that lie within the search radius,
+// the spaces the location lies in first
+func (repo *RedisRepository) getSpacesWithDistance(ctx context.Context, geoLocations []redis.GeoLocation, searchRadius models.Radius) ([]models.SpaceWithDistance, error) {
+	const op errors.Op = "redis_repo.RedisRepository.getSpacesWithDistance"
+
+	if len(geoLocations) == 0 {
+		return []models.SpaceWithDistance{}, nil
+	}
+
 	pipe := repo.redisClient.Pipeline()
 	for _, geoLocation := range geoLocations {
 		spaceId, err := uuid.Parse(geoLocation.Name)
@@ -141,6 +245,68 @@ func (repo *RedisRepository) GetSpacesByLocation(
 	return append(inSpaces, closeSpaces...), nil
 }
 
+// GetSpacesInBox returns up to count spaces whose location lies inside the bounding box, the closest to its center
+// first
+func (repo *RedisRepository) GetSpacesInBox(ctx context.Context, box models.BoundingBox, count int) ([]models.Space, error) {
+	const op errors.Op = "redis_repo.RedisRepository.GetSpacesInBox"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	var center = box.Center()
+	width, height := box.SizeM()
+	spaceIdStrs, err := repo.redisClient.GeoSearch(ctx, getSpaceCoordinatesKey(), &redis.GeoSearchQuery{
+		Longitude: center.Long,
+		Latitude:  center.Lat,
+		BoxWidth:  width,
+		BoxHeight: height,
+		BoxUnit:   "m",
+		Sort:      "ASC",
+		Count:     count,
+	}).Result()
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	var spaceIds = make([]uuid.Uuid, 0, len(spaceIdStrs))
+	pipe := repo.redisClient.Pipeline()
+	for _, spaceIdStr := range spaceIdStrs {
+		spaceId, err := uuid.Parse(spaceIdStr)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+
+		spaceIds = append(spaceIds, spaceId)
+		pipe.HGetAll(ctx, getSpaceKey(spaceId))
+	}
+
+	cmds, err := pipe.Exec(ctx)
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, errors.E(op, err)
+	}
+
+	var spaces = make([]models.Space, 0, len(cmds))
+	for i, cmd := range cmds {
+		spaceMap := cmd.(*redis.MapStringStringCmd).Val()
+		// the space might have been removed since the search
+		if len(spaceMap) == 0 {
+			continue
+		}
+
+		space, err := repo.parseSpace(spaceMap)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+		space.ID = spaceIds[i]
+
+		// the search box is larger than the bounding box away from the equator
+		if box.Contains(space.Location) {
+			spaces = append(spaces, *space)
+		}
+	}
+
+	return spaces, nil
+}
+
 func (repo *RedisRepository) GetSpaceSubscribers(ctx context.Context, spaceId uuid.Uuid, offset, count int64) ([]models.User, error) {
 	var spaceSubscribersKey = getSpaceSubscribersKey(spaceId)
 
@@ -156,6 +322,8 @@ func (repo *RedisRepository) GetSpaceActiveSubscribers(ctx context.Context, spac
 // from is including
 func (repo *RedisRepository) GetSpaceTopLevelThreadsByTime(ctx context.Context, spaceId uuid.Uuid, offset, count int64) ([]models.TopLevelThread, error) {
 	const op errors.Op = "redis_repo.RedisRepository.GetSpaceTopLevelThreadsByTime"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
 	var spaceToplevelThreadsByTimeKey = getSpaceToplevelThreadsByTimeKey(spaceId)
 
 	threads, err := repo.getSpaceTopLevelThreads(ctx, spaceToplevelThreadsByTimeKey, offset, count)
@@ -168,6 +336,8 @@ func (repo *RedisRepository) GetSpaceTopLevelThreadsByTime(ctx context.Context,
 
 func (repo *RedisRepository) GetSpaceTopLevelThreadsByPopularity(ctx context.Context, spaceId uuid.Uuid, offset, count int64) ([]models.TopLevelThread, error) {
 	const op errors.Op = "redis_repo.RedisRepository.GetSpaceTopLevelThreadsByPopularity"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
 	var spaceToplevelThreadsByPopularityKey = getSpaceToplevelThreadsByPopularityKey(spaceId)
 
 	threads, err := repo.getSpaceTopLevelThreads(ctx, spaceToplevelThreadsByPopularityKey, offset, count)
@@ -180,6 +350,8 @@ func (repo *RedisRepository) GetSpaceTopLevelThreadsByPopularity(ctx context.Con
 
 func (repo *RedisRepository) SetSpace(ctx context.Context, newSpace models.NewSpace) (uuid.Uuid, error) {
 	const op errors.Op = "redis_repo.RedisRepository.SetSpace"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
 	var spaceId = uuid.New()
 	var spaceCoordinatesKey = getSpaceCoordinatesKey()
 	var spaceKey = getSpaceKey(spaceId)
@@ -187,14 +359,34 @@ func (repo *RedisRepository) SetSpace(ctx context.Context, newSpace models.NewSp
 	unixTimeStamp := time.Now().UnixMilli()
 	unixTimeStampStr := strconv.FormatInt(unixTimeStamp, 10)
 	locationStr := newSpace.Location.String()
-	if err := repo.redisClient.HSet(ctx, spaceKey, map[string]any{
+	var spaceMap = map[string]any{
 		spaceFields.nameField:               newSpace.Name,
 		spaceFields.radiusField:             newSpace.Radius,
 		spaceFields.locationField:           locationStr,
 		spaceFields.themeColorHexaCodeField: newSpace.ThemeColorHexaCode,
 		spaceFields.createdAtField:          unixTimeStampStr,
 		spaceFields.adminIdField:            newSpace.AdminId,
-	}).Err(); err != nil {
+	}
+	if newSpace.StartsAt != nil {
+		spaceMap[spaceFields.startsAtField] = strconv.FormatInt(newSpace.StartsAt.UnixMilli(), 10)
+	}
+	if newSpace.EndsAt != nil {
+		spaceMap[spaceFields.endsAtField] = strconv.FormatInt(newSpace.EndsAt.UnixMilli(), 10)
+	}
+	if newSpace.MessageRetentionHours > 0 {
+		spaceMap[spaceFields.retentionField] = strconv.Itoa(newSpace.MessageRetentionHours)
+	}
+	if newSpace.Category != "" {
+		spaceMap[spaceFields.categoryField] = string(newSpace.Category)
+	}
+	if len(newSpace.Tags) > 0 {
+		spaceMap[spaceFields.tagsField] = strings.Join(newSpace.Tags, ",")
+	}
+	if err := repo.redisClient.HSet(ctx, spaceKey, spaceMap).Err(); err != nil {
+		return uuid.Nil, errors.E(op, err)
+	}
+
+	if err := repo.UpdateSpaceTileEntry(ctx, spaceId); err != nil {
 		return uuid.Nil, errors.E(op, err)
 	}
 
@@ -206,14 +398,118 @@ func (repo *RedisRepository) SetSpace(ctx context.Context, newSpace models.NewSp
 		return uuid.Nil, errors.E(op, err)
 	}
 
+	if err := repo.indexSpaceTags(ctx, spaceId, newSpace.Tags); err != nil {
+		return uuid.Nil, errors.E(op, err)
+	}
+
+	if err := repo.indexSpaceName(ctx, spaceId, newSpace.Name); err != nil {
+		return uuid.Nil, errors.E(op, err)
+	}
+
+	if newSpace.MessageRetentionHours > 0 {
+		if err := repo.redisClient.SAdd(ctx, getMessageRetentionSpacesKey(), spaceId.String()).Err(); err != nil {
+			return uuid.Nil, errors.E(op, err)
+		}
+	}
+
+	// the first transition of a time-bounded space is its opening, or its closing if it has no start time
+	var firstTransitionAt = newSpace.StartsAt
+	if firstTransitionAt == nil {
+		firstTransitionAt = newSpace.EndsAt
+	}
+	if firstTransitionAt != nil {
+		if err := repo.redisClient.ZAdd(ctx, getSpaceTransitionsKey(), redis.Z{
+			Score:  float64(firstTransitionAt.UnixMilli()),
+			Member: spaceId.String(),
+		}).Err(); err != nil {
+			return uuid.Nil, errors.E(op, err)
+		}
+	}
+
 	return spaceId, nil
 }
 
+// GetSpaceSubscriberIds returns the ids of all subscribers of the space
+func (repo *RedisRepository) GetSpaceSubscriberIds(ctx context.Context, spaceId uuid.Uuid) ([]models.UserUid, error) {
+	const op errors.Op = "redis_repo.RedisRepository.GetSpaceSubscriberIds"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	subscriberIdStrs, err := repo.redisClient.ZRange(ctx, getSpaceSubscribersKey(spaceId), 0, -1).Result()
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	var subscriberIds = make([]models.UserUid, 0, len(subscriberIdStrs))
+	for _, subscriberIdStr := range subscriberIdStrs {
+		subscriberIds = append(subscriberIds, models.UserUid(subscriberIdStr))
+	}
+
+	return subscriberIds, nil
+}
+
+// DeleteSpace removes the space along with its threads, messages, subscriptions, held messages and the reports of its
+// content. The space itself goes last, so that it can be deleted again if it fails halfway.
 func (repo *RedisRepository) DeleteSpace(ctx context.Context, spaceId uuid.Uuid) error {
 	const op errors.Op = "redis_repo.RedisRepository.DeleteSpace"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
 	var spaceKey = getSpaceKey(spaceId)
 	var spaceCoordinatesKey = getSpaceCoordinatesKey()
 
+	if err := repo.unindexSpaceTags(ctx, spaceId); err != nil {
+		return errors.E(op, err)
+	}
+
+	if err := repo.unindexSpaceName(ctx, spaceId); err != nil {
+		return errors.E(op, err)
+	}
+
+	if err := repo.deleteSpaceTileEntry(ctx, spaceId); err != nil {
+		return errors.E(op, err)
+	}
+
+	threadIdStrs, err := repo.redisClient.ZRange(ctx, getSpaceToplevelThreadsByTimeKey(spaceId), 0, -1).Result()
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	for _, threadIdStr := range threadIdStrs {
+		threadId, err := uuid.Parse(threadIdStr)
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		if err := repo.DeleteTopLevelThread(ctx, spaceId, threadId); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	if err := repo.deleteSpaceSubscribers(ctx, spaceId); err != nil {
+		return errors.E(op, err)
+	}
+
+	if err := repo.deleteSpaceHeldMessages(ctx, spaceId); err != nil {
+		return errors.E(op, err)
+	}
+
+	if err := repo.deleteSpaceReports(ctx, spaceId); err != nil {
+		return errors.E(op, err)
+	}
+
+	// all keys share the {spaceid} hash tag, so this also works in cluster mode
+	if err := repo.redisClient.Del(
+		ctx,
+		getSpaceToplevelThreadsByTimeKey(spaceId),
+		getSpaceToplevelThreadsByPopularityKey(spaceId),
+		getSpaceRecentMessagesKey(spaceId),
+		getSpaceBannedUsersKey(spaceId),
+		getSpaceLocationPinsKey(spaceId),
+		getSpacePinsKey(spaceId),
+	).Err(); err != nil {
+		return errors.E(op, err)
+	}
+
 	if err := repo.redisClient.Del(ctx, spaceKey).Err(); err != nil {
 		return errors.E(op, err)
 	}
@@ -222,11 +518,221 @@ func (repo *RedisRepository) DeleteSpace(ctx context.Context, spaceId uuid.Uuid)
 		return errors.E(op, err)
 	}
 
+	if err := repo.redisClient.ZRem(ctx, getSpaceTransitionsKey(), spaceId.String()).Err(); err != nil {
+		return errors.E(op, err)
+	}
+
+	if err := repo.redisClient.SRem(ctx, getMessageRetentionSpacesKey(), spaceId.String()).Err(); err != nil {
+		return errors.E(op, err)
+	}
+
 	return nil
 }
 
+// deleteSpaceSubscribers removes the subscriptions of the space from its subscribers along with their sessions and
+// their read markers of the space
+func (repo *RedisRepository) deleteSpaceSubscribers(ctx context.Context, spaceId uuid.Uuid) error {
+	const op errors.Op = "redis_repo.RedisRepository.deleteSpaceSubscribers"
+	var spaceSubscribersKey = getSpaceSubscribersKey(spaceId)
+
+	subscriberIdStrs, err := repo.redisClient.ZRange(ctx, spaceSubscribersKey, 0, -1).Result()
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	for _, subscriberIdStr := range subscriberIdStrs {
+		var subscriberId = models.UserUid(subscriberIdStr)
+		var sessionsKey = getSpaceActiveSubscriberSessionsKey(spaceId, subscriberId)
+
+		sessionIdStrs, err := repo.redisClient.ZRange(ctx, sessionsKey, 0, -1).Result()
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		for _, sessionIdStr := range sessionIdStrs {
+			if sessionId, err := uuid.Parse(sessionIdStr); err == nil {
+				if err := repo.redisClient.Del(ctx, getSessionKey(sessionId)).Err(); err != nil {
+					return errors.E(op, err)
+				}
+			}
+
+			if err := repo.redisClient.ZRem(ctx, getSessionExpirationsKey(), sessionIdStr).Err(); err != nil {
+				return errors.E(op, err)
+			}
+		}
+
+		if err := repo.redisClient.Del(ctx, sessionsKey).Err(); err != nil {
+			return errors.E(op, err)
+		}
+
+		if err := repo.redisClient.HDel(ctx, getUserReadMarkersKey(subscriberId), getSpaceReadMarkerField(spaceId)).Err(); err != nil {
+			return errors.E(op, err)
+		}
+
+		if err := repo.redisClient.ZRem(ctx, getUserSpacesKey(subscriberId), spaceId.String()).Err(); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	if err := repo.redisClient.Del(ctx, spaceSubscribersKey, getSpaceActiveSubscribersKey(spaceId)).Err(); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// deleteSpaceHeldMessages removes the messages of the space that wait for review
+func (repo *RedisRepository) deleteSpaceHeldMessages(ctx context.Context, spaceId uuid.Uuid) error {
+	const op errors.Op = "redis_repo.RedisRepository.deleteSpaceHeldMessages"
+
+	heldMessageIdStrs, err := repo.redisClient.ZRange(ctx, getSpaceHeldMessagesKey(spaceId), 0, -1).Result()
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	for _, heldMessageIdStr := range heldMessageIdStrs {
+		heldMessageId, err := uuid.Parse(heldMessageIdStr)
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		if err := repo.DeleteHeldMessage(ctx, spaceId, heldMessageId); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	return nil
+}
+
+// deleteSpaceReports removes the reports of the space's threads and messages. The reports of the space itself are
+// left to the caller.
+func (repo *RedisRepository) deleteSpaceReports(ctx context.Context, spaceId uuid.Uuid) error {
+	const op errors.Op = "redis_repo.RedisRepository.deleteSpaceReports"
+
+	targetStrs, err := repo.redisClient.ZRange(ctx, getSpaceReportsKey(spaceId), 0, -1).Result()
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	for _, targetStr := range targetStrs {
+		var target models.ReportTarget
+		if err := target.ParseString(targetStr); err != nil {
+			return errors.E(op, err)
+		}
+
+		if err := repo.DeleteReport(ctx, target, spaceId); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	return nil
+}
+
+// ArchiveSpace marks the space as archived and removes it from the space coordinates, so that it can't be found by
+// location anymore. Its threads and messages are kept.
+func (repo *RedisRepository) ArchiveSpace(ctx context.Context, spaceId uuid.Uuid) error {
+	const op errors.Op = "redis_repo.RedisRepository.ArchiveSpace"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if err := hSetIfExistsScript.Run(ctx, repo.redisClient, []string{getSpaceKey(spaceId)}, spaceFields.archivedField, "1").Err(); err != nil {
+		return errors.E(op, err)
+	}
+
+	if err := repo.redisClient.ZRem(ctx, getSpaceCoordinatesKey(), spaceId.String()).Err(); err != nil {
+		return errors.E(op, err)
+	}
+
+	if err := repo.UpdateSpaceTileEntry(ctx, spaceId); err != nil {
+		return errors.E(op, err)
+	}
+
+	if err := repo.unindexSpaceTags(ctx, spaceId); err != nil {
+		return errors.E(op, err)
+	}
+
+	if err := repo.unindexSpaceName(ctx, spaceId); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// GetDueSpaceTransitions returns up to count scheduled transitions of time-bounded spaces whose time lies before until
+func (repo *RedisRepository) GetDueSpaceTransitions(ctx context.Context, until time.Time, count int64) ([]models.SpaceTransition, error) {
+	const op errors.Op = "redis_repo.RedisRepository.GetDueSpaceTransitions"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	members, err := repo.redisClient.ZRangeByScoreWithScores(ctx, getSpaceTransitionsKey(), &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(until.UnixMilli(), 10),
+		Count: count,
+	}).Result()
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	var transitions = make([]models.SpaceTransition, 0, len(members))
+	for _, member := range members {
+		spaceId, err := uuid.Parse(member.Member)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+
+		transitions = append(transitions, models.SpaceTransition{SpaceId: spaceId, At: time.UnixMilli(int64(member.Score))})
+	}
+
+	return transitions, nil
+}
+
+var moveSpaceTransitionScript = redis.NewScript(`
+local score = redis.call('ZSCORE', KEYS[1], ARGV[1])
+if not score or tonumber(score) ~= tonumber(ARGV[2]) then
+	return 0
+end
+
+if ARGV[3] == '' then
+	redis.call('ZREM', KEYS[1], ARGV[1])
+else
+	redis.call('ZADD', KEYS[1], ARGV[3], ARGV[1])
+end
+
+return 1
+`)
+
+// MoveSpaceTransition reschedules the transition of the space to next, or removes it if next is nil. It returns false
+// if the transition isn't scheduled at the given time anymore, e.g. because another server instance has already moved
+// it.
+func (repo *RedisRepository) MoveSpaceTransition(ctx context.Context, transition models.SpaceTransition, next *time.Time) (bool, error) {
+	const op errors.Op = "redis_repo.RedisRepository.MoveSpaceTransition"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	var nextStr string
+	if next != nil {
+		nextStr = strconv.FormatInt(next.UnixMilli(), 10)
+	}
+
+	moved, err := moveSpaceTransitionScript.Run(
+		ctx,
+		repo.redisClient,
+		[]string{getSpaceTransitionsKey()},
+		transition.SpaceId.String(),
+		strconv.FormatInt(transition.At.UnixMilli(), 10),
+		nextStr,
+	).Int()
+	if err != nil {
+		return false, errors.E(op, err)
+	}
+
+	return moved == 1, nil
+}
+
 func (repo *RedisRepository) HasSpaceThread(ctx context.Context, spaceId, threadId uuid.Uuid) (bool, error) {
 	const op errors.Op = "redis_repo.RedisRepository.HasSpaceThread"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
 
 	thread, err := repo.GetThread(ctx, threadId)
 	if err != nil {
@@ -238,6 +744,8 @@ func (repo *RedisRepository) HasSpaceThread(ctx context.Context, spaceId, thread
 
 func (repo *RedisRepository) SetSpaceSubscriber(ctx context.Context, spaceId uuid.Uuid, userUid models.UserUid) error {
 	const op errors.Op = "redis_repo.RedisRepository.SetSpaceSubscriber"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
 	var spaceSubscribersKey = getSpaceSubscribersKey(spaceId)
 	var userSpacesKey = getUserSpacesKey(userUid)
 	var score = float64(time.Now().UnixMilli())
@@ -261,6 +769,8 @@ func (repo *RedisRepository) SetSpaceSubscriber(ctx context.Context, spaceId uui
 
 func (repo *RedisRepository) DeleteSpaceSubscriber(ctx context.Context, spaceId uuid.Uuid, userUid models.UserUid) error {
 	const op errors.Op = "redis_repo.RedisRepository.DeleteSpaceSubscriber"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
 	var spaceSubscribersKey = getSpaceSubscribersKey(spaceId)
 	var userSpacesKey = getUserSpacesKey(userUid)
 
@@ -275,11 +785,32 @@ func (repo *RedisRepository) DeleteSpaceSubscriber(ctx context.Context, spaceId
 	return nil
 }
 
-func (repo *RedisRepository) SetSpaceSubscriberSession(ctx context.Context, spaceId uuid.Uuid, userUid models.UserUid, sessionId uuid.Uuid) error {
+func (repo *RedisRepository) SetSpaceSubscriberSession(ctx context.Context, spaceId uuid.Uuid, userUid models.UserUid, sessionId uuid.Uuid, instanceId string, ttl time.Duration) error {
 	const op errors.Op = "redis_repo.RedisRepository.SetSpaceSubscriberSession"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
 	var spaceActiveSubscribersKey = getSpaceActiveSubscribersKey(spaceId)
 	var spaceActiveSubscriberSessionsKey = getSpaceActiveSubscriberSessionsKey(spaceId, userUid)
-	var score = float64(time.Now().UnixMilli())
+	var sessionKey = getSessionKey(sessionId)
+	var sessionExpirationsKey = getSessionExpirationsKey()
+	var now = time.Now()
+	var score = float64(now.UnixMilli())
+
+	if err := repo.redisClient.HSet(ctx, sessionKey, map[string]any{
+		sessionFields.spaceIdField:    spaceId.String(),
+		sessionFields.userIdField:     string(userUid),
+		sessionFields.instanceIdField: instanceId,
+		sessionFields.createdAtField:  strconv.FormatInt(now.UnixMilli(), 10),
+	}).Err(); err != nil {
+		return errors.E(op, err)
+	}
+
+	if err := repo.redisClient.ZAdd(ctx, sessionExpirationsKey, redis.Z{
+		Score:  float64(now.Add(ttl).UnixMilli()),
+		Member: sessionId.String(),
+	}).Err(); err != nil {
+		return errors.E(op, err)
+	}
 
 	if err := repo.redisClient.ZAdd(ctx, spaceActiveSubscribersKey, redis.Z{
 		Score:  score,
@@ -298,49 +829,151 @@ func (repo *RedisRepository) SetSpaceSubscriberSession(ctx context.Context, spac
 	return nil
 }
 
-func (repo *RedisRepository) DeleteSpaceSubscriberSession(ctx context.Context, spaceId uuid.Uuid, userUid models.UserUid, sessionId uuid.Uuid) error {
+// RefreshSpaceSubscriberSession pushes the expiration of the session ttl into the future.
+// It returns common.ErrNotFound when the session has already been removed, e.g. by the session reaper.
+func (repo *RedisRepository) RefreshSpaceSubscriberSession(ctx context.Context, sessionId uuid.Uuid, ttl time.Duration) error {
+	const op errors.Op = "redis_repo.RedisRepository.RefreshSpaceSubscriberSession"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+	var sessionExpirationsKey = getSessionExpirationsKey()
+
+	// XX: only update existing members, never resurrect removed sessions
+	changed, err := repo.redisClient.ZAddArgs(ctx, sessionExpirationsKey, redis.ZAddArgs{
+		XX: true,
+		Ch: true,
+		Members: []redis.Z{{
+			Score:  float64(time.Now().Add(ttl).UnixMilli()),
+			Member: sessionId.String(),
+		}},
+	}).Result()
+	if err != nil {
+		return errors.E(op, err)
+	}
+	if changed > 0 {
+		return nil
+	}
+
+	// the score might not have changed when refreshing twice within the same millisecond
+	_, err = repo.redisClient.ZScore(ctx, sessionExpirationsKey, sessionId.String()).Result()
+	switch {
+	case errors.Is(err, redis.Nil):
+		return errors.E(op, common.ErrNotFound)
+	case err != nil:
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// GetExpiredSpaceSubscriberSessions returns up to count sessions whose expiration lies before until
+func (repo *RedisRepository) GetExpiredSpaceSubscriberSessions(ctx context.Context, until time.Time, count int64) ([]models.SpaceSubscriberSession, error) {
+	const op errors.Op = "redis_repo.RedisRepository.GetExpiredSpaceSubscriberSessions"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+	var sessionExpirationsKey = getSessionExpirationsKey()
+
+	sessionIdStrs, err := repo.redisClient.ZRangeByScore(ctx, sessionExpirationsKey, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(until.UnixMilli(), 10),
+		Count: count,
+	}).Result()
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	var sessions = make([]models.SpaceSubscriberSession, 0, len(sessionIdStrs))
+	for _, sessionIdStr := range sessionIdStrs {
+		sessionId, err := uuid.Parse(sessionIdStr)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+
+		sessionMap, err := repo.redisClient.HGetAll(ctx, getSessionKey(sessionId)).Result()
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+
+		// an empty session hash results in a session with a nil space id, which can still be deleted
+		spaceId, err := uuid.Parse(sessionMap[sessionFields.spaceIdField])
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+
+		sessions = append(sessions, models.SpaceSubscriberSession{
+			ID:         sessionId,
+			SpaceId:    spaceId,
+			UserId:     models.UserUid(sessionMap[sessionFields.userIdField]),
+			InstanceId: sessionMap[sessionFields.instanceIdField],
+		})
+	}
+
+	return sessions, nil
+}
+
+// DeleteSpaceSubscriberSession removes the session and removes the user from the space's active subscribers
+// if this was their last session. The returned bool reports whether the user was removed from the active subscribers.
+func (repo *RedisRepository) DeleteSpaceSubscriberSession(ctx context.Context, spaceId uuid.Uuid, userUid models.UserUid, sessionId uuid.Uuid) (bool, error) {
 	const op errors.Op = "redis_repo.RedisRepository.DeleteSpaceSubscriberSession"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
 	var spaceActiveSubscribersKey = getSpaceActiveSubscribersKey(spaceId)
 	var spaceActiveSubscriberSessionsKey = getSpaceActiveSubscriberSessionsKey(spaceId, userUid)
 
 	if err := repo.redisClient.ZRem(ctx, spaceActiveSubscriberSessionsKey, sessionId.String()).Err(); err != nil {
-		return errors.E(op, err)
+		return false, errors.E(op, err)
+	}
+
+	if err := repo.redisClient.ZRem(ctx, getSessionExpirationsKey(), sessionId.String()).Err(); err != nil {
+		return false, errors.E(op, err)
+	}
+
+	if err := repo.redisClient.Del(ctx, getSessionKey(sessionId)).Err(); err != nil {
+		return false, errors.E(op, err)
 	}
 
 	for i := 0; i < txRetries; i++ {
-		err := repo.redisClient.Watch(ctx, func(_ *redis.Tx) error {
-			sessionsCount, err := repo.redisClient.ZCard(ctx, spaceActiveSubscriberSessionsKey).Result()
+		var removed bool
+
+		// both keys share the {spaceid} hash tag, so this also works in cluster mode
+		err := repo.redisClient.Watch(ctx, func(tx *redis.Tx) error {
+			sessionsCount, err := tx.ZCard(ctx, spaceActiveSubscriberSessionsKey).Result()
 			if err != nil {
 				return err
 			}
 
-			tx := repo.redisClient.TxPipeline()
 			if sessionsCount > 0 {
-				tx.Discard()
-
 				return nil
 			}
 
-			tx.ZRem(ctx, spaceActiveSubscribersKey, string(userUid))
+			var zRemCmd *redis.IntCmd
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				zRemCmd = pipe.ZRem(ctx, spaceActiveSubscribersKey, string(userUid))
+				return nil
+			})
+			if err != nil {
+				return err
+			}
 
-			_, err = tx.Exec(ctx)
-			return err
+			removed = zRemCmd.Val() > 0
+			return nil
 		}, spaceActiveSubscriberSessionsKey)
 		switch err {
 		case redis.TxFailedErr:
 			continue
 		case nil:
-			return nil
+			return removed, nil
 		default:
-			return errors.E(op, err)
+			return false, errors.E(op, err)
 		}
 	}
 
-	return nil
+	return false, nil
 }
 
 func (repo *RedisRepository) HasSpaceSubscriber(ctx context.Context, spaceId uuid.Uuid, userUid models.UserUid) (bool, error) {
 	const op errors.Op = "redis_repo.RedisRepository.HasSpaceSubscriber"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
 	var spaceSubscribersKey = getSpaceSubscribersKey(spaceId)
 
 	_, err := repo.redisClient.ZScore(ctx, spaceSubscribersKey, string(userUid)).Result()
@@ -354,8 +987,41 @@ func (repo *RedisRepository) HasSpaceSubscriber(ctx context.Context, spaceId uui
 	return true, nil
 }
 
+func (repo *RedisRepository) SetSpaceBannedUser(ctx context.Context, spaceId uuid.Uuid, userUid models.UserUid) error {
+	const op errors.Op = "redis_repo.RedisRepository.SetSpaceBannedUser"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if err := repo.redisClient.ZAdd(ctx, getSpaceBannedUsersKey(spaceId), redis.Z{
+		Score:  float64(time.Now().UnixMilli()),
+		Member: string(userUid),
+	}).Err(); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+func (repo *RedisRepository) IsSpaceBannedUser(ctx context.Context, spaceId uuid.Uuid, userUid models.UserUid) (bool, error) {
+	const op errors.Op = "redis_repo.RedisRepository.IsSpaceBannedUser"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	_, err := repo.redisClient.ZScore(ctx, getSpaceBannedUsersKey(spaceId), string(userUid)).Result()
+	switch {
+	case errors.Is(err, redis.Nil):
+		return false, nil
+	case err != nil:
+		return false, errors.E(op, err)
+	}
+
+	return true, nil
+}
+
 func (repo *RedisRepository) getSpaceSubscribers(ctx context.Context, collectionKey string, offset, count int64) ([]models.User, error) {
 	const op errors.Op = "redis_repo.RedisRepository.getSpaceSubscribers"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
 
 	userIdStrs, err := repo.redisClient.ZRevRangeByScore(ctx, collectionKey, &redis.ZRangeBy{
 		Max:    "+inf",
@@ -392,6 +1058,8 @@ func (repo *RedisRepository) getSpaceSubscribers(ctx context.Context, collection
 
 func (repo *RedisRepository) getSpaceTopLevelThreads(ctx context.Context, collectionKey string, offset, count int64) ([]models.TopLevelThread, error) {
 	const op errors.Op = "redis_repo.RedisRepository.getSpaceTopLevelThreads"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
 
 	threadMaps, topLevelThreadIds, err := getCollectionValues(ctx, repo, collectionKey, offset, count, getThreadKey)
 	if err != nil {
@@ -441,6 +1109,8 @@ func (repo *RedisRepository) parseSpace(spaceMap map[string]string) (*models.Spa
 	createdAtStr := spaceMap[spaceFields.createdAtField]
 	adminIdStr := spaceMap[spaceFields.adminIdField]
 	locationStr := spaceMap[spaceFields.locationField]
+	hidden := spaceMap[spaceFields.hiddenField] == "1"
+	archived := spaceMap[spaceFields.archivedField] == "1"
 
 	var location models.Location
 	if err := location.ParseString(locationStr); err != nil {
@@ -455,16 +1125,58 @@ func (repo *RedisRepository) parseSpace(spaceMap map[string]string) (*models.Spa
 		return nil, errors.E(op, err)
 	}
 	adminId := models.UserUid(adminIdStr)
+	startsAt, err := parseOptionalTime(spaceMap, spaceFields.startsAtField)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+	endsAt, err := parseOptionalTime(spaceMap, spaceFields.endsAtField)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+	var retentionHours int
+	if retentionStr, ok := spaceMap[spaceFields.retentionField]; ok {
+		if retentionHours, err = strconv.Atoi(retentionStr); err != nil {
+			return nil, errors.E(op, err)
+		}
+	}
+	var tags []string
+	if tagsStr, ok := spaceMap[spaceFields.tagsField]; ok {
+		tags = strings.Split(tagsStr, ",")
+	}
 
 	return &models.Space{
 		ID:        uuid.Nil,
 		CreatedAt: createdAt,
 		AdminId:   adminId,
+		Hidden:    hidden,
+		Archived:  archived,
 		BaseSpace: models.BaseSpace{
-			Name:               name,
-			ThemeColorHexaCode: themeColor,
-			Radius:             radius,
-			Location:           location,
+			Name:                  name,
+			ThemeColorHexaCode:    themeColor,
+			Radius:                radius,
+			Location:              location,
+			StartsAt:              startsAt,
+			EndsAt:                endsAt,
+			MessageRetentionHours: retentionHours,
+			Category:              models.SpaceCategory(spaceMap[spaceFields.categoryField]),
+			Tags:                  tags,
 		},
 	}, nil
 }
+
+// parseOptionalTime parses the unix milliseconds of the field, it returns nil if the field is not set
+func parseOptionalTime(hashMap map[string]string, field string) (*time.Time, error) {
+	const op errors.Op = "redis_repo.parseOptionalTime"
+
+	str, ok := hashMap[field]
+	if !ok {
+		return nil, nil
+	}
+
+	t, err := utils.StringToTime(str)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return &t, nil
+}
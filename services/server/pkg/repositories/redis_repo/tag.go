@@ -0,0 +1,132 @@
+package redis_repo
+
+import (
+	"context"
+	"sort"
+	"spaces-p/pkg/errors"
+	"spaces-p/pkg/models"
+	"spaces-p/pkg/tracing"
+	"spaces-p/pkg/uuid"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// the maximum number of tags matching a prefix that are ranked by their spaces count
+const maxTagSuggestionCandidates = 100
+
+// GetTagSuggestions returns up to count tags starting with prefix, the tags of the most spaces first
+func (repo *RedisRepository) GetTagSuggestions(ctx context.Context, prefix string, count int) ([]models.TagSuggestion, error) {
+	const op errors.Op = "redis_repo.RedisRepository.GetTagSuggestions"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	// tags only consist of ascii characters, so every tag starting with prefix sorts before prefix followed by 0xff
+	tags, err := repo.redisClient.ZRangeByLex(ctx, getSpaceTagsKey(), &redis.ZRangeBy{
+		Min:   "[" + prefix,
+		Max:   "[" + prefix + "\xff",
+		Count: maxTagSuggestionCandidates,
+	}).Result()
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	var sCardCmds = make([]*redis.IntCmd, len(tags))
+	pipe := repo.redisClient.Pipeline()
+	for i, tag := range tags {
+		sCardCmds[i] = pipe.SCard(ctx, getTagSpacesKey(tag))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	var tagSuggestions = make([]models.TagSuggestion, 0, len(tags))
+	for i, tag := range tags {
+		// the tag might not be carried by any space anymore
+		if spacesCount := sCardCmds[i].Val(); spacesCount > 0 {
+			tagSuggestions = append(tagSuggestions, models.TagSuggestion{Tag: tag, SpacesCount: spacesCount})
+		}
+	}
+
+	// tags with the same spaces count stay in alphabetical order
+	sort.SliceStable(tagSuggestions, func(i, j int) bool {
+		return tagSuggestions[i].SpacesCount > tagSuggestions[j].SpacesCount
+	})
+
+	return tagSuggestions[:min(count, len(tagSuggestions))], nil
+}
+
+// indexSpaceTags adds the space to the indexes of its tags
+func (repo *RedisRepository) indexSpaceTags(ctx context.Context, spaceId uuid.Uuid, tags []string) error {
+	const op errors.Op = "redis_repo.RedisRepository.indexSpaceTags"
+
+	for _, tag := range tags {
+		if err := repo.redisClient.SAdd(ctx, getTagSpacesKey(tag), spaceId.String()).Err(); err != nil {
+			return errors.E(op, err)
+		}
+
+		if err := repo.redisClient.ZAdd(ctx, getSpaceTagsKey(), redis.Z{Score: 0, Member: tag}).Err(); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	return nil
+}
+
+// unindexSpaceTags removes the space from the indexes of the tags stored in its hash
+func (repo *RedisRepository) unindexSpaceTags(ctx context.Context, spaceId uuid.Uuid) error {
+	const op errors.Op = "redis_repo.RedisRepository.unindexSpaceTags"
+
+	tagsStr, err := repo.redisClient.HGet(ctx, getSpaceKey(spaceId), spaceFields.tagsField).Result()
+	switch {
+	case errors.Is(err, redis.Nil):
+		return nil
+	case err != nil:
+		return errors.E(op, err)
+	}
+
+	for _, tag := range strings.Split(tagsStr, ",") {
+		var tagSpacesKey = getTagSpacesKey(tag)
+		if err := repo.redisClient.SRem(ctx, tagSpacesKey, spaceId.String()).Err(); err != nil {
+			return errors.E(op, err)
+		}
+
+		if err := repo.unindexUnusedTag(ctx, tag); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	return nil
+}
+
+// unindexUnusedTag removes the tag from the tags looked up by prefix if no space carries it anymore
+func (repo *RedisRepository) unindexUnusedTag(ctx context.Context, tag string) error {
+	const op errors.Op = "redis_repo.RedisRepository.unindexUnusedTag"
+	var tagSpacesKey = getTagSpacesKey(tag)
+	var spaceTagsKey = getSpaceTagsKey()
+
+	spacesCount, err := repo.redisClient.SCard(ctx, tagSpacesKey).Result()
+	switch {
+	case err != nil:
+		return errors.E(op, err)
+	case spacesCount > 0:
+		return nil
+	}
+
+	if err := repo.redisClient.ZRem(ctx, spaceTagsKey, tag).Err(); err != nil {
+		return errors.E(op, err)
+	}
+
+	// a space might have been tagged with it in the meantime, both keys can't be changed atomically in cluster mode
+	spacesCount, err = repo.redisClient.SCard(ctx, tagSpacesKey).Result()
+	switch {
+	case err != nil:
+		return errors.E(op, err)
+	case spacesCount > 0:
+		if err := repo.redisClient.ZAdd(ctx, spaceTagsKey, redis.Z{Score: 0, Member: tag}).Err(); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	return nil
+}
@@ -2,9 +2,11 @@ package redis_repo
 
 import (
 	"context"
+	"fmt"
 	"spaces-p/pkg/common"
 	"spaces-p/pkg/errors"
 	"spaces-p/pkg/models"
+	"spaces-p/pkg/tracing"
 	"spaces-p/pkg/utils"
 	"spaces-p/pkg/uuid"
 	"strconv"
@@ -15,6 +17,8 @@ import (
 
 func (repo *RedisRepository) GetThread(ctx context.Context, threadId uuid.Uuid) (*models.Thread, error) {
 	const op errors.Op = "redis_repo.RedisRepository.GetThread"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
 	var threadKey = getThreadKey(threadId)
 
 	r, err := repo.redisClient.HGetAll(ctx, threadKey).Result()
@@ -47,8 +51,49 @@ func (repo *RedisRepository) GetThread(ctx context.Context, threadId uuid.Uuid)
 	}, nil
 }
 
+func (repo *RedisRepository) GetTopLevelThread(ctx context.Context, threadId uuid.Uuid) (*models.TopLevelThread, error) {
+	const op errors.Op = "redis_repo.RedisRepository.GetTopLevelThread"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+	var threadKey = getThreadKey(threadId)
+
+	threadMap, err := repo.redisClient.HGetAll(ctx, threadKey).Result()
+	switch {
+	case err != nil:
+		return nil, errors.E(op, err)
+	case len(threadMap) == 0:
+		return nil, errors.E(op, common.ErrNotFound)
+	case threadMap[threadFields.firstMessageIdField] == "":
+		err := errors.New(fmt.Sprintf("thread with id %s is not a toplevel thread", threadId.String()))
+		return nil, errors.E(op, err)
+	}
+
+	baseThread, err := repo.parseBaseThread(threadMap)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+	baseThread.ID = threadId
+
+	firstMessageId, err := uuid.Parse(threadMap[threadFields.firstMessageIdField])
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	firstMessage, err := repo.GetMessage(ctx, firstMessageId)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return &models.TopLevelThread{
+		BaseThread:   *baseThread,
+		FirstMessage: firstMessage.Message,
+	}, nil
+}
+
 func (repo *RedisRepository) GetThreadMessagesByTime(ctx context.Context, threadId uuid.Uuid, offset, count int64) ([]models.MessageWithChildThreadMessagesCount, error) {
 	const op errors.Op = "redis_repo.RedisRepository.GetSpaceTopLevelThreadsByTime"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
 	var threadMessagesByTimeKey = getThreadMessagesByTimeKey(threadId)
 
 	messages, err := repo.getThreadMessages(ctx, threadMessagesByTimeKey, offset, count)
@@ -61,6 +106,8 @@ func (repo *RedisRepository) GetThreadMessagesByTime(ctx context.Context, thread
 
 func (repo *RedisRepository) GetThreadMessagesByPopularity(ctx context.Context, threadId uuid.Uuid, offset, count int64) ([]models.MessageWithChildThreadMessagesCount, error) {
 	const op errors.Op = "redis_repo.RedisRepository.GetThreadMessagesByPopularity"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
 	var threadMessagesByPopularityKey = getThreadMessagesByPopularityKey(threadId)
 
 	messages, err := repo.getThreadMessages(ctx, threadMessagesByPopularityKey, offset, count)
@@ -74,6 +121,8 @@ func (repo *RedisRepository) GetThreadMessagesByPopularity(ctx context.Context,
 // set parent's message child_thread_id field, set thread
 func (repo *RedisRepository) SetThread(ctx context.Context, spaceId, parentMessageId uuid.Uuid, createdAt time.Time) (*models.Thread, error) {
 	const op errors.Op = "redis_repo.RedisRepository.SetThread"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
 	var threadId = uuid.New()
 	var threadKey = getThreadKey(threadId)
 	var parentMessageKey = getMessageKey(parentMessageId)
@@ -110,6 +159,8 @@ func (repo *RedisRepository) SetThread(ctx context.Context, spaceId, parentMessa
 // add new thread to space toplevel sets, set first message
 func (repo *RedisRepository) SetTopLevelThread(ctx context.Context, spaceId uuid.Uuid, newMessage models.NewTopLevelThreadFirstMessage) (*models.TopLevelThread, *models.Message, error) {
 	const op errors.Op = "redis_repo.RedisRepository.SetTopLevelThread"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
 	var threadId = uuid.New()
 	var createdAt = time.Now()
 
@@ -125,22 +176,27 @@ func (repo *RedisRepository) SetTopLevelThread(ctx context.Context, spaceId uuid
 
 	var createdTopLevelThread = &models.TopLevelThread{
 		BaseThread: models.BaseThread{
-			ID:        threadId,
-			SpaceId:   spaceId,
-			CreatedAt: createdAt,
+			ID:           threadId,
+			SpaceId:      spaceId,
+			CreatedAt:    createdAt,
+			Announcement: newMessage.Announcement,
 		},
 		FirstMessage: *createdFirstMessage,
 	}
 	// set thread hash
 	var threadKey = getThreadKey(threadId)
-	if err := repo.redisClient.HSet(ctx, threadKey, map[string]any{
+	var threadHash = map[string]any{
 		threadFields.firstMessageIdField:  createdFirstMessage.ID.String(),
 		threadFields.likesField:           "0",
 		threadFields.messagesCountField:   "0",
 		threadFields.parentMessageIdField: "",
 		threadFields.createdAtField:       strconv.FormatInt(createdAt.UnixMilli(), 10),
 		threadFields.spaceIdField:         spaceId.String(),
-	}).Err(); err != nil {
+	}
+	if newMessage.Announcement {
+		threadHash[threadFields.announcementField] = "1"
+	}
+	if err := repo.redisClient.HSet(ctx, threadKey, threadHash).Err(); err != nil {
 		return nil, nil, errors.E(op, err)
 	}
 
@@ -161,11 +217,83 @@ func (repo *RedisRepository) SetTopLevelThread(ctx context.Context, spaceId uuid
 		return nil, nil, errors.E(op, err)
 	}
 
+	if _, ok := createdFirstMessage.Payload.(*models.LocationPin); ok {
+		if err := repo.addLocationPin(ctx, spaceId, createdFirstMessage); err != nil {
+			return nil, nil, errors.E(op, err)
+		}
+	}
+
 	return createdTopLevelThread, createdFirstMessage, nil
 }
 
+// DeleteTopLevelThread removes the toplevel thread along with all its messages and their child threads
+func (repo *RedisRepository) DeleteTopLevelThread(ctx context.Context, spaceId, threadId uuid.Uuid) error {
+	const op errors.Op = "redis_repo.RedisRepository.DeleteTopLevelThread"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if _, err := repo.deleteTopLevelThread(ctx, spaceId, threadId); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// deleteTopLevelThread removes the toplevel thread along with all its messages and their child threads. The thread's
+// entry in the space's toplevel threads by time goes last, so that the thread can still be found and deleted again if
+// it fails halfway. It returns false if that entry had been removed before, e.g. concurrently by another server
+// instance.
+func (repo *RedisRepository) deleteTopLevelThread(ctx context.Context, spaceId, threadId uuid.Uuid) (bool, error) {
+	const op errors.Op = "redis_repo.RedisRepository.deleteTopLevelThread"
+	var threadKey = getThreadKey(threadId)
+
+	firstMessageIdStr, err := repo.redisClient.HGet(ctx, threadKey, threadFields.firstMessageIdField).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return false, errors.E(op, err)
+	}
+
+	if err := repo.deleteThreadMessages(ctx, threadId); err != nil {
+		return false, errors.E(op, err)
+	}
+
+	if firstMessageId, err := uuid.Parse(firstMessageIdStr); err == nil {
+		if err := repo.deleteChildThread(ctx, firstMessageId); err != nil {
+			return false, errors.E(op, err)
+		}
+
+		if err := repo.deleteMessageData(ctx, threadId, firstMessageId); err != nil {
+			return false, errors.E(op, err)
+		}
+	}
+
+	if err := repo.redisClient.ZRem(ctx, getSpaceToplevelThreadsByPopularityKey(spaceId), threadId.String()).Err(); err != nil {
+		return false, errors.E(op, err)
+	}
+
+	if err := repo.redisClient.ZRem(ctx, getSpacePinsKey(spaceId), getPinMember(models.ThreadPinTarget, threadId)).Err(); err != nil {
+		return false, errors.E(op, err)
+	}
+
+	if err := repo.deleteThreadReadMarkers(ctx, spaceId, threadId); err != nil {
+		return false, errors.E(op, err)
+	}
+
+	if err := repo.redisClient.Del(ctx, threadKey, getThreadMessagesByTimeKey(threadId), getThreadMessagesByPopularityKey(threadId), getThreadFollowersKey(threadId)).Err(); err != nil {
+		return false, errors.E(op, err)
+	}
+
+	removed, err := repo.redisClient.ZRem(ctx, getSpaceToplevelThreadsByTimeKey(spaceId), threadId.String()).Result()
+	if err != nil {
+		return false, errors.E(op, err)
+	}
+
+	return removed > 0, nil
+}
+
 func (repo *RedisRepository) HasThreadMessage(ctx context.Context, threadId, messageId uuid.Uuid) (bool, error) {
 	const op errors.Op = "redis_repo.RedisRepository.HasThreadMessage"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
 
 	message, err := repo.GetMessage(ctx, messageId)
 	if err != nil {
@@ -177,6 +305,8 @@ func (repo *RedisRepository) HasThreadMessage(ctx context.Context, threadId, mes
 
 func (repo *RedisRepository) IncrementTopLevelThreadLikesBy(ctx context.Context, spaceId, threadId uuid.Uuid, increment int64) error {
 	const op errors.Op = "redis_repo.RedisRepository.IncrementThreadLikesBy"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
 	var threadKey = getThreadKey(threadId)
 	var spaceToplevelThreadsByPopularityKey = getSpaceToplevelThreadsByPopularityKey(spaceId)
 
@@ -193,6 +323,8 @@ func (repo *RedisRepository) IncrementTopLevelThreadLikesBy(ctx context.Context,
 
 func (repo *RedisRepository) IncrementThreadLikesBy(ctx context.Context, threadId uuid.Uuid, increment int64) error {
 	const op errors.Op = "redis_repo.RedisRepository.IncrementThreadLikesBy"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
 	var threadKey = getThreadKey(threadId)
 
 	if err := repo.redisClient.HIncrBy(ctx, threadKey, threadFields.likesField, increment).Err(); err != nil {
@@ -204,6 +336,8 @@ func (repo *RedisRepository) IncrementThreadLikesBy(ctx context.Context, threadI
 
 func (repo *RedisRepository) getThreadMessages(ctx context.Context, collectionKey string, offset, count int64) ([]models.MessageWithChildThreadMessagesCount, error) {
 	const op errors.Op = "redis_repo.RedisRepository.getThreadMessages"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
 
 	messageMaps, messageIds, err := getCollectionValues(ctx, repo, collectionKey, offset, count, getMessageKey)
 	if err != nil {
@@ -212,6 +346,10 @@ func (repo *RedisRepository) getThreadMessages(ctx context.Context, collectionKe
 
 	var messages = make([]models.MessageWithChildThreadMessagesCount, 0, len(messageMaps))
 	for i, messageMap := range messageMaps {
+		// collections that are not cleaned up along with their messages (e.g. inboxes) might reference deleted ones
+		if len(messageMap) == 0 {
+			continue
+		}
 
 		childThreadIdStr := messageMap[messageFields.childThreadIdField]
 		var childThreadId uuid.Uuid
@@ -236,6 +374,9 @@ func (repo *RedisRepository) getThreadMessages(ctx context.Context, collectionKe
 		threadIdStr := messageMap[messageFields.threadIdField]
 		createdAtMilliStr := messageMap[messageFields.createdAtField]
 		messageTypeStr := messageMap[messageFields.typeField]
+		hidden := messageMap[messageFields.hiddenField] == "1"
+		attachmentIdStr := messageMap[messageFields.attachmentIdField]
+		payloadStr := messageMap[messageFields.payloadField]
 
 		likes, err := strconv.Atoi(likesStr)
 		if err != nil {
@@ -257,6 +398,20 @@ func (repo *RedisRepository) getThreadMessages(ctx context.Context, collectionKe
 			return nil, errors.E(op, err)
 		}
 
+		attachmentId, err := uuid.Parse(attachmentIdStr)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+
+		payload, err := parseMessagePayload(messageType, payloadStr)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+
+		if err := repo.addPollResults(ctx, messageIds[i], payload); err != nil {
+			return nil, errors.E(op, err)
+		}
+
 		messages = append(messages, models.MessageWithChildThreadMessagesCount{
 			ChildThreadMessagesCount: childThreadMessagesCount,
 			Message: models.Message{
@@ -264,10 +419,13 @@ func (repo *RedisRepository) getThreadMessages(ctx context.Context, collectionKe
 				CreatedAt:     createdAt,
 				ChildThreadId: childThreadId,
 				Likes:         likes,
+				Hidden:        hidden,
 				NewMessage: models.NewMessage{
 					BaseMessage: models.BaseMessage{
-						Content: content,
-						Type:    messageType,
+						Content:      content,
+						Type:         messageType,
+						AttachmentId: attachmentId,
+						Payload:      payload,
 					},
 					ThreadId: threadId,
 					SenderId: models.UserUid(senderId),
@@ -286,6 +444,8 @@ func (repo *RedisRepository) parseBaseThread(threadMap map[string]string) (*mode
 	messagesCountStr := threadMap[threadFields.messagesCountField]
 	spaceIdStr := threadMap[threadFields.spaceIdField]
 	createdAtStr := threadMap[threadFields.createdAtField]
+	hidden := threadMap[threadFields.hiddenField] == "1"
+	announcement := threadMap[threadFields.announcementField] == "1"
 
 	likes, err := strconv.Atoi(likesStr)
 	if err != nil {
@@ -310,5 +470,7 @@ func (repo *RedisRepository) parseBaseThread(threadMap map[string]string) (*mode
 		Likes:         likes,
 		MessagesCount: messagesCount,
 		CreatedAt:     createdAt,
+		Hidden:        hidden,
+		Announcement:  announcement,
 	}, nil
 }
@@ -0,0 +1,113 @@
+package redis_repo
+
+import (
+	"context"
+	"spaces-p/pkg/errors"
+	"spaces-p/pkg/models"
+	"spaces-p/pkg/tracing"
+	"spaces-p/pkg/uuid"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// the geohash precision of the entries of the space geohashes, which is the highest precision of the tiles
+const spaceGeoHashPrecision = 9
+
+// the fields of a space that decide whether and where it is shown on the map
+var spaceTileFields = []string{spaceFields.locationField, spaceFields.hiddenField, spaceFields.archivedField, spaceFields.startsAtField}
+
+// GetTileSpacesCounts returns the number of spaces shown on the map in each of the geohash cells
+func (repo *RedisRepository) GetTileSpacesCounts(ctx context.Context, geoHashes []string) ([]int64, error) {
+	const op errors.Op = "redis_repo.RedisRepository.GetTileSpacesCounts"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	var zLexCountCmds = make([]*redis.IntCmd, len(geoHashes))
+	pipe := repo.redisClient.Pipeline()
+	for i, geoHash := range geoHashes {
+		// geohashes only consist of ascii characters, so every entry of the cell sorts before its geohash followed by
+		// 0xff
+		zLexCountCmds[i] = pipe.ZLexCount(ctx, getSpaceGeoHashesKey(), "["+geoHash, "["+geoHash+"\xff")
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	var counts = make([]int64, len(geoHashes))
+	for i, cmd := range zLexCountCmds {
+		counts[i] = cmd.Val()
+	}
+
+	return counts, nil
+}
+
+// UpdateSpaceTileEntry adds the space to the space geohashes if it is shown on the map and removes it otherwise. It has
+// to be called whenever a space starts, is hidden, shown again or archived.
+func (repo *RedisRepository) UpdateSpaceTileEntry(ctx context.Context, spaceId uuid.Uuid) error {
+	const op errors.Op = "redis_repo.RedisRepository.UpdateSpaceTileEntry"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	spaceMap, err := repo.redisClient.HMGet(ctx, getSpaceKey(spaceId), spaceTileFields...).Result()
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	member, shown, ok := getSpaceTileEntry(spaceId, spaceMap, time.Now())
+	switch {
+	case !ok:
+		return nil
+	case shown:
+		err = repo.redisClient.ZAdd(ctx, getSpaceGeoHashesKey(), redis.Z{Score: 0, Member: member}).Err()
+	default:
+		err = repo.redisClient.ZRem(ctx, getSpaceGeoHashesKey(), member).Err()
+	}
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// deleteSpaceTileEntry removes the space from the space geohashes, the space must still exist
+func (repo *RedisRepository) deleteSpaceTileEntry(ctx context.Context, spaceId uuid.Uuid) error {
+	const op errors.Op = "redis_repo.RedisRepository.deleteSpaceTileEntry"
+
+	spaceMap, err := repo.redisClient.HMGet(ctx, getSpaceKey(spaceId), spaceTileFields...).Result()
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	member, _, ok := getSpaceTileEntry(spaceId, spaceMap, time.Now())
+	if !ok {
+		return nil
+	}
+
+	if err := repo.redisClient.ZRem(ctx, getSpaceGeoHashesKey(), member).Err(); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// getSpaceTileEntry returns the entry of the space in the space geohashes and whether the space is shown on the map,
+// given the values of the spaceTileFields. It returns false as the last value if the space does not exist.
+func getSpaceTileEntry(spaceId uuid.Uuid, spaceMap []any, now time.Time) (string, bool, bool) {
+	var location models.Location
+	locationStr, ok := spaceMap[0].(string)
+	if !ok || location.ParseString(locationStr) != nil {
+		return "", false, false
+	}
+
+	var started = true
+	if startsAtStr, ok := spaceMap[3].(string); ok {
+		startsAt, err := strconv.ParseInt(startsAtStr, 10, 64)
+		started = err != nil || startsAt <= now.UnixMilli()
+	}
+
+	shown := spaceMap[1] != "1" && spaceMap[2] != "1" && started
+
+	return location.GeoHash(spaceGeoHashPrecision) + ":" + spaceId.String(), shown, true
+}
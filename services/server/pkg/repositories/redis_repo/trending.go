@@ -0,0 +1,69 @@
+package redis_repo
+
+import (
+	"context"
+	"spaces-p/pkg/errors"
+	"spaces-p/pkg/models"
+	"spaces-p/pkg/tracing"
+	"spaces-p/pkg/uuid"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// AddSpaceRecentMessage records a new message of the space and drops the messages that have been sent before the
+// window
+func (repo *RedisRepository) AddSpaceRecentMessage(ctx context.Context, spaceId, messageId uuid.Uuid, createdAt time.Time, window time.Duration) error {
+	const op errors.Op = "redis_repo.RedisRepository.AddSpaceRecentMessage"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+	var spaceRecentMessagesKey = getSpaceRecentMessagesKey(spaceId)
+
+	pipe := repo.redisClient.TxPipeline()
+	pipe.ZAdd(ctx, spaceRecentMessagesKey, redis.Z{
+		Score:  float64(createdAt.UnixMilli()),
+		Member: messageId.String(),
+	})
+	pipe.ZRemRangeByScore(ctx, spaceRecentMessagesKey, "-inf", "("+strconv.FormatInt(createdAt.Add(-window).UnixMilli(), 10))
+	pipe.PExpire(ctx, spaceRecentMessagesKey, window)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// GetSpacesActivity returns the activity of every space in the order of the space ids: the messages sent since
+// messagesSince, the active subscribers and the subscribers who joined since subscribersSince
+func (repo *RedisRepository) GetSpacesActivity(ctx context.Context, spaceIds []uuid.Uuid, messagesSince, subscribersSince time.Time) ([]models.SpaceActivity, error) {
+	const op errors.Op = "redis_repo.RedisRepository.GetSpacesActivity"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	var messagesMin = strconv.FormatInt(messagesSince.UnixMilli(), 10)
+	var subscribersMin = strconv.FormatInt(subscribersSince.UnixMilli(), 10)
+	var recentMessagesCmds = make([]*redis.IntCmd, len(spaceIds))
+	var activeSubscribersCmds = make([]*redis.IntCmd, len(spaceIds))
+	var newSubscribersCmds = make([]*redis.IntCmd, len(spaceIds))
+	pipe := repo.redisClient.Pipeline()
+	for i, spaceId := range spaceIds {
+		recentMessagesCmds[i] = pipe.ZCount(ctx, getSpaceRecentMessagesKey(spaceId), messagesMin, "+inf")
+		activeSubscribersCmds[i] = pipe.ZCard(ctx, getSpaceActiveSubscribersKey(spaceId))
+		newSubscribersCmds[i] = pipe.ZCount(ctx, getSpaceSubscribersKey(spaceId), subscribersMin, "+inf")
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	var activities = make([]models.SpaceActivity, len(spaceIds))
+	for i := range spaceIds {
+		activities[i] = models.SpaceActivity{
+			RecentMessagesCount:    recentMessagesCmds[i].Val(),
+			ActiveSubscribersCount: activeSubscribersCmds[i].Val(),
+			NewSubscribersCount:    newSubscribersCmds[i].Val(),
+		}
+	}
+
+	return activities, nil
+}
@@ -5,10 +5,17 @@ import (
 	"spaces-p/pkg/common"
 	"spaces-p/pkg/errors"
 	"spaces-p/pkg/models"
+	"spaces-p/pkg/tracing"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
 )
 
 func (repo *RedisRepository) GetUserById(ctx context.Context, id models.UserUid) (*models.User, error) {
 	const op errors.Op = "redis_repo.RedisRepository.GetUserById"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
 	var userKey = getUserKey(id)
 
 	r, err := repo.redisClient.HGetAll(ctx, userKey).Result()
@@ -24,7 +31,15 @@ func (repo *RedisRepository) GetUserById(ctx context.Context, id models.UserUid)
 
 func (repo *RedisRepository) SetUser(ctx context.Context, newUser models.NewUser) error {
 	const op errors.Op = "redis_repo.RedisRepository.SetUser"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
 	var userKey = getUserKey(newUser.ID)
+	var usernamesKey = getUsernamesKey()
+
+	oldUsername, err := repo.redisClient.HGet(ctx, userKey, userFields.userUsernameField).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return errors.E(op, err)
+	}
 
 	v := map[string]interface{}{
 		userFields.userFirstNameField: newUser.FirstName,
@@ -37,6 +52,209 @@ func (repo *RedisRepository) SetUser(ctx context.Context, newUser models.NewUser
 		return errors.E(op, err)
 	}
 
+	var oldUsernameMember = getUsernameMember(newUser.ID, oldUsername)
+	var usernameMember = getUsernameMember(newUser.ID, newUser.Username)
+	if oldUsernameMember == usernameMember {
+		return nil
+	}
+
+	if oldUsernameMember != "" {
+		if err := repo.redisClient.ZRem(ctx, usernamesKey, oldUsernameMember).Err(); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	if usernameMember != "" {
+		if err := repo.redisClient.ZAdd(ctx, usernamesKey, redis.Z{Score: 0, Member: usernameMember}).Err(); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	return nil
+}
+
+// GetUserIdsByUsername returns the ids of the users whose username is username, ignoring the case
+func (repo *RedisRepository) GetUserIdsByUsername(ctx context.Context, username string) ([]models.UserUid, error) {
+	const op errors.Op = "redis_repo.RedisRepository.GetUserIdsByUsername"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+	var prefix = strings.ToLower(username) + ":"
+
+	members, err := repo.redisClient.ZRangeByLex(ctx, getUsernamesKey(), &redis.ZRangeBy{
+		Min: "[" + prefix,
+		Max: "[" + prefix + "\xff",
+	}).Result()
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	var userIds = make([]models.UserUid, 0, len(members))
+	for _, member := range members {
+		// the usernames of other users may start with the prefix as well, e.g. "alice:b"
+		i := strings.LastIndex(member, ":")
+		if member[:i+1] != prefix {
+			continue
+		}
+
+		userIds = append(userIds, models.UserUid(member[i+1:]))
+	}
+
+	return userIds, nil
+}
+
+// SetUserBanned bans the user from all spaces
+func (repo *RedisRepository) SetUserBanned(ctx context.Context, userId models.UserUid) error {
+	const op errors.Op = "redis_repo.RedisRepository.SetUserBanned"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if err := hSetIfExistsScript.Run(ctx, repo.redisClient, []string{getUserKey(userId)}, userFields.userIsBannedField, "1").Err(); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+func (repo *RedisRepository) SetUserBlockedUser(ctx context.Context, userId, blockedUserId models.UserUid) error {
+	const op errors.Op = "redis_repo.RedisRepository.SetUserBlockedUser"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if err := repo.setUserRelation(ctx, getUserBlockedUsersKey(userId), blockedUserId); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+func (repo *RedisRepository) DeleteUserBlockedUser(ctx context.Context, userId, blockedUserId models.UserUid) error {
+	const op errors.Op = "redis_repo.RedisRepository.DeleteUserBlockedUser"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if err := repo.redisClient.ZRem(ctx, getUserBlockedUsersKey(userId), string(blockedUserId)).Err(); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// HasUserBlockedUser reports whether the user has blocked the other user
+func (repo *RedisRepository) HasUserBlockedUser(ctx context.Context, userId, otherUserId models.UserUid) (bool, error) {
+	const op errors.Op = "redis_repo.RedisRepository.HasUserBlockedUser"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	_, err := repo.redisClient.ZScore(ctx, getUserBlockedUsersKey(userId), string(otherUserId)).Result()
+	switch {
+	case errors.Is(err, redis.Nil):
+		return false, nil
+	case err != nil:
+		return false, errors.E(op, err)
+	}
+
+	return true, nil
+}
+
+func (repo *RedisRepository) SetUserMutedUser(ctx context.Context, userId, mutedUserId models.UserUid) error {
+	const op errors.Op = "redis_repo.RedisRepository.SetUserMutedUser"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if err := repo.setUserRelation(ctx, getUserMutedUsersKey(userId), mutedUserId); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+func (repo *RedisRepository) DeleteUserMutedUser(ctx context.Context, userId, mutedUserId models.UserUid) error {
+	const op errors.Op = "redis_repo.RedisRepository.DeleteUserMutedUser"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if err := repo.redisClient.ZRem(ctx, getUserMutedUsersKey(userId), string(mutedUserId)).Err(); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// GetUserHiddenUsers returns the ids of the users whose content is hidden from the user, i.e. the users the user has
+// blocked or muted
+func (repo *RedisRepository) GetUserHiddenUsers(ctx context.Context, userId models.UserUid) ([]models.UserUid, error) {
+	const op errors.Op = "redis_repo.RedisRepository.GetUserHiddenUsers"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	// both sets share the user's hash slot
+	hiddenUserIdStrs, err := repo.redisClient.ZUnion(ctx, redis.ZStore{
+		Keys: []string{getUserBlockedUsersKey(userId), getUserMutedUsersKey(userId)},
+	}).Result()
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	var hiddenUserIds = make([]models.UserUid, 0, len(hiddenUserIdStrs))
+	for _, hiddenUserIdStr := range hiddenUserIdStrs {
+		hiddenUserIds = append(hiddenUserIds, models.UserUid(hiddenUserIdStr))
+	}
+
+	return hiddenUserIds, nil
+}
+
+// GetUsersHidingUser returns which of the users have blocked or muted the other user
+func (repo *RedisRepository) GetUsersHidingUser(ctx context.Context, userIds []models.UserUid, otherUserId models.UserUid) (map[models.UserUid]bool, error) {
+	const op errors.Op = "redis_repo.RedisRepository.GetUsersHidingUser"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	var hidingUserIds = make(map[models.UserUid]bool)
+	if len(userIds) == 0 {
+		return hidingUserIds, nil
+	}
+
+	var blockedCmds = make([]*redis.FloatCmd, len(userIds))
+	var mutedCmds = make([]*redis.FloatCmd, len(userIds))
+	pipe := repo.redisClient.Pipeline()
+	for i, userId := range userIds {
+		blockedCmds[i] = pipe.ZScore(ctx, getUserBlockedUsersKey(userId), string(otherUserId))
+		mutedCmds[i] = pipe.ZScore(ctx, getUserMutedUsersKey(userId), string(otherUserId))
+	}
+
+	// missing members make the pipeline return redis.Nil, the errors of the commands are checked one by one
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, errors.E(op, err)
+	}
+
+	for i, userId := range userIds {
+		for _, cmd := range []*redis.FloatCmd{blockedCmds[i], mutedCmds[i]} {
+			switch err := cmd.Err(); {
+			case errors.Is(err, redis.Nil):
+			case err != nil:
+				return nil, errors.E(op, err)
+			default:
+				hidingUserIds[userId] = true
+			}
+		}
+	}
+
+	return hidingUserIds, nil
+}
+
+// setUserRelation adds the other user to the user's collection. An existing member keeps its original score.
+func (repo *RedisRepository) setUserRelation(ctx context.Context, collectionKey string, otherUserId models.UserUid) error {
+	const op errors.Op = "redis_repo.RedisRepository.setUserRelation"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if err := repo.redisClient.ZAddNX(ctx, collectionKey, redis.Z{
+		Score:  float64(time.Now().UnixMilli()),
+		Member: string(otherUserId),
+	}).Err(); err != nil {
+		return errors.E(op, err)
+	}
+
 	return nil
 }
 
@@ -46,6 +264,7 @@ func (repo *RedisRepository) parseUser(userUid models.UserUid, stringMap map[str
 	userNameStr := stringMap[userFields.userUsernameField]
 	avatarUrlStr := stringMap[userFields.userAvatarUrlField]
 	isSignedUp := firstNameStr != "" && lastNameStr != "" && userNameStr != "" && avatarUrlStr != ""
+	isBanned := stringMap[userFields.userIsBannedField] == "1"
 
 	return &models.User{
 		BaseUser: models.BaseUser{
@@ -56,5 +275,6 @@ func (repo *RedisRepository) parseUser(userUid models.UserUid, stringMap map[str
 			AvatarUrl: avatarUrlStr,
 		},
 		IsSignedUp: isSignedUp,
+		IsBanned:   isBanned,
 	}
 }
@@ -0,0 +1,177 @@
+package s3blobstore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"spaces-p/pkg/common"
+	"spaces-p/pkg/errors"
+	"spaces-p/pkg/tracing"
+	"strings"
+	"time"
+)
+
+// S3BlobStore keeps blobs in a bucket of an S3 compatible object storage. Buckets are addressed path-style
+// (endpoint/bucket/key), which AWS S3 as well as stand-ins like MinIO support.
+type S3BlobStore struct {
+	endpoint        *url.URL
+	region          string
+	bucket          string
+	accessKeyId     string
+	secretAccessKey string
+	client          *http.Client
+}
+
+func NewS3BlobStore(endpoint, region, bucket, accessKeyId, secretAccessKey string) (*S3BlobStore, error) {
+	const op errors.Op = "s3blobstore.NewS3BlobStore"
+
+	endpointUrl, err := url.Parse(strings.TrimSuffix(endpoint, "/"))
+	switch {
+	case err != nil:
+		return nil, errors.E(op, err)
+	case endpointUrl.Scheme == "" || endpointUrl.Host == "":
+		err := fmt.Errorf("invalid s3 endpoint: %s", endpoint)
+		return nil, errors.E(op, err)
+	}
+
+	return &S3BlobStore{
+		endpoint:        endpointUrl,
+		region:          region,
+		bucket:          bucket,
+		accessKeyId:     accessKeyId,
+		secretAccessKey: secretAccessKey,
+		client:          &http.Client{Timeout: time.Minute},
+	}, nil
+}
+
+func (store *S3BlobStore) PutBlob(ctx context.Context, key string, content io.Reader, size int64, contentType string) error {
+	const op errors.Op = "s3blobstore.S3BlobStore.PutBlob"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	res, err := store.do(ctx, http.MethodPut, store.bucket+"/"+key, content, size, contentType)
+	if err != nil {
+		return errors.E(op, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return errors.E(op, unexpectedStatusError(res))
+	}
+
+	return nil
+}
+
+// GetBlob returns the blob's content, which the caller has to close
+func (store *S3BlobStore) GetBlob(ctx context.Context, key string) (io.ReadCloser, error) {
+	const op errors.Op = "s3blobstore.S3BlobStore.GetBlob"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	res, err := store.do(ctx, http.MethodGet, store.bucket+"/"+key, nil, 0, "")
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		return res.Body, nil
+	case http.StatusNotFound:
+		res.Body.Close()
+		return nil, errors.E(op, common.ErrNotFound)
+	default:
+		defer res.Body.Close()
+		return nil, errors.E(op, unexpectedStatusError(res))
+	}
+}
+
+func (store *S3BlobStore) DeleteBlob(ctx context.Context, key string) error {
+	const op errors.Op = "s3blobstore.S3BlobStore.DeleteBlob"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	res, err := store.do(ctx, http.MethodDelete, store.bucket+"/"+key, nil, 0, "")
+	if err != nil {
+		return errors.E(op, err)
+	}
+	defer res.Body.Close()
+
+	// deleting a key that doesn't exist succeeds as well
+	if res.StatusCode != http.StatusNoContent && res.StatusCode != http.StatusOK {
+		return errors.E(op, unexpectedStatusError(res))
+	}
+
+	return nil
+}
+
+// EnsureBucket creates the bucket if it doesn't exist yet, e.g. in a fresh local stand-in
+func (store *S3BlobStore) EnsureBucket(ctx context.Context) error {
+	const op errors.Op = "s3blobstore.S3BlobStore.EnsureBucket"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	res, err := store.do(ctx, http.MethodHead, store.bucket, nil, 0, "")
+	if err != nil {
+		return errors.E(op, err)
+	}
+	res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusNotFound:
+	default:
+		return errors.E(op, unexpectedStatusError(res))
+	}
+
+	// us-east-1 is the default location and must not be set explicitly
+	var body io.Reader
+	var size int64
+	if store.region != "us-east-1" {
+		configuration := fmt.Sprintf(`<CreateBucketConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><LocationConstraint>%s</LocationConstraint></CreateBucketConfiguration>`, store.region)
+		body, size = strings.NewReader(configuration), int64(len(configuration))
+	}
+
+	res, err = store.do(ctx, http.MethodPut, store.bucket, body, size, "")
+	if err != nil {
+		return errors.E(op, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return errors.E(op, unexpectedStatusError(res))
+	}
+
+	return nil
+}
+
+func (store *S3BlobStore) do(ctx context.Context, method, path string, body io.Reader, size int64, contentType string) (*http.Response, error) {
+	var requestUrl = *store.endpoint
+	requestUrl.Path = store.endpoint.Path + "/" + path
+
+	req, err := http.NewRequestWithContext(ctx, method, requestUrl.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	// the signature covers the path encoded the way S3 encodes it
+	req.URL.RawPath = uriEncode(req.URL.Path, false)
+	if body != nil {
+		req.ContentLength = size
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	signRequest(req, unsignedPayload, store.accessKeyId, store.secretAccessKey, store.region, time.Now())
+
+	return store.client.Do(req)
+}
+
+func unexpectedStatusError(res *http.Response) error {
+	// S3 describes errors in a small XML document
+	body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+
+	return fmt.Errorf("unexpected s3 response status %s: %s", res.Status, body)
+}
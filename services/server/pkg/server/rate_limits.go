@@ -0,0 +1,110 @@
+package server
+
+import (
+	"fmt"
+	"spaces-p/pkg/errors"
+	"spaces-p/pkg/models"
+	"spaces-p/pkg/utils"
+	"strings"
+	"time"
+)
+
+// RateLimitConfig holds the rate limit policies of the routes that write data or call paid apis, and of the filters
+// clients send over their user update connection. Policies that share a name share their counters.
+type RateLimitConfig struct {
+	CreateUser        models.RateLimitPolicy
+	CreateSpace       models.RateLimitPolicy
+	CreateMessage     models.RateLimitPolicy
+	LikeMessage       models.RateLimitPolicy
+	VotePoll          models.RateLimitPolicy
+	GetAddress        models.RateLimitPolicy
+	SpaceConnect      models.RateLimitPolicy
+	UserConnect       models.RateLimitPolicy
+	UserUpdatesFilter models.RateLimitPolicy
+	FollowThread      models.RateLimitPolicy
+	CreateReport      models.RateLimitPolicy
+	UploadAttachment  models.RateLimitPolicy
+	SetReadMarker     models.RateLimitPolicy
+	HideUser          models.RateLimitPolicy
+	Pin               models.RateLimitPolicy
+}
+
+func DefaultRateLimitConfig() RateLimitConfig {
+	return RateLimitConfig{
+		CreateUser:        models.RateLimitPolicy{Name: "create_user", Limit: 10, Window: time.Hour},
+		CreateSpace:       models.RateLimitPolicy{Name: "create_space", Limit: 10, Window: time.Hour},
+		CreateMessage:     models.RateLimitPolicy{Name: "create_message", Limit: 30, Window: time.Minute},
+		LikeMessage:       models.RateLimitPolicy{Name: "like_message", Limit: 60, Window: time.Minute},
+		VotePoll:          models.RateLimitPolicy{Name: "vote_poll", Limit: 60, Window: time.Minute},
+		GetAddress:        models.RateLimitPolicy{Name: "get_address", Limit: 30, Window: time.Minute},
+		SpaceConnect:      models.RateLimitPolicy{Name: "space_connect", Limit: 20, Window: time.Minute},
+		UserConnect:       models.RateLimitPolicy{Name: "user_connect", Limit: 20, Window: time.Minute},
+		UserUpdatesFilter: models.RateLimitPolicy{Name: "user_updates_filter", Limit: 60, Window: time.Minute},
+		FollowThread:      models.RateLimitPolicy{Name: "follow_thread", Limit: 60, Window: time.Minute},
+		CreateReport:      models.RateLimitPolicy{Name: "create_report", Limit: 20, Window: time.Hour},
+		UploadAttachment:  models.RateLimitPolicy{Name: "upload_attachment", Limit: 60, Window: time.Hour},
+		SetReadMarker:     models.RateLimitPolicy{Name: "set_read_marker", Limit: 120, Window: time.Minute},
+		HideUser:          models.RateLimitPolicy{Name: "hide_user", Limit: 30, Window: time.Minute},
+		Pin:               models.RateLimitPolicy{Name: "pin", Limit: 30, Window: time.Minute},
+	}
+}
+
+// NewRateLimitConfigFromEnv builds a RateLimitConfig from the RATE_LIMIT_[POLICY NAME]_LIMIT and
+// RATE_LIMIT_[POLICY NAME]_WINDOW environment variables, e.g. RATE_LIMIT_CREATE_MESSAGE_LIMIT=30 and
+// RATE_LIMIT_CREATE_MESSAGE_WINDOW=1m. Variables that are not set fall back to the values of DefaultRateLimitConfig.
+func NewRateLimitConfigFromEnv(getenv func(string) (string, error)) (RateLimitConfig, error) {
+	const op errors.Op = "server.NewRateLimitConfigFromEnv"
+	var cfg = DefaultRateLimitConfig()
+	var err error
+
+	for _, policy := range cfg.policies() {
+		var envPrefix = "RATE_LIMIT_" + strings.ToUpper(policy.Name)
+
+		if policy.Limit, err = utils.ParseIntEnv(getenv, envPrefix+"_LIMIT", policy.Limit); err != nil {
+			return RateLimitConfig{}, errors.E(op, err)
+		}
+		if policy.Window, err = utils.ParseDurationEnv(getenv, envPrefix+"_WINDOW", policy.Window); err != nil {
+			return RateLimitConfig{}, errors.E(op, err)
+		}
+	}
+
+	if err := cfg.validate(); err != nil {
+		return RateLimitConfig{}, errors.E(op, err)
+	}
+
+	return cfg, nil
+}
+
+func (cfg *RateLimitConfig) policies() []*models.RateLimitPolicy {
+	return []*models.RateLimitPolicy{
+		&cfg.CreateUser,
+		&cfg.CreateSpace,
+		&cfg.CreateMessage,
+		&cfg.LikeMessage,
+		&cfg.VotePoll,
+		&cfg.GetAddress,
+		&cfg.SpaceConnect,
+		&cfg.UserConnect,
+		&cfg.UserUpdatesFilter,
+		&cfg.FollowThread,
+		&cfg.CreateReport,
+		&cfg.UploadAttachment,
+		&cfg.SetReadMarker,
+		&cfg.HideUser,
+		&cfg.Pin,
+	}
+}
+
+func (cfg *RateLimitConfig) validate() error {
+	for _, policy := range cfg.policies() {
+		if policy.Limit < 1 {
+			return fmt.Errorf("limit of rate limit policy %s must be positive, got %d", policy.Name, policy.Limit)
+		}
+
+		if policy.Window <= 0 {
+			return fmt.Errorf("window of rate limit policy %s must be positive, got %s", policy.Name, policy.Window)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package server_test
+
+import (
+	"fmt"
+	"spaces-p/pkg/server"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func envGetter(env map[string]string) func(string) (string, error) {
+	return func(key string) (string, error) {
+		val, ok := env[key]
+		if !ok || val == "" {
+			return "", fmt.Errorf("no value found for key: %s", key)
+		}
+
+		return val, nil
+	}
+}
+
+func TestNewRateLimitConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    server.RateLimitConfig
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			env:  map[string]string{},
+			want: server.DefaultRateLimitConfig(),
+		},
+		{
+			name: "overridden limit and window",
+			env: map[string]string{
+				"RATE_LIMIT_CREATE_MESSAGE_LIMIT":       "100",
+				"RATE_LIMIT_USER_UPDATES_FILTER_WINDOW": "30s",
+				"RATE_LIMIT_PIN_LIMIT":                  "5",
+			},
+			want: func() server.RateLimitConfig {
+				cfg := server.DefaultRateLimitConfig()
+				cfg.CreateMessage.Limit = 100
+				cfg.UserUpdatesFilter.Window = 30 * time.Second
+				cfg.Pin.Limit = 5
+				return cfg
+			}(),
+		},
+		{
+			name:    "invalid limit",
+			env:     map[string]string{"RATE_LIMIT_CREATE_SPACE_LIMIT": "many"},
+			wantErr: true,
+		},
+		{
+			name:    "zero limit",
+			env:     map[string]string{"RATE_LIMIT_CREATE_SPACE_LIMIT": "0"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid window",
+			env:     map[string]string{"RATE_LIMIT_GET_ADDRESS_WINDOW": "1 minute"},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := server.NewRateLimitConfigFromEnv(envGetter(test.env))
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
@@ -1,12 +1,17 @@
 package server
 
 import (
+	"context"
+	"spaces-p/pkg/attachments"
 	"spaces-p/pkg/common"
 	"spaces-p/pkg/controllers"
+	"spaces-p/pkg/metrics"
 	"spaces-p/pkg/middlewares"
+	"spaces-p/pkg/moderation"
 	localmemory "spaces-p/pkg/repositories/local_memory"
 	"spaces-p/pkg/repositories/redis_repo"
 	"spaces-p/pkg/services"
+	"spaces-p/pkg/uuid"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jmoiron/sqlx"
@@ -15,32 +20,66 @@ import (
 
 // add all routes
 func addRoutes(
+	ctx context.Context,
 	apiVersion string,
 	router *gin.Engine,
 	logger common.Logger,
-	redisClient *redis.Client,
+	redisClient redis.UniversalClient,
 	postgresClient *sqlx.DB,
 	authClient common.AuthClient,
 	geoCodeRepo common.GeocodeRepository,
+	localMemoryRepo *localmemory.LocalMemoryRepo,
+	moderationPipeline *moderation.Pipeline,
+	moderationConfig moderation.Config,
+	blobStore common.BlobStore,
+	urlSigner *attachments.UrlSigner,
+	attachmentsConfig attachments.Config,
+	rateLimitConfig RateLimitConfig,
+	serverMetrics *metrics.Metrics,
 ) {
 	api := router.Group("/" + apiVersion)
 
 	// set repos
 	redisRepo := redis_repo.NewRedisRepository(redisClient)
-	localMemoryRepo := localmemory.NewLocalMemoryRepo()
+	instanceId := uuid.New().String()
+	logger.Info("instance id: ", instanceId)
 
 	// set up services
-	userService := services.NewUserService(logger, redisRepo)
+	userService := services.NewUserService(logger, redisRepo, localMemoryRepo)
 	spaceService := services.NewSpaceService(logger, redisRepo, localMemoryRepo)
-	spaceNotificationService := services.NewSpaceNotificationsService(logger, redisRepo, localMemoryRepo)
-	threadService := services.NewThreadService(logger, redisRepo, localMemoryRepo)
-	messageService := services.NewMessageService(logger, redisRepo, localMemoryRepo)
+	spaceNotificationService := services.NewSpaceNotificationsService(logger, redisRepo, localMemoryRepo, instanceId)
+	userNotificationService := services.NewUserNotificationsService(logger, redisRepo, localMemoryRepo, rateLimitConfig.UserUpdatesFilter)
+	inboxService := services.NewInboxService(logger, redisRepo, localMemoryRepo)
+	pinService := services.NewPinService(logger, redisRepo, localMemoryRepo)
+	retentionService := services.NewRetentionService(logger, redisRepo, localMemoryRepo)
 	addressService := services.NewAddressService(logger, redisRepo, geoCodeRepo)
+	threadService := services.NewThreadService(logger, redisRepo, localMemoryRepo, moderationPipeline, addressService)
+	messageService := services.NewMessageService(logger, redisRepo, localMemoryRepo, moderationPipeline, addressService)
+	pollService := services.NewPollService(logger, redisRepo, localMemoryRepo)
+	moderationService := services.NewModerationService(logger, redisRepo, localMemoryRepo)
+	reportService := services.NewReportService(logger, redisRepo, localMemoryRepo, moderationConfig.ReportsAutoHideThreshold)
+	attachmentService := services.NewAttachmentService(logger, redisRepo, blobStore, urlSigner, attachmentsConfig.MaxSize, attachmentsConfig.ThumbnailSize)
 	healthService := services.NewHealthService(logger, postgresClient)
 
+	serverMetrics.MustRegister(metrics.NewGeocodeCacheCollector(addressService))
+
+	// background jobs
+	go spaceNotificationService.RunSessionReaper(ctx, services.SessionReaperInterval)
+	go pollService.RunPollCloser(ctx, services.PollCloserInterval)
+	go spaceService.RunSpaceScheduler(ctx, services.SpaceSchedulerInterval)
+	go retentionService.RunMessageSweeper(ctx, services.MessageSweeperInterval)
+	go attachmentService.RunAttachmentCollector(ctx, services.AttachmentCollectorInterval)
+
 	// set up controllers
-	userController := controllers.NewUserController(logger, userService, authClient)
+	userController := controllers.NewUserController(logger, userService, userNotificationService, authClient)
 	spaceController := controllers.NewSpaceController(logger, spaceService, spaceNotificationService, threadService, messageService)
+	pollController := controllers.NewPollController(logger, pollService)
+	inboxController := controllers.NewInboxController(logger, inboxService)
+	pinController := controllers.NewPinController(logger, pinService)
+	retentionController := controllers.NewRetentionController(logger, retentionService)
+	moderationController := controllers.NewModerationController(logger, moderationService)
+	reportController := controllers.NewReportController(logger, reportService)
+	attachmentController := controllers.NewAttachmentController(logger, attachmentService, attachmentsConfig.MaxSize)
 	addressController := controllers.NewAddressController(logger, addressService)
 	healthController := controllers.NewHealthController(logger, healthService)
 
@@ -48,25 +87,80 @@ func addRoutes(
 	validateThreadInSpaceMiddleware := middlewares.ValidateThreadInSpace(logger, redisRepo)
 	validateMessageInThreadMiddleware := middlewares.ValidateMessageInThread(logger, redisRepo)
 	isSpaceSubscriberMiddleware := middlewares.IsSpaceSubscriber(logger, redisRepo)
+	isSpaceAdminMiddleware := middlewares.IsSpaceAdmin(logger, redisRepo)
+	isModeratorMiddleware := middlewares.IsModerator(logger, moderationConfig.Moderators)
+	isSpaceAdminOrModeratorMiddleware := middlewares.IsSpaceAdminOrModerator(logger, redisRepo, moderationConfig.Moderators)
+	isSpaceWritableMiddleware := middlewares.IsSpaceWritable(logger, redisRepo)
 
 	// USERS
-	api.POST("/users", userController.CreateUserFromIdToken)                                                                       // to test
+	api.POST("/users", middlewares.RateLimit(logger, redisRepo, rateLimitConfig.CreateUser), userController.CreateUserFromIdToken) // to test
 	api.GET("/users/:userid", middlewares.EnsureAuthenticated(logger, authClient, redisRepo, true, false), userController.GetUser) // to test
+	api.POST("/users/:userid/reports",
+		middlewares.EnsureAuthenticated(logger, authClient, redisRepo, true, true),
+		middlewares.RateLimit(logger, redisRepo, rateLimitConfig.CreateReport),
+		reportController.CreateUserReport,
+	)
 
 	// AUTHENTICATED USER
 	api.GET("/user",
 		middlewares.EnsureAuthenticated(logger, authClient, redisRepo, false, false),
 		userController.GetAuthedUser,
 	)
+	api.POST("/user/blocks/:userid",
+		middlewares.EnsureAuthenticated(logger, authClient, redisRepo, true, false),
+		middlewares.RateLimit(logger, redisRepo, rateLimitConfig.HideUser),
+		userController.BlockUser,
+	)
+	api.DELETE("/user/blocks/:userid",
+		middlewares.EnsureAuthenticated(logger, authClient, redisRepo, true, false),
+		middlewares.RateLimit(logger, redisRepo, rateLimitConfig.HideUser),
+		userController.UnblockUser,
+	)
+	api.POST("/user/mutes/:userid",
+		middlewares.EnsureAuthenticated(logger, authClient, redisRepo, true, false),
+		middlewares.RateLimit(logger, redisRepo, rateLimitConfig.HideUser),
+		userController.MuteUser,
+	)
+	api.DELETE("/user/mutes/:userid",
+		middlewares.EnsureAuthenticated(logger, authClient, redisRepo, true, false),
+		middlewares.RateLimit(logger, redisRepo, rateLimitConfig.HideUser),
+		userController.UnmuteUser,
+	)
+	api.GET("/user/inbox",
+		middlewares.EnsureAuthenticated(logger, authClient, redisRepo, true, false),
+		inboxController.GetInbox,
+	)
+	api.GET("/user/updates/ws",
+		middlewares.EnsureAuthenticated(logger, authClient, redisRepo, true, true),
+		middlewares.RateLimit(logger, redisRepo, rateLimitConfig.UserConnect),
+		userController.UserConnect,
+	)
 	api.PUT("/user", middlewares.EnsureAuthenticated(logger, authClient, redisRepo, true, false)) // TODO
 	api.DELETE("/user")                                                                           // TODO
 
 	// SPACES
-	api.GET("/spaces", middlewares.EnsureAuthenticated(logger, authClient, redisRepo, true, false), spaceController.GetSpaces)    // tested
-	api.POST("/spaces", middlewares.EnsureAuthenticated(logger, authClient, redisRepo, true, false), spaceController.CreateSpace) // tested
-	api.GET("/spaces/:spaceid", spaceController.GetSpace)                                                                         // tested
+	api.GET("/spaces", middlewares.EnsureAuthenticated(logger, authClient, redisRepo, true, false), spaceController.GetSpaces) // tested
+	api.POST("/spaces",                                                                                                        // tested
+		middlewares.EnsureAuthenticated(logger, authClient, redisRepo, true, false),
+		middlewares.RateLimit(logger, redisRepo, rateLimitConfig.CreateSpace),
+		spaceController.CreateSpace,
+	)
+	api.GET("/spaces/tiles",
+		middlewares.EnsureAuthenticated(logger, authClient, redisRepo, true, false),
+		spaceController.GetSpaceTiles,
+	)
+	api.GET("/spaces/tags",
+		middlewares.EnsureAuthenticated(logger, authClient, redisRepo, true, false),
+		spaceController.GetTagSuggestions,
+	)
+	api.GET("/spaces/search",
+		middlewares.EnsureAuthenticated(logger, authClient, redisRepo, true, false),
+		spaceController.SearchSpaces,
+	)
+	api.GET("/spaces/:spaceid", spaceController.GetSpace) // tested
 	api.GET("/spaces/:spaceid/updates/ws",
 		middlewares.EnsureAuthenticated(logger, authClient, redisRepo, true, true),
+		middlewares.RateLimit(logger, redisRepo, rateLimitConfig.SpaceConnect),
 		isSpaceSubscriberMiddleware,
 		spaceController.SpaceConnect,
 	)
@@ -78,23 +172,87 @@ func addRoutes(
 		middlewares.EnsureAuthenticated(logger, authClient, redisRepo, true, false),
 		spaceController.AddSpaceSubscriber,
 	)
+	api.DELETE("/spaces/:spaceid/subscribers", // tested
+		middlewares.EnsureAuthenticated(logger, authClient, redisRepo, true, false),
+		isSpaceSubscriberMiddleware,
+		spaceController.RemoveSpaceSubscriber,
+	)
 	api.GET("/spaces/:spaceid/toplevel-threads",
 		middlewares.EnsureAuthenticated(logger, authClient, redisRepo, true, false),
 		spaceController.GetTopLevelThreads,
 	)
 	api.POST("/spaces/:spaceid/toplevel-threads",
 		middlewares.EnsureAuthenticated(logger, authClient, redisRepo, true, false),
+		middlewares.RateLimit(logger, redisRepo, rateLimitConfig.CreateMessage),
 		isSpaceSubscriberMiddleware,
+		isSpaceWritableMiddleware,
 		spaceController.CreateTopLevelThread,
 	)
+	api.POST("/spaces/:spaceid/announcements",
+		middlewares.EnsureAuthenticated(logger, authClient, redisRepo, true, false),
+		middlewares.RateLimit(logger, redisRepo, rateLimitConfig.CreateMessage),
+		isSpaceAdminOrModeratorMiddleware,
+		isSpaceWritableMiddleware,
+		spaceController.CreateAnnouncement,
+	)
+	api.PUT("/spaces/:spaceid/read-marker",
+		middlewares.EnsureAuthenticated(logger, authClient, redisRepo, true, false),
+		middlewares.RateLimit(logger, redisRepo, rateLimitConfig.SetReadMarker),
+		spaceController.SetReadMarker,
+	)
+	api.PUT("/spaces/:spaceid/message-retention",
+		middlewares.EnsureAuthenticated(logger, authClient, redisRepo, true, false),
+		isSpaceAdminMiddleware,
+		retentionController.SetMessageRetention,
+	)
+	api.GET("/spaces/:spaceid/location-pins",
+		middlewares.EnsureAuthenticated(logger, authClient, redisRepo, true, false),
+		spaceController.GetSpaceLocationPins,
+	)
 	api.GET("/spaces/:spaceid/threads/:threadid",
+		middlewares.EnsureAuthenticated(logger, authClient, redisRepo, true, false),
 		validateThreadInSpaceMiddleware,
 		spaceController.GetThreadWithMessages,
 	)
+	api.PUT("/spaces/:spaceid/threads/:threadid/read-marker",
+		middlewares.EnsureAuthenticated(logger, authClient, redisRepo, true, false),
+		middlewares.RateLimit(logger, redisRepo, rateLimitConfig.SetReadMarker),
+		validateThreadInSpaceMiddleware,
+		spaceController.SetReadMarker,
+	)
+	api.PUT("/spaces/:spaceid/threads/:threadid/pin",
+		middlewares.EnsureAuthenticated(logger, authClient, redisRepo, true, false),
+		middlewares.RateLimit(logger, redisRepo, rateLimitConfig.Pin),
+		validateThreadInSpaceMiddleware,
+		isSpaceAdminOrModeratorMiddleware,
+		isSpaceWritableMiddleware,
+		pinController.PinTopLevelThread,
+	)
+	api.DELETE("/spaces/:spaceid/threads/:threadid/pin",
+		middlewares.EnsureAuthenticated(logger, authClient, redisRepo, true, false),
+		middlewares.RateLimit(logger, redisRepo, rateLimitConfig.Pin),
+		validateThreadInSpaceMiddleware,
+		isSpaceAdminOrModeratorMiddleware,
+		pinController.UnpinTopLevelThread,
+	)
+	api.POST("/spaces/:spaceid/threads/:threadid/followers",
+		middlewares.EnsureAuthenticated(logger, authClient, redisRepo, true, false),
+		middlewares.RateLimit(logger, redisRepo, rateLimitConfig.FollowThread),
+		validateThreadInSpaceMiddleware,
+		inboxController.FollowThread,
+	)
+	api.DELETE("/spaces/:spaceid/threads/:threadid/followers",
+		middlewares.EnsureAuthenticated(logger, authClient, redisRepo, true, false),
+		middlewares.RateLimit(logger, redisRepo, rateLimitConfig.FollowThread),
+		validateThreadInSpaceMiddleware,
+		inboxController.UnfollowThread,
+	)
 	api.POST("/spaces/:spaceid/threads/:threadid/messages",
 		middlewares.EnsureAuthenticated(logger, authClient, redisRepo, true, false),
+		middlewares.RateLimit(logger, redisRepo, rateLimitConfig.CreateMessage),
 		validateThreadInSpaceMiddleware,
 		isSpaceSubscriberMiddleware,
+		isSpaceWritableMiddleware,
 		spaceController.CreateMessage,
 	)
 	api.GET("/spaces/:spaceid/threads/:threadid/messages/:messageid",
@@ -103,22 +261,124 @@ func addRoutes(
 		validateMessageInThreadMiddleware,
 		spaceController.GetMessage,
 	)
+	api.PUT("/spaces/:spaceid/threads/:threadid/messages/:messageid/pin",
+		middlewares.EnsureAuthenticated(logger, authClient, redisRepo, true, false),
+		middlewares.RateLimit(logger, redisRepo, rateLimitConfig.Pin),
+		validateThreadInSpaceMiddleware,
+		validateMessageInThreadMiddleware,
+		isSpaceAdminOrModeratorMiddleware,
+		isSpaceWritableMiddleware,
+		pinController.PinMessage,
+	)
+	api.DELETE("/spaces/:spaceid/threads/:threadid/messages/:messageid/pin",
+		middlewares.EnsureAuthenticated(logger, authClient, redisRepo, true, false),
+		middlewares.RateLimit(logger, redisRepo, rateLimitConfig.Pin),
+		validateThreadInSpaceMiddleware,
+		validateMessageInThreadMiddleware,
+		isSpaceAdminOrModeratorMiddleware,
+		pinController.UnpinMessage,
+	)
 	api.POST("/spaces/:spaceid/threads/:threadid/messages/:messageid/threads",
 		middlewares.EnsureAuthenticated(logger, authClient, redisRepo, true, false),
+		middlewares.RateLimit(logger, redisRepo, rateLimitConfig.CreateMessage),
 		validateThreadInSpaceMiddleware,
 		validateMessageInThreadMiddleware,
+		isSpaceWritableMiddleware,
 		spaceController.CreateThread,
 	)
 	api.POST("/spaces/:spaceid/threads/:threadid/messages/:messageid/likes",
 		middlewares.EnsureAuthenticated(logger, authClient, redisRepo, true, false),
+		middlewares.RateLimit(logger, redisRepo, rateLimitConfig.LikeMessage),
 		validateThreadInSpaceMiddleware,
 		validateMessageInThreadMiddleware,
+		isSpaceWritableMiddleware,
 		spaceController.LikeMessage,
 	)
+	api.POST("/spaces/:spaceid/threads/:threadid/messages/:messageid/votes",
+		middlewares.EnsureAuthenticated(logger, authClient, redisRepo, true, false),
+		middlewares.RateLimit(logger, redisRepo, rateLimitConfig.VotePoll),
+		validateThreadInSpaceMiddleware,
+		validateMessageInThreadMiddleware,
+		isSpaceSubscriberMiddleware,
+		isSpaceWritableMiddleware,
+		pollController.VotePoll,
+	)
+
+	// ATTACHMENTS
+	api.POST("/spaces/:spaceid/attachments",
+		middlewares.EnsureAuthenticated(logger, authClient, redisRepo, true, false),
+		middlewares.RateLimit(logger, redisRepo, rateLimitConfig.UploadAttachment),
+		isSpaceSubscriberMiddleware,
+		isSpaceWritableMiddleware,
+		attachmentController.UploadAttachment,
+	)
+	api.GET("/spaces/:spaceid/attachments/:attachmentid",
+		middlewares.EnsureAuthenticated(logger, authClient, redisRepo, true, false),
+		attachmentController.GetAttachment,
+	)
+	api.GET("/attachments/:attachmentid/:variant", attachmentController.GetAttachmentContent) // authorized by the signed url
+
+	// MODERATION
+	api.GET("/spaces/:spaceid/held-messages",
+		middlewares.EnsureAuthenticated(logger, authClient, redisRepo, true, false),
+		isSpaceAdminMiddleware,
+		moderationController.GetHeldMessages,
+	)
+	api.POST("/spaces/:spaceid/held-messages/:heldmessageid/approve",
+		middlewares.EnsureAuthenticated(logger, authClient, redisRepo, true, false),
+		isSpaceAdminMiddleware,
+		moderationController.ApproveHeldMessage,
+	)
+	api.POST("/spaces/:spaceid/held-messages/:heldmessageid/reject",
+		middlewares.EnsureAuthenticated(logger, authClient, redisRepo, true, false),
+		isSpaceAdminMiddleware,
+		moderationController.RejectHeldMessage,
+	)
+
+	// REPORTS
+	api.POST("/spaces/:spaceid/reports",
+		middlewares.EnsureAuthenticated(logger, authClient, redisRepo, true, true),
+		middlewares.RateLimit(logger, redisRepo, rateLimitConfig.CreateReport),
+		reportController.CreateSpaceReport,
+	)
+	api.POST("/spaces/:spaceid/threads/:threadid/reports",
+		middlewares.EnsureAuthenticated(logger, authClient, redisRepo, true, true),
+		middlewares.RateLimit(logger, redisRepo, rateLimitConfig.CreateReport),
+		validateThreadInSpaceMiddleware,
+		reportController.CreateThreadReport,
+	)
+	api.POST("/spaces/:spaceid/threads/:threadid/messages/:messageid/reports",
+		middlewares.EnsureAuthenticated(logger, authClient, redisRepo, true, true),
+		middlewares.RateLimit(logger, redisRepo, rateLimitConfig.CreateReport),
+		validateThreadInSpaceMiddleware,
+		validateMessageInThreadMiddleware,
+		reportController.CreateMessageReport,
+	)
+	api.GET("/spaces/:spaceid/reports",
+		middlewares.EnsureAuthenticated(logger, authClient, redisRepo, true, false),
+		isSpaceAdminMiddleware,
+		reportController.GetSpaceReports,
+	)
+	api.POST("/spaces/:spaceid/reports/:targettype/:targetid/resolution",
+		middlewares.EnsureAuthenticated(logger, authClient, redisRepo, true, false),
+		isSpaceAdminMiddleware,
+		reportController.ResolveSpaceReport,
+	)
+	api.GET("/reports",
+		middlewares.EnsureAuthenticated(logger, authClient, redisRepo, true, false),
+		isModeratorMiddleware,
+		reportController.GetReports,
+	)
+	api.POST("/reports/:targettype/:targetid/resolution",
+		middlewares.EnsureAuthenticated(logger, authClient, redisRepo, true, false),
+		isModeratorMiddleware,
+		reportController.ResolveReport,
+	)
 
 	// ADDRESSES
 	api.GET("/address",
 		middlewares.EnsureAuthenticated(logger, authClient, redisRepo, true, false),
+		middlewares.RateLimit(logger, redisRepo, rateLimitConfig.GetAddress),
 		addressController.GetAddress,
 	) // tested
 
@@ -127,4 +387,5 @@ func addRoutes(
 	api.GET("/healthz", func(ctx *gin.Context) {
 		ctx.JSON(200, gin.H{"message": "OK"})
 	})
+
 }
@@ -8,9 +8,16 @@ import (
 	"os"
 	"os/signal"
 	"runtime"
+	"spaces-p/pkg/attachments"
 	"spaces-p/pkg/common"
 	"spaces-p/pkg/errors"
+	"spaces-p/pkg/metrics"
+	"spaces-p/pkg/moderation"
 	"spaces-p/pkg/redis"
+	localmemory "spaces-p/pkg/repositories/local_memory"
+	"spaces-p/pkg/tracing"
+	"spaces-p/pkg/utils"
+	"syscall"
 	"time"
 
 	"github.com/gin-contrib/cors"
@@ -26,7 +33,7 @@ func Run(
 	geoCodeRepo common.GeocodeRepository,
 ) error {
 	var op errors.Op = "main.run"
-	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt)
+	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
 	logger.Info("GOMAXPROCS: >> ", runtime.GOMAXPROCS(0))
@@ -39,18 +46,30 @@ func Run(
 		MaxAge:           12 * time.Hour,
 	})
 
-	redisPort, err := getenv("REDIS_PORT")
+	tracingConfig, err := tracing.NewConfigFromEnv(getenv)
 	if err != nil {
 		return errors.E(op, err)
 	}
 
-	redisHost, err := getenv("REDIS_HOST")
+	shutdownTracing, err := tracing.Setup(ctx, tracingConfig)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	redisConfig, err := redis.NewConfigFromEnv(getenv)
 	if err != nil {
 		return errors.E(op, err)
 	}
 
 	// initialize redis client
-	redisClient := redis.GetRedisClient(redisHost, redisPort)
+	redisClient, err := redis.GetRedisClient(redisConfig)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	// hooks can't be removed from a client, so the redis metrics are hooked in once right after creating it
+	serverMetrics := metrics.New()
+	redisClient.AddHook(serverMetrics.RedisHook())
 
 	// initialize postgres client
 	// postgresClient, err := postgres.GetPostgresClient()
@@ -73,7 +92,47 @@ func Run(
 		return errors.E(op, err)
 	}
 
-	srv := NewServer(apiVersion, logger, cors, redisClient, nil, authClient, geoCodeRepo)
+	localMemoryConfig, err := localmemory.NewConfigFromEnv(getenv)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	// holds the websocket sessions of this server instance
+	localMemoryRepo := localmemory.NewLocalMemoryRepo(localMemoryConfig)
+
+	moderationConfig, err := moderation.NewConfigFromEnv(getenv)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	// runs before new messages are stored
+	moderationPipeline, err := moderation.NewPipelineFromConfig(moderationConfig)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	attachmentsConfig, err := attachments.NewConfigFromEnv(getenv)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	blobStore, err := attachments.NewBlobStoreFromConfig(ctx, attachmentsConfig)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	// download urls point to this server, which streams the content from the blob store
+	urlSigner, err := attachments.NewUrlSignerFromConfig(attachmentsConfig, "/"+apiVersion)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	rateLimitConfig, err := NewRateLimitConfigFromEnv(getenv)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	srv := NewServer(ctx, apiVersion, logger, cors, redisClient, nil, authClient, geoCodeRepo, localMemoryRepo, moderationPipeline, moderationConfig, blobStore, urlSigner, attachmentsConfig, rateLimitConfig, serverMetrics)
 
 	httpServer := &http.Server{
 		Addr:    net.JoinHostPort(host, port),
@@ -87,6 +146,22 @@ func Run(
 		}
 	}()
 
+	// the metrics are served on their own port, which is not exposed to the public, and not at all without one
+	var metricsServer *http.Server
+	if metricsPort := utils.LookupEnv(getenv, "METRICS_PORT"); metricsPort != "" {
+		metricsServer = &http.Server{
+			Addr:    net.JoinHostPort(host, metricsPort),
+			Handler: serverMetrics.Handler(),
+		}
+
+		go func() {
+			logger.Info("serving metrics on ", metricsServer.Addr)
+			if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				fmt.Fprintf(os.Stderr, "error serving metrics: %s\n", err)
+			}
+		}()
+	}
+
 	<-ctx.Done()
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -94,5 +169,22 @@ func Run(
 		return errors.E(op, err)
 	}
 
+	if metricsServer != nil {
+		if err := metricsServer.Shutdown(shutdownCtx); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	// hijacked websocket connections are not tracked by httpServer.Shutdown
+	logger.Info("draining websocket sessions: ", localMemoryRepo.SessionsCount())
+	if err := localMemoryRepo.Drain(shutdownCtx); err != nil {
+		return errors.E(op, err)
+	}
+
+	// flushes the spans of the drained sessions
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		return errors.E(op, err)
+	}
+
 	return nil
 }
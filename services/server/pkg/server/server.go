@@ -1,10 +1,15 @@
 package server
 
 import (
+	"context"
 	"net/http"
 	"os"
+	"spaces-p/pkg/attachments"
 	"spaces-p/pkg/common"
+	"spaces-p/pkg/metrics"
 	"spaces-p/pkg/middlewares"
+	"spaces-p/pkg/moderation"
+	localmemory "spaces-p/pkg/repositories/local_memory"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jmoiron/sqlx"
@@ -13,20 +18,32 @@ import (
 
 // top-level HTTP stuff that applies to all endpoints
 func NewServer(
+	ctx context.Context,
 	apiVersion string,
 	logger common.Logger,
 	cors gin.HandlerFunc,
-	redisClient *redis.Client,
+	redisClient redis.UniversalClient,
 	postgresClient *sqlx.DB,
 	authClient common.AuthClient,
 	geoCodeRepo common.GeocodeRepository,
+	localMemoryRepo *localmemory.LocalMemoryRepo,
+	moderationPipeline *moderation.Pipeline,
+	moderationConfig moderation.Config,
+	blobStore common.BlobStore,
+	urlSigner *attachments.UrlSigner,
+	attachmentsConfig attachments.Config,
+	rateLimitConfig RateLimitConfig,
+	serverMetrics *metrics.Metrics,
 ) http.Handler {
 	gin.SetMode(os.Getenv("GIN_MODE"))
 	var router = gin.New()
 
-	router.Use(middlewares.GinZerologLogger(logger), gin.Recovery(), cors)
+	serverMetrics.MustRegister(metrics.NewLocalMemoryCollector(localMemoryRepo))
+
+	router.Use(middlewares.Tracing(), middlewares.RequestId(logger), middlewares.GinZerologLogger(logger), middlewares.Metrics(serverMetrics), gin.Recovery(), cors)
 
 	addRoutes(
+		ctx,
 		apiVersion,
 		router,
 		logger,
@@ -34,6 +51,14 @@ func NewServer(
 		postgresClient,
 		authClient,
 		geoCodeRepo,
+		localMemoryRepo,
+		moderationPipeline,
+		moderationConfig,
+		blobStore,
+		urlSigner,
+		attachmentsConfig,
+		rateLimitConfig,
+		serverMetrics,
 	)
 
 	return router.Handler()
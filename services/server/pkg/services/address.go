@@ -8,29 +8,46 @@ import (
 	"spaces-p/pkg/errors"
 	"spaces-p/pkg/models"
 	googlegeocode "spaces-p/pkg/repositories/google_geocode"
+	"spaces-p/pkg/tracing"
+	"spaces-p/pkg/uuid"
+	"sync/atomic"
+	"unicode/utf8"
+
+	"github.com/go-playground/validator/v10"
 )
 
 type AddressService struct {
 	logger      common.Logger
 	cacheRepo   common.CacheRepository
 	geoCodeRepo common.GeocodeRepository
+	cacheHits   atomic.Int64
+	cacheMisses atomic.Int64
 }
 
 func NewAddressService(logger common.Logger, cacheRepo common.CacheRepository, geoCodeRepo common.GeocodeRepository) *AddressService {
-	return &AddressService{logger, cacheRepo, geoCodeRepo}
+	return &AddressService{logger: logger, cacheRepo: cacheRepo, geoCodeRepo: geoCodeRepo}
+}
+
+// CacheStats returns how often addresses were served from and missing in the cache since the service was created
+func (ts *AddressService) CacheStats() (hits, misses int64) {
+	return ts.cacheHits.Load(), ts.cacheMisses.Load()
 }
 
 func (ts *AddressService) GetAddress(ctx context.Context, location models.Location) (*models.Address, error) {
 	const op errors.Op = "services.AddressService.GetAddress"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
 
 	geoHash := location.GeoHash(8)
 	address, err := ts.cacheRepo.GetAddress(ctx, geoHash)
 	switch {
 	case errors.Is(err, common.ErrNotFound):
-		ts.logger.Info(fmt.Sprintf("address cache miss for geohash %s", geoHash))
+		ts.cacheMisses.Add(1)
+		common.LoggerFromContext(ctx, ts.logger).Debug(fmt.Sprintf("address cache miss for geohash %s", geoHash))
 	case err != nil:
 		return &models.Address{}, errors.E(op, err)
 	default:
+		ts.cacheHits.Add(1)
 		return address, nil
 	}
 
@@ -52,3 +69,61 @@ func (ts *AddressService) GetAddress(ctx context.Context, location models.Locati
 
 	return address, nil
 }
+
+const (
+	maxLocationPinLabelLength = 100
+	// pins may lie a bit outside of their space, e.g. at the entrance of a park the space covers
+	locationPinToleranceM = 200
+)
+
+// validateLocationPin ensures that the pin of a location message lies within or near the space's radius
+func validateLocationPin(ctx context.Context, cacheRepo common.CacheRepository, spaceId uuid.Uuid, pin *models.LocationPin) error {
+	const op errors.Op = "services.validateLocationPin"
+
+	if utf8.RuneCountInString(pin.Label) > maxLocationPinLabelLength {
+		err := errors.New(fmt.Sprintf("location labels can't be longer than %d characters", maxLocationPinLabelLength))
+		return errors.E(op, err, http.StatusBadRequest)
+	}
+
+	if err := validator.New().Struct(pin.Location); err != nil {
+		return errors.E(op, err, http.StatusBadRequest)
+	}
+
+	space, err := cacheRepo.GetSpace(ctx, spaceId)
+	switch {
+	case errors.Is(err, common.ErrNotFound):
+		return errors.E(op, err, http.StatusNotFound)
+	case err != nil:
+		return errors.E(op, err, http.StatusInternalServerError)
+	}
+
+	if distance := space.Location.DistanceM(pin.Location); distance > space.Radius+locationPinToleranceM {
+		err := errors.New(fmt.Sprintf("the location is %.0fm away from the space, it must be within %.0fm", distance, space.Radius+locationPinToleranceM))
+		return errors.E(op, err, http.StatusBadRequest)
+	}
+
+	return nil
+}
+
+// resolveLocationPinAddress sets the reverse geocoded address of the pin of a location message. Pins of locations
+// without an address (e.g. in the middle of a lake) don't get one.
+func resolveLocationPinAddress(ctx context.Context, addressService *AddressService, newMessage models.NewMessage) error {
+	const op errors.Op = "services.resolveLocationPinAddress"
+
+	pin, ok := newMessage.Payload.(*models.LocationPin)
+	if !ok {
+		return nil
+	}
+
+	address, err := addressService.GetAddress(ctx, pin.Location)
+	switch {
+	case errors.Is(err, googlegeocode.ErrZeroResults):
+		pin.Address = nil
+	case err != nil:
+		return errors.E(op, err, http.StatusInternalServerError)
+	default:
+		pin.Address = address
+	}
+
+	return nil
+}
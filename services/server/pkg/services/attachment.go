@@ -0,0 +1,311 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"spaces-p/pkg/attachments"
+	"spaces-p/pkg/common"
+	"spaces-p/pkg/errors"
+	"spaces-p/pkg/models"
+	"spaces-p/pkg/tracing"
+	"spaces-p/pkg/uuid"
+	"time"
+)
+
+const (
+	AttachmentCollectorInterval  = time.Minute
+	attachmentCollectorBatchSize = 100
+	// uploads that no message has claimed within this time are collected
+	unattachedAttachmentTTL = 24 * time.Hour
+)
+
+type AttachmentService struct {
+	logger        common.Logger
+	cacheRepo     common.CacheRepository
+	blobStore     common.BlobStore
+	urlSigner     *attachments.UrlSigner
+	maxSize       int64
+	thumbnailSize int
+}
+
+func NewAttachmentService(logger common.Logger, cacheRepo common.CacheRepository, blobStore common.BlobStore, urlSigner *attachments.UrlSigner, maxSize int64, thumbnailSize int) *AttachmentService {
+	return &AttachmentService{logger, cacheRepo, blobStore, urlSigner, maxSize, thumbnailSize}
+}
+
+// CreateAttachment validates and stores an uploaded file. The content type is sniffed from the content, images are
+// re-encoded without their metadata and get a thumbnail.
+func (as *AttachmentService) CreateAttachment(ctx context.Context, spaceId uuid.Uuid, uploaderId models.UserUid, fileName string, content []byte) (*models.AttachmentWithUrls, error) {
+	const op errors.Op = "services.AttachmentService.CreateAttachment"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	switch {
+	case len(content) == 0:
+		err := errors.New("the uploaded file is empty")
+		return nil, errors.E(op, err, http.StatusBadRequest)
+	case int64(len(content)) > as.maxSize:
+		err := errors.New(fmt.Sprintf("the uploaded file exceeds the max size of %d bytes", as.maxSize))
+		return nil, errors.E(op, err, http.StatusRequestEntityTooLarge)
+	}
+
+	attachmentType, contentType, err := attachments.DetectContentType(content)
+	if err != nil {
+		return nil, errors.E(op, err, http.StatusUnsupportedMediaType)
+	}
+
+	var newAttachment = models.NewAttachment{
+		SpaceId:     spaceId,
+		UploaderId:  uploaderId,
+		Type:        attachmentType,
+		FileName:    attachments.SanitizeFileName(fileName),
+		ContentType: contentType,
+	}
+
+	var thumbnail []byte
+	if attachmentType == models.MessageTypeImage {
+		processedImage, err := attachments.ProcessImage(content, contentType, as.thumbnailSize)
+		switch {
+		case errors.Is(err, attachments.ErrImageTooLarge):
+			return nil, errors.E(op, err, http.StatusRequestEntityTooLarge)
+		case errors.Is(err, attachments.ErrInvalidImage):
+			return nil, errors.E(op, err, http.StatusBadRequest)
+		case err != nil:
+			return nil, errors.E(op, err, http.StatusInternalServerError)
+		}
+
+		content, thumbnail = processedImage.Content, processedImage.Thumbnail
+		newAttachment.Width, newAttachment.Height = processedImage.Width, processedImage.Height
+		newAttachment.ThumbnailContentType = processedImage.ThumbnailContentType
+	}
+	newAttachment.Size = int64(len(content))
+
+	var attachmentId = uuid.New()
+	if err := as.blobStore.PutBlob(ctx, attachments.BlobKey(attachmentId, models.OriginalAttachmentVariant), bytes.NewReader(content), int64(len(content)), contentType); err != nil {
+		return nil, errors.E(op, err, http.StatusInternalServerError)
+	}
+	if thumbnail != nil {
+		if err := as.blobStore.PutBlob(ctx, attachments.BlobKey(attachmentId, models.ThumbnailAttachmentVariant), bytes.NewReader(thumbnail), int64(len(thumbnail)), newAttachment.ThumbnailContentType); err != nil {
+			return nil, errors.E(op, err, http.StatusInternalServerError)
+		}
+	}
+
+	createdAttachment, err := as.cacheRepo.SetAttachment(ctx, attachmentId, newAttachment)
+	if err != nil {
+		return nil, errors.E(op, err, http.StatusInternalServerError)
+	}
+
+	return as.withUrls(createdAttachment), nil
+}
+
+// GetAttachment returns the attachment with freshly signed download urls
+func (as *AttachmentService) GetAttachment(ctx context.Context, spaceId, attachmentId uuid.Uuid) (*models.AttachmentWithUrls, error) {
+	const op errors.Op = "services.AttachmentService.GetAttachment"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	attachment, err := as.cacheRepo.GetAttachment(ctx, attachmentId)
+	switch {
+	case errors.Is(err, common.ErrNotFound):
+		return nil, errors.E(op, err, http.StatusNotFound)
+	case err != nil:
+		return nil, errors.E(op, err, http.StatusInternalServerError)
+	case attachment.SpaceId != spaceId:
+		err := errors.New(fmt.Sprintf("attachment with id %s is not part of space with id %s", attachmentId.String(), spaceId.String()))
+		return nil, errors.E(op, err, http.StatusNotFound)
+	}
+
+	return as.withUrls(attachment), nil
+}
+
+// GetAttachmentContent returns the content of the attachment's variant, if the signature of the download url is valid.
+// The caller has to close the content.
+func (as *AttachmentService) GetAttachmentContent(ctx context.Context, attachmentId uuid.Uuid, variant models.AttachmentVariant, expires, signature string) (io.ReadCloser, *models.Attachment, error) {
+	const op errors.Op = "services.AttachmentService.GetAttachmentContent"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if err := as.urlSigner.Verify(attachmentId, variant, expires, signature); err != nil {
+		return nil, nil, errors.E(op, err, http.StatusForbidden)
+	}
+
+	attachment, err := as.cacheRepo.GetAttachment(ctx, attachmentId)
+	switch {
+	case errors.Is(err, common.ErrNotFound):
+		return nil, nil, errors.E(op, err, http.StatusNotFound)
+	case err != nil:
+		return nil, nil, errors.E(op, err, http.StatusInternalServerError)
+	case attachment.VariantContentType(variant) == "":
+		err := errors.New(fmt.Sprintf("attachment with id %s has no %s variant", attachmentId.String(), variant))
+		return nil, nil, errors.E(op, err, http.StatusNotFound)
+	}
+
+	content, err := as.blobStore.GetBlob(ctx, attachments.BlobKey(attachmentId, variant))
+	switch {
+	case errors.Is(err, common.ErrNotFound):
+		return nil, nil, errors.E(op, err, http.StatusNotFound)
+	case err != nil:
+		return nil, nil, errors.E(op, err, http.StatusInternalServerError)
+	}
+
+	return content, attachment, nil
+}
+
+// RunAttachmentCollector removes the attachments that have stayed unattached for too long and deletes the content of
+// removed attachments from the blob store every interval until ctx is cancelled
+func (as *AttachmentService) RunAttachmentCollector(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := as.collectUnattachedAttachments(ctx); err != nil {
+				as.logger.Error(err)
+			}
+			if err := as.collectDeletedAttachments(ctx); err != nil {
+				as.logger.Error(err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// collectUnattachedAttachments removes a batch of attachments that were uploaded but never sent, e.g. because the
+// upload was abandoned, and queues their content for deletion
+func (as *AttachmentService) collectUnattachedAttachments(ctx context.Context) error {
+	const op errors.Op = "services.AttachmentService.collectUnattachedAttachments"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	attachmentIds, err := as.cacheRepo.GetUnattachedAttachmentIds(ctx, time.Now().Add(-unattachedAttachmentTTL), attachmentCollectorBatchSize)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	for _, attachmentId := range attachmentIds {
+		if err := as.cacheRepo.DeleteUnattachedAttachment(ctx, attachmentId); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	return nil
+}
+
+// collectDeletedAttachments deletes the blobs of a batch of removed attachments. An attachment whose blobs can't be
+// deleted stays queued for the next interval.
+func (as *AttachmentService) collectDeletedAttachments(ctx context.Context) error {
+	const op errors.Op = "services.AttachmentService.collectDeletedAttachments"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	attachmentIds, err := as.cacheRepo.GetDeletedAttachmentIds(ctx, attachmentCollectorBatchSize)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+loop:
+	for _, attachmentId := range attachmentIds {
+		// deleting a variant that an attachment doesn't have succeeds as well
+		for _, variant := range []models.AttachmentVariant{models.OriginalAttachmentVariant, models.ThumbnailAttachmentVariant} {
+			if err := as.blobStore.DeleteBlob(ctx, attachments.BlobKey(attachmentId, variant)); err != nil {
+				common.LoggerFromContext(ctx, as.logger).Error(errors.E(op, err))
+				continue loop
+			}
+		}
+
+		if err := as.cacheRepo.DeleteDeletedAttachmentId(ctx, attachmentId); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	return nil
+}
+
+func (as *AttachmentService) withUrls(attachment *models.Attachment) *models.AttachmentWithUrls {
+	url, expiresAt := as.urlSigner.SignedUrl(attachment.ID, models.OriginalAttachmentVariant)
+
+	var thumbnailUrl string
+	if attachment.ThumbnailContentType != "" {
+		thumbnailUrl, _ = as.urlSigner.SignedUrl(attachment.ID, models.ThumbnailAttachmentVariant)
+	}
+
+	return &models.AttachmentWithUrls{
+		Attachment:   *attachment,
+		Url:          url,
+		ThumbnailUrl: thumbnailUrl,
+		ExpiresAt:    expiresAt,
+	}
+}
+
+// validateMessageAttachment ensures that image and file messages reference an attachment of the same type that their
+// sender uploaded to the space, and that other messages have no attachment
+func validateMessageAttachment(ctx context.Context, cacheRepo common.CacheRepository, spaceId uuid.Uuid, newMessage models.NewMessage) error {
+	const op errors.Op = "services.validateMessageAttachment"
+
+	if newMessage.Type != models.MessageTypeImage && newMessage.Type != models.MessageTypeFile {
+		if newMessage.AttachmentId != uuid.Nil {
+			err := errors.New("only image and file messages can have an attachment")
+			return errors.E(op, err, http.StatusBadRequest)
+		}
+
+		return nil
+	}
+
+	if newMessage.AttachmentId == uuid.Nil {
+		err := errors.New("image and file messages require an attachment")
+		return errors.E(op, err, http.StatusBadRequest)
+	}
+
+	attachment, err := cacheRepo.GetAttachment(ctx, newMessage.AttachmentId)
+	switch {
+	case errors.Is(err, common.ErrNotFound):
+		return errors.E(op, err, http.StatusBadRequest)
+	case err != nil:
+		return errors.E(op, err, http.StatusInternalServerError)
+	case attachment.SpaceId != spaceId || attachment.UploaderId != newMessage.SenderId:
+		err := errors.New(fmt.Sprintf("attachment with id %s has not been uploaded to space with id %s by the sender", attachment.ID.String(), spaceId.String()))
+		return errors.E(op, err, http.StatusBadRequest)
+	case attachment.Type != newMessage.Type:
+		err := errors.New(fmt.Sprintf("attachment with id %s doesn't match the message type", attachment.ID.String()))
+		return errors.E(op, err, http.StatusBadRequest)
+	}
+
+	return nil
+}
+
+// attachMessageAttachment claims the attachment of an image or file message, so that no other message can refer to it
+func attachMessageAttachment(ctx context.Context, cacheRepo common.CacheRepository, newMessage models.NewMessage) error {
+	const op errors.Op = "services.attachMessageAttachment"
+
+	if newMessage.AttachmentId == uuid.Nil {
+		return nil
+	}
+
+	err := cacheRepo.AttachAttachment(ctx, newMessage.AttachmentId)
+	switch {
+	case errors.Is(err, common.ErrAttachmentAttached):
+		return errors.E(op, err, http.StatusConflict)
+	case err != nil:
+		return errors.E(op, err, http.StatusInternalServerError)
+	}
+
+	return nil
+}
+
+// detachMessageAttachment releases the attachment of a message that hasn't been stored in the end, e.g. because
+// moderation rejected it. The message has failed already, so a failure is only logged.
+func detachMessageAttachment(ctx context.Context, logger common.Logger, cacheRepo common.CacheRepository, newMessage models.NewMessage) {
+	const op errors.Op = "services.detachMessageAttachment"
+
+	if newMessage.AttachmentId == uuid.Nil {
+		return
+	}
+
+	if err := cacheRepo.DetachAttachment(ctx, newMessage.AttachmentId); err != nil {
+		common.LoggerFromContext(ctx, logger).Error(errors.E(op, err))
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"spaces-p/pkg/common"
 	"spaces-p/pkg/errors"
+	"spaces-p/pkg/tracing"
 
 	"github.com/jmoiron/sqlx"
 )
@@ -19,6 +20,8 @@ func NewHealthService(logger common.Logger, db *sqlx.DB) *HealthService {
 
 func (hs *HealthService) GetDbHealth(ctx context.Context) error {
 	const op errors.Op = "services.HealthService.GetDbHealth"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
 
 	var result int
 	err := hs.db.Get(&result, "SELECT 1")
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"spaces-p/pkg/common"
+	"spaces-p/pkg/errors"
+	"spaces-p/pkg/models"
+	localmemory "spaces-p/pkg/repositories/local_memory"
+	"spaces-p/pkg/tracing"
+	"spaces-p/pkg/uuid"
+)
+
+// inboxes keep only their most recent messages
+const maxInboxSize = 500
+
+type InboxService struct {
+	logger          common.Logger
+	cacheRepo       common.CacheRepository
+	localMemoryRepo *localmemory.LocalMemoryRepo
+}
+
+func NewInboxService(logger common.Logger, cacheRepo common.CacheRepository, localMemoryRepo *localmemory.LocalMemoryRepo) *InboxService {
+	return &InboxService{logger, cacheRepo, localMemoryRepo}
+}
+
+func (is *InboxService) FollowThread(ctx context.Context, threadId uuid.Uuid, userId models.UserUid) error {
+	const op errors.Op = "services.InboxService.FollowThread"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if err := is.cacheRepo.SetThreadFollower(ctx, threadId, userId); err != nil {
+		return errors.E(op, err, http.StatusInternalServerError)
+	}
+
+	return nil
+}
+
+func (is *InboxService) UnfollowThread(ctx context.Context, threadId uuid.Uuid, userId models.UserUid) error {
+	const op errors.Op = "services.InboxService.UnfollowThread"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if err := is.cacheRepo.DeleteThreadFollower(ctx, threadId, userId); err != nil {
+		return errors.E(op, err, http.StatusInternalServerError)
+	}
+
+	return nil
+}
+
+// GetInbox returns the new messages of the threads the user follows, the most recent first, without the ones sent by
+// users the user has blocked or muted since
+func (is *InboxService) GetInbox(ctx context.Context, userId models.UserUid, offset, count int64) ([]models.InboxMessage, error) {
+	const op errors.Op = "services.InboxService.GetInbox"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	messages, err := is.cacheRepo.GetUserInbox(ctx, userId, offset, count)
+	if err != nil {
+		return nil, errors.E(op, err, http.StatusInternalServerError)
+	}
+
+	hiddenUserIds, err := getHiddenUserIds(ctx, is.cacheRepo, userId)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	var inboxMessages = make([]models.InboxMessage, 0, len(messages))
+	var threadSpaceIds = make(map[uuid.Uuid]uuid.Uuid)
+	for _, message := range messages {
+		if hiddenUserIds[message.SenderId] {
+			continue
+		}
+
+		spaceId, ok := threadSpaceIds[message.ThreadId]
+		if !ok {
+			thread, err := is.cacheRepo.GetThread(ctx, message.ThreadId)
+			switch {
+			case errors.Is(err, common.ErrNotFound):
+				// the message has been orphaned by the deletion of its thread
+				continue
+			case err != nil:
+				return nil, errors.E(op, err, http.StatusInternalServerError)
+			}
+
+			spaceId = thread.SpaceId
+			threadSpaceIds[message.ThreadId] = spaceId
+		}
+
+		redactHiddenMessage(&message.Message)
+		inboxMessages = append(inboxMessages, models.InboxMessage{
+			MessageWithChildThreadMessagesCount: message,
+			SpaceId:                             spaceId,
+		})
+	}
+
+	return inboxMessages, nil
+}
+
+// followAndDeliver makes the sender of a new message follow its thread and delivers the message to the followers of
+// the thread and of the threads it is nested in. The message has been sent already, so a failure is only logged,
+// failing the request would make the client send the message again.
+func followAndDeliver(ctx context.Context, logger common.Logger, cacheRepo common.CacheRepository, localMemoryRepo *localmemory.LocalMemoryRepo, spaceId uuid.Uuid, message models.Message) {
+	const op errors.Op = "services.followAndDeliver"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if err := cacheRepo.SetThreadFollower(ctx, message.ThreadId, message.SenderId); err != nil {
+		common.LoggerFromContext(ctx, logger).Error(errors.E(op, err))
+	}
+
+	if err := deliverToFollowers(ctx, cacheRepo, localMemoryRepo, spaceId, message); err != nil {
+		common.LoggerFromContext(ctx, logger).Error(errors.E(op, err))
+	}
+}
+
+// deliverToFollowers delivers the message to the followers of its thread and of the threads it is nested in, except
+// for the followers who have blocked or muted its sender
+func deliverToFollowers(ctx context.Context, cacheRepo common.CacheRepository, localMemoryRepo *localmemory.LocalMemoryRepo, spaceId uuid.Uuid, message models.Message) error {
+	const op errors.Op = "services.deliverToFollowers"
+
+	var followerIds []models.UserUid
+	var isFollower = map[models.UserUid]bool{message.SenderId: true}
+	var threadId = message.ThreadId
+	for {
+		threadFollowerIds, err := cacheRepo.GetThreadFollowers(ctx, threadId)
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		for _, followerId := range threadFollowerIds {
+			if !isFollower[followerId] {
+				isFollower[followerId] = true
+				followerIds = append(followerIds, followerId)
+			}
+		}
+
+		thread, err := cacheRepo.GetThread(ctx, threadId)
+		if err != nil {
+			return errors.E(op, err)
+		}
+		if thread.ParentMessageId == uuid.Nil {
+			break
+		}
+
+		parentMessage, err := cacheRepo.GetMessage(ctx, thread.ParentMessageId)
+		if err != nil {
+			return errors.E(op, err)
+		}
+		threadId = parentMessage.ThreadId
+	}
+
+	hidingUserIds, err := cacheRepo.GetUsersHidingUser(ctx, followerIds, message.SenderId)
+	if err != nil {
+		return errors.E(op, err)
+	}
+	var recipientIds = slices.DeleteFunc(followerIds, func(followerId models.UserUid) bool {
+		return hidingUserIds[followerId]
+	})
+
+	if err := cacheRepo.AddInboxMessage(ctx, recipientIds, message, maxInboxSize); err != nil {
+		return errors.E(op, err)
+	}
+
+	var inboxMessage = models.InboxMessage{
+		MessageWithChildThreadMessagesCount: models.MessageWithChildThreadMessagesCount{Message: message},
+		SpaceId:                             spaceId,
+	}
+	for _, recipientId := range recipientIds {
+		localMemoryRepo.PublishInboxMessage(recipientId, inboxMessage)
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"context"
+	"spaces-p/pkg/common"
+	"spaces-p/pkg/errors"
+	"spaces-p/pkg/models"
+	localmemory "spaces-p/pkg/repositories/local_memory"
+	"spaces-p/pkg/tracing"
+	"spaces-p/pkg/uuid"
+)
+
+// notifyMentions notifies the subscribers of the space that the new message mentions by their username on their own
+// channel. The message has been sent already, so a failure is only logged.
+func notifyMentions(ctx context.Context, logger common.Logger, cacheRepo common.CacheRepository, localMemoryRepo *localmemory.LocalMemoryRepo, spaceId uuid.Uuid, message models.Message) {
+	const op errors.Op = "services.notifyMentions"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	mentionedUserIds, err := getMentionedUserIds(ctx, cacheRepo, spaceId, message)
+	if err != nil {
+		common.LoggerFromContext(ctx, logger).Error(errors.E(op, err))
+		return
+	}
+
+	var inboxMessage = models.InboxMessage{
+		MessageWithChildThreadMessagesCount: models.MessageWithChildThreadMessagesCount{Message: message},
+		SpaceId:                             spaceId,
+	}
+	// users who have blocked or muted the sender are left out by their sessions
+	for _, userId := range mentionedUserIds {
+		localMemoryRepo.PublishMention(userId, inboxMessage)
+	}
+}
+
+// getMentionedUserIds returns the subscribers of the space other than the sender that the message mentions
+func getMentionedUserIds(ctx context.Context, cacheRepo common.CacheRepository, spaceId uuid.Uuid, message models.Message) ([]models.UserUid, error) {
+	const op errors.Op = "services.getMentionedUserIds"
+
+	var mentionedUserIds []models.UserUid
+	var isMentioned = map[models.UserUid]bool{message.SenderId: true}
+	for _, username := range message.MentionedUsernames() {
+		userIds, err := cacheRepo.GetUserIdsByUsername(ctx, username)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+
+		for _, userId := range userIds {
+			if isMentioned[userId] {
+				continue
+			}
+			isMentioned[userId] = true
+
+			isSubscriber, err := cacheRepo.HasSpaceSubscriber(ctx, spaceId, userId)
+			if err != nil {
+				return nil, errors.E(op, err)
+			}
+			if isSubscriber {
+				mentionedUserIds = append(mentionedUserIds, userId)
+			}
+		}
+	}
+
+	return mentionedUserIds, nil
+}
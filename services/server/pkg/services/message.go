@@ -7,44 +7,159 @@ import (
 	"spaces-p/pkg/common"
 	"spaces-p/pkg/errors"
 	"spaces-p/pkg/models"
+	"spaces-p/pkg/moderation"
 	localmemory "spaces-p/pkg/repositories/local_memory"
+	"spaces-p/pkg/tracing"
 	"spaces-p/pkg/uuid"
+	"time"
 )
 
 type MessageService struct {
-	logger          common.Logger
-	cacheRepo       common.CacheRepository
-	localMemoryRepo *localmemory.LocalMemoryRepo
+	logger             common.Logger
+	cacheRepo          common.CacheRepository
+	localMemoryRepo    *localmemory.LocalMemoryRepo
+	moderationPipeline *moderation.Pipeline
+	addressService     *AddressService
 }
 
-func NewMessageService(logger common.Logger, cacheRepo common.CacheRepository, localMemoryRepo *localmemory.LocalMemoryRepo) *MessageService {
-	return &MessageService{logger, cacheRepo, localMemoryRepo}
+func NewMessageService(logger common.Logger, cacheRepo common.CacheRepository, localMemoryRepo *localmemory.LocalMemoryRepo, moderationPipeline *moderation.Pipeline, addressService *AddressService) *MessageService {
+	return &MessageService{logger, cacheRepo, localMemoryRepo, moderationPipeline, addressService}
 }
 
-func (ts *MessageService) CreateMessage(ctx context.Context, spaceId uuid.Uuid, authenticatedUserId models.UserUid, newMessage models.NewMessage) (uuid.Uuid, error) {
+// CreateMessage returns the id of the created message, or the held message if moderation put it into the review queue
+func (ts *MessageService) CreateMessage(ctx context.Context, spaceId uuid.Uuid, authenticatedUserId models.UserUid, newMessage models.NewMessage) (uuid.Uuid, *models.HeldMessage, error) {
 	const op errors.Op = "services.MessageService.CreateMessage"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
 
 	// ensure that thread exists
-	_, err := ts.cacheRepo.GetThread(ctx, newMessage.ThreadId)
+	thread, err := ts.cacheRepo.GetThread(ctx, newMessage.ThreadId)
 	switch {
 	case errors.Is(err, common.ErrNotFound):
 		err := errors.New(fmt.Sprintf("thread with id %s does not exist", newMessage.ThreadId.String()))
-		return uuid.Nil, errors.E(op, err, http.StatusBadRequest)
+		return uuid.Nil, nil, errors.E(op, err, http.StatusBadRequest)
 	case err != nil:
-		return uuid.Nil, errors.E(op, err, http.StatusInternalServerError)
+		return uuid.Nil, nil, errors.E(op, err, http.StatusInternalServerError)
+	}
+
+	// messages of a thread reply to the thread's parent message, or to the first message of a toplevel thread
+	var repliedToSenderId models.UserUid
+	if thread.ParentMessageId != uuid.Nil {
+		parentMessage, err := ts.cacheRepo.GetMessage(ctx, thread.ParentMessageId)
+		if err != nil {
+			return uuid.Nil, nil, errors.E(op, err, http.StatusInternalServerError)
+		}
+		repliedToSenderId = parentMessage.SenderId
+	} else {
+		topLevelThread, err := ts.cacheRepo.GetTopLevelThread(ctx, thread.ID)
+		if err != nil {
+			return uuid.Nil, nil, errors.E(op, err, http.StatusInternalServerError)
+		}
+		repliedToSenderId = topLevelThread.FirstMessage.SenderId
+	}
+
+	if err := ensureNotBlocked(ctx, ts.cacheRepo, repliedToSenderId, authenticatedUserId); err != nil {
+		return uuid.Nil, nil, errors.E(op, err)
+	}
+
+	if err := validateMessage(ctx, ts.cacheRepo, spaceId, newMessage); err != nil {
+		return uuid.Nil, nil, errors.E(op, err)
+	}
+
+	if err := resolveLocationPinAddress(ctx, ts.addressService, newMessage); err != nil {
+		return uuid.Nil, nil, errors.E(op, err)
+	}
+
+	// held messages keep their attachment until they are reviewed
+	if err := attachMessageAttachment(ctx, ts.cacheRepo, newMessage); err != nil {
+		return uuid.Nil, nil, errors.E(op, err)
+	}
+
+	heldMessage, err := moderateMessage(ctx, ts.moderationPipeline, ts.cacheRepo, spaceId, newMessage)
+	switch {
+	case err != nil:
+		detachMessageAttachment(ctx, ts.logger, ts.cacheRepo, newMessage)
+		return uuid.Nil, nil, errors.E(op, err)
+	case heldMessage != nil:
+		return uuid.Nil, heldMessage, nil
 	}
 
 	createdMessage, err := ts.cacheRepo.SetMessage(ctx, newMessage)
 	if err != nil {
-		return uuid.Nil, errors.E(op, err, http.StatusInternalServerError)
+		detachMessageAttachment(ctx, ts.logger, ts.cacheRepo, newMessage)
+		return uuid.Nil, nil, errors.E(op, err, http.StatusInternalServerError)
+	}
+	publishStoredMessage(ctx, ts.logger, ts.cacheRepo, ts.localMemoryRepo, spaceId, *createdMessage, nil)
+
+	return createdMessage.ID, nil, nil
+}
+
+// publishStoredMessage does what follows storing a new message: it publishes the message, or the toplevel thread it
+// starts if topLevelThread is set, counts it towards the trending score of the space, notifies the users it mentions
+// and makes its sender follow its thread, delivering it to the thread's followers. The message has been sent already,
+// so failures are only logged.
+func publishStoredMessage(ctx context.Context, logger common.Logger, cacheRepo common.CacheRepository, localMemoryRepo *localmemory.LocalMemoryRepo, spaceId uuid.Uuid, message models.Message, topLevelThread *models.TopLevelThread) {
+	const op errors.Op = "services.publishStoredMessage"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if topLevelThread != nil {
+		localMemoryRepo.PublishNewToplevelThread(spaceId, message.SenderId, *topLevelThread)
+	} else {
+		localMemoryRepo.PublishNewMessage(spaceId, message.SenderId, message)
 	}
-	ts.localMemoryRepo.PublishNewMessage(spaceId, authenticatedUserId, *createdMessage)
 
-	return createdMessage.ID, nil
+	recordRecentMessage(ctx, logger, cacheRepo, spaceId, message)
+	notifyMentions(ctx, logger, cacheRepo, localMemoryRepo, spaceId, message)
+	followAndDeliver(ctx, logger, cacheRepo, localMemoryRepo, spaceId, message)
+}
+
+// validateMessage ensures that a new message has what its type requires: a content for text messages, an attachment
+// for image and file messages and a valid payload for the types that carry one, e.g. a location near the space for
+// location messages
+func validateMessage(ctx context.Context, cacheRepo common.CacheRepository, spaceId uuid.Uuid, newMessage models.NewMessage) error {
+	const op errors.Op = "services.validateMessage"
+
+	switch {
+	case newMessage.Type == models.MessageTypeText && newMessage.Content == "":
+		err := errors.New("text messages require a content")
+		return errors.E(op, err, http.StatusBadRequest)
+	case newMessage.Payload != nil && newMessage.Payload.MessageType() != newMessage.Type:
+		err := errors.New("the payload doesn't match the message type")
+		return errors.E(op, err, http.StatusBadRequest)
+	}
+
+	if poll, ok := newMessage.Payload.(*models.Poll); ok {
+		if err := validatePoll(poll, time.Now()); err != nil {
+			return errors.E(op, err, http.StatusBadRequest)
+		}
+	}
+
+	if pin, ok := newMessage.Payload.(*models.LocationPin); ok {
+		if err := validateLocationPin(ctx, cacheRepo, spaceId, pin); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	if err := validateMessageAttachment(ctx, cacheRepo, spaceId, newMessage); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// redactHiddenMessage removes the content of a message that has been reported too often until its reports are reviewed
+func redactHiddenMessage(message *models.Message) {
+	if message.Hidden {
+		message.Content = ""
+		message.Payload = nil
+	}
 }
 
 func (ts *MessageService) GetMessage(ctx context.Context, messageId uuid.Uuid) (*models.MessageWithChildThreadMessagesCount, error) {
 	const op errors.Op = "services.MessageService.GetMessage"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
 
 	message, err := ts.cacheRepo.GetMessage(ctx, messageId)
 	switch {
@@ -54,13 +169,25 @@ func (ts *MessageService) GetMessage(ctx context.Context, messageId uuid.Uuid) (
 		return nil, errors.E(op, err, http.StatusInternalServerError)
 	}
 
+	redactHiddenMessage(&message.Message)
+
 	return message, nil
 }
 
 func (ts *MessageService) LikeMessage(ctx context.Context, spaceId, threadId, likedMessageId uuid.Uuid, authenticatedUserId models.UserUid) error {
 	const op errors.Op = "services.MessageService.LikeMessage"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
 
 	// don't need to validate if message with messageId exists, because validateMessageInThreadMiddleware middleware is already doing this
+	likedMessage, err := ts.cacheRepo.GetMessage(ctx, likedMessageId)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	if err := ensureNotBlocked(ctx, ts.cacheRepo, likedMessage.SenderId, authenticatedUserId); err != nil {
+		return errors.E(op, err)
+	}
 
 	if err := ts.cacheRepo.IncrementMessageLikesBy(ctx, threadId, likedMessageId, 1); err != nil {
 		return errors.E(op, err)
@@ -76,11 +203,10 @@ loop:
 		}
 
 		thread, err := ts.cacheRepo.GetThread(ctx, message.ThreadId)
-		var isTopLevelThread = thread.ParentMessageId == uuid.Nil
 		switch {
 		case err != nil:
 			return errors.E(op, err)
-		case isTopLevelThread:
+		case thread.ParentMessageId == uuid.Nil:
 			if err := ts.cacheRepo.IncrementTopLevelThreadLikesBy(ctx, spaceId, thread.ID, 1); err != nil {
 				return errors.E(op, err)
 			}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"spaces-p/pkg/common"
+	"spaces-p/pkg/errors"
+	"spaces-p/pkg/models"
+	"spaces-p/pkg/moderation"
+	localmemory "spaces-p/pkg/repositories/local_memory"
+	"spaces-p/pkg/tracing"
+	"spaces-p/pkg/uuid"
+)
+
+type ModerationService struct {
+	logger          common.Logger
+	cacheRepo       common.CacheRepository
+	localMemoryRepo *localmemory.LocalMemoryRepo
+}
+
+func NewModerationService(logger common.Logger, cacheRepo common.CacheRepository, localMemoryRepo *localmemory.LocalMemoryRepo) *ModerationService {
+	return &ModerationService{logger, cacheRepo, localMemoryRepo}
+}
+
+func (ms *ModerationService) GetHeldMessages(ctx context.Context, spaceId uuid.Uuid, offset, count int64) ([]models.HeldMessage, error) {
+	const op errors.Op = "services.ModerationService.GetHeldMessages"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	heldMessages, err := ms.cacheRepo.GetSpaceHeldMessages(ctx, spaceId, offset, count)
+	if err != nil {
+		return nil, errors.E(op, err, http.StatusInternalServerError)
+	}
+
+	return heldMessages, nil
+}
+
+// ApproveHeldMessage stores and publishes the held message as if it had passed moderation right away.
+// It returns the id of the created message.
+func (ms *ModerationService) ApproveHeldMessage(ctx context.Context, spaceId, heldMessageId uuid.Uuid) (uuid.Uuid, error) {
+	const op errors.Op = "services.ModerationService.ApproveHeldMessage"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	heldMessage, err := ms.getSpaceHeldMessage(ctx, spaceId, heldMessageId)
+	if err != nil {
+		return uuid.Nil, errors.E(op, err)
+	}
+
+	var messageId uuid.Uuid
+	if heldMessage.IsTopLevelThread() {
+		createdTopLevelThread, createdFirstMessage, err := ms.cacheRepo.SetTopLevelThread(ctx, spaceId, models.NewTopLevelThreadFirstMessage{
+			NewMessageInput: models.NewMessageInput(heldMessage.BaseMessage),
+			SenderId:        heldMessage.SenderId,
+		})
+		if err != nil {
+			return uuid.Nil, errors.E(op, err, http.StatusInternalServerError)
+		}
+
+		publishStoredMessage(ctx, ms.logger, ms.cacheRepo, ms.localMemoryRepo, spaceId, *createdFirstMessage, createdTopLevelThread)
+		messageId = createdFirstMessage.ID
+	} else {
+		// the thread might have been removed while the message was waiting for review
+		_, err := ms.cacheRepo.GetThread(ctx, heldMessage.ThreadId)
+		switch {
+		case errors.Is(err, common.ErrNotFound):
+			err := errors.New(fmt.Sprintf("thread with id %s does not exist anymore", heldMessage.ThreadId.String()))
+			return uuid.Nil, errors.E(op, err, http.StatusConflict)
+		case err != nil:
+			return uuid.Nil, errors.E(op, err, http.StatusInternalServerError)
+		}
+
+		createdMessage, err := ms.cacheRepo.SetMessage(ctx, heldMessage.NewMessage)
+		if err != nil {
+			return uuid.Nil, errors.E(op, err, http.StatusInternalServerError)
+		}
+
+		publishStoredMessage(ctx, ms.logger, ms.cacheRepo, ms.localMemoryRepo, spaceId, *createdMessage, nil)
+		messageId = createdMessage.ID
+	}
+
+	if err := ms.cacheRepo.DeleteHeldMessage(ctx, spaceId, heldMessageId); err != nil {
+		return uuid.Nil, errors.E(op, err, http.StatusInternalServerError)
+	}
+
+	return messageId, nil
+}
+
+// RejectHeldMessage discards the held message. Its attachment is released to be collected.
+func (ms *ModerationService) RejectHeldMessage(ctx context.Context, spaceId, heldMessageId uuid.Uuid) error {
+	const op errors.Op = "services.ModerationService.RejectHeldMessage"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	heldMessage, err := ms.getSpaceHeldMessage(ctx, spaceId, heldMessageId)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	if err := ms.cacheRepo.DeleteHeldMessage(ctx, spaceId, heldMessageId); err != nil {
+		return errors.E(op, err, http.StatusInternalServerError)
+	}
+	detachMessageAttachment(ctx, ms.logger, ms.cacheRepo, heldMessage.NewMessage)
+
+	return nil
+}
+
+// getSpaceHeldMessage returns the held message if it belongs to the space
+func (ms *ModerationService) getSpaceHeldMessage(ctx context.Context, spaceId, heldMessageId uuid.Uuid) (*models.HeldMessage, error) {
+	const op errors.Op = "services.ModerationService.getSpaceHeldMessage"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	heldMessage, err := ms.cacheRepo.GetHeldMessage(ctx, heldMessageId)
+	switch {
+	case errors.Is(err, common.ErrNotFound):
+		return nil, errors.E(op, err, http.StatusNotFound)
+	case err != nil:
+		return nil, errors.E(op, err, http.StatusInternalServerError)
+	case heldMessage.SpaceId != spaceId:
+		err := errors.New(fmt.Sprintf("held message with id %s is not part of space with id %s", heldMessageId.String(), spaceId.String()))
+		return nil, errors.E(op, err, http.StatusNotFound)
+	}
+
+	return heldMessage, nil
+}
+
+// moderateMessage runs the moderation pipeline on a new message before it is stored. Rejected messages result in an
+// error carrying the rejection reason for the sender. Held messages are put into the space's review queue and
+// returned, allowed messages return nil.
+func moderateMessage(ctx context.Context, pipeline *moderation.Pipeline, cacheRepo common.CacheRepository, spaceId uuid.Uuid, newMessage models.NewMessage) (*models.HeldMessage, error) {
+	const op errors.Op = "services.moderateMessage"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	decision, err := pipeline.Moderate(ctx, moderation.Content{
+		SpaceId:  spaceId,
+		SenderId: newMessage.SenderId,
+		Text:     newMessage.Text(),
+	})
+	if err != nil {
+		return nil, errors.E(op, err, http.StatusInternalServerError)
+	}
+
+	switch decision.Verdict {
+	case moderation.Reject:
+		err := errors.New(fmt.Sprintf("message was rejected by the %s filter: %s", decision.Filter, decision.Reason))
+		return nil, errors.E(op, err, http.StatusUnprocessableEntity, errors.Messages{"message": decision.Reason})
+	case moderation.Hold:
+		heldMessage, err := cacheRepo.SetHeldMessage(ctx, models.NewHeldMessage{
+			NewMessage: newMessage,
+			SpaceId:    spaceId,
+			Reason:     decision.Reason,
+			Filter:     decision.Filter,
+		})
+		if err != nil {
+			return nil, errors.E(op, err, http.StatusInternalServerError)
+		}
+
+		return heldMessage, nil
+	default:
+		return nil, nil
+	}
+}
@@ -0,0 +1,47 @@
+package services
+
+import (
+	"context"
+	"spaces-p/pkg/errors"
+)
+
+// a page of mostly hidden items ends short rather than scanning the whole list
+const maxPageFetches = 5
+
+// fetchVisiblePage fetches the items of a list from offset on until count of them are visible to the user, so that
+// filtering doesn't leave pages short. It returns all fetched items up to the last visible one, the visible ones and
+// the offset the next page starts at.
+func fetchVisiblePage[T any](
+	ctx context.Context,
+	fetch func(ctx context.Context, offset, count int64) ([]T, error),
+	offset, count int64,
+	isVisible func(item T) bool,
+) (fetched, visible []T, nextOffset int64, err error) {
+	const op errors.Op = "services.fetchVisiblePage"
+
+	nextOffset = offset
+	for i := 0; i < maxPageFetches && int64(len(visible)) < count; i++ {
+		items, err := fetch(ctx, nextOffset, count)
+		if err != nil {
+			return nil, nil, offset, errors.E(op, err)
+		}
+
+		for _, item := range items {
+			if int64(len(visible)) == count {
+				break
+			}
+
+			nextOffset++
+			fetched = append(fetched, item)
+			if isVisible(item) {
+				visible = append(visible, item)
+			}
+		}
+
+		if int64(len(items)) < count {
+			break
+		}
+	}
+
+	return fetched, visible, nextOffset, nil
+}
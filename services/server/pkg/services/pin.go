@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"spaces-p/pkg/common"
+	"spaces-p/pkg/errors"
+	"spaces-p/pkg/models"
+	localmemory "spaces-p/pkg/repositories/local_memory"
+	"spaces-p/pkg/tracing"
+	"spaces-p/pkg/uuid"
+	"time"
+)
+
+// the maximum number of toplevel threads and messages pinned to a space together
+const maxSpacePins = 10
+
+type PinService struct {
+	logger          common.Logger
+	cacheRepo       common.CacheRepository
+	localMemoryRepo *localmemory.LocalMemoryRepo
+}
+
+func NewPinService(logger common.Logger, cacheRepo common.CacheRepository, localMemoryRepo *localmemory.LocalMemoryRepo) *PinService {
+	return &PinService{logger, cacheRepo, localMemoryRepo}
+}
+
+func (ps *PinService) PinTopLevelThread(ctx context.Context, spaceId, threadId uuid.Uuid, authenticatedUserId models.UserUid) error {
+	const op errors.Op = "services.PinService.PinTopLevelThread"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if err := ensureTopLevelThread(ctx, ps.cacheRepo, threadId); err != nil {
+		return errors.E(op, err)
+	}
+
+	var pin = models.Pin{TargetType: models.ThreadPinTarget, TargetId: threadId, PinnedAt: time.Now()}
+	if err := pinItem(ctx, ps.cacheRepo, ps.localMemoryRepo, spaceId, authenticatedUserId, pin); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+func (ps *PinService) UnpinTopLevelThread(ctx context.Context, spaceId, threadId uuid.Uuid, authenticatedUserId models.UserUid) error {
+	const op errors.Op = "services.PinService.UnpinTopLevelThread"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if err := ps.unpinItem(ctx, spaceId, authenticatedUserId, models.ThreadPinTarget, threadId); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+func (ps *PinService) PinMessage(ctx context.Context, spaceId, messageId uuid.Uuid, authenticatedUserId models.UserUid) error {
+	const op errors.Op = "services.PinService.PinMessage"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	var pin = models.Pin{TargetType: models.MessagePinTarget, TargetId: messageId, PinnedAt: time.Now()}
+	if err := pinItem(ctx, ps.cacheRepo, ps.localMemoryRepo, spaceId, authenticatedUserId, pin); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+func (ps *PinService) UnpinMessage(ctx context.Context, spaceId, messageId uuid.Uuid, authenticatedUserId models.UserUid) error {
+	const op errors.Op = "services.PinService.UnpinMessage"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if err := ps.unpinItem(ctx, spaceId, authenticatedUserId, models.MessagePinTarget, messageId); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+func (ps *PinService) unpinItem(ctx context.Context, spaceId uuid.Uuid, userId models.UserUid, targetType models.PinTargetType, targetId uuid.Uuid) error {
+	const op errors.Op = "services.PinService.unpinItem"
+
+	unpinned, err := ps.cacheRepo.DeleteSpacePin(ctx, spaceId, targetType, targetId)
+	if err != nil {
+		return errors.E(op, err, http.StatusInternalServerError)
+	}
+
+	if unpinned {
+		ps.localMemoryRepo.PublishPin(spaceId, userId, models.Pin{TargetType: targetType, TargetId: targetId}, false)
+	}
+
+	return nil
+}
+
+// pinItem pins the item to the space and publishes the pin, unless the item already was pinned
+func pinItem(ctx context.Context, cacheRepo common.CacheRepository, localMemoryRepo *localmemory.LocalMemoryRepo, spaceId uuid.Uuid, userId models.UserUid, pin models.Pin) error {
+	const op errors.Op = "services.pinItem"
+
+	pinned, err := cacheRepo.SetSpacePin(ctx, spaceId, pin, maxSpacePins)
+	switch {
+	case errors.Is(err, common.ErrPinLimitReached):
+		err := fmt.Errorf("%w: spaces can have at most %d pins", err, maxSpacePins)
+		return errors.E(op, err, http.StatusConflict)
+	case err != nil:
+		return errors.E(op, err, http.StatusInternalServerError)
+	}
+
+	if pinned {
+		localMemoryRepo.PublishPin(spaceId, userId, pin, true)
+	}
+
+	return nil
+}
+
+func ensureTopLevelThread(ctx context.Context, cacheRepo common.CacheRepository, threadId uuid.Uuid) error {
+	const op errors.Op = "services.ensureTopLevelThread"
+
+	thread, err := cacheRepo.GetThread(ctx, threadId)
+	switch {
+	case errors.Is(err, common.ErrNotFound):
+		return errors.E(op, err, http.StatusNotFound)
+	case err != nil:
+		return errors.E(op, err, http.StatusInternalServerError)
+	case thread.ParentMessageId != uuid.Nil:
+		err := errors.New(fmt.Sprintf("thread with id %s is not a toplevel thread", threadId.String()))
+		return errors.E(op, err, http.StatusBadRequest)
+	}
+
+	return nil
+}
+
+// getSpacePins returns the pinned items of the space without the ones that are hidden from the user. Items that have
+// been removed since they were pinned are left out.
+func getSpacePins(ctx context.Context, cacheRepo common.CacheRepository, spaceId uuid.Uuid, hiddenUserIds map[models.UserUid]bool) (*models.SpacePins, error) {
+	const op errors.Op = "services.getSpacePins"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	pins, err := cacheRepo.GetSpacePins(ctx, spaceId)
+	if err != nil {
+		return nil, errors.E(op, err, http.StatusInternalServerError)
+	}
+
+	var spacePins = &models.SpacePins{
+		TopLevelThreads: []models.TopLevelThread{},
+		Messages:        []models.MessageWithChildThreadMessagesCount{},
+	}
+	for _, pin := range pins {
+		switch pin.TargetType {
+		case models.ThreadPinTarget:
+			thread, err := cacheRepo.GetTopLevelThread(ctx, pin.TargetId)
+			switch {
+			case errors.Is(err, common.ErrNotFound):
+				continue
+			case err != nil:
+				return nil, errors.E(op, err, http.StatusInternalServerError)
+			}
+
+			if !thread.Hidden && !hiddenUserIds[thread.FirstMessage.SenderId] {
+				spacePins.TopLevelThreads = append(spacePins.TopLevelThreads, *thread)
+			}
+		case models.MessagePinTarget:
+			message, err := cacheRepo.GetMessage(ctx, pin.TargetId)
+			switch {
+			case errors.Is(err, common.ErrNotFound):
+				continue
+			case err != nil:
+				return nil, errors.E(op, err, http.StatusInternalServerError)
+			}
+
+			if !hiddenUserIds[message.SenderId] {
+				redactHiddenMessage(&message.Message)
+				spacePins.Messages = append(spacePins.Messages, *message)
+			}
+		}
+	}
+
+	return spacePins, nil
+}
@@ -0,0 +1,241 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"spaces-p/pkg/common"
+	"spaces-p/pkg/errors"
+	"spaces-p/pkg/models"
+	localmemory "spaces-p/pkg/repositories/local_memory"
+	"spaces-p/pkg/tracing"
+	"spaces-p/pkg/uuid"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	PollCloserInterval  = 5 * time.Second
+	pollCloserBatchSize = 100
+
+	maxPollQuestionLength = 300
+	minPollOptions        = 2
+	maxPollOptions        = 10
+	maxPollOptionLength   = 100
+	maxPollDuration       = 30 * 24 * time.Hour
+)
+
+type PollService struct {
+	logger          common.Logger
+	cacheRepo       common.CacheRepository
+	localMemoryRepo *localmemory.LocalMemoryRepo
+}
+
+func NewPollService(logger common.Logger, cacheRepo common.CacheRepository, localMemoryRepo *localmemory.LocalMemoryRepo) *PollService {
+	return &PollService{logger, cacheRepo, localMemoryRepo}
+}
+
+// VotePoll records the user's vote for the options with the given indexes and returns the poll's updated results.
+// A later vote of the same user replaces the earlier one.
+func (ps *PollService) VotePoll(ctx context.Context, spaceId, threadId, messageId uuid.Uuid, voterId models.UserUid, options []int) (*models.PollResults, error) {
+	const op errors.Op = "services.PollService.VotePoll"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	// don't need to validate if message with messageId exists, because validateMessageInThreadMiddleware middleware is already doing this
+	message, err := ps.cacheRepo.GetMessage(ctx, messageId)
+	if err != nil {
+		return nil, errors.E(op, err, http.StatusInternalServerError)
+	}
+
+	poll, ok := message.Payload.(*models.Poll)
+	if !ok {
+		err := errors.New(fmt.Sprintf("message with id %s is not a poll", messageId.String()))
+		return nil, errors.E(op, err, http.StatusBadRequest)
+	}
+
+	if err := ensureNotBlocked(ctx, ps.cacheRepo, message.SenderId, voterId); err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	if err := validatePollVote(poll, options); err != nil {
+		return nil, errors.E(op, err, http.StatusBadRequest)
+	}
+
+	// the poll might not have been closed by the poll closer yet
+	if poll.IsExpired(time.Now()) {
+		return nil, errors.E(op, common.ErrPollClosed, http.StatusConflict)
+	}
+
+	err = ps.cacheRepo.SetPollVote(ctx, messageId, voterId, options)
+	switch {
+	case errors.Is(err, common.ErrPollClosed):
+		return nil, errors.E(op, err, http.StatusConflict)
+	case errors.Is(err, common.ErrNotFound):
+		return nil, errors.E(op, err, http.StatusNotFound)
+	case err != nil:
+		return nil, errors.E(op, err, http.StatusInternalServerError)
+	}
+
+	results, err := ps.cacheRepo.GetPollResults(ctx, messageId, len(poll.Options))
+	if err != nil {
+		return nil, errors.E(op, err, http.StatusInternalServerError)
+	}
+	ps.localMemoryRepo.PublishPollResults(spaceId, voterId, threadId, messageId, *results)
+
+	return results, nil
+}
+
+// RunPollCloser closes the polls whose closing time has passed every interval until ctx is cancelled
+func (ps *PollService) RunPollCloser(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := ps.closeExpiredPolls(ctx); err != nil {
+				ps.logger.Error(err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (ps *PollService) closeExpiredPolls(ctx context.Context) error {
+	const op errors.Op = "services.PollService.closeExpiredPolls"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	for {
+		messageIds, err := ps.cacheRepo.GetExpiredPolls(ctx, time.Now(), pollCloserBatchSize)
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		for _, messageId := range messageIds {
+			if err := ps.closePoll(ctx, messageId); err != nil {
+				return errors.E(op, err)
+			}
+		}
+
+		if len(messageIds) < pollCloserBatchSize {
+			return nil
+		}
+	}
+}
+
+// closePoll closes the poll and publishes its final results, unless another server instance has closed it already
+func (ps *PollService) closePoll(ctx context.Context, messageId uuid.Uuid) error {
+	const op errors.Op = "services.PollService.closePoll"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	closed, err := ps.cacheRepo.ClosePoll(ctx, messageId)
+	switch {
+	case err != nil:
+		return errors.E(op, err)
+	case !closed:
+		return nil
+	}
+
+	// the message or its thread might have been removed in the meantime
+	message, err := ps.cacheRepo.GetMessage(ctx, messageId)
+	switch {
+	case errors.Is(err, common.ErrNotFound):
+		return nil
+	case err != nil:
+		return errors.E(op, err)
+	}
+
+	thread, err := ps.cacheRepo.GetThread(ctx, message.ThreadId)
+	switch {
+	case errors.Is(err, common.ErrNotFound):
+		return nil
+	case err != nil:
+		return errors.E(op, err)
+	}
+
+	poll, ok := message.Payload.(*models.Poll)
+	if !ok || poll.Results == nil {
+		err := errors.New(fmt.Sprintf("message with id %s is not a poll", messageId.String()))
+		return errors.E(op, err)
+	}
+	ps.localMemoryRepo.PublishPollResults(thread.SpaceId, "", thread.ID, messageId, *poll.Results)
+
+	ps.logger.With(common.Fields{
+		common.SpaceIdLogField: thread.SpaceId,
+		"message_id":           messageId,
+	}).Info("closed expired poll")
+
+	return nil
+}
+
+// validatePoll validates the poll of a new poll message
+func validatePoll(poll *models.Poll, now time.Time) error {
+	const op errors.Op = "services.validatePoll"
+
+	var question = strings.TrimSpace(poll.Question)
+	switch {
+	case question == "":
+		return errors.E(op, errors.New("polls require a question"))
+	case utf8.RuneCountInString(question) > maxPollQuestionLength:
+		err := errors.New(fmt.Sprintf("poll questions can't be longer than %d characters", maxPollQuestionLength))
+		return errors.E(op, err)
+	case len(poll.Options) < minPollOptions || len(poll.Options) > maxPollOptions:
+		err := errors.New(fmt.Sprintf("polls require between %d and %d options", minPollOptions, maxPollOptions))
+		return errors.E(op, err)
+	}
+
+	var options = make(map[string]bool, len(poll.Options))
+	for _, option := range poll.Options {
+		option = strings.TrimSpace(option)
+		switch {
+		case option == "":
+			return errors.E(op, errors.New("poll options can't be empty"))
+		case utf8.RuneCountInString(option) > maxPollOptionLength:
+			err := errors.New(fmt.Sprintf("poll options can't be longer than %d characters", maxPollOptionLength))
+			return errors.E(op, err)
+		case options[option]:
+			err := errors.New(fmt.Sprintf("poll option %q is not unique", option))
+			return errors.E(op, err)
+		}
+		options[option] = true
+	}
+
+	if poll.ClosesAt != nil && (!poll.ClosesAt.After(now) || poll.ClosesAt.Sub(now) > maxPollDuration) {
+		err := errors.New(fmt.Sprintf("polls have to close within %s", maxPollDuration))
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// validatePollVote ensures that the vote chooses existing options, and only one of them if the poll is single choice
+func validatePollVote(poll *models.Poll, options []int) error {
+	const op errors.Op = "services.validatePollVote"
+
+	switch {
+	case len(options) == 0:
+		return errors.E(op, errors.New("votes require at least one option"))
+	case len(options) > 1 && !poll.MultipleChoice:
+		return errors.E(op, errors.New("the poll allows only one option"))
+	}
+
+	var chosen = make(map[int]bool, len(options))
+	for _, option := range options {
+		switch {
+		case option < 0 || option >= len(poll.Options):
+			err := errors.New(fmt.Sprintf("option %d doesn't exist", option))
+			return errors.E(op, err)
+		case chosen[option]:
+			err := errors.New(fmt.Sprintf("option %d has been chosen more than once", option))
+			return errors.E(op, err)
+		}
+		chosen[option] = true
+	}
+
+	return nil
+}
@@ -0,0 +1,275 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"spaces-p/pkg/common"
+	"spaces-p/pkg/errors"
+	"spaces-p/pkg/models"
+	localmemory "spaces-p/pkg/repositories/local_memory"
+	"spaces-p/pkg/tracing"
+	"spaces-p/pkg/uuid"
+)
+
+// the excerpt stored with a report keeps reported content reviewable after it has been deleted
+const reportExcerptMaxLength = 280
+
+type ReportService struct {
+	logger            common.Logger
+	cacheRepo         common.CacheRepository
+	localMemoryRepo   *localmemory.LocalMemoryRepo
+	autoHideThreshold int
+}
+
+func NewReportService(logger common.Logger, cacheRepo common.CacheRepository, localMemoryRepo *localmemory.LocalMemoryRepo, autoHideThreshold int) *ReportService {
+	return &ReportService{logger, cacheRepo, localMemoryRepo, autoHideThreshold}
+}
+
+// CreateReport reports the target. The spaceId and threadId are the ids of the target's space and thread, they are
+// uuid.Nil where they don't apply. Reporting a target repeatedly has no effect. Messages, threads and spaces are hidden
+// when their reports count reaches the auto hide threshold.
+func (rs *ReportService) CreateReport(ctx context.Context, target models.ReportTarget, spaceId, threadId uuid.Uuid, reporterId models.UserUid, reason models.ReportReason) error {
+	const op errors.Op = "services.ReportService.CreateReport"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	authorId, excerpt, err := rs.getReportTargetContent(ctx, target)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	if authorId == reporterId {
+		err := errors.New("users cannot report their own content")
+		return errors.E(op, err, http.StatusBadRequest)
+	}
+
+	added, reportsCount, err := rs.cacheRepo.AddReport(ctx, models.NewReport{
+		Target:     target,
+		SpaceId:    spaceId,
+		ThreadId:   threadId,
+		AuthorId:   authorId,
+		Excerpt:    excerpt,
+		ReporterId: reporterId,
+		Reason:     reason,
+	})
+	if err != nil {
+		return errors.E(op, err, http.StatusInternalServerError)
+	}
+
+	// the count only reaches the threshold once, dismissing a report resets it
+	if added && target.Type != models.UserReportTarget && rs.autoHideThreshold > 0 && reportsCount == rs.autoHideThreshold {
+		if err := rs.cacheRepo.SetReportTargetHidden(ctx, target, true); err != nil {
+			return errors.E(op, err, http.StatusInternalServerError)
+		}
+	}
+
+	return nil
+}
+
+func (rs *ReportService) GetSpaceReports(ctx context.Context, spaceId uuid.Uuid, offset, count int64) ([]models.Report, error) {
+	const op errors.Op = "services.ReportService.GetSpaceReports"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	reports, err := rs.cacheRepo.GetSpaceReports(ctx, spaceId, offset, count)
+	if err != nil {
+		return nil, errors.E(op, err, http.StatusInternalServerError)
+	}
+
+	return reports, nil
+}
+
+func (rs *ReportService) GetReports(ctx context.Context, offset, count int64) ([]models.Report, error) {
+	const op errors.Op = "services.ReportService.GetReports"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	reports, err := rs.cacheRepo.GetReports(ctx, offset, count)
+	if err != nil {
+		return nil, errors.E(op, err, http.StatusInternalServerError)
+	}
+
+	return reports, nil
+}
+
+// ResolveReport applies the action to the reported target and removes its reports from the review queues. If spaceId
+// is not uuid.Nil, the report is resolved by the admin of that space: only reports of the space's messages and threads
+// can be resolved and authors are only banned from the space instead of from all spaces.
+func (rs *ReportService) ResolveReport(ctx context.Context, target models.ReportTarget, action models.ReportResolutionAction, spaceId uuid.Uuid) error {
+	const op errors.Op = "services.ReportService.ResolveReport"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	report, err := rs.cacheRepo.GetReport(ctx, target)
+	switch {
+	case errors.Is(err, common.ErrNotFound):
+		return errors.E(op, err, http.StatusNotFound)
+	case err != nil:
+		return errors.E(op, err, http.StatusInternalServerError)
+	case spaceId != uuid.Nil && (!target.HasSpaceQueue() || report.SpaceId != spaceId):
+		err := errors.New(fmt.Sprintf("report of %s is not part of space with id %s", target.String(), spaceId.String()))
+		return errors.E(op, err, http.StatusNotFound)
+	}
+
+	switch action {
+	case models.DismissResolutionAction:
+		if report.Hidden {
+			if err := rs.cacheRepo.SetReportTargetHidden(ctx, target, false); err != nil {
+				return errors.E(op, err, http.StatusInternalServerError)
+			}
+		}
+	case models.DeleteResolutionAction:
+		if target.Type == models.UserReportTarget {
+			err := errors.New("reported users cannot be deleted, ban them instead")
+			return errors.E(op, err, http.StatusBadRequest)
+		}
+
+		if err := rs.deleteReportTarget(ctx, report); err != nil {
+			return errors.E(op, err)
+		}
+	case models.BanResolutionAction:
+		if spaceId != uuid.Nil {
+			if err := rs.cacheRepo.SetSpaceBannedUser(ctx, spaceId, report.AuthorId); err != nil {
+				return errors.E(op, err, http.StatusInternalServerError)
+			}
+
+			if err := rs.cacheRepo.DeleteSpaceSubscriber(ctx, spaceId, report.AuthorId); err != nil {
+				return errors.E(op, err, http.StatusInternalServerError)
+			}
+			rs.localMemoryRepo.RemoveUserChannelSpace(report.AuthorId, spaceId)
+		} else {
+			if err := rs.cacheRepo.SetUserBanned(ctx, report.AuthorId); err != nil {
+				return errors.E(op, err, http.StatusInternalServerError)
+			}
+		}
+
+		if target.Type != models.UserReportTarget {
+			if err := rs.deleteReportTarget(ctx, report); err != nil {
+				return errors.E(op, err)
+			}
+		}
+	default:
+		err := errors.New(fmt.Sprintf("unknown report resolution action: %s", action))
+		return errors.E(op, err, http.StatusBadRequest)
+	}
+
+	if err := rs.cacheRepo.DeleteReport(ctx, target, report.SpaceId); err != nil {
+		return errors.E(op, err, http.StatusInternalServerError)
+	}
+
+	return nil
+}
+
+// getReportTargetContent returns the author of the reported target and an excerpt of its content
+func (rs *ReportService) getReportTargetContent(ctx context.Context, target models.ReportTarget) (models.UserUid, string, error) {
+	const op errors.Op = "services.ReportService.getReportTargetContent"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if target.Type == models.UserReportTarget {
+		user, err := rs.cacheRepo.GetUserById(ctx, models.UserUid(target.ID))
+		switch {
+		case errors.Is(err, common.ErrNotFound):
+			return "", "", errors.E(op, err, http.StatusNotFound)
+		case err != nil:
+			return "", "", errors.E(op, err, http.StatusInternalServerError)
+		}
+
+		return user.ID, user.Username, nil
+	}
+
+	id, err := uuid.Parse(target.ID)
+	if err != nil {
+		return "", "", errors.E(op, err, http.StatusBadRequest)
+	}
+
+	switch target.Type {
+	case models.MessageReportTarget:
+		message, err := rs.cacheRepo.GetMessage(ctx, id)
+		switch {
+		case errors.Is(err, common.ErrNotFound):
+			return "", "", errors.E(op, err, http.StatusNotFound)
+		case err != nil:
+			return "", "", errors.E(op, err, http.StatusInternalServerError)
+		}
+
+		return message.SenderId, truncateExcerpt(message.Content), nil
+	case models.ThreadReportTarget:
+		thread, err := rs.cacheRepo.GetThread(ctx, id)
+		switch {
+		case errors.Is(err, common.ErrNotFound):
+			return "", "", errors.E(op, err, http.StatusNotFound)
+		case err != nil:
+			return "", "", errors.E(op, err, http.StatusInternalServerError)
+		case thread.ParentMessageId != uuid.Nil:
+			err := errors.New("only toplevel threads can be reported, report the messages of other threads instead")
+			return "", "", errors.E(op, err, http.StatusBadRequest)
+		}
+
+		topLevelThread, err := rs.cacheRepo.GetTopLevelThread(ctx, id)
+		if err != nil {
+			return "", "", errors.E(op, err, http.StatusInternalServerError)
+		}
+
+		return topLevelThread.FirstMessage.SenderId, truncateExcerpt(topLevelThread.FirstMessage.Content), nil
+	case models.SpaceReportTarget:
+		space, err := rs.cacheRepo.GetSpace(ctx, id)
+		switch {
+		case errors.Is(err, common.ErrNotFound):
+			return "", "", errors.E(op, err, http.StatusNotFound)
+		case err != nil:
+			return "", "", errors.E(op, err, http.StatusInternalServerError)
+		}
+
+		return space.AdminId, truncateExcerpt(space.Name), nil
+	default:
+		err := errors.New(fmt.Sprintf("unknown report target type: %s", target.Type))
+		return "", "", errors.E(op, err, http.StatusBadRequest)
+	}
+}
+
+func (rs *ReportService) deleteReportTarget(ctx context.Context, report *models.Report) error {
+	const op errors.Op = "services.ReportService.deleteReportTarget"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	id, err := uuid.Parse(report.Target.ID)
+	if err != nil {
+		return errors.E(op, err, http.StatusInternalServerError)
+	}
+
+	switch report.Target.Type {
+	case models.MessageReportTarget:
+		err = rs.cacheRepo.DeleteMessage(ctx, report.ThreadId, id)
+	case models.ThreadReportTarget:
+		err = rs.cacheRepo.DeleteTopLevelThread(ctx, report.SpaceId, id)
+	case models.SpaceReportTarget:
+		err = rs.cacheRepo.DeleteSpace(ctx, id)
+	default:
+		err = errors.New(fmt.Sprintf("targets of type %s cannot be deleted", report.Target.Type))
+	}
+	if err != nil {
+		return errors.E(op, err, http.StatusInternalServerError)
+	}
+
+	switch report.Target.Type {
+	case models.ThreadReportTarget:
+		rs.localMemoryRepo.PublishDeleteTopLevelThread(report.SpaceId, id)
+	case models.SpaceReportTarget:
+		// the space can't be used anymore, just like a space that has closed
+		rs.localMemoryRepo.PublishSpaceStatus(id, models.SpaceClosed)
+		rs.localMemoryRepo.RemoveChannelSpace(id)
+	}
+
+	return nil
+}
+
+func truncateExcerpt(content string) string {
+	var runes = []rune(content)
+	if len(runes) <= reportExcerptMaxLength {
+		return content
+	}
+
+	return string(runes[:reportExcerptMaxLength]) + "…"
+}
@@ -0,0 +1,187 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"spaces-p/pkg/common"
+	"spaces-p/pkg/errors"
+	localmemory "spaces-p/pkg/repositories/local_memory"
+	"spaces-p/pkg/tracing"
+	"spaces-p/pkg/uuid"
+	"time"
+)
+
+const (
+	MessageSweeperInterval  = time.Minute
+	messageSweeperBatchSize = 100
+)
+
+// RetentionService expires the messages of the spaces that have a message retention, e.g. spaces that are used like a
+// bulletin board. Every message expires by its own age, except that a message that a thread has been started from is
+// kept until all messages of that thread have expired, so that they keep their context. Messages are always younger
+// than their thread, so only the messages of toplevel threads older than the retention can have expired.
+type RetentionService struct {
+	logger          common.Logger
+	cacheRepo       common.CacheRepository
+	localMemoryRepo *localmemory.LocalMemoryRepo
+}
+
+func NewRetentionService(logger common.Logger, cacheRepo common.CacheRepository, localMemoryRepo *localmemory.LocalMemoryRepo) *RetentionService {
+	return &RetentionService{logger, cacheRepo, localMemoryRepo}
+}
+
+func (rs *RetentionService) SetMessageRetention(ctx context.Context, spaceId uuid.Uuid, retentionHours int) error {
+	const op errors.Op = "services.RetentionService.SetMessageRetention"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if err := rs.cacheRepo.SetSpaceMessageRetention(ctx, spaceId, retentionHours); err != nil {
+		return errors.E(op, err, http.StatusInternalServerError)
+	}
+
+	return nil
+}
+
+// RunMessageSweeper expires the messages that are older than the retention of their space every interval until ctx is
+// cancelled
+func (rs *RetentionService) RunMessageSweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := rs.sweepExpiredMessages(ctx); err != nil {
+				rs.logger.Error(err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (rs *RetentionService) sweepExpiredMessages(ctx context.Context) error {
+	const op errors.Op = "services.RetentionService.sweepExpiredMessages"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	spaceIds, err := rs.cacheRepo.GetMessageRetentionSpaceIds(ctx)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	// a space that fails to be swept is tried again at the next interval, it doesn't hold up the other spaces
+	for _, spaceId := range spaceIds {
+		if err := rs.sweepSpace(ctx, spaceId); err != nil {
+			common.LoggerFromContext(ctx, rs.logger).Error(errors.E(op, err))
+		}
+	}
+
+	return nil
+}
+
+// sweepSpace expires the space's messages that are older than its retention along with the toplevel threads that have
+// no messages left and publishes their removal, unless another server instance has expired them already
+func (rs *RetentionService) sweepSpace(ctx context.Context, spaceId uuid.Uuid) error {
+	const op errors.Op = "services.RetentionService.sweepSpace"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	// the space might have been removed or its retention unset in the meantime
+	space, err := rs.cacheRepo.GetSpace(ctx, spaceId)
+	switch {
+	case errors.Is(err, common.ErrNotFound):
+		return nil
+	case err != nil:
+		return errors.E(op, err)
+	case space.MessageRetentionHours == 0:
+		return nil
+	}
+
+	var until = time.Now().Add(-time.Duration(space.MessageRetentionHours) * time.Hour)
+	// the expired toplevel threads that are kept for their younger messages are skipped in the next batches
+	var keptCount int64
+	for {
+		threadIds, err := rs.cacheRepo.GetExpiredTopLevelThreadIds(ctx, spaceId, until, keptCount, messageSweeperBatchSize)
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		for _, threadId := range threadIds {
+			messagesCount, err := rs.expireThreadMessages(ctx, spaceId, threadId, until)
+			switch {
+			case err != nil:
+				return errors.E(op, err)
+			case messagesCount > 0:
+				keptCount++
+				continue
+			}
+
+			expired, err := rs.cacheRepo.ExpireTopLevelThread(ctx, spaceId, threadId)
+			switch {
+			case err != nil:
+				return errors.E(op, err)
+			case expired:
+				rs.localMemoryRepo.PublishDeleteTopLevelThread(spaceId, threadId)
+			}
+		}
+
+		if len(threadIds) < messageSweeperBatchSize {
+			return nil
+		}
+	}
+}
+
+// expireThreadMessages expires the thread's messages that have been created before until and publishes their removal.
+// It returns the number of messages the thread has left.
+func (rs *RetentionService) expireThreadMessages(ctx context.Context, spaceId, threadId uuid.Uuid, until time.Time) (int, error) {
+	const op errors.Op = "services.RetentionService.expireThreadMessages"
+
+	var keptCount int64
+	for {
+		messageIds, err := rs.cacheRepo.GetExpiredThreadMessageIds(ctx, threadId, until, keptCount, messageSweeperBatchSize)
+		if err != nil {
+			return 0, errors.E(op, err)
+		}
+
+		for _, messageId := range messageIds {
+			message, err := rs.cacheRepo.GetMessage(ctx, messageId)
+			switch {
+			case errors.Is(err, common.ErrNotFound):
+				continue
+			case err != nil:
+				return 0, errors.E(op, err)
+			}
+
+			if message.ChildThreadId != uuid.Nil {
+				childMessagesCount, err := rs.expireThreadMessages(ctx, spaceId, message.ChildThreadId, until)
+				switch {
+				case err != nil:
+					return 0, errors.E(op, err)
+				case childMessagesCount > 0:
+					keptCount++
+					continue
+				}
+			}
+
+			if err := rs.cacheRepo.DeleteMessage(ctx, threadId, messageId); err != nil {
+				return 0, errors.E(op, err)
+			}
+			rs.localMemoryRepo.PublishDeleteMessage(spaceId, threadId, messageId)
+		}
+
+		if len(messageIds) < messageSweeperBatchSize {
+			break
+		}
+	}
+
+	thread, err := rs.cacheRepo.GetThread(ctx, threadId)
+	switch {
+	case errors.Is(err, common.ErrNotFound):
+		return 0, nil
+	case err != nil:
+		return 0, errors.E(op, err)
+	}
+
+	return thread.MessagesCount, nil
+}
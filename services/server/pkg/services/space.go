@@ -2,12 +2,49 @@ package services
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"sort"
 	"spaces-p/pkg/common"
 	"spaces-p/pkg/errors"
 	"spaces-p/pkg/models"
 	localmemory "spaces-p/pkg/repositories/local_memory"
+	"spaces-p/pkg/tracing"
 	"spaces-p/pkg/uuid"
+	"time"
+)
+
+const (
+	SpaceSchedulerInterval  = 10 * time.Second
+	spaceSchedulerBatchSize = 100
+
+	// ended spaces stay discoverable for this long before they are archived
+	endedSpaceRetention = 30 * 24 * time.Hour
+	maxSpaceDuration    = 90 * 24 * time.Hour
+
+	// the maximum number of spaces shown in the tiles of a bounding box
+	maxTileSpaces = 1000
+	// the maximum number of clusters counted for a bounding box
+	maxTileCells = 4096
+
+	// the messages and new subscribers within these windows make a space trending
+	trendingMessagesWindow    = time.Hour
+	trendingSubscribersWindow = 24 * time.Hour
+	// the maximum number of nearby spaces that are ranked by their trending score
+	maxTrendingSpaces = 200
+	// the maximum number of nearby spaces that are looked at for the ones matching a filter
+	maxFilteredSpaces = 500
+
+	// only the first words of a search query are looked up
+	maxSearchTerms = 5
+	// the distance at which the relevance of a space found by name is discounted by a quarter when the search is biased
+	// by a location
+	searchDistanceScaleM = 10_000
+	// the spaces within this radius are looked at for matching names besides the spaces found by name when the search
+	// is biased by a location, since the closest spaces might not be among the latter for common words
+	searchNearbyRadiusM = 2 * searchDistanceScaleM
+	// the maximum number of nearby spaces that are looked at for matching names
+	maxSearchNearbySpaces = 500
 )
 
 type SpaceService struct {
@@ -22,6 +59,8 @@ func NewSpaceService(logger common.Logger, cacheRepo common.CacheRepository, loc
 
 func (ss *SpaceService) GetSpace(ctx context.Context, spaceId uuid.Uuid) (*models.Space, error) {
 	const op errors.Op = "services.SpaceService.GetSpace"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
 
 	space, err := ss.cacheRepo.GetSpace(ctx, spaceId)
 	switch {
@@ -29,24 +68,290 @@ func (ss *SpaceService) GetSpace(ctx context.Context, spaceId uuid.Uuid) (*model
 		return nil, errors.E(op, err, http.StatusNotFound)
 	case err != nil:
 		return nil, errors.E(op, err, http.StatusInternalServerError)
+	case space.Hidden:
+		err := errors.New(fmt.Sprintf("space with id %s is hidden", spaceId.String()))
+		return nil, errors.E(op, err, http.StatusNotFound)
 	}
 
 	return space, nil
 }
 
-func (ss *SpaceService) GetSpacesByLocation(ctx context.Context, location models.Location, radius models.Radius, count, offset int) ([]models.SpaceWithDistance, error) {
+// GetSpacesByLocation returns the visible spaces near the location that match the filter. Only the closest spaces, or
+// the closest spaces with the filter's tag, are looked at for the ones matching a filter.
+func (ss *SpaceService) GetSpacesByLocation(ctx context.Context, location models.Location, radius models.Radius, filter models.SpaceFilter, count, offset int) ([]models.SpaceWithDistance, error) {
 	const op errors.Op = "services.SpaceService.GetSpacesByLocation"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	var now = time.Now()
+	var isVisible = func(space models.SpaceWithDistance) bool {
+		return !space.Hidden && space.HasStarted(now)
+	}
+
+	var spaces []models.SpaceWithDistance
+	var err error
+	switch {
+	case filter.IsEmpty():
+		// the closest spaces are fetched until the hidden ones and the ones that haven't started yet don't leave the
+		// page short, the repository only returns the closest count spaces so each fetch skips the ones fetched before
+		fetch := func(ctx context.Context, fetchOffset, fetchCount int64) ([]models.SpaceWithDistance, error) {
+			closestSpaces, err := ss.cacheRepo.GetSpacesByLocation(ctx, location, radius, int(fetchOffset+fetchCount))
+			if err != nil || int64(len(closestSpaces)) <= fetchOffset {
+				return nil, err
+			}
+
+			return closestSpaces[fetchOffset:], nil
+		}
+		_, spaces, _, err = fetchVisiblePage(ctx, fetch, 0, int64(offset+count), isVisible)
+	case filter.Tag != "":
+		// spaces are looked up through the index of the tag, so the closest spaces without it don't crowd out the ones
+		// with it
+		spaces, err = ss.cacheRepo.GetSpacesByLocationAndTag(ctx, location, radius, filter.Tag, maxFilteredSpaces)
+	default:
+		spaces, err = ss.cacheRepo.GetSpacesByLocation(ctx, location, radius, maxFilteredSpaces)
+	}
+	if err != nil {
+		return nil, errors.E(op, err, http.StatusInternalServerError)
+	}
+
+	// the offset is applied to the visible spaces matching the filter, so hidden spaces don't shift the pages
+	var matchingSpaces = make([]models.SpaceWithDistance, 0, count)
+	for _, space := range spaces {
+		if !isVisible(space) || !filter.Matches(&space.Space) {
+			continue
+		}
+
+		if offset > 0 {
+			offset--
+			continue
+		}
+
+		matchingSpaces = append(matchingSpaces, space)
+		if len(matchingSpaces) == count {
+			break
+		}
+	}
+
+	return matchingSpaces, nil
+}
+
+// GetTrendingSpacesByLocation ranks the visible spaces near the location by their trending score, which blends their
+// recent activity with their distance. Only the closest spaces are ranked.
+func (ss *SpaceService) GetTrendingSpacesByLocation(ctx context.Context, location models.Location, radius models.Radius, filter models.SpaceFilter, count, offset int) ([]models.TrendingSpace, error) {
+	const op errors.Op = "services.SpaceService.GetTrendingSpacesByLocation"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	spaces, err := ss.GetSpacesByLocation(ctx, location, radius, filter, maxTrendingSpaces, 0)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	var spaceIds = make([]uuid.Uuid, len(spaces))
+	for i, space := range spaces {
+		spaceIds[i] = space.ID
+	}
+
+	var now = time.Now()
+	activities, err := ss.cacheRepo.GetSpacesActivity(ctx, spaceIds, now.Add(-trendingMessagesWindow), now.Add(-trendingSubscribersWindow))
+	if err != nil {
+		return nil, errors.E(op, err, http.StatusInternalServerError)
+	}
+
+	var trendingSpaces = make([]models.TrendingSpace, len(spaces))
+	for i, space := range spaces {
+		trendingSpaces[i] = models.TrendingSpace{
+			SpaceWithDistance: space,
+			Activity:          activities[i],
+			TrendingScore:     activities[i].TrendingScore(space.Distance),
+		}
+	}
+
+	// spaces with the same score stay ordered by distance
+	sort.SliceStable(trendingSpaces, func(i, j int) bool {
+		return trendingSpaces[i].TrendingScore > trendingSpaces[j].TrendingScore
+	})
+
+	if offset >= len(trendingSpaces) {
+		return []models.TrendingSpace{}, nil
+	}
+
+	return trendingSpaces[offset:min(offset+count, len(trendingSpaces))], nil
+}
+
+// GetTagSuggestions returns the tags starting with the prefix, the most used first
+func (ss *SpaceService) GetTagSuggestions(ctx context.Context, prefix string, count int) ([]models.TagSuggestion, error) {
+	const op errors.Op = "services.SpaceService.GetTagSuggestions"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	tagSuggestions, err := ss.cacheRepo.GetTagSuggestions(ctx, prefix, count)
+	if err != nil {
+		return nil, errors.E(op, err, http.StatusInternalServerError)
+	}
+
+	return tagSuggestions, nil
+}
+
+// SearchSpaces returns the visible spaces whose names match the query best. Their relevance is discounted by their
+// distance to the location, if it is given, and the spaces near it are searched besides the ones found by name, but far
+// away spaces are found as well.
+func (ss *SpaceService) SearchSpaces(ctx context.Context, query string, location *models.Location, count int) ([]models.SpaceSearchResult, error) {
+	const op errors.Op = "services.SpaceService.SearchSpaces"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	terms := models.SpaceNameTerms(query)
+	if len(terms) == 0 {
+		err := errors.New("the search query must contain a letter or digit")
+		return nil, errors.E(op, err, http.StatusBadRequest)
+	}
+	terms = terms[:min(maxSearchTerms, len(terms))]
+
+	spaces, err := ss.cacheRepo.GetSpacesByName(ctx, terms)
+	if err != nil {
+		return nil, errors.E(op, err, http.StatusInternalServerError)
+	}
 
-	spaces, err := ss.cacheRepo.GetSpacesByLocation(ctx, location, radius, count+offset)
+	if location != nil {
+		nearbySpaces, err := ss.cacheRepo.GetSpacesByLocation(ctx, *location, searchNearbyRadiusM, maxSearchNearbySpaces)
+		if err != nil {
+			return nil, errors.E(op, err, http.StatusInternalServerError)
+		}
+
+		var isCandidate = make(map[uuid.Uuid]bool, len(spaces))
+		for _, space := range spaces {
+			isCandidate[space.ID] = true
+		}
+		for _, nearbySpace := range nearbySpaces {
+			if !isCandidate[nearbySpace.ID] {
+				spaces = append(spaces, nearbySpace.Space)
+			}
+		}
+	}
+
+	var now = time.Now()
+	var searchResults = make([]models.SpaceSearchResult, 0, len(spaces))
+	for _, space := range spaces {
+		if space.Hidden || space.Archived || !space.HasStarted(now) {
+			continue
+		}
+
+		relevance := models.SpaceNameRelevance(terms, space.Name)
+		if relevance == 0 {
+			continue
+		}
+
+		var searchResult = models.SpaceSearchResult{Space: space, Relevance: relevance}
+		if location != nil {
+			distance := location.DistanceM(space.Location)
+			searchResult.Distance = &distance
+			searchResult.Relevance *= 0.5 + 0.5/(1+distance/searchDistanceScaleM)
+		}
+
+		searchResults = append(searchResults, searchResult)
+	}
+
+	sort.SliceStable(searchResults, func(i, j int) bool {
+		return searchResults[i].Relevance > searchResults[j].Relevance
+	})
+
+	return searchResults[:min(count, len(searchResults))], nil
+}
+
+// recordRecentMessage counts a new message towards the trending score of its space. The message has been sent
+// already, so a failure is only logged.
+func recordRecentMessage(ctx context.Context, logger common.Logger, cacheRepo common.CacheRepository, spaceId uuid.Uuid, message models.Message) {
+	const op errors.Op = "services.recordRecentMessage"
+
+	if err := cacheRepo.AddSpaceRecentMessage(ctx, spaceId, message.ID, message.CreatedAt, trendingMessagesWindow); err != nil {
+		common.LoggerFromContext(ctx, logger).Error(errors.E(op, err))
+	}
+}
+
+// GetSpaceTiles groups the visible spaces inside the bounding box by the geohash cells that fit the zoom. Below
+// models.MinUnclusteredTileZoom the tiles only count their spaces as clusters, which works for bounding boxes of any
+// size as long as they aren't covered by too many cells.
+func (ss *SpaceService) GetSpaceTiles(ctx context.Context, box models.BoundingBox, zoom int) (*models.SpaceTiles, error) {
+	const op errors.Op = "services.SpaceService.GetSpaceTiles"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	var spaceTiles = &models.SpaceTiles{
+		Precision: models.TileGeoHashPrecision(zoom),
+		Clustered: zoom < models.MinUnclusteredTileZoom,
+		Tiles:     []models.SpaceTile{},
+	}
+
+	if spaceTiles.Clustered {
+		geoHashes, ok := box.GeoHashCells(spaceTiles.Precision, maxTileCells)
+		if !ok {
+			err := errors.New("the bounding box is too large for the zoom")
+			return nil, errors.E(op, err, http.StatusBadRequest)
+		}
+
+		counts, err := ss.cacheRepo.GetTileSpacesCounts(ctx, geoHashes)
+		if err != nil {
+			return nil, errors.E(op, err, http.StatusInternalServerError)
+		}
+
+		for i, geoHash := range geoHashes {
+			if counts[i] > 0 {
+				spaceTiles.Tiles = append(spaceTiles.Tiles, models.SpaceTile{
+					GeoHash:  geoHash,
+					Count:    int(counts[i]),
+					Location: models.GeoHashCenter(geoHash),
+				})
+			}
+		}
+
+		return spaceTiles, nil
+	}
+
+	spaces, err := ss.cacheRepo.GetSpacesInBox(ctx, box, maxTileSpaces)
 	if err != nil {
 		return nil, errors.E(op, err, http.StatusInternalServerError)
 	}
+	spaceTiles.Truncated = len(spaces) == maxTileSpaces
+
+	var now = time.Now()
+	var tileIndexes = make(map[string]int)
+	for _, space := range spaces {
+		if space.Hidden || !space.HasStarted(now) {
+			continue
+		}
+
+		geoHash := space.Location.GeoHash(spaceTiles.Precision)
+		i, ok := tileIndexes[geoHash]
+		if !ok {
+			i = len(spaceTiles.Tiles)
+			tileIndexes[geoHash] = i
+			spaceTiles.Tiles = append(spaceTiles.Tiles, models.SpaceTile{GeoHash: geoHash})
+		}
+
+		// the location sums up the coordinates until the center is taken below
+		tile := &spaceTiles.Tiles[i]
+		tile.Count++
+		tile.Location.Long += space.Location.Long
+		tile.Location.Lat += space.Location.Lat
+		tile.Spaces = append(tile.Spaces, space)
+	}
+
+	for i := range spaceTiles.Tiles {
+		tile := &spaceTiles.Tiles[i]
+		tile.Location.Long /= float64(tile.Count)
+		tile.Location.Lat /= float64(tile.Count)
+	}
 
-	return spaces[offset:], nil
+	return spaceTiles, nil
 }
 
-func (ss *SpaceService) GetSpacesByUser(ctx context.Context, userId models.UserUid, count, offset int64) ([]models.Space, error) {
+// GetSpacesByUser returns the spaces of the user. Their unread counts are only added if the user is the authenticated
+// user.
+func (ss *SpaceService) GetSpacesByUser(ctx context.Context, userId models.UserUid, count, offset int64, authenticatedUserId models.UserUid) ([]models.SpaceWithUnreadCount, error) {
 	const op errors.Op = "services.SpaceService.GetSpacesByUser"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
 
 	// validate user id
 	_, err := ss.cacheRepo.GetUserById(ctx, userId)
@@ -62,29 +367,104 @@ func (ss *SpaceService) GetSpacesByUser(ctx context.Context, userId models.UserU
 		return nil, errors.E(op, err)
 	}
 
-	return spaces, nil
+	var visibleSpaces = make([]models.SpaceWithUnreadCount, 0, len(spaces))
+	var visibleSpaceIds = make([]uuid.Uuid, 0, len(spaces))
+	for _, space := range spaces {
+		if !space.Hidden {
+			visibleSpaces = append(visibleSpaces, models.SpaceWithUnreadCount{Space: space})
+			visibleSpaceIds = append(visibleSpaceIds, space.ID)
+		}
+	}
+
+	if userId != authenticatedUserId {
+		return visibleSpaces, nil
+	}
+
+	unreadCounts, err := ss.cacheRepo.GetSpacesUnreadCounts(ctx, userId, visibleSpaceIds)
+	if err != nil {
+		return nil, errors.E(op, err, http.StatusInternalServerError)
+	}
+	for i := range visibleSpaces {
+		visibleSpaces[i].UnreadCount = &unreadCounts[i]
+	}
+
+	return visibleSpaces, nil
 }
 
-func (ss *SpaceService) GetTopLevelThreads(ctx context.Context, spaceId uuid.Uuid, sort models.Sorting, offset, count int64) ([]models.TopLevelThread, error) {
+// GetTopLevelThreads returns the space's toplevel threads and, separately, its pinned items without the ones started by
+// users the authenticated user has blocked or muted. Threads are fetched until count of them are visible, the offset
+// of the next page is returned along with them. The space is marked as read up to the most recent of the fetched
+// threads.
+func (ss *SpaceService) GetTopLevelThreads(ctx context.Context, spaceId uuid.Uuid, sort models.Sorting, offset, count int64, authenticatedUserId models.UserUid) (*models.TopLevelThreadsWithPins, error) {
 	const op errors.Op = "services.SpaceService.GetTopLevelThreads"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
 
-	var threads []models.TopLevelThread
-	var err error
-	switch sort {
-	case models.PopularitySorting:
-		threads, err = ss.cacheRepo.GetSpaceTopLevelThreadsByPopularity(ctx, spaceId, offset, count)
-	case models.RecentSorting:
-		threads, err = ss.cacheRepo.GetSpaceTopLevelThreadsByTime(ctx, spaceId, offset, count)
+	hiddenUserIds, err := getHiddenUserIds(ctx, ss.cacheRepo, authenticatedUserId)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	var getTopLevelThreads = ss.cacheRepo.GetSpaceTopLevelThreadsByTime
+	if sort == models.PopularitySorting {
+		getTopLevelThreads = ss.cacheRepo.GetSpaceTopLevelThreadsByPopularity
+	}
+	threads, shownThreads, nextOffset, err := fetchVisiblePage(
+		ctx,
+		func(ctx context.Context, offset, count int64) ([]models.TopLevelThread, error) {
+			return getTopLevelThreads(ctx, spaceId, offset, count)
+		},
+		offset,
+		count,
+		// hidden threads have been reported too often and wait for review
+		func(thread models.TopLevelThread) bool {
+			return !thread.Hidden && !hiddenUserIds[thread.FirstMessage.SenderId]
+		},
+	)
+	if err != nil {
+		return nil, errors.E(op, err, http.StatusInternalServerError)
+	}
+
+	spacePins, err := getSpacePins(ctx, ss.cacheRepo, spaceId, hiddenUserIds)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	var readAt time.Time
+	for _, thread := range threads {
+		if thread.CreatedAt.After(readAt) {
+			readAt = thread.CreatedAt
+		}
+	}
+
+	var visibleThreads = make([]models.TopLevelThreadWithUnreadCount, 0, len(shownThreads))
+	var visibleThreadIds = make([]uuid.Uuid, 0, len(shownThreads))
+	for _, thread := range shownThreads {
+		visibleThreads = append(visibleThreads, models.TopLevelThreadWithUnreadCount{TopLevelThread: thread})
+		visibleThreadIds = append(visibleThreadIds, thread.ID)
 	}
+
+	unreadCounts, err := ss.cacheRepo.GetThreadsUnreadCounts(ctx, authenticatedUserId, visibleThreadIds)
 	if err != nil {
 		return nil, errors.E(op, err, http.StatusInternalServerError)
 	}
+	for i := range visibleThreads {
+		visibleThreads[i].UnreadCount = unreadCounts[i]
+	}
 
-	return threads, nil
+	if !readAt.IsZero() {
+		if err := ss.markRead(ctx, authenticatedUserId, models.ReadMarker{SpaceId: spaceId, ReadAt: readAt}); err != nil {
+			return nil, errors.E(op, err)
+		}
+	}
+
+	return &models.TopLevelThreadsWithPins{Pinned: *spacePins, TopLevelThreads: visibleThreads, NextOffset: nextOffset}, nil
 }
 
 func (ss *SpaceService) GetSpaceSubscribers(ctx context.Context, spaceId uuid.Uuid, activeSubscribers bool, offset, count int64) ([]models.User, error) {
 	const op errors.Op = "services.SpaceService.GetSpaceSubscribers"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
 
 	// verify if space exists
 	_, err := ss.GetSpace(ctx, spaceId)
@@ -106,33 +486,156 @@ func (ss *SpaceService) GetSpaceSubscribers(ctx context.Context, spaceId uuid.Uu
 	return subscribers, nil
 }
 
-func (ss *SpaceService) GetThreadWithMessages(ctx context.Context, spaceId, threadId uuid.Uuid, messagesSort models.Sorting, messagesOffset, messagesCount int64) (*models.ThreadWithMessages, error) {
+// GetThreadWithMessages returns the thread with its messages without the ones sent by users the authenticated user
+// has blocked or muted. Messages are fetched until count of them are visible, the offset of the next page is returned
+// along with them. The thread is marked as read up to the most recent of the fetched messages.
+func (ss *SpaceService) GetThreadWithMessages(ctx context.Context, spaceId, threadId uuid.Uuid, messagesSort models.Sorting, messagesOffset, messagesCount int64, authenticatedUserId models.UserUid) (*models.ThreadWithMessages, error) {
 	const op errors.Op = "services.SpaceService.GetThreadWithMessages"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
 
 	thread, err := ss.cacheRepo.GetThread(ctx, threadId)
-	if err != nil {
+	switch {
+	case err != nil:
 		return &models.ThreadWithMessages{}, errors.E(op, err, http.StatusInternalServerError)
+	case thread.Hidden:
+		err := errors.New(fmt.Sprintf("thread with id %s is hidden", threadId.String()))
+		return &models.ThreadWithMessages{}, errors.E(op, err, http.StatusNotFound)
+	}
+
+	hiddenUserIds, err := getHiddenUserIds(ctx, ss.cacheRepo, authenticatedUserId)
+	if err != nil {
+		return &models.ThreadWithMessages{}, errors.E(op, err)
 	}
 
-	var messages []models.MessageWithChildThreadMessagesCount
-	switch messagesSort {
-	case models.PopularitySorting:
-		messages, err = ss.cacheRepo.GetThreadMessagesByPopularity(ctx, threadId, messagesOffset, messagesCount)
-	case models.RecentSorting:
-		messages, err = ss.cacheRepo.GetThreadMessagesByTime(ctx, threadId, messagesOffset, messagesCount)
+	var getThreadMessages = ss.cacheRepo.GetThreadMessagesByTime
+	if messagesSort == models.PopularitySorting {
+		getThreadMessages = ss.cacheRepo.GetThreadMessagesByPopularity
 	}
+	messages, visibleMessages, nextMessagesOffset, err := fetchVisiblePage(
+		ctx,
+		func(ctx context.Context, offset, count int64) ([]models.MessageWithChildThreadMessagesCount, error) {
+			return getThreadMessages(ctx, threadId, offset, count)
+		},
+		messagesOffset,
+		messagesCount,
+		func(message models.MessageWithChildThreadMessagesCount) bool {
+			return !hiddenUserIds[message.SenderId]
+		},
+	)
 	if err != nil {
 		return &models.ThreadWithMessages{}, errors.E(op, err, http.StatusInternalServerError)
 	}
 
+	var readAt time.Time
+	for _, message := range messages {
+		if message.CreatedAt.After(readAt) {
+			readAt = message.CreatedAt
+		}
+	}
+	for i := range visibleMessages {
+		redactHiddenMessage(&visibleMessages[i].Message)
+	}
+
+	if !readAt.IsZero() {
+		if err := ss.markRead(ctx, authenticatedUserId, models.ReadMarker{SpaceId: spaceId, ThreadId: threadId, ReadAt: readAt}); err != nil {
+			return &models.ThreadWithMessages{}, errors.E(op, err)
+		}
+	}
+
 	return &models.ThreadWithMessages{
-		Thread:   *thread,
-		Messages: messages,
+		Thread:             *thread,
+		Messages:           visibleMessages,
+		NextMessagesOffset: nextMessagesOffset,
 	}, nil
 }
 
+// GetSpaceLocationPins returns the location messages of the space for its map, without the ones sent by users the
+// authenticated user has blocked or muted and the hidden ones
+func (ss *SpaceService) GetSpaceLocationPins(ctx context.Context, spaceId uuid.Uuid, offset, count int64, authenticatedUserId models.UserUid) ([]models.MessageWithChildThreadMessagesCount, error) {
+	const op errors.Op = "services.SpaceService.GetSpaceLocationPins"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	messages, err := ss.cacheRepo.GetSpaceLocationPins(ctx, spaceId, offset, count)
+	if err != nil {
+		return nil, errors.E(op, err, http.StatusInternalServerError)
+	}
+
+	hiddenUserIds, err := getHiddenUserIds(ctx, ss.cacheRepo, authenticatedUserId)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	// hidden messages have no location to show until their reports are reviewed
+	var visibleMessages = make([]models.MessageWithChildThreadMessagesCount, 0, len(messages))
+	for _, message := range messages {
+		if message.Hidden || hiddenUserIds[message.SenderId] {
+			continue
+		}
+
+		visibleMessages = append(visibleMessages, message)
+	}
+
+	return visibleMessages, nil
+}
+
+// SetReadMarker marks the space, or the thread if the marker's ThreadId is set, as read up to the marker's time. A zero
+// time marks everything as read up to now.
+func (ss *SpaceService) SetReadMarker(ctx context.Context, userId models.UserUid, readMarker models.ReadMarker) error {
+	const op errors.Op = "services.SpaceService.SetReadMarker"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	// the thread has been validated by the validateThreadInSpace middleware
+	if readMarker.ThreadId == uuid.Nil {
+		if _, err := ss.GetSpace(ctx, readMarker.SpaceId); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	var now = time.Now()
+	if readMarker.ReadAt.IsZero() || readMarker.ReadAt.After(now) {
+		readMarker.ReadAt = now
+	}
+
+	if err := ss.markRead(ctx, userId, readMarker); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// markRead moves the read marker forward and syncs it to the user's other devices
+func (ss *SpaceService) markRead(ctx context.Context, userId models.UserUid, readMarker models.ReadMarker) error {
+	const op errors.Op = "services.SpaceService.markRead"
+
+	moved, err := ss.cacheRepo.SetReadMarker(ctx, userId, readMarker)
+	if err != nil {
+		return errors.E(op, err, http.StatusInternalServerError)
+	}
+
+	if moved {
+		ss.localMemoryRepo.PublishReadMarker(userId, readMarker)
+	}
+
+	return nil
+}
+
 func (ss *SpaceService) CreateSpace(ctx context.Context, newSpace models.NewSpace) (uuid.Uuid, error) {
 	const op errors.Op = "services.SpaceService.CreateSpace"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if err := validateSpaceTimes(newSpace.BaseSpace, time.Now()); err != nil {
+		return uuid.Nil, errors.E(op, err, http.StatusBadRequest)
+	}
+
+	tags, err := models.NormalizeTags(newSpace.Tags)
+	if err != nil {
+		return uuid.Nil, errors.E(op, err, http.StatusBadRequest)
+	}
+	newSpace.Tags = tags
 
 	spaceId, err := ss.cacheRepo.SetSpace(ctx, newSpace)
 	if err != nil {
@@ -144,6 +647,8 @@ func (ss *SpaceService) CreateSpace(ctx context.Context, newSpace models.NewSpac
 
 func (ss *SpaceService) AddSpaceSubscriber(ctx context.Context, spaceId uuid.Uuid, userId models.UserUid) error {
 	const op errors.Op = "services.SpaceService.AddSpaceSubscriber"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
 
 	// verify that space exists
 	_, err := ss.GetSpace(ctx, spaceId)
@@ -151,6 +656,15 @@ func (ss *SpaceService) AddSpaceSubscriber(ctx context.Context, spaceId uuid.Uui
 		return err
 	}
 
+	isBanned, err := ss.cacheRepo.IsSpaceBannedUser(ctx, spaceId, userId)
+	switch {
+	case err != nil:
+		return errors.E(op, err, http.StatusInternalServerError)
+	case isBanned:
+		err := errors.New(fmt.Sprintf("user %s is banned from space with id %s", userId, spaceId.String()))
+		return errors.E(op, err, http.StatusForbidden)
+	}
+
 	// check if space subscriber already exists so the created at time is not overridden in the spaceSubscribersKey and userSpacesKey sorted sets
 	spaceHasSubscriber, err := ss.cacheRepo.HasSpaceSubscriber(ctx, spaceId, userId)
 	switch {
@@ -165,6 +679,138 @@ func (ss *SpaceService) AddSpaceSubscriber(ctx context.Context, spaceId uuid.Uui
 	}
 
 	ss.localMemoryRepo.PublishNewSpaceSubscriber(spaceId, userId)
+	ss.localMemoryRepo.AddUserChannelSpace(userId, spaceId)
+
+	return nil
+}
+
+// RemoveSpaceSubscriber lets the user leave the space. The admin of the space can't leave it.
+func (ss *SpaceService) RemoveSpaceSubscriber(ctx context.Context, spaceId uuid.Uuid, userId models.UserUid) error {
+	const op errors.Op = "services.SpaceService.RemoveSpaceSubscriber"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	space, err := ss.GetSpace(ctx, spaceId)
+	switch {
+	case err != nil:
+		return err
+	case space.AdminId == userId:
+		err := errors.New("the admin of a space can't leave it")
+		return errors.E(op, err, http.StatusForbidden)
+	}
+
+	if err := ss.cacheRepo.DeleteSpaceSubscriber(ctx, spaceId, userId); err != nil {
+		return errors.E(op, err, http.StatusInternalServerError)
+	}
+
+	ss.localMemoryRepo.RemoveUserChannelSpace(userId, spaceId)
+
+	return nil
+}
+
+// RunSpaceScheduler opens, closes and archives the time-bounded spaces whose transition time has passed every interval
+// until ctx is cancelled
+func (ss *SpaceService) RunSpaceScheduler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := ss.runDueSpaceTransitions(ctx); err != nil {
+				ss.logger.Error(err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (ss *SpaceService) runDueSpaceTransitions(ctx context.Context) error {
+	const op errors.Op = "services.SpaceService.runDueSpaceTransitions"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	for {
+		transitions, err := ss.cacheRepo.GetDueSpaceTransitions(ctx, time.Now(), spaceSchedulerBatchSize)
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		for _, transition := range transitions {
+			if err := ss.runSpaceTransition(ctx, transition); err != nil {
+				return errors.E(op, err)
+			}
+		}
+
+		if len(transitions) < spaceSchedulerBatchSize {
+			return nil
+		}
+	}
+}
+
+// runSpaceTransition opens, closes or archives the space, depending on which of its times the transition is scheduled
+// at, and schedules the space's next transition. The updates are only published by the server instance that moved the
+// transition.
+func (ss *SpaceService) runSpaceTransition(ctx context.Context, transition models.SpaceTransition) error {
+	const op errors.Op = "services.SpaceService.runSpaceTransition"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	// the space might have been removed in the meantime
+	space, err := ss.cacheRepo.GetSpace(ctx, transition.SpaceId)
+	switch {
+	case errors.Is(err, common.ErrNotFound):
+		space = &models.Space{}
+	case err != nil:
+		return errors.E(op, err)
+	}
+
+	var at = transition.At.UnixMilli()
+	var status models.SpaceStatus
+	var next *time.Time
+	switch {
+	case space.StartsAt != nil && space.StartsAt.UnixMilli() == at:
+		// showing the space on the map is idempotent as well
+		if err := ss.cacheRepo.UpdateSpaceTileEntry(ctx, transition.SpaceId); err != nil {
+			return errors.E(op, err)
+		}
+		status, next = models.SpaceOpened, space.EndsAt
+	case space.EndsAt != nil && space.EndsAt.UnixMilli() == at:
+		archivedAt := space.EndsAt.Add(endedSpaceRetention)
+		status, next = models.SpaceClosed, &archivedAt
+	case space.EndsAt != nil && space.EndsAt.Add(endedSpaceRetention).UnixMilli() == at:
+		// archiving is idempotent, so it is done before the transition is removed to not get lost if it fails
+		if err := ss.cacheRepo.ArchiveSpace(ctx, transition.SpaceId); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	moved, err := ss.cacheRepo.MoveSpaceTransition(ctx, transition, next)
+	switch {
+	case err != nil:
+		return errors.E(op, err)
+	case moved && status != "":
+		ss.localMemoryRepo.PublishSpaceStatus(transition.SpaceId, status)
+	}
+
+	return nil
+}
+
+// validateSpaceTimes ensures that time-bounded spaces end after they start, and within the maximum duration from now
+func validateSpaceTimes(space models.BaseSpace, now time.Time) error {
+	const op errors.Op = "services.validateSpaceTimes"
+
+	switch {
+	case space.StartsAt != nil && space.EndsAt != nil && !space.EndsAt.After(*space.StartsAt):
+		return errors.E(op, errors.New("spaces have to end after they start"))
+	case space.EndsAt != nil && (!space.EndsAt.After(now) || space.EndsAt.Sub(now) > maxSpaceDuration):
+		err := errors.New(fmt.Sprintf("spaces have to end within %s", maxSpaceDuration))
+		return errors.E(op, err)
+	case space.StartsAt != nil && space.StartsAt.Sub(now) > maxSpaceDuration:
+		err := errors.New(fmt.Sprintf("spaces have to start within %s", maxSpaceDuration))
+		return errors.E(op, err)
+	}
 
 	return nil
 }
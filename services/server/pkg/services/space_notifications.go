@@ -7,26 +7,41 @@ import (
 	"spaces-p/pkg/errors"
 	"spaces-p/pkg/models"
 	localmemory "spaces-p/pkg/repositories/local_memory"
+	"spaces-p/pkg/tracing"
 	"spaces-p/pkg/uuid"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"nhooyr.io/websocket"
 	"nhooyr.io/websocket/wsjson"
 )
 
+const (
+	// sessions whose heartbeat is older than SessionTTL are considered stale and get removed by the session reaper
+	SessionTTL               = 30 * time.Second
+	SessionReaperInterval    = 15 * time.Second
+	sessionHeartbeatInterval = 10 * time.Second
+	sessionReaperBatchSize   = 100
+	sessionCleanupTimeout    = 5 * time.Second
+)
+
 type SpaceNotificationsService struct {
 	logger          common.Logger
 	cacheRepo       common.CacheRepository
 	localMemoryRepo *localmemory.LocalMemoryRepo
+	instanceId      string
 }
 
-func NewSpaceNotificationsService(logger common.Logger, cacheRepo common.CacheRepository, localMemoryRepo *localmemory.LocalMemoryRepo) *SpaceNotificationsService {
-	return &SpaceNotificationsService{logger, cacheRepo, localMemoryRepo}
+func NewSpaceNotificationsService(logger common.Logger, cacheRepo common.CacheRepository, localMemoryRepo *localmemory.LocalMemoryRepo, instanceId string) *SpaceNotificationsService {
+	return &SpaceNotificationsService{logger, cacheRepo, localMemoryRepo, instanceId}
 }
 
-func (ss *SpaceNotificationsService) SpaceConnect(ctx context.Context, c *gin.Context, spaceId uuid.Uuid, authenticatedUser models.User) error {
+func (ss *SpaceNotificationsService) SpaceConnect(ctx context.Context, c *gin.Context, spaceId uuid.Uuid, authenticatedUser models.User, onUpgrade func()) error {
 	const op errors.Op = "services.SpaceNotificationsService.SpaceConnect"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
 
 	conn, err := websocket.Accept(c.Writer, c.Request, &websocket.AcceptOptions{
 		OriginPatterns: []string{"*"},
@@ -34,58 +49,186 @@ func (ss *SpaceNotificationsService) SpaceConnect(ctx context.Context, c *gin.Co
 	if err != nil {
 		return errors.E(op, err, http.StatusBadRequest)
 	}
-	// CHECK: will the closing status not always be StatusInternalError?
-	defer conn.Close(websocket.StatusInternalError, "")
-	err = ss.subscribe(ctx, conn, spaceId, authenticatedUser.ID)
-	return errors.E(op, err)
+
+	// the request is done once the connection has been upgraded, the session is traced on its own
+	span.End()
+	onUpgrade()
+
+	if err := ss.subscribe(ctx, conn, spaceId, authenticatedUser.ID); err != nil {
+		conn.Close(websocket.StatusInternalError, "")
+		return errors.E(op, err)
+	}
+
+	// no-op if the connection has been closed already, e.g. by the client or with StatusGoingAway while draining
+	conn.Close(websocket.StatusNormalClosure, "")
+	return nil
 }
 
-func (ss *SpaceNotificationsService) subscribe(ctx context.Context, conn *websocket.Conn, spaceId uuid.Uuid, userId models.UserUid) error {
+func (ss *SpaceNotificationsService) subscribe(ctx context.Context, conn *websocket.Conn, spaceId uuid.Uuid, userId models.UserUid) (err error) {
 	const op errors.Op = "services.SpaceNotificationsService.subscribe"
+	ctx, span := tracing.StartSession(ctx, op)
+	defer func() {
+		if err != nil {
+			tracing.RecordError(ctx, err)
+		}
+		span.End()
+	}()
 
+	// clients only receive updates on the space connection, so there are no inbound messages to rate limit: the
+	// first data message a client sends closes the connection with StatusPolicyViolation
 	ctx = conn.CloseRead(ctx)
 
+	hiddenUserIds, err := ss.cacheRepo.GetUserHiddenUsers(ctx, userId)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
 	session := ss.localMemoryRepo.AddSession(localmemory.NewSessionInput{
 		SpaceId:         spaceId,
 		UserId:          userId,
-		NotificationsCh: make(chan models.SpaceUpdate, localmemory.NotificationsBufferSize),
+		NotificationsCh: make(chan models.SpaceUpdate, ss.localMemoryRepo.Config().NotificationsBufferSize),
 		CloseSlow: func() {
 			conn.Close(websocket.StatusInternalError, "")
 		},
+		GoAway: func() {
+			conn.Close(websocket.StatusGoingAway, "server going away, reconnect")
+		},
+		HiddenUserIds: hiddenUserIds,
 	})
 	defer ss.localMemoryRepo.DeleteSession(session.SpaceId, session.SessionId)
 
-	if err := ss.cacheRepo.SetSpaceSubscriberSession(ctx, session.SpaceId, session.UserId, session.SessionId); err != nil {
+	if err := ss.cacheRepo.SetSpaceSubscriberSession(ctx, session.SpaceId, session.UserId, session.SessionId, ss.instanceId, SessionTTL); err != nil {
 		return errors.E(op, err)
 	}
 
 	ss.localMemoryRepo.PublishNewActiveSpaceSubscriber(session.SpaceId, session.UserId)
 
 	defer func() {
-		if ss.cacheRepo.DeleteSpaceSubscriberSession(ctx, session.SpaceId, session.UserId, session.SessionId) != nil {
-			return
+		// ctx is already cancelled when the connection has been closed
+		cleanupCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sessionCleanupTimeout)
+		defer cancel()
+
+		removed, err := ss.cacheRepo.DeleteSpaceSubscriberSession(cleanupCtx, session.SpaceId, session.UserId, session.SessionId)
+		switch {
+		case err != nil:
+			common.LoggerFromContext(ctx, ss.logger).Error(errors.E(op, err))
+		case removed:
+			ss.localMemoryRepo.PublishRemoveActiveSpaceSubscriber(session.SpaceId, session.UserId)
 		}
-
-		ss.localMemoryRepo.PublishRemoveActiveSpaceSubscriber(session.SpaceId, session.UserId)
 	}()
 
+	heartbeatTicker := time.NewTicker(sessionHeartbeatInterval)
+	defer heartbeatTicker.Stop()
+
 	for {
 		select {
 		case spaceUpdate := <-session.NotificationsCh:
-			err := writeWithTimeout(ctx, 5*time.Second, conn, spaceUpdate)
-			if err != nil {
+			err := writeWithTimeout(ctx, ss.localMemoryRepo.Config().WriteTimeout, conn, spaceUpdate)
+			switch {
+			case err != nil && isConnectionClosed(ctx, err):
+				return nil
+			case err != nil:
+				return errors.E(op, err)
+			}
+		case <-heartbeatTicker.C:
+			if err := ss.refreshSession(ctx, session); err != nil {
 				return errors.E(op, err)
 			}
 		case <-ctx.Done():
-			return errors.E(op, ctx.Err())
+			// the client closed the connection, or the server closed it because the client was too slow or because
+			// the server is draining
+			return nil
+		}
+	}
+}
+
+// refreshSession sends the session's heartbeat. A session that has been reaped in the meantime
+// (e.g. because redis was unreachable for longer than SessionTTL) is registered again.
+func (ss *SpaceNotificationsService) refreshSession(ctx context.Context, session *localmemory.Session) error {
+	const op errors.Op = "services.SpaceNotificationsService.refreshSession"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	err := ss.cacheRepo.RefreshSpaceSubscriberSession(ctx, session.SessionId, SessionTTL)
+	switch {
+	case errors.Is(err, common.ErrNotFound):
+		if err := ss.cacheRepo.SetSpaceSubscriberSession(ctx, session.SpaceId, session.UserId, session.SessionId, ss.instanceId, SessionTTL); err != nil {
+			return errors.E(op, err)
+		}
+
+		ss.localMemoryRepo.PublishNewActiveSpaceSubscriber(session.SpaceId, session.UserId)
+	case err != nil:
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// RunSessionReaper removes sessions whose heartbeat has expired every interval until ctx is cancelled.
+// Sessions expire when the owning server instance dies without cleaning them up.
+func (ss *SpaceNotificationsService) RunSessionReaper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := ss.reapExpiredSessions(ctx); err != nil {
+				ss.logger.Error(err)
+			}
+		case <-ctx.Done():
+			return
 		}
 	}
 }
 
+func (ss *SpaceNotificationsService) reapExpiredSessions(ctx context.Context) error {
+	const op errors.Op = "services.SpaceNotificationsService.reapExpiredSessions"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	for {
+		sessions, err := ss.cacheRepo.GetExpiredSpaceSubscriberSessions(ctx, time.Now(), sessionReaperBatchSize)
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		for _, session := range sessions {
+			removed, err := ss.cacheRepo.DeleteSpaceSubscriberSession(ctx, session.SpaceId, session.UserId, session.ID)
+			if err != nil {
+				return errors.E(op, err)
+			}
+
+			ss.logger.With(common.Fields{
+				common.SpaceIdLogField: session.SpaceId,
+				common.UserIdLogField:  session.UserId,
+				"session_id":           session.ID,
+				"instance_id":          session.InstanceId,
+			}).Info("reaped expired session")
+
+			if removed {
+				ss.localMemoryRepo.PublishRemoveActiveSpaceSubscriber(session.SpaceId, session.UserId)
+			}
+		}
+
+		if len(sessions) < sessionReaperBatchSize {
+			return nil
+		}
+	}
+}
+
+// isConnectionClosed reports whether reading from or writing to the connection failed only because it has been closed
+// in the meantime, either with a close frame or by cancelling ctx, e.g. the context returned by conn.CloseRead
+func isConnectionClosed(ctx context.Context, err error) bool {
+	return ctx.Err() != nil || websocket.CloseStatus(err) != -1
+}
+
 func writeWithTimeout(ctx context.Context, timeout time.Duration, conn *websocket.Conn, spaceUpdate models.SpaceUpdate) error {
 	const op errors.Op = "services.writeWithTimeout"
+	ctx, span := tracing.Start(ctx, op, trace.WithAttributes(attribute.String("space_update.type", spaceUpdate.GetType().String())))
+	defer span.End()
 
-	ctx, cancel := context.WithTimeout(ctx, 5*timeout)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
 	err := wsjson.Write(ctx, conn, spaceUpdate)
@@ -2,27 +2,35 @@ package services
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"slices"
 	"spaces-p/pkg/common"
 	"spaces-p/pkg/errors"
 	"spaces-p/pkg/models"
+	"spaces-p/pkg/moderation"
 	localmemory "spaces-p/pkg/repositories/local_memory"
+	"spaces-p/pkg/tracing"
 	"spaces-p/pkg/uuid"
 	"time"
 )
 
 type ThreadService struct {
-	logger          common.Logger
-	cacheRepo       common.CacheRepository
-	localMemoryRepo *localmemory.LocalMemoryRepo
+	logger             common.Logger
+	cacheRepo          common.CacheRepository
+	localMemoryRepo    *localmemory.LocalMemoryRepo
+	moderationPipeline *moderation.Pipeline
+	addressService     *AddressService
 }
 
-func NewThreadService(logger common.Logger, cacheRepo common.CacheRepository, localMemoryRepo *localmemory.LocalMemoryRepo) *ThreadService {
-	return &ThreadService{logger, cacheRepo, localMemoryRepo}
+func NewThreadService(logger common.Logger, cacheRepo common.CacheRepository, localMemoryRepo *localmemory.LocalMemoryRepo, moderationPipeline *moderation.Pipeline, addressService *AddressService) *ThreadService {
+	return &ThreadService{logger, cacheRepo, localMemoryRepo, moderationPipeline, addressService}
 }
 
 func (ts *ThreadService) CreateThread(ctx context.Context, spaceId, parentMessageId uuid.Uuid, authenticatedUserId models.UserUid) (uuid.Uuid, error) {
 	const op errors.Op = "services.ThreadService.CreateThread"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
 
 	m, err := ts.cacheRepo.GetMessage(ctx, parentMessageId)
 	switch {
@@ -35,26 +43,159 @@ func (ts *ThreadService) CreateThread(ctx context.Context, spaceId, parentMessag
 		return uuid.Nil, errors.E(op, err, http.StatusBadRequest)
 	}
 
+	if err := ensureNotBlocked(ctx, ts.cacheRepo, m.SenderId, authenticatedUserId); err != nil {
+		return uuid.Nil, errors.E(op, err)
+	}
+
 	var createdAt = time.Now()
 	thread, err := ts.cacheRepo.SetThread(ctx, spaceId, parentMessageId, createdAt)
 	if err != nil {
 		return uuid.Nil, errors.E(op, err, http.StatusInternalServerError)
 	}
 
+	// the creator of a child thread follows it like the author of a toplevel thread
+	if err := ts.cacheRepo.SetThreadFollower(ctx, thread.ID, authenticatedUserId); err != nil {
+		return uuid.Nil, errors.E(op, err, http.StatusInternalServerError)
+	}
+
 	ts.localMemoryRepo.PublishNewThread(spaceId, authenticatedUserId, *thread)
 
 	return thread.ID, nil
 }
 
-func (ts *ThreadService) CreateTopLevelThread(ctx context.Context, spaceId uuid.Uuid, newTopLevelThreadFirstMessage models.NewTopLevelThreadFirstMessage) (uuid.Uuid, uuid.Uuid, error) {
+// CreateTopLevelThread returns the ids of the created thread and its first message, or the held first message if
+// moderation put it into the review queue
+func (ts *ThreadService) CreateTopLevelThread(ctx context.Context, spaceId uuid.Uuid, newTopLevelThreadFirstMessage models.NewTopLevelThreadFirstMessage) (uuid.Uuid, uuid.Uuid, *models.HeldMessage, error) {
 	const op errors.Op = "services.ThreadService.CreateTopLevelThread"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	var firstMessage = models.NewMessage{
+		BaseMessage: models.BaseMessage(newTopLevelThreadFirstMessage.NewMessageInput),
+		SenderId:    newTopLevelThreadFirstMessage.SenderId,
+	}
+
+	if err := validateMessage(ctx, ts.cacheRepo, spaceId, firstMessage); err != nil {
+		return uuid.Nil, uuid.Nil, nil, errors.E(op, err)
+	}
+
+	// the first message shares its payload with the new thread's first message
+	if err := resolveLocationPinAddress(ctx, ts.addressService, firstMessage); err != nil {
+		return uuid.Nil, uuid.Nil, nil, errors.E(op, err)
+	}
+
+	// held messages keep their attachment until they are reviewed
+	if err := attachMessageAttachment(ctx, ts.cacheRepo, firstMessage); err != nil {
+		return uuid.Nil, uuid.Nil, nil, errors.E(op, err)
+	}
+
+	heldMessage, err := moderateMessage(ctx, ts.moderationPipeline, ts.cacheRepo, spaceId, firstMessage)
+	switch {
+	case err != nil:
+		detachMessageAttachment(ctx, ts.logger, ts.cacheRepo, firstMessage)
+		return uuid.Nil, uuid.Nil, nil, errors.E(op, err)
+	case heldMessage != nil:
+		return uuid.Nil, uuid.Nil, heldMessage, nil
+	}
 
 	createdTopLevelThread, createdFirstMessage, err := ts.cacheRepo.SetTopLevelThread(ctx, spaceId, newTopLevelThreadFirstMessage)
 	if err != nil {
+		detachMessageAttachment(ctx, ts.logger, ts.cacheRepo, firstMessage)
+		return uuid.Nil, uuid.Nil, nil, errors.E(op, err, http.StatusInternalServerError)
+	}
+
+	publishStoredMessage(ctx, ts.logger, ts.cacheRepo, ts.localMemoryRepo, spaceId, *createdFirstMessage, createdTopLevelThread)
+
+	return createdTopLevelThread.ID, createdFirstMessage.ID, nil, nil
+}
+
+// CreateAnnouncement starts a toplevel thread flagged as announcement, pins it and delivers its first message to the
+// inbox of every subscriber of the space in the background. It returns the ids of the created thread and its first
+// message.
+//
+// Announcements skip moderation: they are posted by space admins or moderators, who review the held messages of the
+// space themselves, and an announcement held for review would lose its pin and its delivery once approved.
+func (ts *ThreadService) CreateAnnouncement(ctx context.Context, spaceId uuid.Uuid, newTopLevelThreadFirstMessage models.NewTopLevelThreadFirstMessage) (uuid.Uuid, uuid.Uuid, error) {
+	const op errors.Op = "services.ThreadService.CreateAnnouncement"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	var firstMessage = models.NewMessage{
+		BaseMessage: models.BaseMessage(newTopLevelThreadFirstMessage.NewMessageInput),
+		SenderId:    newTopLevelThreadFirstMessage.SenderId,
+	}
+
+	if err := validateMessage(ctx, ts.cacheRepo, spaceId, firstMessage); err != nil {
+		return uuid.Nil, uuid.Nil, errors.E(op, err)
+	}
+
+	if err := resolveLocationPinAddress(ctx, ts.addressService, firstMessage); err != nil {
+		return uuid.Nil, uuid.Nil, errors.E(op, err)
+	}
+
+	// an announcement that could not stay at the top would be missed by those who don't check their inbox
+	pins, err := ts.cacheRepo.GetSpacePins(ctx, spaceId)
+	switch {
+	case err != nil:
 		return uuid.Nil, uuid.Nil, errors.E(op, err, http.StatusInternalServerError)
+	case len(pins) >= maxSpacePins:
+		err := fmt.Errorf("%w: unpin an item before posting an announcement", common.ErrPinLimitReached)
+		return uuid.Nil, uuid.Nil, errors.E(op, err, http.StatusConflict)
 	}
 
-	ts.localMemoryRepo.PublishNewToplevelThread(spaceId, newTopLevelThreadFirstMessage.SenderId, *createdTopLevelThread)
+	if err := attachMessageAttachment(ctx, ts.cacheRepo, firstMessage); err != nil {
+		return uuid.Nil, uuid.Nil, errors.E(op, err)
+	}
+
+	newTopLevelThreadFirstMessage.Announcement = true
+	createdTopLevelThread, createdFirstMessage, err := ts.cacheRepo.SetTopLevelThread(ctx, spaceId, newTopLevelThreadFirstMessage)
+	if err != nil {
+		detachMessageAttachment(ctx, ts.logger, ts.cacheRepo, firstMessage)
+		return uuid.Nil, uuid.Nil, errors.E(op, err, http.StatusInternalServerError)
+	}
+
+	publishStoredMessage(ctx, ts.logger, ts.cacheRepo, ts.localMemoryRepo, spaceId, *createdFirstMessage, createdTopLevelThread)
+
+	// the pins might have filled up concurrently, the announcement is delivered anyway
+	var pin = models.Pin{TargetType: models.ThreadPinTarget, TargetId: createdTopLevelThread.ID, PinnedAt: createdTopLevelThread.CreatedAt}
+	if err := pinItem(ctx, ts.cacheRepo, ts.localMemoryRepo, spaceId, newTopLevelThreadFirstMessage.SenderId, pin); err != nil {
+		common.LoggerFromContext(ctx, ts.logger).Error(errors.E(op, err))
+	}
+
+	// delivering to every subscriber takes a while in large spaces, so it must not hold up the response nor be cancelled
+	// along with the request
+	go ts.deliverAnnouncement(context.WithoutCancel(ctx), spaceId, *createdFirstMessage)
 
 	return createdTopLevelThread.ID, createdFirstMessage.ID, nil
 }
+
+// deliverAnnouncement delivers the first message of an announcement to the inbox of every subscriber of the space. The
+// announcement has been posted already, so a failure is only logged.
+func (ts *ThreadService) deliverAnnouncement(ctx context.Context, spaceId uuid.Uuid, firstMessage models.Message) {
+	const op errors.Op = "services.ThreadService.deliverAnnouncement"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	subscriberIds, err := ts.cacheRepo.GetSpaceSubscriberIds(ctx, spaceId)
+	if err != nil {
+		common.LoggerFromContext(ctx, ts.logger).Error(errors.E(op, err))
+		return
+	}
+
+	// subscribers who have blocked or muted the sender don't see the announcement when they read their inbox
+	var recipientIds = slices.DeleteFunc(subscriberIds, func(subscriberId models.UserUid) bool {
+		return subscriberId == firstMessage.SenderId
+	})
+	if err := ts.cacheRepo.AddInboxMessage(ctx, recipientIds, firstMessage, maxInboxSize); err != nil {
+		common.LoggerFromContext(ctx, ts.logger).Error(errors.E(op, err))
+		return
+	}
+
+	var inboxMessage = models.InboxMessage{
+		MessageWithChildThreadMessagesCount: models.MessageWithChildThreadMessagesCount{Message: firstMessage},
+		SpaceId:                             spaceId,
+	}
+	for _, recipientId := range recipientIds {
+		ts.localMemoryRepo.PublishInboxMessage(recipientId, inboxMessage)
+	}
+}
@@ -2,23 +2,29 @@ package services
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"spaces-p/pkg/common"
 	"spaces-p/pkg/errors"
 	"spaces-p/pkg/models"
+	localmemory "spaces-p/pkg/repositories/local_memory"
+	"spaces-p/pkg/tracing"
 )
 
 type UserService struct {
-	logger    common.Logger
-	cacheRepo common.CacheRepository
+	logger          common.Logger
+	cacheRepo       common.CacheRepository
+	localMemoryRepo *localmemory.LocalMemoryRepo
 }
 
-func NewUserService(logger common.Logger, cacheRepo common.CacheRepository) *UserService {
-	return &UserService{logger, cacheRepo}
+func NewUserService(logger common.Logger, cacheRepo common.CacheRepository, localMemoryRepo *localmemory.LocalMemoryRepo) *UserService {
+	return &UserService{logger, cacheRepo, localMemoryRepo}
 }
 
 func (us *UserService) GetUser(ctx context.Context, userId models.UserUid) (*models.User, error) {
 	const op errors.Op = "services.UserService.GetUser"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
 
 	user, err := us.cacheRepo.GetUserById(ctx, userId)
 	switch {
@@ -34,6 +40,8 @@ func (us *UserService) GetUser(ctx context.Context, userId models.UserUid) (*mod
 
 func (us *UserService) CreateUser(ctx context.Context, newUser models.NewUser) error {
 	const op errors.Op = "services.UserService.CreateUser"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
 
 	if err := us.cacheRepo.SetUser(ctx, newUser); err != nil {
 		return errors.E(op, err, http.StatusInternalServerError)
@@ -47,6 +55,8 @@ func (us *UserService) CreateUser(ctx context.Context, newUser models.NewUser) e
 // In case a user already exists, CreateUser basically becomes a no-op and returns that existing user.
 func (us *UserService) CreateUserFromIdToken(ctx context.Context, authClient common.AuthClient, idToken string) (*models.User, error) {
 	const op errors.Op = "services.UserService.CreateUser"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
 
 	userToken, err := authClient.VerifyToken(ctx, idToken)
 	if err != nil {
@@ -77,3 +87,151 @@ func (us *UserService) CreateUserFromIdToken(ctx context.Context, authClient com
 
 	return user, nil
 }
+
+// BlockUser hides the blocked user's messages and threads from the user and keeps the blocked user from liking and
+// replying to the user's messages
+func (us *UserService) BlockUser(ctx context.Context, userId, blockedUserId models.UserUid) error {
+	const op errors.Op = "services.UserService.BlockUser"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if err := us.validateOtherUser(ctx, userId, blockedUserId); err != nil {
+		return errors.E(op, err)
+	}
+
+	if err := us.cacheRepo.SetUserBlockedUser(ctx, userId, blockedUserId); err != nil {
+		return errors.E(op, err, http.StatusInternalServerError)
+	}
+
+	if err := us.updateSessionsHiddenUsers(ctx, userId); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+func (us *UserService) UnblockUser(ctx context.Context, userId, blockedUserId models.UserUid) error {
+	const op errors.Op = "services.UserService.UnblockUser"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if err := us.cacheRepo.DeleteUserBlockedUser(ctx, userId, blockedUserId); err != nil {
+		return errors.E(op, err, http.StatusInternalServerError)
+	}
+
+	if err := us.updateSessionsHiddenUsers(ctx, userId); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// MuteUser hides the muted user's messages and threads from the user. Unlike blocked users, muted users can still
+// interact with the user's messages.
+func (us *UserService) MuteUser(ctx context.Context, userId, mutedUserId models.UserUid) error {
+	const op errors.Op = "services.UserService.MuteUser"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if err := us.validateOtherUser(ctx, userId, mutedUserId); err != nil {
+		return errors.E(op, err)
+	}
+
+	if err := us.cacheRepo.SetUserMutedUser(ctx, userId, mutedUserId); err != nil {
+		return errors.E(op, err, http.StatusInternalServerError)
+	}
+
+	if err := us.updateSessionsHiddenUsers(ctx, userId); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+func (us *UserService) UnmuteUser(ctx context.Context, userId, mutedUserId models.UserUid) error {
+	const op errors.Op = "services.UserService.UnmuteUser"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if err := us.cacheRepo.DeleteUserMutedUser(ctx, userId, mutedUserId); err != nil {
+		return errors.E(op, err, http.StatusInternalServerError)
+	}
+
+	if err := us.updateSessionsHiddenUsers(ctx, userId); err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// validateOtherUser ensures that the other user exists and is not the user
+func (us *UserService) validateOtherUser(ctx context.Context, userId, otherUserId models.UserUid) error {
+	const op errors.Op = "services.UserService.validateOtherUser"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if userId == otherUserId {
+		err := errors.New("users cannot block or mute themselves")
+		return errors.E(op, err, http.StatusBadRequest)
+	}
+
+	_, err := us.cacheRepo.GetUserById(ctx, otherUserId)
+	switch {
+	case errors.Is(err, common.ErrNotFound):
+		return errors.E(op, err, http.StatusNotFound)
+	case err != nil:
+		return errors.E(op, err, http.StatusInternalServerError)
+	}
+
+	return nil
+}
+
+// updateSessionsHiddenUsers applies the user's current blocks and mutes to the user's open sessions on this server instance
+func (us *UserService) updateSessionsHiddenUsers(ctx context.Context, userId models.UserUid) error {
+	const op errors.Op = "services.UserService.updateSessionsHiddenUsers"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	hiddenUserIds, err := us.cacheRepo.GetUserHiddenUsers(ctx, userId)
+	if err != nil {
+		return errors.E(op, err, http.StatusInternalServerError)
+	}
+
+	us.localMemoryRepo.SetUserHiddenUsers(userId, hiddenUserIds)
+
+	return nil
+}
+
+// getHiddenUserIds returns the set of users the user has blocked or muted
+func getHiddenUserIds(ctx context.Context, cacheRepo common.CacheRepository, userId models.UserUid) (map[models.UserUid]bool, error) {
+	const op errors.Op = "services.getHiddenUserIds"
+
+	hiddenUserIds, err := cacheRepo.GetUserHiddenUsers(ctx, userId)
+	if err != nil {
+		return nil, errors.E(op, err, http.StatusInternalServerError)
+	}
+
+	var hiddenUserIdSet = make(map[models.UserUid]bool, len(hiddenUserIds))
+	for _, hiddenUserId := range hiddenUserIds {
+		hiddenUserIdSet[hiddenUserId] = true
+	}
+
+	return hiddenUserIdSet, nil
+}
+
+// ensureNotBlocked returns a forbidden error if the author has blocked the user who wants to interact with the
+// author's message
+func ensureNotBlocked(ctx context.Context, cacheRepo common.CacheRepository, authorId, userId models.UserUid) error {
+	const op errors.Op = "services.ensureNotBlocked"
+
+	isBlocked, err := cacheRepo.HasUserBlockedUser(ctx, authorId, userId)
+	switch {
+	case err != nil:
+		return errors.E(op, err, http.StatusInternalServerError)
+	case isBlocked:
+		err := errors.New(fmt.Sprintf("user %s has been blocked by user %s", userId, authorId))
+		return errors.E(op, err, http.StatusForbidden)
+	}
+
+	return nil
+}
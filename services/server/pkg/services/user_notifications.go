@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"spaces-p/pkg/common"
+	"spaces-p/pkg/errors"
+	"spaces-p/pkg/models"
+	localmemory "spaces-p/pkg/repositories/local_memory"
+	"spaces-p/pkg/tracing"
+
+	"github.com/gin-gonic/gin"
+	"nhooyr.io/websocket"
+	"nhooyr.io/websocket/wsjson"
+)
+
+// UserNotificationsService serves the users' own update channels, which get the updates addressed to the user
+// regardless of the space they happen in, e.g. new messages in followed threads, and the updates of all the user's
+// spaces over a single connection
+type UserNotificationsService struct {
+	logger          common.Logger
+	cacheRepo       common.CacheRepository
+	localMemoryRepo *localmemory.LocalMemoryRepo
+	filterRateLimit models.RateLimitPolicy
+}
+
+func NewUserNotificationsService(logger common.Logger, cacheRepo common.CacheRepository, localMemoryRepo *localmemory.LocalMemoryRepo, filterRateLimit models.RateLimitPolicy) *UserNotificationsService {
+	return &UserNotificationsService{logger, cacheRepo, localMemoryRepo, filterRateLimit}
+}
+
+func (us *UserNotificationsService) UserConnect(ctx context.Context, c *gin.Context, authenticatedUser models.User, onUpgrade func()) error {
+	const op errors.Op = "services.UserNotificationsService.UserConnect"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	conn, err := websocket.Accept(c.Writer, c.Request, &websocket.AcceptOptions{
+		OriginPatterns: []string{"*"},
+	})
+	if err != nil {
+		return errors.E(op, err, http.StatusBadRequest)
+	}
+
+	// the request is done once the connection has been upgraded, the session is traced on its own
+	span.End()
+	onUpgrade()
+
+	if err := us.subscribe(ctx, conn, authenticatedUser.ID); err != nil {
+		conn.Close(websocket.StatusInternalError, "")
+		return errors.E(op, err)
+	}
+
+	// no-op if the connection has been closed already, e.g. by the client or with StatusGoingAway while draining
+	conn.Close(websocket.StatusNormalClosure, "")
+	return nil
+}
+
+// subscribe writes the updates of the user's channel to the connection while the client may send filters to choose
+// the spaces it gets updates of. Unlike space sessions, user sessions are not registered in redis, so they don't make
+// the user an active subscriber of the multiplexed spaces.
+func (us *UserNotificationsService) subscribe(ctx context.Context, conn *websocket.Conn, userId models.UserUid) (err error) {
+	const op errors.Op = "services.UserNotificationsService.subscribe"
+	ctx, span := tracing.StartSession(ctx, op)
+	defer func() {
+		if err != nil {
+			tracing.RecordError(ctx, err)
+		}
+		span.End()
+	}()
+
+	hiddenUserIds, err := us.cacheRepo.GetUserHiddenUsers(ctx, userId)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	spaceIds, err := us.cacheRepo.GetUserSpaceIds(ctx, userId)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	session := us.localMemoryRepo.AddUserSession(localmemory.NewSessionInput{
+		UserId:          userId,
+		NotificationsCh: make(chan models.SpaceUpdate, us.localMemoryRepo.Config().NotificationsBufferSize),
+		CloseSlow: func() {
+			conn.Close(websocket.StatusInternalError, "")
+		},
+		GoAway: func() {
+			conn.Close(websocket.StatusGoingAway, "server going away, reconnect")
+		},
+		HiddenUserIds: hiddenUserIds,
+		SpaceIds:      spaceIds,
+	})
+	defer us.localMemoryRepo.DeleteUserSession(session.UserId, session.SessionId)
+
+	var readErrCh = make(chan error, 1)
+	go func() {
+		readErrCh <- us.readFilters(ctx, conn, session)
+	}()
+
+	for {
+		select {
+		case update := <-session.NotificationsCh:
+			err := writeWithTimeout(ctx, us.localMemoryRepo.Config().WriteTimeout, conn, update)
+			switch {
+			case err != nil && isConnectionClosed(ctx, err):
+				return nil
+			case err != nil:
+				return errors.E(op, err)
+			}
+		case err := <-readErrCh:
+			if isConnectionClosed(ctx, err) {
+				return nil
+			}
+			return errors.E(op, err)
+		case <-ctx.Done():
+			// the server closed the connection because the client was too slow or because the server is draining
+			return nil
+		}
+	}
+}
+
+// readFilters applies the filters sent by the client until the connection fails, sends something else than a filter or
+// exceeds the filter rate limit. Filters are counted per user, so reconnecting doesn't reset the limit.
+func (us *UserNotificationsService) readFilters(ctx context.Context, conn *websocket.Conn, session *localmemory.Session) error {
+	const op errors.Op = "services.UserNotificationsService.readFilters"
+	var subject = "user:" + string(session.UserId)
+
+	for {
+		var filter models.UserUpdatesFilter
+		if err := wsjson.Read(ctx, conn, &filter); err != nil {
+			return errors.E(op, err)
+		}
+
+		allowed, _, err := us.cacheRepo.TakeRateLimitToken(ctx, us.filterRateLimit, subject)
+		switch {
+		case err != nil:
+			// an unavailable rate limiter must not break the connection
+			common.LoggerFromContext(ctx, us.logger).Error(errors.E(op, err))
+		case !allowed:
+			conn.Close(websocket.StatusPolicyViolation, "too many filters, reconnect later")
+			err := fmt.Errorf("rate limit %s of %d per %s exceeded by %s", us.filterRateLimit.Name, us.filterRateLimit.Limit, us.filterRateLimit.Window, subject)
+			return errors.E(op, err)
+		}
+
+		us.localMemoryRepo.SetUserSessionSpaceFilter(session.UserId, session.SessionId, filter.SpaceIds)
+	}
+}
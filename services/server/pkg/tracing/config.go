@@ -0,0 +1,74 @@
+package tracing
+
+import (
+	"fmt"
+	"spaces-p/pkg/errors"
+	"spaces-p/pkg/utils"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+type Exporter string
+
+const (
+	// tracing is disabled, spans are created but never recorded
+	NoExporter Exporter = "none"
+	// spans are sent to an OTLP receiver over HTTP, e.g. the OpenTelemetry collector or Jaeger
+	OTLPExporter Exporter = "otlp"
+	// spans are pretty printed to stdout, only meant for local development
+	StdoutExporter Exporter = "stdout"
+)
+
+type Config struct {
+	Exporter     Exporter
+	OTLPEndpoint string // host:port of the OTLP HTTP receiver, the exporter's default is used when empty
+	OTLPInsecure bool   // send spans without TLS
+	ServiceName  string
+	SampleRatio  float64 // share of root spans that are sampled, child spans follow their parent's decision
+
+	// SpanExporter replaces the exporter selected by Exporter when set, e.g. with an in-memory exporter in tests
+	SpanExporter sdktrace.SpanExporter
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Exporter:    NoExporter,
+		ServiceName: "spaces-server",
+		SampleRatio: 1,
+	}
+}
+
+// NewConfigFromEnv builds a Config from the TRACING_* environment variables. Variables that are not set fall back
+// to the values of DefaultConfig.
+func NewConfigFromEnv(getenv func(string) (string, error)) (Config, error) {
+	const op errors.Op = "tracing.NewConfigFromEnv"
+	var cfg = DefaultConfig()
+	var err error
+
+	if exporter := utils.LookupEnv(getenv, "TRACING_EXPORTER"); exporter != "" {
+		cfg.Exporter = Exporter(exporter)
+	}
+	if serviceName := utils.LookupEnv(getenv, "TRACING_SERVICE_NAME"); serviceName != "" {
+		cfg.ServiceName = serviceName
+	}
+	cfg.OTLPEndpoint = utils.LookupEnv(getenv, "TRACING_OTLP_ENDPOINT")
+
+	if cfg.OTLPInsecure, err = utils.ParseBoolEnv(getenv, "TRACING_OTLP_INSECURE", cfg.OTLPInsecure); err != nil {
+		return Config{}, errors.E(op, err)
+	}
+	if cfg.SampleRatio, err = utils.ParseFloatEnv(getenv, "TRACING_SAMPLE_RATIO", cfg.SampleRatio); err != nil {
+		return Config{}, errors.E(op, err)
+	}
+
+	switch cfg.Exporter {
+	case NoExporter, OTLPExporter, StdoutExporter:
+	default:
+		return Config{}, errors.E(op, fmt.Errorf("unknown tracing exporter: %s", cfg.Exporter))
+	}
+
+	if cfg.SampleRatio < 0 || cfg.SampleRatio > 1 {
+		return Config{}, errors.E(op, fmt.Errorf("tracing sample ratio must be between 0 and 1, got %v", cfg.SampleRatio))
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,94 @@
+package tracing
+
+import (
+	"context"
+	"spaces-p/pkg/errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+const tracerName = "spaces-p"
+
+// Setup installs the global tracer provider and the W3C trace context propagator.
+// The returned shutdown function flushes the spans that have not been exported yet.
+func Setup(ctx context.Context, cfg Config) (shutdown func(context.Context) error, err error) {
+	const op errors.Op = "tracing.Setup"
+
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	exporter := cfg.SpanExporter
+	if exporter == nil {
+		switch cfg.Exporter {
+		case OTLPExporter:
+			var opts []otlptracehttp.Option
+			if cfg.OTLPEndpoint != "" {
+				opts = append(opts, otlptracehttp.WithEndpoint(cfg.OTLPEndpoint))
+			}
+			if cfg.OTLPInsecure {
+				opts = append(opts, otlptracehttp.WithInsecure())
+			}
+
+			exporter, err = otlptracehttp.New(ctx, opts...)
+		case StdoutExporter:
+			exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+		default:
+			otel.SetTracerProvider(noop.NewTracerProvider())
+			return func(context.Context) error { return nil }, nil
+		}
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+	}
+
+	tracerProvider := NewTracerProvider(cfg, exporter)
+	otel.SetTracerProvider(tracerProvider)
+
+	return tracerProvider.Shutdown, nil
+}
+
+func NewTracerProvider(cfg Config, exporter sdktrace.SpanExporter) *sdktrace.TracerProvider {
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(cfg.ServiceName))),
+	)
+}
+
+// Start starts a span named after the operation, e.g. services.SpaceService.GetSpace
+func Start(ctx context.Context, op errors.Op, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, string(op), opts...)
+}
+
+// StartSession starts the span of a session that outlives the request that opened it, e.g. a websocket connection.
+// The span is the root of a trace of its own that links to the span of the request, so the request's spans can end
+// once the connection has been upgraded.
+func StartSession(ctx context.Context, op errors.Op) (context.Context, trace.Span) {
+	return Start(ctx, op, trace.WithNewRoot(), trace.WithLinks(trace.LinkFromContext(ctx)))
+}
+
+// RecordError marks the span of ctx as failed
+func RecordError(ctx context.Context, err error) {
+	span := trace.SpanFromContext(ctx)
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// TraceId returns the id of the trace of ctx, or an empty string if ctx is not part of a sampled trace
+func TraceId(ctx context.Context) string {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.IsSampled() {
+		return ""
+	}
+
+	return spanContext.TraceID().String()
+}
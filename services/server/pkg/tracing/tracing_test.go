@@ -0,0 +1,115 @@
+package tracing_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"spaces-p/pkg/common"
+	"spaces-p/pkg/errors"
+	"spaces-p/pkg/middlewares"
+	"spaces-p/pkg/tracing"
+	"spaces-p/pkg/utils"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type errorsLogger struct {
+	errors []string
+}
+
+func (l *errorsLogger) Debug(v ...any) {}
+
+func (l *errorsLogger) Info(v ...any) {}
+
+func (l *errorsLogger) Warn(v ...any) {}
+
+func (l *errorsLogger) Error(v ...any) {
+	l.errors = append(l.errors, fmt.Sprint(v...))
+}
+
+func (l *errorsLogger) RequestInfo(method, path, clientIP string, statusCode int, latency time.Duration) {
+}
+
+func (l *errorsLogger) With(fields common.Fields) common.Logger {
+	return l
+}
+
+func TestTracingMiddleware(t *testing.T) {
+	const traceId = "4bf92f3577b34da6a3ce929d0e0e4736"
+
+	exporter := tracetest.NewInMemoryExporter()
+	cfg := tracing.DefaultConfig()
+	cfg.SpanExporter = exporter
+	shutdown, err := tracing.Setup(context.Background(), cfg)
+	require.NoError(t, err)
+
+	logger := &errorsLogger{}
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middlewares.Tracing())
+	router.GET("/spaces/:spaceid", func(c *gin.Context) {
+		const op errors.Op = "controllers.SpaceController.GetSpace"
+		ctx, span := tracing.Start(c.Request.Context(), op)
+		defer span.End()
+		c.Request = c.Request.WithContext(ctx)
+
+		utils.WriteError(c, errors.E(op, errors.New("boom"), http.StatusNotFound), logger)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/spaces/1", nil)
+	req.Header.Set("traceparent", "00-"+traceId+"-00f067aa0ba902b7-01")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	// shutting down would reset the in-memory exporter
+	require.NoError(t, otel.GetTracerProvider().(*sdktrace.TracerProvider).ForceFlush(context.Background()))
+	defer shutdown(context.Background())
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2)
+	assert.Equal(t, "controllers.SpaceController.GetSpace", spans[0].Name)
+	assert.Equal(t, "GET /spaces/:spaceid", spans[1].Name)
+	assert.Equal(t, traceId, spans[1].SpanContext.TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", spans[1].Parent.SpanID().String())
+	assert.Equal(t, spans[1].SpanContext.SpanID(), spans[0].Parent.SpanID())
+	require.Len(t, spans[0].Events, 1, "error event")
+
+	require.Len(t, logger.errors, 1)
+	assert.Contains(t, logger.errors[0], "Trace id: "+traceId)
+}
+
+func TestStartSession(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	cfg := tracing.DefaultConfig()
+	cfg.SpanExporter = exporter
+	shutdown, err := tracing.Setup(context.Background(), cfg)
+	require.NoError(t, err)
+
+	ctx, requestSpan := tracing.Start(context.Background(), "controllers.SpaceController.SpaceConnect")
+	requestSpan.End()
+
+	_, sessionSpan := tracing.StartSession(ctx, "services.SpaceNotificationsService.subscribe")
+	sessionSpan.End()
+
+	// shutting down would reset the in-memory exporter
+	require.NoError(t, otel.GetTracerProvider().(*sdktrace.TracerProvider).ForceFlush(context.Background()))
+	defer shutdown(context.Background())
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2)
+	request, session := spans[0], spans[1]
+	assert.Equal(t, "services.SpaceNotificationsService.subscribe", session.Name)
+	assert.False(t, session.Parent.IsValid(), "session span must be a root span")
+	assert.NotEqual(t, request.SpanContext.TraceID(), session.SpanContext.TraceID())
+	require.Len(t, session.Links, 1)
+	assert.Equal(t, request.SpanContext.SpanID(), session.Links[0].SpanContext.SpanID())
+	assert.Equal(t, request.SpanContext.TraceID(), session.Links[0].SpanContext.TraceID())
+}
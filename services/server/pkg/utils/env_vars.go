@@ -3,21 +3,101 @@ package utils
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"time"
 )
 
 func GetEnv(key string) (string, error) {
 	envVars := map[string]string{
-		"DB_HOST":                os.Getenv("DB_HOST"),
-		"DB_USER":                os.Getenv("DB_USER"),
-		"DB_PASSWORD":            os.Getenv("DB_PASSWORD"),
-		"DB_NAME":                os.Getenv("DB_NAME"),
-		"ENVIRONMENT":            os.Getenv("ENVIRONMENT"),
-		"API_VERSION":            os.Getenv("API_VERSION"),
-		"REDIS_HOST":             os.Getenv("REDIS_HOST"),
-		"REDIS_PORT":             os.Getenv("REDIS_PORT"),
-		"GOOGLE_GEOCODE_API_KEY": os.Getenv("GOOGLE_GEOCODE_API_KEY"),
-		"HOST":                   os.Getenv("HOST"),
-		"PORT":                   os.Getenv("PORT"),
+		"DB_HOST":                               os.Getenv("DB_HOST"),
+		"DB_USER":                               os.Getenv("DB_USER"),
+		"DB_PASSWORD":                           os.Getenv("DB_PASSWORD"),
+		"DB_NAME":                               os.Getenv("DB_NAME"),
+		"ENVIRONMENT":                           os.Getenv("ENVIRONMENT"),
+		"API_VERSION":                           os.Getenv("API_VERSION"),
+		"REDIS_HOST":                            os.Getenv("REDIS_HOST"),
+		"REDIS_PORT":                            os.Getenv("REDIS_PORT"),
+		"REDIS_MODE":                            os.Getenv("REDIS_MODE"),
+		"REDIS_ADDRS":                           os.Getenv("REDIS_ADDRS"),
+		"REDIS_MASTER_NAME":                     os.Getenv("REDIS_MASTER_NAME"),
+		"REDIS_USERNAME":                        os.Getenv("REDIS_USERNAME"),
+		"REDIS_PASSWORD":                        os.Getenv("REDIS_PASSWORD"),
+		"REDIS_SENTINEL_USERNAME":               os.Getenv("REDIS_SENTINEL_USERNAME"),
+		"REDIS_SENTINEL_PASSWORD":               os.Getenv("REDIS_SENTINEL_PASSWORD"),
+		"REDIS_DB":                              os.Getenv("REDIS_DB"),
+		"REDIS_TLS":                             os.Getenv("REDIS_TLS"),
+		"REDIS_TLS_INSECURE_SKIP_VERIFY":        os.Getenv("REDIS_TLS_INSECURE_SKIP_VERIFY"),
+		"REDIS_TLS_SERVER_NAME":                 os.Getenv("REDIS_TLS_SERVER_NAME"),
+		"REDIS_TLS_CA_FILE":                     os.Getenv("REDIS_TLS_CA_FILE"),
+		"REDIS_POOL_SIZE":                       os.Getenv("REDIS_POOL_SIZE"),
+		"REDIS_MIN_IDLE_CONNS":                  os.Getenv("REDIS_MIN_IDLE_CONNS"),
+		"REDIS_DIAL_TIMEOUT":                    os.Getenv("REDIS_DIAL_TIMEOUT"),
+		"REDIS_READ_TIMEOUT":                    os.Getenv("REDIS_READ_TIMEOUT"),
+		"REDIS_WRITE_TIMEOUT":                   os.Getenv("REDIS_WRITE_TIMEOUT"),
+		"REDIS_POOL_TIMEOUT":                    os.Getenv("REDIS_POOL_TIMEOUT"),
+		"WS_SLOW_CONSUMER_POLICY":               os.Getenv("WS_SLOW_CONSUMER_POLICY"),
+		"WS_NOTIFICATIONS_BUFFER_SIZE":          os.Getenv("WS_NOTIFICATIONS_BUFFER_SIZE"),
+		"WS_WRITE_TIMEOUT":                      os.Getenv("WS_WRITE_TIMEOUT"),
+		"TRACING_EXPORTER":                      os.Getenv("TRACING_EXPORTER"),
+		"TRACING_OTLP_ENDPOINT":                 os.Getenv("TRACING_OTLP_ENDPOINT"),
+		"TRACING_OTLP_INSECURE":                 os.Getenv("TRACING_OTLP_INSECURE"),
+		"TRACING_SERVICE_NAME":                  os.Getenv("TRACING_SERVICE_NAME"),
+		"TRACING_SAMPLE_RATIO":                  os.Getenv("TRACING_SAMPLE_RATIO"),
+		"MODERATION_BLOCKED_WORDS":              os.Getenv("MODERATION_BLOCKED_WORDS"),
+		"MODERATION_HELD_WORDS":                 os.Getenv("MODERATION_HELD_WORDS"),
+		"MODERATION_BLOCKED_PATTERN":            os.Getenv("MODERATION_BLOCKED_PATTERN"),
+		"MODERATION_BLOCKED_DOMAINS":            os.Getenv("MODERATION_BLOCKED_DOMAINS"),
+		"MODERATION_CLASSIFIER":                 os.Getenv("MODERATION_CLASSIFIER"),
+		"MODERATION_HOLD_THRESHOLD":             os.Getenv("MODERATION_HOLD_THRESHOLD"),
+		"MODERATION_REJECT_THRESHOLD":           os.Getenv("MODERATION_REJECT_THRESHOLD"),
+		"REPORTS_AUTO_HIDE_THRESHOLD":           os.Getenv("REPORTS_AUTO_HIDE_THRESHOLD"),
+		"MODERATOR_USER_IDS":                    os.Getenv("MODERATOR_USER_IDS"),
+		"ATTACHMENTS_BLOB_STORE":                os.Getenv("ATTACHMENTS_BLOB_STORE"),
+		"ATTACHMENTS_LOCAL_DIR":                 os.Getenv("ATTACHMENTS_LOCAL_DIR"),
+		"ATTACHMENTS_MAX_SIZE":                  os.Getenv("ATTACHMENTS_MAX_SIZE"),
+		"ATTACHMENTS_THUMBNAIL_SIZE":            os.Getenv("ATTACHMENTS_THUMBNAIL_SIZE"),
+		"ATTACHMENTS_PUBLIC_URL":                os.Getenv("ATTACHMENTS_PUBLIC_URL"),
+		"ATTACHMENTS_URL_SIGNING_KEY":           os.Getenv("ATTACHMENTS_URL_SIGNING_KEY"),
+		"ATTACHMENTS_URL_TTL":                   os.Getenv("ATTACHMENTS_URL_TTL"),
+		"S3_ENDPOINT":                           os.Getenv("S3_ENDPOINT"),
+		"S3_REGION":                             os.Getenv("S3_REGION"),
+		"S3_BUCKET":                             os.Getenv("S3_BUCKET"),
+		"S3_ACCESS_KEY_ID":                      os.Getenv("S3_ACCESS_KEY_ID"),
+		"S3_SECRET_ACCESS_KEY":                  os.Getenv("S3_SECRET_ACCESS_KEY"),
+		"RATE_LIMIT_CREATE_USER_LIMIT":          os.Getenv("RATE_LIMIT_CREATE_USER_LIMIT"),
+		"RATE_LIMIT_CREATE_USER_WINDOW":         os.Getenv("RATE_LIMIT_CREATE_USER_WINDOW"),
+		"RATE_LIMIT_CREATE_SPACE_LIMIT":         os.Getenv("RATE_LIMIT_CREATE_SPACE_LIMIT"),
+		"RATE_LIMIT_CREATE_SPACE_WINDOW":        os.Getenv("RATE_LIMIT_CREATE_SPACE_WINDOW"),
+		"RATE_LIMIT_CREATE_MESSAGE_LIMIT":       os.Getenv("RATE_LIMIT_CREATE_MESSAGE_LIMIT"),
+		"RATE_LIMIT_CREATE_MESSAGE_WINDOW":      os.Getenv("RATE_LIMIT_CREATE_MESSAGE_WINDOW"),
+		"RATE_LIMIT_LIKE_MESSAGE_LIMIT":         os.Getenv("RATE_LIMIT_LIKE_MESSAGE_LIMIT"),
+		"RATE_LIMIT_LIKE_MESSAGE_WINDOW":        os.Getenv("RATE_LIMIT_LIKE_MESSAGE_WINDOW"),
+		"RATE_LIMIT_VOTE_POLL_LIMIT":            os.Getenv("RATE_LIMIT_VOTE_POLL_LIMIT"),
+		"RATE_LIMIT_VOTE_POLL_WINDOW":           os.Getenv("RATE_LIMIT_VOTE_POLL_WINDOW"),
+		"RATE_LIMIT_GET_ADDRESS_LIMIT":          os.Getenv("RATE_LIMIT_GET_ADDRESS_LIMIT"),
+		"RATE_LIMIT_GET_ADDRESS_WINDOW":         os.Getenv("RATE_LIMIT_GET_ADDRESS_WINDOW"),
+		"RATE_LIMIT_SPACE_CONNECT_LIMIT":        os.Getenv("RATE_LIMIT_SPACE_CONNECT_LIMIT"),
+		"RATE_LIMIT_SPACE_CONNECT_WINDOW":       os.Getenv("RATE_LIMIT_SPACE_CONNECT_WINDOW"),
+		"RATE_LIMIT_USER_CONNECT_LIMIT":         os.Getenv("RATE_LIMIT_USER_CONNECT_LIMIT"),
+		"RATE_LIMIT_USER_CONNECT_WINDOW":        os.Getenv("RATE_LIMIT_USER_CONNECT_WINDOW"),
+		"RATE_LIMIT_USER_UPDATES_FILTER_LIMIT":  os.Getenv("RATE_LIMIT_USER_UPDATES_FILTER_LIMIT"),
+		"RATE_LIMIT_USER_UPDATES_FILTER_WINDOW": os.Getenv("RATE_LIMIT_USER_UPDATES_FILTER_WINDOW"),
+		"RATE_LIMIT_FOLLOW_THREAD_LIMIT":        os.Getenv("RATE_LIMIT_FOLLOW_THREAD_LIMIT"),
+		"RATE_LIMIT_FOLLOW_THREAD_WINDOW":       os.Getenv("RATE_LIMIT_FOLLOW_THREAD_WINDOW"),
+		"RATE_LIMIT_CREATE_REPORT_LIMIT":        os.Getenv("RATE_LIMIT_CREATE_REPORT_LIMIT"),
+		"RATE_LIMIT_CREATE_REPORT_WINDOW":       os.Getenv("RATE_LIMIT_CREATE_REPORT_WINDOW"),
+		"RATE_LIMIT_UPLOAD_ATTACHMENT_LIMIT":    os.Getenv("RATE_LIMIT_UPLOAD_ATTACHMENT_LIMIT"),
+		"RATE_LIMIT_UPLOAD_ATTACHMENT_WINDOW":   os.Getenv("RATE_LIMIT_UPLOAD_ATTACHMENT_WINDOW"),
+		"RATE_LIMIT_SET_READ_MARKER_LIMIT":      os.Getenv("RATE_LIMIT_SET_READ_MARKER_LIMIT"),
+		"RATE_LIMIT_SET_READ_MARKER_WINDOW":     os.Getenv("RATE_LIMIT_SET_READ_MARKER_WINDOW"),
+		"RATE_LIMIT_HIDE_USER_LIMIT":            os.Getenv("RATE_LIMIT_HIDE_USER_LIMIT"),
+		"RATE_LIMIT_HIDE_USER_WINDOW":           os.Getenv("RATE_LIMIT_HIDE_USER_WINDOW"),
+		"RATE_LIMIT_PIN_LIMIT":                  os.Getenv("RATE_LIMIT_PIN_LIMIT"),
+		"RATE_LIMIT_PIN_WINDOW":                 os.Getenv("RATE_LIMIT_PIN_WINDOW"),
+		"LOG_LEVEL":                             os.Getenv("LOG_LEVEL"),
+		"GOOGLE_GEOCODE_API_KEY":                os.Getenv("GOOGLE_GEOCODE_API_KEY"),
+		"HOST":                                  os.Getenv("HOST"),
+		"PORT":                                  os.Getenv("PORT"),
 	}
 
 	val, ok := envVars[key]
@@ -28,3 +108,69 @@ func GetEnv(key string) (string, error) {
 
 	return val, nil
 }
+
+// LookupEnv returns an empty string for variables that are not set
+func LookupEnv(getenv func(string) (string, error), key string) string {
+	val, err := getenv(key)
+	if err != nil {
+		return ""
+	}
+
+	return val
+}
+
+func ParseIntEnv(getenv func(string) (string, error), key string, fallback int) (int, error) {
+	val := LookupEnv(getenv, key)
+	if val == "" {
+		return fallback, nil
+	}
+
+	i, err := strconv.Atoi(val)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value for %s: %w", key, err)
+	}
+
+	return i, nil
+}
+
+func ParseBoolEnv(getenv func(string) (string, error), key string, fallback bool) (bool, error) {
+	val := LookupEnv(getenv, key)
+	if val == "" {
+		return fallback, nil
+	}
+
+	b, err := strconv.ParseBool(val)
+	if err != nil {
+		return false, fmt.Errorf("invalid value for %s: %w", key, err)
+	}
+
+	return b, nil
+}
+
+func ParseDurationEnv(getenv func(string) (string, error), key string, fallback time.Duration) (time.Duration, error) {
+	val := LookupEnv(getenv, key)
+	if val == "" {
+		return fallback, nil
+	}
+
+	d, err := time.ParseDuration(val)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value for %s: %w", key, err)
+	}
+
+	return d, nil
+}
+
+func ParseFloatEnv(getenv func(string) (string, error), key string, fallback float64) (float64, error) {
+	val := LookupEnv(getenv, key)
+	if val == "" {
+		return fallback, nil
+	}
+
+	f, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value for %s: %w", key, err)
+	}
+
+	return f, nil
+}
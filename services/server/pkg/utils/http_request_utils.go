@@ -59,6 +59,27 @@ func GetMessageIdFromPath(c *gin.Context) (threadId uuid.Uuid, err error) {
 	return threadId, nil
 }
 
+func GetHeldMessageIdFromPath(c *gin.Context) (heldMessageId uuid.Uuid, err error) {
+	const op errors.Op = "utils.GetHeldMessageIdFromPath"
+
+	heldMessageId, err = getUuidFromPath(c, "heldmessageid")
+	if err != nil {
+		return uuid.Nil, errors.E(op, err)
+	}
+
+	return heldMessageId, nil
+}
+
+func GetReportTargetFromPath(c *gin.Context) (target models.ReportTarget, err error) {
+	const op errors.Op = "utils.GetReportTargetFromPath"
+
+	if err := target.ParseString(c.Param("targettype") + ":" + c.Param("targetid")); err != nil {
+		return models.ReportTarget{}, errors.E(op, err)
+	}
+
+	return target, nil
+}
+
 func GetUserUidFromPath(c *gin.Context) models.UserUid {
 	return models.UserUid(c.Param("userid"))
 }
@@ -80,3 +101,14 @@ func GetUserFromContext(c *gin.Context) (user *models.User, err error) {
 
 	return user, nil
 }
+
+func GetAttachmentIdFromPath(c *gin.Context) (attachmentId uuid.Uuid, err error) {
+	const op errors.Op = "utils.GetAttachmentIdFromPath"
+
+	attachmentId, err = getUuidFromPath(c, "attachmentid")
+	if err != nil {
+		return uuid.Nil, errors.E(op, err)
+	}
+
+	return attachmentId, nil
+}
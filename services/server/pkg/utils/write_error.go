@@ -6,10 +6,18 @@ import (
 	"spaces-p/pkg/errors"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func WriteError(c *gin.Context, err error, logger common.Logger) {
-	logger.Error(err)
+	span := trace.SpanFromContext(c.Request.Context())
+	if spanContext := span.SpanContext(); spanContext.IsSampled() {
+		err = errors.WithTraceId(err, spanContext.TraceID().String())
+	}
+	// the tracing middleware marks the span as failed for server errors
+	span.RecordError(err)
+
+	common.LoggerFromContext(c.Request.Context(), logger).Error(err)
 
 	if cr, ok := err.(interface {
 		Message() errors.Messages
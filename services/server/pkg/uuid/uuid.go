@@ -22,6 +22,12 @@ func (u Uuid) MarshalJSON() ([]byte, error) {
 func (u *Uuid) UnmarshalJSON(data []byte) error {
 	const op errors.Op = "uuid.Uuid.UnmarshalJSON"
 
+	// the nil uuid is marshalled as an empty string
+	if string(data) == `""` {
+		*u = Nil
+		return nil
+	}
+
 	var rawUUID uuid.UUID
 	if err := json.Unmarshal(data, &rawUUID); err != nil {
 		return errors.E(op, err)
@@ -19,14 +19,26 @@ func New(writer io.Writer) common.Logger {
 	return &Zerologger{logger}
 }
 
+func (zl *Zerologger) Debug(v ...any) {
+	zl.logger.Debug().Msg(fmt.Sprint(v...))
+}
+
 func (zl *Zerologger) Info(v ...any) {
 	zl.logger.Info().Msg(fmt.Sprint(v...))
 }
 
+func (zl *Zerologger) Warn(v ...any) {
+	zl.logger.Warn().Msg(fmt.Sprint(v...))
+}
+
 func (zl *Zerologger) Error(v ...any) {
 	zl.logger.Error().Msg(fmt.Sprint(v...))
 }
 
+func (zl *Zerologger) With(fields common.Fields) common.Logger {
+	return &Zerologger{zl.logger.With().Fields(map[string]any(fields)).Logger()}
+}
+
 func (zl *Zerologger) RequestInfo(method, path, clientIP string, statusCode int, latency time.Duration) {
 	zl.logger.Info().
 		Str("method", method).
@@ -6,6 +6,7 @@ import (
 	"spaces-p/pkg/firebase"
 	"spaces-p/pkg/redis"
 	"spaces-p/pkg/repositories/redis_repo"
+	"spaces-p/pkg/utils"
 	"spaces-p/pkg/zerologger"
 	"time"
 
@@ -15,15 +16,22 @@ import (
 func main() {
 	var ctx = context.Background()
 
-	redisPort := os.Getenv("REDIS_PORT")
-	redisHost := os.Getenv("REDIS_HOST")
-
-	redisClient := redis.GetRedisClient(redisHost, redisPort)
-	redisRepo := redis_repo.NewRedisRepository(redisClient)
-
 	zl := zerolog.New(zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: time.RFC3339}).With().Timestamp().Logger()
 	logger := zerologger.New(zl)
 
+	redisConfig, err := redis.NewConfigFromEnv(utils.GetEnv)
+	if err != nil {
+		logger.Error(err)
+		os.Exit(1)
+	}
+
+	redisClient, err := redis.GetRedisClient(redisConfig)
+	if err != nil {
+		logger.Error(err)
+		os.Exit(1)
+	}
+	redisRepo := redis_repo.NewRedisRepository(redisClient)
+
 	firebaseAuthClient, err := firebase.NewFirebaseAuthClient(ctx, "./secrets/firebase_service_account_key.json")
 	if err != nil {
 		logger.Error(err)
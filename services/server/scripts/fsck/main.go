@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"spaces-p/pkg/redis"
+	"spaces-p/pkg/repositories/redis_repo"
+	"spaces-p/pkg/utils"
+	"spaces-p/pkg/zerologger"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// repairs can cascade, e.g. deleting an orphaned thread orphans its messages
+const maxRepairPasses = 10
+
+func main() {
+	var ctx = context.Background()
+
+	repair := flag.Bool("repair", false, "fix the found inconsistencies")
+	flag.Parse()
+
+	zl := zerolog.New(zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: time.RFC3339}).With().Timestamp().Logger()
+	logger := zerologger.New(zl)
+
+	redisConfig, err := redis.NewConfigFromEnv(utils.GetEnv)
+	if err != nil {
+		logger.Error(err)
+		os.Exit(1)
+	}
+
+	redisClient, err := redis.GetRedisClient(redisConfig)
+	if err != nil {
+		logger.Error(err)
+		os.Exit(1)
+	}
+	redisRepo := redis_repo.NewRedisRepository(redisClient)
+
+	if !*repair {
+		inconsistencies, err := redisRepo.CheckConsistency(ctx, false)
+		for _, inconsistency := range inconsistencies {
+			logger.Info(inconsistency)
+		}
+		if err != nil {
+			logger.Error(err)
+			os.Exit(1)
+		}
+
+		logger.Info(fmt.Sprintf("found %d inconsistencies", len(inconsistencies)))
+		if len(inconsistencies) > 0 {
+			os.Exit(2)
+		}
+
+		return
+	}
+
+	for pass := 1; pass <= maxRepairPasses; pass++ {
+		inconsistencies, err := redisRepo.CheckConsistency(ctx, true)
+		var repairedCount int
+		for _, inconsistency := range inconsistencies {
+			logger.Info(inconsistency)
+			if inconsistency.Repaired {
+				repairedCount++
+			}
+		}
+		if err != nil {
+			logger.Error(err)
+			os.Exit(1)
+		}
+
+		logger.Info(fmt.Sprintf("pass %d: found %d inconsistencies, repaired %d", pass, len(inconsistencies), repairedCount))
+		switch {
+		case len(inconsistencies) == 0:
+			return
+		case repairedCount == 0:
+			logger.Error(fmt.Sprintf("%d inconsistencies cannot be repaired automatically", len(inconsistencies)))
+			os.Exit(2)
+		}
+	}
+
+	logger.Error(fmt.Sprintf("inconsistencies left after %d repair passes", maxRepairPasses))
+	os.Exit(2)
+}
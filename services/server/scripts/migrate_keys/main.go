@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"spaces-p/pkg/redis"
+	"spaces-p/pkg/repositories/redis_repo"
+	"spaces-p/pkg/utils"
+	"spaces-p/pkg/zerologger"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func main() {
+	var ctx = context.Background()
+
+	zl := zerolog.New(zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: time.RFC3339}).With().Timestamp().Logger()
+	logger := zerologger.New(zl)
+
+	redisConfig, err := redis.NewConfigFromEnv(utils.GetEnv)
+	if err != nil {
+		logger.Error(err)
+		os.Exit(1)
+	}
+
+	redisClient, err := redis.GetRedisClient(redisConfig)
+	if err != nil {
+		logger.Error(err)
+		os.Exit(1)
+	}
+	redisRepo := redis_repo.NewRedisRepository(redisClient)
+
+	migrations, err := redisRepo.MigrateHashTaggedKeys(ctx)
+	var skippedCount int
+	for _, migration := range migrations {
+		logger.Info(migration)
+		if migration.Skipped {
+			skippedCount++
+		}
+	}
+	if err != nil {
+		logger.Error(err)
+		os.Exit(1)
+	}
+
+	logger.Info(fmt.Sprintf("migrated %d keys, skipped %d", len(migrations)-skippedCount, skippedCount))
+	if skippedCount > 0 {
+		os.Exit(2)
+	}
+}
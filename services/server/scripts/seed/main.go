@@ -28,16 +28,23 @@ const (
 func main() {
 	var ctx = context.Background()
 
-	redisPort := os.Getenv("REDIS_PORT")
-	redisHost := os.Getenv("REDIS_HOST")
-
-	redisClient := redis.GetRedisClient(redisHost, redisPort)
-	redisRepo := redis_repo.NewRedisRepository(redisClient)
-	localMemoryRepo := localmemory.NewLocalMemoryRepo()
-
 	zl := zerolog.New(zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: time.RFC3339}).With().Timestamp().Logger()
 	logger := zerologger.New(zl)
 
+	redisConfig, err := redis.NewConfigFromEnv(utils.GetEnv)
+	if err != nil {
+		logger.Error(err)
+		os.Exit(1)
+	}
+
+	redisClient, err := redis.GetRedisClient(redisConfig)
+	if err != nil {
+		logger.Error(err)
+		os.Exit(1)
+	}
+	redisRepo := redis_repo.NewRedisRepository(redisClient)
+	localMemoryRepo := localmemory.NewLocalMemoryRepo(localmemory.DefaultConfig())
+
 	firebaseAuthClient, err := firebase.NewFirebaseAuthClient(ctx, "./secrets/firebase_service_account_key.json")
 	if err != nil {
 		logger.Error(err)
@@ -45,7 +52,7 @@ func main() {
 	}
 
 	spaceService := services.NewSpaceService(logger, redisRepo, localMemoryRepo)
-	userService := services.NewUserService(logger, redisRepo)
+	userService := services.NewUserService(logger, redisRepo, localMemoryRepo)
 
 	newFakeUsers, err := createFakeUsers(3)
 	if err != nil {
@@ -0,0 +1,129 @@
+//go:build e2e
+// +build e2e
+
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"spaces-p/pkg/models"
+	"spaces-p/pkg/uuid"
+	"spaces-p/tests/e2e/helpers"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateAnnouncement(t *testing.T) {
+	ctx := context.Background()
+	testUsers := helpers.GetUsers(t)
+
+	tests := []helpers.Test[string, bool]{
+		{
+			Name:            "admin posts an announcement",
+			CurrentTestUser: testUsers[1],
+			Args:            `{"content":"the park closes early today","type":"text"}`,
+			WantStatusCode:  http.StatusOK,
+			WantData:        true,
+		},
+		{
+			Name:            "subscriber who is neither admin nor moderator can't post an announcement",
+			CurrentTestUser: testUsers[0],
+			Args:            `{"content":"the park closes early today","type":"text"}`,
+			WantStatusCode:  http.StatusForbidden,
+			WantData:        false,
+		},
+	}
+
+	client := http.Client{}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			helpers.CreateTestUsers(ctx, t, helpers.Tc.Repo)
+			t.Cleanup(func() {
+				err := helpers.Tc.Repo.DeleteAllKeys()
+				if err != nil {
+					t.Fatalf("helpers.Tc.Repo.DeleteAllKeys() err = %s; want nil", err)
+				}
+			})
+
+			// arrange
+			spaceId := setPinTestSpace(ctx, t, testUsers)
+			url := fmt.Sprintf("%s/spaces/%s/announcements", helpers.Tc.ApiEndpoint, spaceId)
+
+			// act
+			createAnnouncementResponse, teardownFunc := helpers.MakeRequest[map[string]map[string]uuid.Uuid](t, client, http.MethodPost, url, bytes.NewBufferString(test.Args), test.WantStatusCode, test.CurrentTestUser, helpers.Tc.AuthClient)
+			t.Cleanup(teardownFunc)
+			if createAnnouncementResponse == nil {
+				assert.Empty(t, getInbox(t, client, testUsers[0]))
+				return
+			}
+
+			// assert
+			threadId := (*createAnnouncementResponse)["data"]["threadId"]
+			firstMessageId := (*createAnnouncementResponse)["data"]["firstMessageId"]
+
+			assert.Equal(t, test.WantData, isTopLevelThreadPinned(t, client, spaceId, threadId, testUsers[0]))
+
+			// announcements are delivered to the inboxes of the subscribers in the background
+			assert.Eventually(t, func() bool {
+				for _, inboxMessage := range getInbox(t, client, testUsers[0]) {
+					if inboxMessage.ID == firstMessageId && inboxMessage.SpaceId == spaceId {
+						return true
+					}
+				}
+
+				return false
+			}, 5*time.Second, 50*time.Millisecond)
+		})
+	}
+}
+
+func TestCreateAnnouncementSkipsModeration(t *testing.T) {
+	ctx := context.Background()
+	testUsers := helpers.GetUsers(t)
+	helpers.CreateTestUsers(ctx, t, helpers.Tc.Repo)
+	t.Cleanup(func() {
+		err := helpers.Tc.Repo.DeleteAllKeys()
+		if err != nil {
+			t.Fatalf("helpers.Tc.Repo.DeleteAllKeys() err = %s; want nil", err)
+		}
+	})
+
+	// arrange
+	spaceId := setPinTestSpace(ctx, t, testUsers)
+	body := fmt.Sprintf(`{"content":"the %s stand closes early today","type":"text"}`, helpers.BlockedWord)
+	client := http.Client{}
+
+	// the content is rejected as a toplevel thread of the admin
+	url := fmt.Sprintf("%s/spaces/%s/toplevel-threads", helpers.Tc.ApiEndpoint, spaceId)
+	_, teardownFunc := helpers.MakeRequest[map[string]any](t, client, http.MethodPost, url, bytes.NewBufferString(body), http.StatusUnprocessableEntity, testUsers[1], helpers.Tc.AuthClient)
+	t.Cleanup(teardownFunc)
+
+	// act
+	url = fmt.Sprintf("%s/spaces/%s/announcements", helpers.Tc.ApiEndpoint, spaceId)
+	createAnnouncementResponse, teardownFunc := helpers.MakeRequest[map[string]map[string]uuid.Uuid](t, client, http.MethodPost, url, bytes.NewBufferString(body), http.StatusOK, testUsers[1], helpers.Tc.AuthClient)
+	t.Cleanup(teardownFunc)
+
+	// assert
+	if createAnnouncementResponse == nil {
+		t.Fatal("createAnnouncementResponse = nil; want the ids of the announcement")
+	}
+	threadId := (*createAnnouncementResponse)["data"]["threadId"]
+	assert.True(t, isTopLevelThreadPinned(t, client, spaceId, threadId, testUsers[0]))
+}
+
+func getInbox(t *testing.T, client http.Client, asUser models.BaseUser) []models.InboxMessage {
+	t.Helper()
+
+	url := fmt.Sprintf("%s/user/inbox", helpers.Tc.ApiEndpoint)
+	inboxResponse, teardownFunc := helpers.MakeRequest[map[string][]models.InboxMessage](t, client, http.MethodGet, url, nil, http.StatusOK, asUser, helpers.Tc.AuthClient)
+	t.Cleanup(teardownFunc)
+	if inboxResponse == nil {
+		return nil
+	}
+
+	return (*inboxResponse)["data"]
+}
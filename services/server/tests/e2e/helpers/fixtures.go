@@ -104,6 +104,8 @@ var SpaceFixtures = []*models.Space{
 			ThemeColorHexaCode: "#A1BA6D",
 			Radius:             68,
 			Location:           models.Location{Long: 13.420215, Lat: 52.555241},
+			Category:           models.FoodSpaceCategory,
+			Tags:               []string{"street-food", "vegan"},
 		},
 	},
 	{
@@ -112,6 +114,7 @@ var SpaceFixtures = []*models.Space{
 			ThemeColorHexaCode: "#9AE174",
 			Radius:             50,
 			Location:           models.Location{Long: 13.419568, Lat: 52.555263},
+			Tags:               []string{"street-art"},
 		},
 	},
 	{
@@ -120,6 +123,8 @@ var SpaceFixtures = []*models.Space{
 			ThemeColorHexaCode: "#86EB4F",
 			Radius:             70,
 			Location:           models.Location{Long: 13.420848, Lat: 52.554357},
+			Category:           models.OutdoorsSpaceCategory,
+			Tags:               []string{"street-food", "park"},
 		},
 	},
 	{
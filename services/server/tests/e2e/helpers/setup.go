@@ -11,6 +11,7 @@ import (
 	"net"
 	"net/url"
 	"os"
+	"path/filepath"
 	"spaces-p/pkg/common"
 	"spaces-p/pkg/redis"
 	"spaces-p/pkg/repositories/redis_repo"
@@ -49,6 +50,10 @@ func SetupE2EEnv(apiVersion, serverPort string) (func(), error) {
 			return "localhost", nil
 		case "PORT":
 			return serverPort, nil
+		case "ATTACHMENTS_LOCAL_DIR":
+			return filepath.Join(os.TempDir(), "spaces-p-e2e-attachments"), nil
+		case "MODERATION_BLOCKED_WORDS":
+			return BlockedWord, nil
 		default:
 			return "", fmt.Errorf("no value found for key: %s", key)
 		}
@@ -84,7 +89,13 @@ func setupRedis(ctx context.Context) (redisHost, redisPort string, redisRepo *re
 		return "", "", nil, nil, err
 	}
 
-	redisClient := redis.GetRedisClient(redisHost, redisPort)
+	redisConfig := redis.DefaultConfig()
+	redisConfig.Addrs = []string{redisEndpoint}
+	redisClient, err := redis.GetRedisClient(redisConfig)
+	if err != nil {
+		teardownFunc()
+		return "", "", nil, nil, err
+	}
 	redisRepo = redis_repo.NewRedisRepository(redisClient)
 
 	return redisHost, redisPort, redisRepo, teardownFunc, nil
@@ -66,7 +66,12 @@ func (gr *SpyGeocodeRepository) Reset() {
 // implements the common.Logger interface
 type NoopLogger struct{}
 
+func (lg *NoopLogger) Debug(v ...any) {}
 func (lg *NoopLogger) Info(v ...any)  {}
+func (lg *NoopLogger) Warn(v ...any)  {}
 func (lg *NoopLogger) Error(v ...any) {}
+func (lg *NoopLogger) With(fields common.Fields) common.Logger {
+	return lg
+}
 func (lg *NoopLogger) RequestInfo(method, path, clientIP string, statusCode int, latency time.Duration) {
 }
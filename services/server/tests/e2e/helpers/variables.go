@@ -6,3 +6,6 @@ package helpers
 var (
 	Tc = &TestContext{}
 )
+
+// BlockedWord is rejected by the moderation of the e2e server
+const BlockedWord = "spamword"
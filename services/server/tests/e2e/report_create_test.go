@@ -0,0 +1,93 @@
+//go:build e2e
+// +build e2e
+
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"spaces-p/pkg/models"
+	"spaces-p/tests/e2e/helpers"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateUserReport(t *testing.T) {
+	ctx := context.Background()
+	testUsers := helpers.CreateTestUsers(ctx, t, helpers.Tc.Repo)
+	reportedUser := testUsers[1]
+	tests := []struct {
+		name             string
+		reportedUserId   models.UserUid
+		currentTestUser  models.BaseUser
+		args             string
+		wantStatusCode   int
+		wantReportsCount int
+	}{
+		{
+			name:             "report user",
+			reportedUserId:   reportedUser.ID,
+			currentTestUser:  testUsers[0],
+			args:             `{"reason":"spam"}`,
+			wantStatusCode:   http.StatusOK,
+			wantReportsCount: 1,
+		},
+		{
+			name:            "report user with invalid reason",
+			reportedUserId:  reportedUser.ID,
+			currentTestUser: testUsers[0],
+			args:            `{"reason":"boring"}`,
+			wantStatusCode:  http.StatusBadRequest,
+		},
+		{
+			name:            "report oneself",
+			reportedUserId:  testUsers[0].ID,
+			currentTestUser: testUsers[0],
+			args:            `{"reason":"spam"}`,
+			wantStatusCode:  http.StatusBadRequest,
+		},
+		{
+			name:            "report non-existent user",
+			reportedUserId:  "non-existent",
+			currentTestUser: testUsers[0],
+			args:            `{"reason":"spam"}`,
+			wantStatusCode:  http.StatusNotFound,
+		},
+	}
+
+	client := http.Client{}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			helpers.CreateTestUsers(ctx, t, helpers.Tc.Repo)
+			t.Cleanup(func() {
+				err := helpers.Tc.Repo.DeleteAllKeys()
+				if err != nil {
+					t.Fatalf("helpers.Tc.Repo.DeleteAllKeys() err = %s; want nil", err)
+				}
+			})
+
+			url := fmt.Sprintf("%s/users/%s/reports", helpers.Tc.ApiEndpoint, test.reportedUserId)
+
+			// act
+			createReportResponse, teardownFunc := helpers.MakeRequest[map[string]string](t, client, http.MethodPost, url, bytes.NewReader([]byte(test.args)), test.wantStatusCode, test.currentTestUser, helpers.Tc.AuthClient)
+			t.Cleanup(teardownFunc)
+			if createReportResponse == nil {
+				return
+			}
+
+			// reporting the same user again must not count twice
+			_, teardownFunc = helpers.MakeRequest[map[string]string](t, client, http.MethodPost, url, bytes.NewReader([]byte(test.args)), test.wantStatusCode, test.currentTestUser, helpers.Tc.AuthClient)
+			t.Cleanup(teardownFunc)
+
+			report, err := helpers.Tc.Repo.GetReport(ctx, models.ReportTarget{Type: models.UserReportTarget, ID: string(test.reportedUserId)})
+			if err != nil {
+				t.Fatalf("helpers.Tc.Repo.GetReport() err = %s; want nil", err)
+			}
+
+			assert.Equal(t, test.wantReportsCount, report.ReportsCount)
+		})
+	}
+}
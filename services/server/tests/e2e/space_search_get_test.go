@@ -0,0 +1,140 @@
+//go:build e2e
+// +build e2e
+
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"spaces-p/pkg/models"
+	"spaces-p/tests/e2e/helpers"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getSearchSpacesTests(t *testing.T, apiEndpoint string) []helpers.Test[*struct{}, []string] {
+	return []helpers.Test[*struct{}, []string]{
+		{
+			Name:            "by word",
+			Url:             fmt.Sprintf("%s/spaces/search?q=park", apiEndpoint),
+			CurrentTestUser: *helpers.GetUser(t, 0),
+			WantStatusCode:  http.StatusOK,
+			WantData:        []string{helpers.SpaceFixtures[2].Name},
+		},
+		{
+			Name:            "by prefix",
+			Url:             fmt.Sprintf("%s/spaces/search?q=Trelle", apiEndpoint),
+			CurrentTestUser: *helpers.GetUser(t, 0),
+			WantStatusCode:  http.StatusOK,
+			WantData:        []string{helpers.SpaceFixtures[3].Name},
+		},
+		{
+			Name:            "by word with typo",
+			Url:             fmt.Sprintf("%s/spaces/search?q=thuel", apiEndpoint),
+			CurrentTestUser: *helpers.GetUser(t, 0),
+			WantStatusCode:  http.StatusOK,
+			WantData:        []string{helpers.SpaceFixtures[0].Name},
+		},
+		{
+			Name:            "by word with location",
+			Url:             fmt.Sprintf("%s/spaces/search?q=haus&location=%s", apiEndpoint, thulestr32Location.String()),
+			CurrentTestUser: *helpers.GetUser(t, 0),
+			WantStatusCode:  http.StatusOK,
+			WantData:        []string{helpers.SpaceFixtures[2].Name},
+		},
+		{
+			Name:            "without matching space",
+			Url:             fmt.Sprintf("%s/spaces/search?q=zoo", apiEndpoint),
+			CurrentTestUser: *helpers.GetUser(t, 0),
+			WantStatusCode:  http.StatusOK,
+			WantData:        []string{},
+		},
+		{
+			Name:            "without letters",
+			Url:             fmt.Sprintf("%s/spaces/search?q=-", apiEndpoint),
+			CurrentTestUser: *helpers.GetUser(t, 0),
+			WantStatusCode:  http.StatusBadRequest,
+		},
+		{
+			Name:            "without query",
+			Url:             fmt.Sprintf("%s/spaces/search", apiEndpoint),
+			CurrentTestUser: *helpers.GetUser(t, 0),
+			WantStatusCode:  http.StatusBadRequest,
+		},
+	}
+}
+
+func TestSearchSpaces(t *testing.T) {
+	ctx := context.Background()
+	helpers.CreateTestUsers(ctx, t, helpers.Tc.Repo)
+	helpers.CreateTestSpaces(ctx, t, helpers.Tc.Repo)
+
+	t.Cleanup(func() {
+		err := helpers.Tc.Repo.DeleteAllKeys()
+		if err != nil {
+			t.Fatalf("helpers.Tc.Repo.DeleteAllKeys() err = %s; want nil", err)
+		}
+	})
+
+	client := http.Client{}
+	tests := getSearchSpacesTests(t, helpers.Tc.ApiEndpoint)
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			searchResponse, teardown := helpers.MakeRequest[map[string][]models.SpaceSearchResult](t, client, http.MethodGet, test.Url, nil, test.WantStatusCode, test.CurrentTestUser, helpers.Tc.AuthClient)
+			t.Cleanup(teardown)
+			if searchResponse == nil {
+				return
+			}
+
+			gotSpaceNames := make([]string, len((*searchResponse)["data"]))
+			for i, searchResult := range (*searchResponse)["data"] {
+				gotSpaceNames[i] = searchResult.Name
+			}
+
+			assert.Equal(t, test.WantData, gotSpaceNames)
+		})
+	}
+}
+
+func TestSearchSpacesNearbyAmongManyMatches(t *testing.T) {
+	ctx := context.Background()
+	helpers.CreateTestUsers(ctx, t, helpers.Tc.Repo)
+
+	t.Cleanup(func() {
+		err := helpers.Tc.Repo.DeleteAllKeys()
+		if err != nil {
+			t.Fatalf("helpers.Tc.Repo.DeleteAllKeys() err = %s; want nil", err)
+		}
+	})
+
+	// far more spaces with the word far away than are looked up by name
+	for i := 0; i < 400; i++ {
+		_, err := helpers.Tc.Repo.SetSpace(ctx, models.NewSpace{BaseSpace: models.BaseSpace{
+			Name:               fmt.Sprintf("Cafe %d", i),
+			ThemeColorHexaCode: "#000000",
+			Radius:             50,
+			Location:           models.Location{Long: 11.5761, Lat: 48.1374},
+		}})
+		if err != nil {
+			t.Fatalf("helpers.Tc.Repo.SetSpace() err = %s; want nil", err)
+		}
+	}
+
+	var nearbySpace = helpers.SpaceFixtures[0].BaseSpace
+	nearbySpace.Name = "Cafe Thulestraße"
+	if _, err := helpers.Tc.Repo.SetSpace(ctx, models.NewSpace{BaseSpace: nearbySpace}); err != nil {
+		t.Fatalf("helpers.Tc.Repo.SetSpace() err = %s; want nil", err)
+	}
+
+	url := fmt.Sprintf("%s/spaces/search?q=cafe&location=%s", helpers.Tc.ApiEndpoint, thulestr32Location.String())
+	searchResponse, teardown := helpers.MakeRequest[map[string][]models.SpaceSearchResult](t, http.Client{}, http.MethodGet, url, nil, http.StatusOK, *helpers.GetUser(t, 0), helpers.Tc.AuthClient)
+	t.Cleanup(teardown)
+	if searchResponse == nil || len((*searchResponse)["data"]) == 0 {
+		t.Fatal("no spaces found; want the nearby space first")
+	}
+
+	assert.Equal(t, nearbySpace.Name, (*searchResponse)["data"][0].Name)
+}
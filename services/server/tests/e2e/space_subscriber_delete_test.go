@@ -0,0 +1,81 @@
+//go:build e2e
+// +build e2e
+
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"spaces-p/pkg/models"
+	"spaces-p/tests/e2e/helpers"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeleteSpaceSubscriber(t *testing.T) {
+	ctx := context.Background()
+	testUsers := helpers.GetUsers(t)
+
+	tests := []helpers.Test[*struct{}, []models.BaseUser]{
+		{
+			Name:            "subscriber leaves the space",
+			CurrentTestUser: testUsers[0],
+			WantStatusCode:  http.StatusOK,
+			WantData:        []models.BaseUser{testUsers[1]},
+		},
+		{
+			Name:            "admin can't leave the space",
+			CurrentTestUser: testUsers[1],
+			WantStatusCode:  http.StatusForbidden,
+		},
+		{
+			Name:            "user who isn't a subscriber can't leave the space",
+			CurrentTestUser: testUsers[2],
+			WantStatusCode:  http.StatusForbidden,
+		},
+	}
+
+	client := http.Client{}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			helpers.CreateTestUsers(ctx, t, helpers.Tc.Repo)
+			t.Cleanup(func() {
+				err := helpers.Tc.Repo.DeleteAllKeys()
+				if err != nil {
+					t.Fatalf("helpers.Tc.Repo.DeleteAllKeys() err = %s; want nil", err)
+				}
+			})
+
+			// arrange
+			spaceId, err := helpers.Tc.Repo.SetSpace(ctx, models.NewSpace{BaseSpace: helpers.SpaceFixtures[0].BaseSpace, AdminId: testUsers[1].ID})
+			if err != nil {
+				t.Fatalf("helpers.Tc.Repo.SetSpace() err = %s; want nil", err)
+			}
+			for _, subscriber := range testUsers[:2] {
+				if err := helpers.Tc.Repo.SetSpaceSubscriber(ctx, spaceId, subscriber.ID); err != nil {
+					t.Fatalf("helpers.Tc.Repo.SetSpaceSubscriber() err = %s; want nil", err)
+				}
+			}
+			url := fmt.Sprintf("%s/spaces/%s/subscribers", helpers.Tc.ApiEndpoint, spaceId)
+
+			// act
+			deleteSpaceSubscriberResponse, teardownFunc := helpers.MakeRequest[map[string]string](t, client, http.MethodDelete, url, nil, test.WantStatusCode, test.CurrentTestUser, helpers.Tc.AuthClient)
+			t.Cleanup(teardownFunc)
+			if deleteSpaceSubscriberResponse == nil {
+				return
+			}
+
+			// assert
+			spaceSubscribers, teardownFunc := helpers.MakeRequest[map[string][]models.User](t, client, http.MethodGet, url, nil, http.StatusOK, test.CurrentTestUser, helpers.Tc.AuthClient)
+			t.Cleanup(teardownFunc)
+
+			var gotSubscribers []models.BaseUser
+			for _, subscriber := range (*spaceSubscribers)["data"] {
+				gotSubscribers = append(gotSubscribers, subscriber.BaseUser)
+			}
+			assert.Equal(t, test.WantData, gotSubscribers)
+		})
+	}
+}
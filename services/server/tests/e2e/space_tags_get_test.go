@@ -0,0 +1,82 @@
+//go:build e2e
+// +build e2e
+
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"spaces-p/pkg/models"
+	"spaces-p/tests/e2e/helpers"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getGetTagSuggestionsTests(t *testing.T, apiEndpoint string) []helpers.Test[*struct{}, []models.TagSuggestion] {
+	return []helpers.Test[*struct{}, []models.TagSuggestion]{
+		{
+			Name:            "by prefix",
+			Url:             fmt.Sprintf("%s/spaces/tags?prefix=street", apiEndpoint),
+			CurrentTestUser: *helpers.GetUser(t, 0),
+			WantStatusCode:  http.StatusOK,
+			WantData:        []models.TagSuggestion{{Tag: "street-food", SpacesCount: 2}, {Tag: "street-art", SpacesCount: 1}}, // sorted by spaces count descending
+		},
+		{
+			Name:            "by prefix with 1 count",
+			Url:             fmt.Sprintf("%s/spaces/tags?prefix=street&count=1", apiEndpoint),
+			CurrentTestUser: *helpers.GetUser(t, 0),
+			WantStatusCode:  http.StatusOK,
+			WantData:        []models.TagSuggestion{{Tag: "street-food", SpacesCount: 2}},
+		},
+		{
+			Name:            "by prefix that is normalized",
+			Url:             fmt.Sprintf("%s/spaces/tags?prefix=Street+F", apiEndpoint),
+			CurrentTestUser: *helpers.GetUser(t, 0),
+			WantStatusCode:  http.StatusOK,
+			WantData:        []models.TagSuggestion{{Tag: "street-food", SpacesCount: 2}},
+		},
+		{
+			Name:            "by prefix without tags",
+			Url:             fmt.Sprintf("%s/spaces/tags?prefix=zoo", apiEndpoint),
+			CurrentTestUser: *helpers.GetUser(t, 0),
+			WantStatusCode:  http.StatusOK,
+			WantData:        []models.TagSuggestion{},
+		},
+		{
+			Name:            "without prefix",
+			Url:             fmt.Sprintf("%s/spaces/tags", apiEndpoint),
+			CurrentTestUser: *helpers.GetUser(t, 0),
+			WantStatusCode:  http.StatusBadRequest,
+		},
+	}
+}
+
+func TestGetTagSuggestions(t *testing.T) {
+	ctx := context.Background()
+	helpers.CreateTestUsers(ctx, t, helpers.Tc.Repo)
+	helpers.CreateTestSpaces(ctx, t, helpers.Tc.Repo)
+
+	t.Cleanup(func() {
+		err := helpers.Tc.Repo.DeleteAllKeys()
+		if err != nil {
+			t.Fatalf("helpers.Tc.Repo.DeleteAllKeys() err = %s; want nil", err)
+		}
+	})
+
+	client := http.Client{}
+	tests := getGetTagSuggestionsTests(t, helpers.Tc.ApiEndpoint)
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			tagsResponse, teardown := helpers.MakeRequest[map[string][]models.TagSuggestion](t, client, http.MethodGet, test.Url, nil, test.WantStatusCode, test.CurrentTestUser, helpers.Tc.AuthClient)
+			t.Cleanup(teardown)
+			if tagsResponse == nil {
+				return
+			}
+
+			assert.Equal(t, test.WantData, (*tagsResponse)["data"])
+		})
+	}
+}
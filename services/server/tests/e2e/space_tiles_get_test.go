@@ -0,0 +1,109 @@
+//go:build e2e
+// +build e2e
+
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"spaces-p/pkg/models"
+	"spaces-p/tests/e2e/helpers"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type spaceTilesSummary struct {
+	Clustered   bool
+	TilesCount  int
+	SpacesCount int
+}
+
+func getGetSpaceTilesTests(t *testing.T, apiEndpoint string) []helpers.Test[*struct{}, spaceTilesSummary] {
+	return []helpers.Test[*struct{}, spaceTilesSummary]{
+		{
+			Name:            "spaces at high zoom",
+			Url:             fmt.Sprintf("%s/spaces/tiles?bbox=13.41,52.55,13.43,52.56&zoom=20", apiEndpoint),
+			CurrentTestUser: *helpers.GetUser(t, 0),
+			WantStatusCode:  http.StatusOK,
+			WantData:        spaceTilesSummary{Clustered: false, TilesCount: 4, SpacesCount: 4},
+		},
+		{
+			Name:            "clusters at low zoom",
+			Url:             fmt.Sprintf("%s/spaces/tiles?bbox=13,52,14,53&zoom=5", apiEndpoint),
+			CurrentTestUser: *helpers.GetUser(t, 0),
+			WantStatusCode:  http.StatusOK,
+			WantData:        spaceTilesSummary{Clustered: true, TilesCount: 1, SpacesCount: 4},
+		},
+		{
+			Name:            "clusters of the whole world",
+			Url:             fmt.Sprintf("%s/spaces/tiles?bbox=-180,-85,180,85&zoom=0", apiEndpoint),
+			CurrentTestUser: *helpers.GetUser(t, 0),
+			WantStatusCode:  http.StatusOK,
+			WantData:        spaceTilesSummary{Clustered: true, TilesCount: 1, SpacesCount: 4},
+		},
+		{
+			Name:            "bounding box too large for the zoom",
+			Url:             fmt.Sprintf("%s/spaces/tiles?bbox=-180,-85,180,85&zoom=12", apiEndpoint),
+			CurrentTestUser: *helpers.GetUser(t, 0),
+			WantStatusCode:  http.StatusBadRequest,
+		},
+		{
+			Name:            "no spaces in bounding box",
+			Url:             fmt.Sprintf("%s/spaces/tiles?bbox=2.3,48.8,2.4,48.9&zoom=12", apiEndpoint),
+			CurrentTestUser: *helpers.GetUser(t, 0),
+			WantStatusCode:  http.StatusOK,
+			WantData:        spaceTilesSummary{Clustered: true},
+		},
+		{
+			Name:            "invalid bounding box",
+			Url:             fmt.Sprintf("%s/spaces/tiles?bbox=13.43,52.55,13.41,52.56&zoom=17", apiEndpoint),
+			CurrentTestUser: *helpers.GetUser(t, 0),
+			WantStatusCode:  http.StatusBadRequest,
+		},
+		{
+			Name:            "without zoom",
+			Url:             fmt.Sprintf("%s/spaces/tiles?bbox=13.41,52.55,13.43,52.56", apiEndpoint),
+			CurrentTestUser: *helpers.GetUser(t, 0),
+			WantStatusCode:  http.StatusBadRequest,
+		},
+	}
+}
+
+func TestGetSpaceTiles(t *testing.T) {
+	ctx := context.Background()
+	helpers.CreateTestUsers(ctx, t, helpers.Tc.Repo)
+	helpers.CreateTestSpaces(ctx, t, helpers.Tc.Repo)
+
+	t.Cleanup(func() {
+		err := helpers.Tc.Repo.DeleteAllKeys()
+		if err != nil {
+			t.Fatalf("helpers.Tc.Repo.DeleteAllKeys() err = %s; want nil", err)
+		}
+	})
+
+	client := http.Client{}
+	tests := getGetSpaceTilesTests(t, helpers.Tc.ApiEndpoint)
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			tilesResponse, teardown := helpers.MakeRequest[map[string]models.SpaceTiles](t, client, http.MethodGet, test.Url, nil, test.WantStatusCode, test.CurrentTestUser, helpers.Tc.AuthClient)
+			t.Cleanup(teardown)
+			if tilesResponse == nil {
+				return
+			}
+
+			spaceTiles := (*tilesResponse)["data"]
+			summary := spaceTilesSummary{Clustered: spaceTiles.Clustered, TilesCount: len(spaceTiles.Tiles)}
+			for _, tile := range spaceTiles.Tiles {
+				summary.SpacesCount += tile.Count
+				if !spaceTiles.Clustered {
+					assert.Len(t, tile.Spaces, tile.Count)
+				}
+			}
+
+			assert.Equal(t, test.WantData, summary)
+		})
+	}
+}
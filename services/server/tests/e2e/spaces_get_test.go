@@ -39,6 +39,20 @@ func getGetSpacesTests(t *testing.T, apiEndpoint string) []helpers.Test[*struct{
 			WantStatusCode:  http.StatusOK,
 			WantData:        []string{helpers.SpaceFixtures[2].Name},
 		},
+		{
+			Name:            "by location with offset of all spaces",
+			Url:             fmt.Sprintf("%s/spaces?location=%s&radius=1000&offset=4", apiEndpoint, thulestr32Location.String()),
+			CurrentTestUser: *helpers.GetUser(t, 2),
+			WantStatusCode:  http.StatusOK,
+			WantData:        []string{},
+		},
+		{
+			Name:            "by location with offset past the last space",
+			Url:             fmt.Sprintf("%s/spaces?location=%s&radius=1000&offset=10&count=2", apiEndpoint, thulestr32Location.String()),
+			CurrentTestUser: *helpers.GetUser(t, 2),
+			WantStatusCode:  http.StatusOK,
+			WantData:        []string{},
+		},
 		{
 			Name:            "by location with small radius",
 			Url:             fmt.Sprintf("%s/spaces?location=%s&radius=1", apiEndpoint, thulestr32Location.String()),
@@ -53,6 +67,41 @@ func getGetSpacesTests(t *testing.T, apiEndpoint string) []helpers.Test[*struct{
 			WantStatusCode:  http.StatusBadRequest,
 			WantData:        []string{},
 		},
+		{
+			Name:            "by location and category",
+			Url:             fmt.Sprintf("%s/spaces?location=%s&radius=1000&category=food", apiEndpoint, thulestr32Location.String()),
+			CurrentTestUser: *helpers.GetUser(t, 0),
+			WantStatusCode:  http.StatusOK,
+			WantData:        []string{helpers.SpaceFixtures[0].Name},
+		},
+		{
+			Name:            "by location and tag",
+			Url:             fmt.Sprintf("%s/spaces?location=%s&radius=1000&tag=Street+Food", apiEndpoint, thulestr32Location.String()),
+			CurrentTestUser: *helpers.GetUser(t, 0),
+			WantStatusCode:  http.StatusOK,
+			WantData:        []string{helpers.SpaceFixtures[0].Name, helpers.SpaceFixtures[2].Name},
+		},
+		{
+			Name:            "by location and tag with 1 offset",
+			Url:             fmt.Sprintf("%s/spaces?location=%s&radius=1000&tag=street-food&offset=1", apiEndpoint, thulestr32Location.String()),
+			CurrentTestUser: *helpers.GetUser(t, 0),
+			WantStatusCode:  http.StatusOK,
+			WantData:        []string{helpers.SpaceFixtures[2].Name},
+		},
+		{
+			Name:            "by location and unknown category",
+			Url:             fmt.Sprintf("%s/spaces?location=%s&radius=1000&category=cooking", apiEndpoint, thulestr32Location.String()),
+			CurrentTestUser: *helpers.GetUser(t, 0),
+			WantStatusCode:  http.StatusBadRequest,
+			WantData:        []string{},
+		},
+		{
+			Name:            "by user id and tag",
+			Url:             fmt.Sprintf("%s/spaces?user_id=%s&tag=vegan", apiEndpoint, (*helpers.GetUser(t, 0)).ID),
+			CurrentTestUser: *helpers.GetUser(t, 1),
+			WantStatusCode:  http.StatusBadRequest,
+			WantData:        []string{},
+		},
 		{
 			Name:            "by user id",
 			Url:             fmt.Sprintf("%s/spaces?user_id=%s", apiEndpoint, (*helpers.GetUser(t, 0)).ID),
@@ -122,6 +171,63 @@ func TestGetSpaces(t *testing.T) {
 	}
 }
 
+func TestGetSpacesWithHiddenSpace(t *testing.T) {
+	ctx := context.Background()
+	helpers.CreateTestUsers(ctx, t, helpers.Tc.Repo)
+
+	createdTestSpaces := helpers.CreateTestSpaces(ctx, t, helpers.Tc.Repo)
+
+	// the closest space is hidden
+	target := models.ReportTarget{Type: models.SpaceReportTarget, ID: createdTestSpaces[0].ID.String()}
+	if err := helpers.Tc.Repo.SetReportTargetHidden(ctx, target, true); err != nil {
+		t.Fatalf("helpers.Tc.Repo.SetReportTargetHidden() err = %s; want nil", err)
+	}
+
+	t.Cleanup(func() {
+		err := helpers.Tc.Repo.DeleteAllKeys()
+		if err != nil {
+			t.Fatalf("helpers.Tc.Repo.DeleteAllKeys() err = %s; want nil", err)
+		}
+	})
+
+	tests := []helpers.Test[*struct{}, []string]{
+		{
+			Name:            "by location with 2 count",
+			Url:             fmt.Sprintf("%s/spaces?location=%s&radius=1000&count=2", helpers.Tc.ApiEndpoint, thulestr32Location.String()),
+			CurrentTestUser: *helpers.GetUser(t, 0),
+			WantStatusCode:  http.StatusOK,
+			WantData:        []string{helpers.SpaceFixtures[1].Name, helpers.SpaceFixtures[2].Name},
+		},
+		{
+			Name:            "by location with 1 offset and 2 count",
+			Url:             fmt.Sprintf("%s/spaces?location=%s&radius=1000&offset=1&count=2", helpers.Tc.ApiEndpoint, thulestr32Location.String()),
+			CurrentTestUser: *helpers.GetUser(t, 0),
+			WantStatusCode:  http.StatusOK,
+			WantData:        []string{helpers.SpaceFixtures[2].Name, helpers.SpaceFixtures[3].Name},
+		},
+		{
+			Name:            "by location with offset of all visible spaces",
+			Url:             fmt.Sprintf("%s/spaces?location=%s&radius=1000&offset=3", helpers.Tc.ApiEndpoint, thulestr32Location.String()),
+			CurrentTestUser: *helpers.GetUser(t, 0),
+			WantStatusCode:  http.StatusOK,
+			WantData:        []string{},
+		},
+	}
+
+	client := http.Client{}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			spacesResponse, teardown := helpers.MakeRequest[map[string][]models.SpaceWithDistance](t, client, http.MethodGet, test.Url, nil, test.WantStatusCode, test.CurrentTestUser, helpers.Tc.AuthClient)
+			t.Cleanup(teardown)
+			if spacesResponse == nil {
+				return
+			}
+
+			assert.Equal(t, test.WantData, getSpaceNames(t, (*spacesResponse)["data"]))
+		})
+	}
+}
+
 func getSpaceNames(t *testing.T, spaces []models.SpaceWithDistance) []string {
 	t.Helper()
 
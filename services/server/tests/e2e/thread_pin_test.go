@@ -0,0 +1,206 @@
+//go:build e2e
+// +build e2e
+
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"spaces-p/pkg/models"
+	"spaces-p/pkg/uuid"
+	"spaces-p/tests/e2e/helpers"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPinTopLevelThread(t *testing.T) {
+	ctx := context.Background()
+	testUsers := helpers.GetUsers(t)
+
+	tests := []helpers.Test[*struct{}, bool]{
+		{
+			Name:            "admin pins a toplevel thread",
+			CurrentTestUser: testUsers[1],
+			WantStatusCode:  http.StatusOK,
+			WantData:        true,
+		},
+		{
+			Name:            "subscriber who is neither admin nor moderator can't pin a toplevel thread",
+			CurrentTestUser: testUsers[0],
+			WantStatusCode:  http.StatusForbidden,
+			WantData:        false,
+		},
+	}
+
+	client := http.Client{}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			helpers.CreateTestUsers(ctx, t, helpers.Tc.Repo)
+			t.Cleanup(func() {
+				err := helpers.Tc.Repo.DeleteAllKeys()
+				if err != nil {
+					t.Fatalf("helpers.Tc.Repo.DeleteAllKeys() err = %s; want nil", err)
+				}
+			})
+
+			// arrange
+			spaceId := setPinTestSpace(ctx, t, testUsers)
+			threadIds := setPinTestTopLevelThreads(ctx, t, spaceId, testUsers[0].ID, 1)
+			url := fmt.Sprintf("%s/spaces/%s/threads/%s/pin", helpers.Tc.ApiEndpoint, spaceId, threadIds[0])
+
+			// act
+			_, teardownFunc := helpers.MakeRequest[map[string]string](t, client, http.MethodPut, url, nil, test.WantStatusCode, test.CurrentTestUser, helpers.Tc.AuthClient)
+			t.Cleanup(teardownFunc)
+
+			// assert
+			assert.Equal(t, test.WantData, isTopLevelThreadPinned(t, client, spaceId, threadIds[0], testUsers[0]))
+		})
+	}
+}
+
+func TestUnpinTopLevelThread(t *testing.T) {
+	ctx := context.Background()
+	testUsers := helpers.GetUsers(t)
+
+	tests := []helpers.Test[*struct{}, bool]{
+		{
+			Name:            "admin unpins a toplevel thread",
+			CurrentTestUser: testUsers[1],
+			WantStatusCode:  http.StatusOK,
+			WantData:        false,
+		},
+		{
+			Name:            "subscriber who is neither admin nor moderator can't unpin a toplevel thread",
+			CurrentTestUser: testUsers[0],
+			WantStatusCode:  http.StatusForbidden,
+			WantData:        true,
+		},
+	}
+
+	client := http.Client{}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			helpers.CreateTestUsers(ctx, t, helpers.Tc.Repo)
+			t.Cleanup(func() {
+				err := helpers.Tc.Repo.DeleteAllKeys()
+				if err != nil {
+					t.Fatalf("helpers.Tc.Repo.DeleteAllKeys() err = %s; want nil", err)
+				}
+			})
+
+			// arrange
+			spaceId := setPinTestSpace(ctx, t, testUsers)
+			threadIds := setPinTestTopLevelThreads(ctx, t, spaceId, testUsers[0].ID, 1)
+			setPinTestPins(ctx, t, spaceId, threadIds)
+			url := fmt.Sprintf("%s/spaces/%s/threads/%s/pin", helpers.Tc.ApiEndpoint, spaceId, threadIds[0])
+
+			// act
+			_, teardownFunc := helpers.MakeRequest[map[string]string](t, client, http.MethodDelete, url, nil, test.WantStatusCode, test.CurrentTestUser, helpers.Tc.AuthClient)
+			t.Cleanup(teardownFunc)
+
+			// assert
+			assert.Equal(t, test.WantData, isTopLevelThreadPinned(t, client, spaceId, threadIds[0], testUsers[0]))
+		})
+	}
+}
+
+func TestPinTopLevelThreadPinLimit(t *testing.T) {
+	ctx := context.Background()
+	testUsers := helpers.GetUsers(t)
+	helpers.CreateTestUsers(ctx, t, helpers.Tc.Repo)
+	t.Cleanup(func() {
+		err := helpers.Tc.Repo.DeleteAllKeys()
+		if err != nil {
+			t.Fatalf("helpers.Tc.Repo.DeleteAllKeys() err = %s; want nil", err)
+		}
+	})
+
+	// arrange
+	const maxSpacePins = 10
+	spaceId := setPinTestSpace(ctx, t, testUsers)
+	threadIds := setPinTestTopLevelThreads(ctx, t, spaceId, testUsers[0].ID, maxSpacePins+1)
+	setPinTestPins(ctx, t, spaceId, threadIds[:maxSpacePins])
+	url := fmt.Sprintf("%s/spaces/%s/threads/%s/pin", helpers.Tc.ApiEndpoint, spaceId, threadIds[maxSpacePins])
+
+	// act
+	client := http.Client{}
+	_, teardownFunc := helpers.MakeRequest[map[string]string](t, client, http.MethodPut, url, nil, http.StatusConflict, testUsers[1], helpers.Tc.AuthClient)
+	t.Cleanup(teardownFunc)
+
+	// assert
+	assert.False(t, isTopLevelThreadPinned(t, client, spaceId, threadIds[maxSpacePins], testUsers[0]))
+	for _, threadId := range threadIds[:maxSpacePins] {
+		assert.True(t, isTopLevelThreadPinned(t, client, spaceId, threadId, testUsers[0]))
+	}
+}
+
+// setPinTestSpace sets a space administered by the second test user, which the first two test users are subscribed to
+func setPinTestSpace(ctx context.Context, t *testing.T, testUsers []models.BaseUser) uuid.Uuid {
+	t.Helper()
+
+	spaceId, err := helpers.Tc.Repo.SetSpace(ctx, models.NewSpace{BaseSpace: helpers.SpaceFixtures[0].BaseSpace, AdminId: testUsers[1].ID})
+	if err != nil {
+		t.Fatalf("helpers.Tc.Repo.SetSpace() err = %s; want nil", err)
+	}
+	for _, subscriber := range testUsers[:2] {
+		if err := helpers.Tc.Repo.SetSpaceSubscriber(ctx, spaceId, subscriber.ID); err != nil {
+			t.Fatalf("helpers.Tc.Repo.SetSpaceSubscriber() err = %s; want nil", err)
+		}
+	}
+
+	return spaceId
+}
+
+func setPinTestTopLevelThreads(ctx context.Context, t *testing.T, spaceId uuid.Uuid, senderId models.UserUid, count int) []uuid.Uuid {
+	t.Helper()
+
+	var threadIds []uuid.Uuid
+	for i := range count {
+		thread, _, err := helpers.Tc.Repo.SetTopLevelThread(ctx, spaceId, models.NewTopLevelThreadFirstMessage{
+			NewMessageInput: models.NewMessageInput{Content: fmt.Sprintf("some message %d", i), Type: models.MessageTypeText},
+			SenderId:        senderId,
+		})
+		if err != nil {
+			t.Fatalf("helpers.Tc.Repo.SetTopLevelThread() err = %s; want nil", err)
+		}
+
+		threadIds = append(threadIds, thread.ID)
+	}
+
+	return threadIds
+}
+
+func setPinTestPins(ctx context.Context, t *testing.T, spaceId uuid.Uuid, threadIds []uuid.Uuid) {
+	t.Helper()
+
+	for _, threadId := range threadIds {
+		pin := models.Pin{TargetType: models.ThreadPinTarget, TargetId: threadId, PinnedAt: time.Now()}
+		if _, err := helpers.Tc.Repo.SetSpacePin(ctx, spaceId, pin, int64(len(threadIds))); err != nil {
+			t.Fatalf("helpers.Tc.Repo.SetSpacePin() err = %s; want nil", err)
+		}
+	}
+}
+
+// isTopLevelThreadPinned returns whether the toplevel thread is among the pinned items that are returned along with the
+// toplevel threads of the space
+func isTopLevelThreadPinned(t *testing.T, client http.Client, spaceId, threadId uuid.Uuid, asUser models.BaseUser) bool {
+	t.Helper()
+
+	url := fmt.Sprintf("%s/spaces/%s/toplevel-threads", helpers.Tc.ApiEndpoint, spaceId)
+	topLevelThreadsResponse, teardownFunc := helpers.MakeRequest[map[string]models.TopLevelThreadsWithPins](t, client, http.MethodGet, url, nil, http.StatusOK, asUser, helpers.Tc.AuthClient)
+	t.Cleanup(teardownFunc)
+	if topLevelThreadsResponse == nil {
+		return false
+	}
+
+	for _, pinnedThread := range (*topLevelThreadsResponse)["data"].Pinned.TopLevelThreads {
+		if pinnedThread.ID == threadId {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,176 @@
+//go:build integration
+// +build integration
+
+package integration
+
+import (
+	"context"
+	"spaces-p/pkg/common"
+	"spaces-p/pkg/models"
+	"spaces-p/pkg/repositories/redis_repo"
+	"spaces-p/pkg/uuid"
+	"strconv"
+	"testing"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// This test tests that the consistency check reports the inconsistencies a crash or a bug leaves behind in Redis, and
+// that repairing them leaves a consistent store
+
+// the number of repairing runs after which all cascading repairs must be done
+const maxRepairPasses = 5
+
+func TestCheckConsistency(t *testing.T) {
+	ctx := context.Background()
+
+	redisClient := setupRedis(ctx, t)
+	redisRepo := redis_repo.NewRedisRepository(redisClient)
+
+	var userId models.UserUid = "some user id"
+	require.NoError(t, redisRepo.SetUser(ctx, models.NewUser{ID: userId, Username: "some username"}))
+
+	spaceId, err := redisRepo.SetSpace(ctx, models.NewSpace{
+		BaseSpace: models.BaseSpace{
+			Name:               "some space",
+			ThemeColorHexaCode: "#000000",
+			Radius:             50,
+			Location:           models.Location{Long: 13.4, Lat: 52.5},
+		},
+		AdminId: userId,
+	})
+	require.NoError(t, err)
+	require.NoError(t, redisRepo.SetSpaceSubscriber(ctx, spaceId, userId))
+
+	thread, firstMessage, err := redisRepo.SetTopLevelThread(ctx, spaceId, models.NewTopLevelThreadFirstMessage{
+		NewMessageInput: models.NewMessageInput{Content: "some first message", Type: models.MessageTypeText},
+		SenderId:        userId,
+	})
+	require.NoError(t, err)
+	_, err = redisRepo.SetMessage(ctx, models.NewMessage{
+		BaseMessage: models.BaseMessage{Content: "some message", Type: models.MessageTypeText},
+		SenderId:    userId,
+		ThreadId:    thread.ID,
+	})
+	require.NoError(t, err)
+
+	inconsistencies, err := redisRepo.CheckConsistency(ctx, false)
+	require.NoError(t, err)
+	require.Empty(t, inconsistencies, "store must be consistent before it is corrupted")
+
+	// the space the orphaned entries refer to never existed
+	var missingSpaceId = uuid.New()
+	var threadKey = "threads:{" + thread.ID.String() + "}"
+	var activeSubscribersKey = "spaces:{" + spaceId.String() + "}:active_subscribers"
+
+	orphanedThread, err := redisRepo.SetThread(ctx, missingSpaceId, firstMessage.ID, time.Now())
+	require.NoError(t, err)
+
+	require.NoError(t, redisClient.HSet(ctx, threadKey, "messages_count", 5).Err())
+
+	// an active subscriber without sessions is left behind by an instance that crashed before the session reaper ran
+	require.NoError(t, redisClient.ZAdd(ctx, activeSubscribersKey, goredis.Z{Score: 1, Member: string(userId)}).Err())
+
+	require.NoError(t, redisClient.GeoAdd(ctx, "space_coords", &goredis.GeoLocation{
+		Name:      missingSpaceId.String(),
+		Longitude: 13.4,
+		Latitude:  52.5,
+	}).Err())
+
+	var danglingAttachmentId = uuid.New()
+	_, err = redisRepo.SetAttachment(ctx, danglingAttachmentId, models.NewAttachment{
+		SpaceId:     missingSpaceId,
+		UploaderId:  userId,
+		Type:        models.MessageTypeFile,
+		FileName:    "some file",
+		ContentType: "text/plain",
+		Size:        1,
+	})
+	require.NoError(t, err)
+
+	danglingHeldMessage, err := redisRepo.SetHeldMessage(ctx, models.NewHeldMessage{
+		NewMessage: models.NewMessage{
+			BaseMessage: models.BaseMessage{Content: "some held message", Type: models.MessageTypeText},
+			SenderId:    userId,
+		},
+		SpaceId: missingSpaceId,
+		Reason:  "some reason",
+		Filter:  "some filter",
+	})
+	require.NoError(t, err)
+
+	t.Run("report", func(t *testing.T) {
+		inconsistencies, err := redisRepo.CheckConsistency(ctx, false)
+		require.NoError(t, err)
+
+		kindsByKey := getInconsistencyKindsByKey(inconsistencies)
+		assert.Contains(t, kindsByKey["threads:{"+orphanedThread.ID.String()+"}"], redis_repo.OrphanedThread)
+		assert.Contains(t, kindsByKey[threadKey], redis_repo.MessagesCountDrift)
+		assert.Contains(t, kindsByKey[activeSubscribersKey], redis_repo.StaleActiveSubscriber)
+		assert.Contains(t, kindsByKey["space_coords"], redis_repo.OrphanedSpaceCoordinates)
+		assert.Contains(t, kindsByKey["attachments:"+danglingAttachmentId.String()], redis_repo.OrphanedKey)
+		assert.Contains(t, kindsByKey["held_messages:"+danglingHeldMessage.ID.String()], redis_repo.OrphanedKey)
+
+		for _, inconsistency := range inconsistencies {
+			assert.False(t, inconsistency.Repaired, "%s must not be repaired by a check only", inconsistency)
+		}
+	})
+
+	t.Run("repair", func(t *testing.T) {
+		// repairs can cascade, so the check is repeated until it reports no more inconsistencies
+		var passes int
+		for passes = 1; passes <= maxRepairPasses; passes++ {
+			inconsistencies, err := redisRepo.CheckConsistency(ctx, true)
+			require.NoError(t, err)
+			if len(inconsistencies) == 0 {
+				break
+			}
+
+			for _, inconsistency := range inconsistencies {
+				assert.True(t, inconsistency.Repaired, "%s must be repaired", inconsistency)
+			}
+		}
+		require.LessOrEqual(t, passes, maxRepairPasses, "repairs must be done after %d passes", maxRepairPasses)
+
+		_, err := redisRepo.GetThread(ctx, orphanedThread.ID)
+		assert.ErrorIs(t, err, common.ErrNotFound)
+
+		messagesCount, err := redisClient.ZCard(ctx, "threads:{"+thread.ID.String()+"}:messages_by_time").Result()
+		require.NoError(t, err)
+		storedMessagesCount, err := redisClient.HGet(ctx, threadKey, "messages_count").Result()
+		require.NoError(t, err)
+		assert.Equal(t, strconv.FormatInt(messagesCount, 10), storedMessagesCount)
+
+		err = redisClient.ZScore(ctx, activeSubscribersKey, string(userId)).Err()
+		assert.ErrorIs(t, err, goredis.Nil)
+
+		err = redisClient.ZScore(ctx, "space_coords", missingSpaceId.String()).Err()
+		assert.ErrorIs(t, err, goredis.Nil)
+
+		_, err = redisRepo.GetAttachment(ctx, danglingAttachmentId)
+		assert.ErrorIs(t, err, common.ErrNotFound)
+
+		_, err = redisRepo.GetHeldMessage(ctx, danglingHeldMessage.ID)
+		assert.ErrorIs(t, err, common.ErrNotFound)
+
+		// the consistent part of the store is left untouched
+		_, err = redisRepo.GetThread(ctx, thread.ID)
+		assert.NoError(t, err)
+		_, err = redisRepo.GetMessage(ctx, firstMessage.ID)
+		assert.NoError(t, err)
+		_, err = redisRepo.GetSpace(ctx, spaceId)
+		assert.NoError(t, err)
+	})
+}
+
+func getInconsistencyKindsByKey(inconsistencies []redis_repo.Inconsistency) map[string][]redis_repo.InconsistencyKind {
+	var kindsByKey = make(map[string][]redis_repo.InconsistencyKind)
+	for _, inconsistency := range inconsistencies {
+		kindsByKey[inconsistency.Key] = append(kindsByKey[inconsistency.Key], inconsistency.Kind)
+	}
+
+	return kindsByKey
+}
@@ -0,0 +1,218 @@
+//go:build integration
+// +build integration
+
+package integration
+
+import (
+	"context"
+	"io"
+	"spaces-p/pkg/common"
+	"spaces-p/pkg/errors"
+	"spaces-p/pkg/models"
+	"spaces-p/pkg/redis"
+	localmemory "spaces-p/pkg/repositories/local_memory"
+	"spaces-p/pkg/repositories/redis_repo"
+	"spaces-p/pkg/services"
+	"spaces-p/pkg/uuid"
+	"spaces-p/pkg/zerologger"
+	"testing"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+// This test tests that the message sweeper of the RetentionService expires the messages of a space with a message
+// retention by their own age against Redis, along with everything that refers to them
+
+func TestMessageSweeper(t *testing.T) {
+	ctx := context.Background()
+
+	redisClient := setupRedis(ctx, t)
+	redisRepo := redis_repo.NewRedisRepository(redisClient)
+	localMemoryRepo := localmemory.NewLocalMemoryRepo(localmemory.DefaultConfig())
+	retentionService := services.NewRetentionService(zerologger.New(io.Discard), redisRepo, localMemoryRepo)
+
+	var userId models.UserUid = "some user id"
+	require.NoError(t, redisRepo.SetUser(ctx, models.NewUser{ID: userId, Username: "some username"}))
+
+	spaceId, err := redisRepo.SetSpace(ctx, models.NewSpace{
+		BaseSpace: models.BaseSpace{
+			Name:                  "some space",
+			ThemeColorHexaCode:    "#000000",
+			Radius:                50,
+			Location:              models.Location{Long: 13.4, Lat: 52.5},
+			MessageRetentionHours: 1,
+		},
+		AdminId: userId,
+	})
+	require.NoError(t, err)
+	require.NoError(t, redisRepo.SetSpaceMessageRetention(ctx, spaceId, 1))
+	require.NoError(t, redisRepo.SetSpaceSubscriber(ctx, spaceId, userId))
+
+	var expiredAt = time.Now().Add(-2 * time.Hour)
+
+	setTopLevelThread := func(t *testing.T, createdAt time.Time) uuid.Uuid {
+		t.Helper()
+
+		thread, _, err := redisRepo.SetTopLevelThread(ctx, spaceId, models.NewTopLevelThreadFirstMessage{
+			NewMessageInput: models.NewMessageInput{Content: "some first message", Type: models.MessageTypeText},
+			SenderId:        userId,
+		})
+		require.NoError(t, err)
+		backdate(ctx, t, redisClient, "spaces:{"+spaceId.String()+"}:toplevel_threads_by_time", thread.ID, createdAt)
+
+		return thread.ID
+	}
+
+	setMessage := func(t *testing.T, threadId, attachmentId uuid.Uuid, createdAt time.Time) *models.Message {
+		t.Helper()
+
+		message, err := redisRepo.SetMessage(ctx, models.NewMessage{
+			BaseMessage: models.BaseMessage{Content: "some message", Type: models.MessageTypeText, AttachmentId: attachmentId},
+			SenderId:    userId,
+			ThreadId:    threadId,
+		})
+		require.NoError(t, err)
+		backdate(ctx, t, redisClient, "threads:{"+threadId.String()+"}:messages_by_time", message.ID, createdAt)
+
+		return message
+	}
+
+	// a thread that is kept for its younger message
+	keptThreadId := setTopLevelThread(t, expiredAt)
+
+	attachmentId := uuid.New()
+	_, err = redisRepo.SetAttachment(ctx, attachmentId, models.NewAttachment{
+		SpaceId:     spaceId,
+		UploaderId:  userId,
+		Type:        models.MessageTypeFile,
+		FileName:    "some file",
+		ContentType: "text/plain",
+		Size:        1,
+	})
+	require.NoError(t, err)
+
+	expiredMessage := setMessage(t, keptThreadId, attachmentId, expiredAt)
+	require.NoError(t, redisRepo.SetThreadFollower(ctx, keptThreadId, userId))
+	require.NoError(t, redisRepo.AddInboxMessage(ctx, []models.UserUid{userId}, *expiredMessage, 100))
+	require.NoError(t, redisRepo.AddSpaceRecentMessage(ctx, spaceId, expiredMessage.ID, time.Now(), time.Hour))
+	youngMessage := setMessage(t, keptThreadId, uuid.Nil, time.Now())
+
+	// a message that is kept for the younger message of its child thread
+	parentMessage := setMessage(t, keptThreadId, uuid.Nil, expiredAt)
+	childThread, err := redisRepo.SetThread(ctx, spaceId, parentMessage.ID, expiredAt)
+	require.NoError(t, err)
+	setMessage(t, childThread.ID, uuid.Nil, time.Now())
+
+	// a thread that expires entirely
+	expiredThreadId := setTopLevelThread(t, expiredAt)
+	setMessage(t, expiredThreadId, uuid.Nil, expiredAt)
+	_, err = redisRepo.SetReadMarker(ctx, userId, models.ReadMarker{SpaceId: spaceId, ThreadId: expiredThreadId, ReadAt: time.Now()})
+	require.NoError(t, err)
+
+	// a thread that is younger than the retention
+	youngThreadId := setTopLevelThread(t, time.Now())
+
+	sweeperCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go retentionService.RunMessageSweeper(sweeperCtx, 50*time.Millisecond)
+
+	assert.Eventually(t, func() bool {
+		_, err := redisRepo.GetThread(ctx, expiredThreadId)
+		return errors.Is(err, common.ErrNotFound)
+	}, 5*time.Second, 50*time.Millisecond)
+	cancel()
+
+	t.Run("expired message is removed along with everything that refers to it", func(t *testing.T) {
+		_, err := redisRepo.GetMessage(ctx, expiredMessage.ID)
+		assert.ErrorIs(t, err, common.ErrNotFound)
+
+		_, err = redisRepo.GetAttachment(ctx, attachmentId)
+		assert.ErrorIs(t, err, common.ErrNotFound)
+
+		deletedAttachmentIds, err := redisRepo.GetDeletedAttachmentIds(ctx, 10)
+		require.NoError(t, err)
+		assert.Contains(t, deletedAttachmentIds, attachmentId)
+
+		inbox, err := redisRepo.GetUserInbox(ctx, userId, 0, 10)
+		require.NoError(t, err)
+		assert.Empty(t, inbox)
+
+		err = redisClient.ZScore(ctx, "spaces:{"+spaceId.String()+"}:recent_messages", expiredMessage.ID.String()).Err()
+		assert.ErrorIs(t, err, goredis.Nil)
+	})
+
+	t.Run("younger messages and the messages they depend on are kept", func(t *testing.T) {
+		thread, err := redisRepo.GetThread(ctx, keptThreadId)
+		require.NoError(t, err)
+		assert.Equal(t, 2, thread.MessagesCount)
+
+		_, err = redisRepo.GetMessage(ctx, youngMessage.ID)
+		assert.NoError(t, err)
+
+		_, err = redisRepo.GetMessage(ctx, parentMessage.ID)
+		assert.NoError(t, err)
+
+		_, err = redisRepo.GetThread(ctx, youngThreadId)
+		assert.NoError(t, err)
+	})
+
+	t.Run("read markers of the expired thread are removed", func(t *testing.T) {
+		exists, err := redisClient.HExists(ctx, "users:{"+string(userId)+"}:read_markers", "thread:"+expiredThreadId.String()).Result()
+		require.NoError(t, err)
+		assert.False(t, exists)
+	})
+
+	t.Run("no inconsistencies are left", func(t *testing.T) {
+		inconsistencies, err := redisRepo.CheckConsistency(ctx, false)
+		require.NoError(t, err)
+		assert.Empty(t, inconsistencies)
+	})
+}
+
+// backdate sets the creation time of the member of the sorted set scored by creation time
+func backdate(ctx context.Context, t *testing.T, redisClient goredis.UniversalClient, key string, id uuid.Uuid, createdAt time.Time) {
+	t.Helper()
+
+	err := redisClient.ZAddXX(ctx, key, goredis.Z{Score: float64(createdAt.UnixMilli()), Member: id.String()}).Err()
+	require.NoError(t, err)
+}
+
+func setupRedis(ctx context.Context, t *testing.T) goredis.UniversalClient {
+	t.Helper()
+
+	req := testcontainers.ContainerRequest{
+		Image:        "redis:latest",
+		ExposedPorts: []string{"6379/tcp"},
+		WaitingFor:   wait.ForLog("Ready to accept connections"),
+	}
+
+	redisC, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	})
+	require.NoError(t, err, "could not start redis")
+
+	t.Cleanup(func() {
+		if err := redisC.Terminate(ctx); err != nil {
+			t.Logf("could not stop redis: %s", err)
+		}
+	})
+
+	endpoint, err := redisC.Endpoint(ctx, "")
+	require.NoError(t, err, "could not get redis endpoint")
+
+	cfg := redis.DefaultConfig()
+	cfg.Addrs = []string{endpoint}
+	// every test gets a client of its own container, the process wide client would stay connected to the first one
+	redisClient, err := redis.NewClient(cfg)
+	require.NoError(t, err)
+
+	t.Cleanup(func() { redisClient.Close() })
+
+	return redisClient
+}
@@ -0,0 +1,102 @@
+//go:build integration
+// +build integration
+
+package integration
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"spaces-p/pkg/common"
+	s3blobstore "spaces-p/pkg/repositories/s3_blob_store"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+// This test tests the S3BlobStore implementation of the BlobStore interface against MinIO, a local S3 stand-in
+
+const (
+	minioUser     = "spaces"
+	minioPassword = "password"
+)
+
+func TestS3BlobStore(t *testing.T) {
+	ctx := context.Background()
+
+	endpoint := setupMinio(ctx, t)
+
+	blobStore, err := s3blobstore.NewS3BlobStore(endpoint, "us-east-1", "attachments", minioUser, minioPassword)
+	require.NoError(t, err)
+
+	require.NoError(t, blobStore.EnsureBucket(ctx))
+	// the bucket exists now
+	require.NoError(t, blobStore.EnsureBucket(ctx))
+
+	var key = "attachments/some id/original"
+	var content = "some content"
+
+	t.Run("missing blob", func(t *testing.T) {
+		_, err := blobStore.GetBlob(ctx, key)
+
+		assert.ErrorIs(t, err, common.ErrNotFound)
+	})
+
+	t.Run("put, get and delete blob", func(t *testing.T) {
+		err := blobStore.PutBlob(ctx, key, strings.NewReader(content), int64(len(content)), "text/plain; charset=utf-8")
+		require.NoError(t, err)
+
+		blob, err := blobStore.GetBlob(ctx, key)
+		require.NoError(t, err)
+		defer blob.Close()
+
+		storedContent, err := io.ReadAll(blob)
+		require.NoError(t, err)
+		assert.Equal(t, content, string(storedContent))
+
+		require.NoError(t, blobStore.DeleteBlob(ctx, key))
+
+		_, err = blobStore.GetBlob(ctx, key)
+		assert.ErrorIs(t, err, common.ErrNotFound)
+	})
+
+	t.Run("delete missing blob", func(t *testing.T) {
+		assert.NoError(t, blobStore.DeleteBlob(ctx, "attachments/missing/original"))
+	})
+}
+
+func setupMinio(ctx context.Context, t *testing.T) (endpoint string) {
+	t.Helper()
+
+	req := testcontainers.ContainerRequest{
+		Image:        "minio/minio:latest",
+		Cmd:          []string{"server", "/data"},
+		ExposedPorts: []string{"9000/tcp"},
+		Env: map[string]string{
+			"MINIO_ROOT_USER":     minioUser,
+			"MINIO_ROOT_PASSWORD": minioPassword,
+		},
+		WaitingFor: wait.ForHTTP("/minio/health/live").WithPort("9000/tcp"),
+	}
+
+	minioC, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	})
+	require.NoError(t, err, "could not start minio")
+
+	t.Cleanup(func() {
+		if err := minioC.Terminate(ctx); err != nil {
+			t.Logf("could not stop minio: %s", err)
+		}
+	})
+
+	host, err := minioC.Endpoint(ctx, "")
+	require.NoError(t, err, "could not get minio endpoint")
+
+	return fmt.Sprintf("http://%s", host)
+}
@@ -0,0 +1,129 @@
+//go:build integration
+// +build integration
+
+package integration
+
+import (
+	"context"
+	"io"
+	"spaces-p/pkg/common"
+	"spaces-p/pkg/errors"
+	"spaces-p/pkg/models"
+	localmemory "spaces-p/pkg/repositories/local_memory"
+	"spaces-p/pkg/repositories/redis_repo"
+	"spaces-p/pkg/services"
+	"spaces-p/pkg/uuid"
+	"spaces-p/pkg/zerologger"
+	"testing"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// This test tests that the session reaper removes the sessions of crashed instances from Redis, while sessions that
+// keep sending their heartbeat survive and reaped sessions can register again
+
+const (
+	testSessionReaperInterval = 20 * time.Millisecond
+	// sessions registered with this ttl have expired by the time the reaper runs
+	expiredSessionTTL = time.Millisecond
+)
+
+func TestSessionReaper(t *testing.T) {
+	ctx := context.Background()
+
+	redisClient := setupRedis(ctx, t)
+	redisRepo := redis_repo.NewRedisRepository(redisClient)
+	localMemoryRepo := localmemory.NewLocalMemoryRepo(localmemory.DefaultConfig())
+	spaceNotificationsService := services.NewSpaceNotificationsService(zerologger.New(io.Discard), redisRepo, localMemoryRepo, "some instance id")
+
+	var userId models.UserUid = "some user id"
+
+	isActiveSubscriber := func(t *testing.T, spaceId uuid.Uuid) bool {
+		t.Helper()
+
+		// it runs in the goroutines of assert.Eventually and assert.Never as well, where t.FailNow must not be called
+		err := redisClient.ZScore(ctx, "spaces:{"+spaceId.String()+"}:active_subscribers", string(userId)).Err()
+		if !errors.Is(err, goredis.Nil) {
+			assert.NoError(t, err)
+		}
+
+		return err == nil
+	}
+
+	runSessionReaper := func(t *testing.T) {
+		t.Helper()
+
+		reaperCtx, cancel := context.WithCancel(ctx)
+		t.Cleanup(cancel)
+		go spaceNotificationsService.RunSessionReaper(reaperCtx, testSessionReaperInterval)
+	}
+
+	t.Run("expired session is reaped and its removal is published", func(t *testing.T) {
+		spaceId, sessionId := uuid.New(), uuid.New()
+		require.NoError(t, redisRepo.SetSpaceSubscriberSession(ctx, spaceId, userId, sessionId, "some crashed instance id", expiredSessionTTL))
+		require.True(t, isActiveSubscriber(t, spaceId))
+
+		// another subscriber of the space who is connected to this instance
+		notificationsCh := make(chan models.SpaceUpdate, 10)
+		session := localMemoryRepo.AddSession(localmemory.NewSessionInput{
+			SpaceId:         spaceId,
+			UserId:          "some other user id",
+			NotificationsCh: notificationsCh,
+			CloseSlow:       func() {},
+			GoAway:          func() {},
+		})
+		t.Cleanup(func() { localMemoryRepo.DeleteSession(session.SpaceId, session.SessionId) })
+
+		runSessionReaper(t)
+
+		select {
+		case spaceUpdate := <-notificationsCh:
+			assert.Equal(t, models.RemoveActiveSubscriberSpaceUpdateType, spaceUpdate.GetType())
+			assert.Equal(t, userId, spaceUpdate.GetUserId())
+		case <-time.After(5 * time.Second):
+			t.Fatal("no update was published; want the removal of the active subscriber")
+		}
+
+		assert.False(t, isActiveSubscriber(t, spaceId))
+		assert.ErrorIs(t, redisRepo.RefreshSpaceSubscriberSession(ctx, sessionId, services.SessionTTL), common.ErrNotFound)
+	})
+
+	t.Run("refreshed session survives the reaper", func(t *testing.T) {
+		spaceId, sessionId := uuid.New(), uuid.New()
+		require.NoError(t, redisRepo.SetSpaceSubscriberSession(ctx, spaceId, userId, sessionId, "some instance id", expiredSessionTTL))
+		require.NoError(t, redisRepo.RefreshSpaceSubscriberSession(ctx, sessionId, services.SessionTTL))
+
+		runSessionReaper(t)
+
+		assert.Never(t, func() bool {
+			return !isActiveSubscriber(t, spaceId)
+		}, 10*testSessionReaperInterval, testSessionReaperInterval)
+		assert.NoError(t, redisRepo.RefreshSpaceSubscriberSession(ctx, sessionId, services.SessionTTL))
+	})
+
+	t.Run("reaped client registers again", func(t *testing.T) {
+		spaceId, sessionId := uuid.New(), uuid.New()
+		require.NoError(t, redisRepo.SetSpaceSubscriberSession(ctx, spaceId, userId, sessionId, "some instance id", expiredSessionTTL))
+
+		runSessionReaper(t)
+
+		require.Eventually(t, func() bool {
+			return !isActiveSubscriber(t, spaceId)
+		}, 5*time.Second, testSessionReaperInterval)
+
+		// the heartbeat of a client whose session has been reaped, e.g. because Redis was unreachable for longer than
+		// the session ttl, registers the session again
+		err := redisRepo.RefreshSpaceSubscriberSession(ctx, sessionId, services.SessionTTL)
+		require.ErrorIs(t, err, common.ErrNotFound)
+		require.NoError(t, redisRepo.SetSpaceSubscriberSession(ctx, spaceId, userId, sessionId, "some instance id", services.SessionTTL))
+
+		assert.True(t, isActiveSubscriber(t, spaceId))
+		assert.Never(t, func() bool {
+			return !isActiveSubscriber(t, spaceId)
+		}, 10*testSessionReaperInterval, testSessionReaperInterval)
+		assert.NoError(t, redisRepo.RefreshSpaceSubscriberSession(ctx, sessionId, services.SessionTTL))
+	})
+}
@@ -0,0 +1,85 @@
+//go:build integration
+// +build integration
+
+package integration
+
+import (
+	"context"
+	"spaces-p/pkg/common"
+	"spaces-p/pkg/models"
+	"spaces-p/pkg/repositories/redis_repo"
+	"spaces-p/pkg/uuid"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// This test tests that an uploaded attachment can be claimed by one message only and that the attachments no message
+// has claimed are collected against Redis
+
+func TestUnattachedAttachments(t *testing.T) {
+	ctx := context.Background()
+
+	redisRepo := redis_repo.NewRedisRepository(setupRedis(ctx, t))
+
+	setAttachment := func(t *testing.T) uuid.Uuid {
+		t.Helper()
+
+		attachmentId := uuid.New()
+		_, err := redisRepo.SetAttachment(ctx, attachmentId, models.NewAttachment{
+			SpaceId:     uuid.New(),
+			UploaderId:  "some user id",
+			Type:        models.MessageTypeFile,
+			FileName:    "some file",
+			ContentType: "text/plain",
+			Size:        1,
+		})
+		require.NoError(t, err)
+
+		return attachmentId
+	}
+
+	t.Run("attachment can be claimed by one message only", func(t *testing.T) {
+		attachmentId := setAttachment(t)
+
+		require.NoError(t, redisRepo.AttachAttachment(ctx, attachmentId))
+		assert.ErrorIs(t, redisRepo.AttachAttachment(ctx, attachmentId), common.ErrAttachmentAttached)
+
+		require.NoError(t, redisRepo.DetachAttachment(ctx, attachmentId))
+		assert.NoError(t, redisRepo.AttachAttachment(ctx, attachmentId))
+	})
+
+	t.Run("unattached attachment is collected", func(t *testing.T) {
+		attachedId := setAttachment(t)
+		require.NoError(t, redisRepo.AttachAttachment(ctx, attachedId))
+		unattachedId := setAttachment(t)
+
+		attachmentIds, err := redisRepo.GetUnattachedAttachmentIds(ctx, time.Now().Add(time.Second), 100)
+		require.NoError(t, err)
+		assert.Contains(t, attachmentIds, unattachedId)
+		assert.NotContains(t, attachmentIds, attachedId)
+
+		require.NoError(t, redisRepo.DeleteUnattachedAttachment(ctx, unattachedId))
+
+		_, err = redisRepo.GetAttachment(ctx, unattachedId)
+		assert.ErrorIs(t, err, common.ErrNotFound)
+		deletedAttachmentIds, err := redisRepo.GetDeletedAttachmentIds(ctx, 100)
+		require.NoError(t, err)
+		assert.Contains(t, deletedAttachmentIds, unattachedId)
+
+		// a collected attachment can't be claimed anymore
+		assert.ErrorIs(t, redisRepo.AttachAttachment(ctx, unattachedId), common.ErrAttachmentAttached)
+	})
+
+	t.Run("claimed attachment isn't collected", func(t *testing.T) {
+		attachmentId := setAttachment(t)
+		require.NoError(t, redisRepo.AttachAttachment(ctx, attachmentId))
+
+		require.NoError(t, redisRepo.DeleteUnattachedAttachment(ctx, attachmentId))
+
+		_, err := redisRepo.GetAttachment(ctx, attachmentId)
+		assert.NoError(t, err)
+	})
+}